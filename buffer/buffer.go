@@ -7,14 +7,18 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"unicode"
+
+	"github.com/liamg/aminal/words"
 )
 
 type SelectionMode int
 
 const (
-	SelectionChar SelectionMode = iota // char-by-char selection
-	SelectionWord SelectionMode = iota // by word selection
-	SelectionLine SelectionMode = iota // whole line selection
+	SelectionChar  SelectionMode = iota // char-by-char selection
+	SelectionWord  SelectionMode = iota // by word selection
+	SelectionLine  SelectionMode = iota // whole line selection
+	SelectionBlock SelectionMode = iota // rectangular selection
 )
 
 type Buffer struct {
@@ -24,6 +28,7 @@ type Buffer struct {
 	savedY                uint16
 	savedCursorAttr       *CellAttributes
 	dirty                 bool
+	dirtyRows             DirtyRows
 	selectionStart        *Position
 	selectionEnd          *Position
 	selectionMode         SelectionMode
@@ -31,6 +36,9 @@ type Buffer struct {
 	terminalState         *TerminalState
 	savedCharsets         []*map[rune]rune
 	savedCurrentCharset   int
+	noScrollback          bool   // lines scrolled off the top are discarded, as on the alternate screen
+	scrollbackBytes       uint64 // roughly the memory used by the lines in the scrollback
+	discarded             uint64 // lines discarded from the top of the buffer, see Discarded
 }
 
 type Position struct {
@@ -95,16 +103,19 @@ func (buffer *Buffer) GetURLAtPosition(col uint16, viewRow uint16) string {
 		candidate = fmt.Sprintf("%s%c", candidate, cell.Rune())
 	}
 
-	if candidate == "" || candidate[0] == '/' {
+	if !isURL(candidate) {
 		return ""
 	}
+	return candidate
+}
 
-	// check if url
-	_, err := url.ParseRequestURI(candidate)
-	if err != nil {
-		return ""
+func isURL(candidate string) bool {
+	if candidate == "" || candidate[0] == '/' {
+		return false
 	}
-	return candidate
+
+	_, err := url.ParseRequestURI(candidate)
+	return err == nil
 }
 
 func (buffer *Buffer) IsSelectionComplete() bool {
@@ -118,7 +129,7 @@ func (buffer *Buffer) findEndOfWord(col int, row int) int {
 		if cell == nil {
 			break
 		}
-		if isRuneWordSelectionMarker(cell.Rune()) {
+		if buffer.isWordSeparator(cell) {
 			break
 		}
 		end = i
@@ -133,7 +144,7 @@ func (buffer *Buffer) findBeginningOfWord(col int, row int) int {
 		if cell == nil {
 			break
 		}
-		if isRuneWordSelectionMarker(cell.Rune()) {
+		if buffer.isWordSeparator(cell) {
 			break
 		}
 		start = i
@@ -141,14 +152,18 @@ func (buffer *Buffer) findBeginningOfWord(col int, row int) int {
 	return start
 }
 
-// bounds for word selection
-func isRuneWordSelectionMarker(r rune) bool {
-	switch r {
-	case ',', ' ', ':', ';', 0, '\'', '"', '[', ']', '(', ')', '{', '}':
-		return true
+// isWordSeparator returns true if the cell ends a word selected by double clicking. The spacer of a double width
+// character is part of the same word as it.
+func (buffer *Buffer) isWordSeparator(cell *Cell) bool {
+	if cell.IsWideSpacer() {
+		return false
 	}
+	return cell.Rune() == 0 || unicode.IsSpace(cell.Rune()) || strings.ContainsRune(buffer.terminalState.WordSeparators, cell.Rune())
+}
 
-	return false
+// bounds for hints
+func isRuneWordSelectionMarker(r rune) bool {
+	return r == 0 || r == ' ' || strings.ContainsRune(words.DefaultSeparators, r)
 }
 
 func isRuneURLSelectionMarker(r rune) bool {
@@ -166,6 +181,15 @@ func (buffer *Buffer) GetSelectedText() string {
 		return ""
 	}
 
+	if buffer.selectionMode == SelectionBlock {
+		rows := buffer.selectedBlock()
+		lines := make([]string, len(rows))
+		for i, row := range rows {
+			lines[i] = strings.TrimRight(strings.Join(row, ""), " ")
+		}
+		return strings.Join(lines, "\n")
+	}
+
 	var builder strings.Builder
 	builder.Grow(int(buffer.terminalState.viewWidth) * (end.Line - start.Line + 1)) // reserve space to minimize allocations
 
@@ -191,11 +215,15 @@ func (buffer *Buffer) GetSelectedText() string {
 			if col >= len(line.cells) {
 				break
 			}
+			if line.cells[col].IsWideSpacer() {
+				continue
+			}
 			r := line.cells[col].Rune()
 			if r == 0x00 {
 				r = ' '
 			}
 			builder.WriteRune(r)
+			builder.WriteString(string(line.cells[col].Combining()))
 		}
 	}
 
@@ -280,6 +308,14 @@ func (buffer *Buffer) getActualSelection() (*Position, *Position) {
 		end.Line = buffer.selectionStart.Line
 	}
 
+	if buffer.selectionMode == SelectionBlock {
+		// the columns of a block don't depend on which corner the selection started from
+		if start.Col > end.Col {
+			start.Col, end.Col = end.Col, start.Col
+		}
+		return start, end
+	}
+
 	switch buffer.selectionMode {
 	case SelectionChar:
 		// no action
@@ -289,6 +325,13 @@ func (buffer *Buffer) getActualSelection() (*Position, *Position) {
 		end.Col = buffer.findEndOfWord(end.Col, end.Line)
 
 	case SelectionLine:
+		// all the rows of a line which wraps are selected
+		for start.Line > 0 && start.Line < len(buffer.lines) && buffer.lines[start.Line].wrapped {
+			start.Line--
+		}
+		for end.Line+1 < len(buffer.lines) && buffer.lines[end.Line+1].wrapped {
+			end.Line++
+		}
 		start.Col = 0
 		end.Col = int(buffer.ViewWidth() - 1)
 	}
@@ -314,6 +357,10 @@ func (buffer *Buffer) InSelection(col uint16, row uint16) bool {
 
 	rawY := int(buffer.convertViewLineToRawLine(row) - uint64(buffer.terminalState.scrollLinesFromBottom))
 
+	if buffer.selectionMode == SelectionBlock {
+		return rawY >= start.Line && rawY <= end.Line && int(col) >= start.Col && int(col) <= end.Col
+	}
+
 	return (rawY > start.Line || (rawY == start.Line && int(col) >= start.Col)) &&
 		(rawY < end.Line || (rawY == end.Line && int(col) <= end.Col))
 }
@@ -334,6 +381,42 @@ func (buffer *Buffer) InScrollableRegion() bool {
 	return buffer.HasScrollableRegion() && uint(buffer.terminalState.cursorY) >= buffer.terminalState.topMargin && uint(buffer.terminalState.cursorY) <= buffer.terminalState.bottomMargin
 }
 
+// hasHorizontalMargins returns true if left and right margins (DECSLRM) restrict the columns affected by scrolling and editing
+func (buffer *Buffer) hasHorizontalMargins() bool {
+	return buffer.terminalState.LeftRightMarginMode && (buffer.terminalState.leftMargin > 0 || buffer.terminalState.rightMargin < uint(buffer.ViewWidth())-1)
+}
+
+func (buffer *Buffer) inHorizontalMargins() bool {
+	return uint(buffer.terminalState.cursorX) >= buffer.terminalState.leftMargin && uint(buffer.terminalState.cursorX) <= buffer.terminalState.rightMargin
+}
+
+// scrollRect scrolls the cells between the left and right margins of view rows top to bottom (inclusive) up by n lines, or down if n is negative
+func (buffer *Buffer) scrollRect(top uint16, bottom uint16, n int) {
+	defer buffer.emitRowsChange(top, bottom)
+
+	left := int(buffer.terminalState.leftMargin)
+	right := int(buffer.terminalState.rightMargin) + 1
+	rows := int(bottom) - int(top) + 1
+
+	for i := 0; i < rows; i++ {
+		row := i
+		if n < 0 {
+			row = rows - 1 - i
+		}
+		line := buffer.getViewLine(top + uint16(row))
+		buffer.padCells(line, right)
+
+		from := row + n
+		if from >= 0 && from < rows {
+			source := buffer.getViewLine(top + uint16(from))
+			buffer.padCells(source, right)
+			copy(line.cells[left:right], source.cells[left:right])
+		} else {
+			buffer.eraseCells(line, left, right)
+		}
+	}
+}
+
 // NOTE: bottom is exclusive
 func (buffer *Buffer) getAreaScrollRange() (top uint64, bottom uint64) {
 	top = buffer.convertViewLineToRawLine(uint16(buffer.terminalState.topMargin))
@@ -345,6 +428,11 @@ func (buffer *Buffer) getAreaScrollRange() (top uint64, bottom uint64) {
 }
 
 func (buffer *Buffer) AreaScrollDown(lines uint16) {
+	if buffer.hasHorizontalMargins() {
+		buffer.scrollRect(uint16(buffer.terminalState.topMargin), uint16(buffer.terminalState.bottomMargin), -int(lines))
+		return
+	}
+
 	defer buffer.emitDisplayChange()
 
 	// NOTE: bottom is exclusive
@@ -355,12 +443,17 @@ func (buffer *Buffer) AreaScrollDown(lines uint16) {
 		if i >= top+uint64(lines) {
 			buffer.lines[i] = buffer.lines[i-uint64(lines)]
 		} else {
-			buffer.lines[i] = newLine()
+			buffer.lines[i] = buffer.blankLine()
 		}
 	}
 }
 
 func (buffer *Buffer) AreaScrollUp(lines uint16) {
+	if buffer.hasHorizontalMargins() {
+		buffer.scrollRect(uint16(buffer.terminalState.topMargin), uint16(buffer.terminalState.bottomMargin), int(lines))
+		return
+	}
+
 	defer buffer.emitDisplayChange()
 
 	// NOTE: bottom is exclusive
@@ -371,7 +464,7 @@ func (buffer *Buffer) AreaScrollUp(lines uint16) {
 		if from < bottom {
 			buffer.lines[i] = buffer.lines[from]
 		} else {
-			buffer.lines[i] = newLine()
+			buffer.lines[i] = buffer.blankLine()
 		}
 	}
 }
@@ -423,6 +516,7 @@ func (buffer *Buffer) GetRawCell(viewCol uint16, rawLine uint64) *Cell {
 
 func (buffer *Buffer) emitDisplayChange() {
 	buffer.dirty = true
+	buffer.dirtyRows.SetAll()
 }
 
 // Column returns cursor column
@@ -496,6 +590,10 @@ func (buffer *Buffer) ViewHeight() uint16 {
 
 func (buffer *Buffer) deleteLine() {
 	index := int(buffer.RawLine())
+	if scrollback := buffer.scrollbackLines(); scrollback > 0 {
+		// the last line of the scrollback moves onto the screen to fill it
+		buffer.scrollbackBytes -= lineBytes(&buffer.lines[scrollback-1])
+	}
 	buffer.lines = buffer.lines[:index+copy(buffer.lines[index:], buffer.lines[index+1:])]
 }
 
@@ -505,19 +603,14 @@ func (buffer *Buffer) insertLine() {
 
 	if !buffer.InScrollableRegion() {
 		pos := buffer.RawLine()
-		maxLines := buffer.getMaxLines()
-		newLineCount := uint64(len(buffer.lines) + 1)
-		if newLineCount > maxLines {
-			newLineCount = maxLines
+		if len(buffer.lines) >= int(buffer.ViewHeight()) {
+			// the top line of the screen is pushed up into the scrollback
+			buffer.scrollbackBytes += lineBytes(&buffer.lines[buffer.scrollbackLines()])
 		}
-
-		out := make([]Line, newLineCount)
-		copy(
-			out[:pos-(uint64(len(buffer.lines))+1-newLineCount)],
-			buffer.lines[uint64(len(buffer.lines))+1-newLineCount:pos])
-		out[pos] = newLine()
-		copy(out[pos+1:], buffer.lines[pos:])
-		buffer.lines = out
+		buffer.lines = append(buffer.lines, Line{})
+		copy(buffer.lines[pos+1:], buffer.lines[pos:])
+		buffer.lines[pos] = buffer.blankLine()
+		buffer.trimScrollback()
 	} else {
 		topIndex := buffer.convertViewLineToRawLine(uint16(buffer.terminalState.topMargin))
 		bottomIndex := buffer.convertViewLineToRawLine(uint16(buffer.terminalState.bottomMargin))
@@ -537,18 +630,61 @@ func (buffer *Buffer) insertLine() {
 
 		copy(out[bottomIndex+1:], after)
 
-		out[pos] = newLine()
+		out[pos] = buffer.blankLine()
 		buffer.lines = out
 	}
 }
 
 func (buffer *Buffer) InsertBlankCharacters(count int) {
+	defer buffer.emitRowChange(buffer.terminalState.cursorY)
+
+	if buffer.hasHorizontalMargins() {
+		if buffer.inHorizontalMargins() {
+			buffer.insertCellsInMargins(count)
+		}
+		return
+	}
 
 	index := int(buffer.RawLine())
+	buffer.lines[index].breakWide(int(buffer.terminalState.cursorX))
 	for i := 0; i < count; i++ {
 		cells := buffer.lines[index].cells
-		buffer.lines[index].cells = append(cells[:buffer.terminalState.cursorX], append([]Cell{buffer.terminalState.DefaultCell(true)}, cells[buffer.terminalState.cursorX:]...)...)
+		buffer.lines[index].cells = append(cells[:buffer.terminalState.cursorX], append([]Cell{buffer.terminalState.eraseCell()}, cells[buffer.terminalState.cursorX:]...)...)
+	}
+}
+
+// insertCellsInMargins inserts n blank cells at the cursor, pushing cells beyond the right margin off the line
+func (buffer *Buffer) insertCellsInMargins(n int) {
+	line := buffer.getCurrentLine()
+	x := int(buffer.terminalState.cursorX)
+	right := int(buffer.terminalState.rightMargin) + 1
+	if n > right-x {
+		n = right - x
+	}
+
+	buffer.padCells(line, right)
+	line.breakWide(x)
+	line.breakWide(right - n)
+	line.breakWide(right)
+	copy(line.cells[x+n:right], line.cells[x:right-n])
+	buffer.eraseCells(line, x, x+n)
+}
+
+// deleteCellsInMargins deletes n cells at the cursor, shifting in blank cells at the right margin
+func (buffer *Buffer) deleteCellsInMargins(n int) {
+	line := buffer.getCurrentLine()
+	x := int(buffer.terminalState.cursorX)
+	right := int(buffer.terminalState.rightMargin) + 1
+	if n > right-x {
+		n = right - x
 	}
+
+	buffer.padCells(line, right)
+	line.breakWide(x)
+	line.breakWide(x + n)
+	line.breakWide(right)
+	copy(line.cells[x:right-n], line.cells[x+n:right])
+	buffer.eraseCells(line, right-n, right)
 }
 
 func (buffer *Buffer) InsertLines(count int) {
@@ -558,6 +694,14 @@ func (buffer *Buffer) InsertLines(count int) {
 		return
 	}
 
+	if buffer.hasHorizontalMargins() {
+		if buffer.inHorizontalMargins() {
+			buffer.scrollRect(buffer.terminalState.cursorY, uint16(buffer.terminalState.bottomMargin), -count)
+			buffer.terminalState.cursorX = uint16(buffer.terminalState.leftMargin)
+		}
+		return
+	}
+
 	buffer.terminalState.cursorX = 0
 
 	for i := 0; i < count; i++ {
@@ -573,6 +717,16 @@ func (buffer *Buffer) DeleteLines(count int) {
 		return
 	}
 
+	if buffer.hasHorizontalMargins() {
+		if buffer.inHorizontalMargins() {
+			buffer.scrollRect(buffer.terminalState.cursorY, uint16(buffer.terminalState.bottomMargin), count)
+			buffer.terminalState.cursorX = uint16(buffer.terminalState.leftMargin)
+		}
+		return
+	}
+
+	defer buffer.emitDisplayChange()
+
 	buffer.terminalState.cursorX = 0
 
 	for i := 0; i < count; i++ {
@@ -586,12 +740,19 @@ func (buffer *Buffer) Index() {
 	// This sequence causes the active position to move downward one line without changing the column position.
 	// If the active position is at the bottom margin, a scroll up is performed."
 
-	defer buffer.emitDisplayChange()
+	if buffer.hasHorizontalMargins() && uint(buffer.terminalState.cursorY) == buffer.terminalState.bottomMargin {
+		// only the area between the margins scrolls, so nothing is added to the scrollback
+		if buffer.inHorizontalMargins() {
+			buffer.AreaScrollUp(1)
+		}
+		return
+	}
 
 	if buffer.InScrollableRegion() {
 
 		if uint(buffer.terminalState.cursorY) < buffer.terminalState.bottomMargin {
 			buffer.terminalState.cursorY++
+			buffer.emitCursorChange()
 		} else {
 			buffer.AreaScrollUp(1)
 		}
@@ -600,35 +761,76 @@ func (buffer *Buffer) Index() {
 	}
 
 	if buffer.terminalState.cursorY >= buffer.ViewHeight()-1 {
-		buffer.lines = append(buffer.lines, newLine())
-		maxLines := buffer.getMaxLines()
-		if uint64(len(buffer.lines)) > maxLines {
-			copy(buffer.lines, buffer.lines[uint64(len(buffer.lines))-maxLines:])
-			buffer.lines = buffer.lines[:maxLines]
+		if buffer.HasScrollableRegion() {
+			// the cursor is below the scroll region, so only the region may scroll
+			return
+		}
+		buffer.lines = append(buffer.lines, buffer.blankLine())
+		if scrollback := buffer.scrollbackLines(); scrollback > 0 {
+			// the line scrolled off the top of the screen, which can't change any more
+			buffer.scrollbackBytes += lineBytes(&buffer.lines[scrollback-1])
+		}
+		buffer.trimScrollback()
+		// a view at the bottom moves up a row, and a view scrolled into the scrollback stays on the lines being read, until
+		// the oldest is discarded
+		offset := buffer.terminalState.scrollLinesFromBottom
+		if offset == 0 {
+			buffer.emitScroll()
+		} else {
+			defer buffer.emitDisplayChange()
+			if top := uint(len(buffer.lines)) - uint(buffer.ViewHeight()); offset < top {
+				buffer.terminalState.scrollLinesFromBottom++
+			} else {
+				buffer.terminalState.scrollLinesFromBottom = top
+			}
 		}
 	} else {
 		buffer.terminalState.cursorY++
+		buffer.emitCursorChange()
 	}
 }
 
 func (buffer *Buffer) ReverseIndex() {
 
-	defer buffer.emitDisplayChange()
-
 	if uint(buffer.terminalState.cursorY) == buffer.terminalState.topMargin {
-		buffer.AreaScrollDown(1)
+		if !buffer.hasHorizontalMargins() || buffer.inHorizontalMargins() {
+			buffer.AreaScrollDown(1)
+		}
 	} else if buffer.terminalState.cursorY > 0 {
 		buffer.terminalState.cursorY--
+		buffer.emitCursorChange()
 	}
 }
 
 // Write will write a rune to the terminal at the position of the cursor, and increment the cursor position
 func (buffer *Buffer) Write(runes ...rune) {
-	defer buffer.emitDisplayChange()
-	// scroll to bottom on input
-	buffer.terminalState.scrollLinesFromBottom = 0
-
 	for _, r := range runes {
+		if buffer.combine(r) {
+			continue
+		}
+		width := runeWidth(r)
+
+		buffer.emitRowChange(buffer.terminalState.cursorY)
+
+		if !buffer.terminalState.AutoWrap && buffer.CursorColumn() >= buffer.Width() {
+			// auto-wrap was disabled with a wrap pending, so overwrite the last column
+			buffer.terminalState.cursorX = buffer.Width() - 1
+		}
+
+		if width == 2 {
+			buffer.makeRoomForWide()
+		}
+
+		if buffer.terminalState.marginWrapPending && uint(buffer.terminalState.cursorX) == buffer.terminalState.rightMargin+1 && buffer.hasHorizontalMargins() {
+			if buffer.terminalState.AutoWrap {
+				buffer.terminalState.cursorX = uint16(buffer.terminalState.leftMargin)
+				buffer.Index()
+				buffer.emitRowChange(buffer.terminalState.cursorY)
+			} else {
+				// overwrite the character at the right margin
+				buffer.terminalState.cursorX--
+			}
+		}
 
 		line := buffer.getCurrentLine()
 
@@ -639,53 +841,118 @@ func (buffer *Buffer) Write(runes ...rune) {
 				return
 			}
 
-			for int(buffer.CursorColumn()) >= len(line.cells) {
-				line.Append(buffer.terminalState.DefaultCell(int(buffer.CursorColumn()) == len(line.cells)))
-			}
-			line.cells[buffer.terminalState.cursorX].attr = buffer.terminalState.CursorAttr
-			line.cells[buffer.terminalState.cursorX].setRune(r)
-			buffer.incrementCursorPosition()
+			buffer.putRune(line, int(buffer.CursorColumn()), r, width)
+			buffer.advanceCursor(width)
 			continue
 		}
 
 		if buffer.CursorColumn() >= buffer.Width() { // if we're after the line, move to next
 
-			if buffer.terminalState.AutoWrap {
-
-				buffer.NewLineEx(true)
-
-				newLine := buffer.getCurrentLine()
-				if len(newLine.cells) == 0 {
-					newLine.Append(buffer.terminalState.DefaultCell(true))
-				}
-				cell := &newLine.cells[0]
-				cell.setRune(r)
-				cell.attr = buffer.terminalState.CursorAttr
+			buffer.NewLineEx(true)
+			buffer.emitRowChange(buffer.terminalState.cursorY)
 
-			} else {
-				// no more room on line and wrapping is disabled
-				return
-			}
+			newLine := buffer.getCurrentLine()
+			newLine.setWrapped(true)
+			buffer.putRune(newLine, 0, r, width)
 
 			// @todo if next line is wrapped then prepend to it and shuffle characters along line, wrapping to next if necessary
 		} else {
+			buffer.putRune(line, int(buffer.CursorColumn()), r, width)
+		}
 
-			for int(buffer.CursorColumn()) >= len(line.cells) {
-				line.Append(buffer.terminalState.DefaultCell(int(buffer.CursorColumn()) == len(line.cells)))
-			}
+		buffer.advanceCursor(width)
+	}
+}
 
-			cell := &line.cells[buffer.CursorColumn()]
-			cell.setRune(r)
-			cell.attr = buffer.terminalState.CursorAttr
-		}
+// combine attaches r to the character before the cursor if r is a combining character or follows a zero width
+// joiner, returning false if it should be written to a cell of its own instead
+func (buffer *Buffer) combine(r rune) bool {
+	line := buffer.getCurrentLine()
+	col := int(buffer.terminalState.cursorX) - 1
+	if col > 0 && col < len(line.cells) && line.cells[col].wide == wideSpacer {
+		col--
+	}
+	if col < 0 || col >= len(line.cells) || line.cells[col].r == 0 {
+		return false
+	}
+
+	cell := &line.cells[col]
+	if !isCombining(r) && !cell.joined() {
+		return false
+	}
+	cell.combine(r)
+	buffer.emitRowChange(buffer.terminalState.cursorY)
+	return true
+}
+
+// makeRoomForWide moves the cursor off the last column before a double width character is written, as there's no
+// room for both halves of it there. With auto-wrap it wraps to the next line, and without it the character is
+// written over the last two columns.
+func (buffer *Buffer) makeRoomForWide() {
+	margins := buffer.hasHorizontalMargins() && buffer.inHorizontalMargins()
+	last := buffer.Width() - 1
+	if margins {
+		last = uint16(buffer.terminalState.rightMargin)
+	}
+	if buffer.terminalState.cursorX != last {
+		return
+	}
 
+	if buffer.terminalState.AutoWrap {
+		buffer.terminalState.cursorX = last + 1
+		buffer.terminalState.marginWrapPending = margins
+	} else if last > 0 {
+		buffer.terminalState.cursorX = last - 1
+	}
+}
+
+// putRune writes r to the cell at col with the cursor attributes, along with a spacer in the following cell if r is
+// double width. Any double width character which is half overwritten is erased.
+func (buffer *Buffer) putRune(line *Line, col int, r rune, width int) {
+	for len(line.cells) < col+width {
+		line.Append(buffer.terminalState.DefaultCell(len(line.cells) >= col))
+	}
+	line.breakWide(col)
+	line.breakWide(col + width)
+
+	for i := col; i < col+width; i++ {
+		line.cells[i].attr = buffer.terminalState.CursorAttr
+		line.cells[i].link = buffer.terminalState.hyperlink
+		line.cells[i].image = nil
+	}
+	line.cells[col].setRune(r)
+	if width == 2 {
+		line.cells[col].wide = wideStart
+		line.cells[col+1].setRune(0)
+		line.cells[col+1].wide = wideSpacer
+	}
+}
+
+// advanceCursor moves the cursor past a character which is width cells wide
+func (buffer *Buffer) advanceCursor(width int) {
+	for i := 0; i < width; i++ {
 		buffer.incrementCursorPosition()
 	}
 }
 
 func (buffer *Buffer) incrementCursorPosition() {
+	if !buffer.terminalState.AutoWrap {
+		// without auto-wrap the cursor stops at the right margin (or the end of the line), and the next character
+		// overwrites the last one, so a wrap is never pending
+		buffer.terminalState.marginWrapPending = false
+		last := buffer.Width() - 1
+		if buffer.hasHorizontalMargins() && buffer.inHorizontalMargins() {
+			last = uint16(buffer.terminalState.rightMargin)
+		}
+		if buffer.CursorColumn() < last {
+			buffer.terminalState.cursorX++
+		}
+		return
+	}
+
 	// we can increment one column past the end of the line.
 	// this is effectively the beginning of the next line, except when we \r etc.
+	buffer.terminalState.marginWrapPending = buffer.hasHorizontalMargins() && uint(buffer.terminalState.cursorX) == buffer.terminalState.rightMargin
 	if buffer.CursorColumn() < buffer.Width() {
 		buffer.terminalState.cursorX++
 	}
@@ -702,7 +969,7 @@ func (buffer *Buffer) Backspace() {
 
 	if buffer.terminalState.cursorX == 0 {
 		line := buffer.getCurrentLine()
-		if line.wrapped {
+		if line.wrapped && buffer.terminalState.AutoWrap {
 			buffer.MovePosition(int16(buffer.Width()-1), -1)
 		} else {
 			//@todo ring bell or whatever - actually i think the pty will trigger this
@@ -716,25 +983,29 @@ func (buffer *Buffer) Backspace() {
 }
 
 func (buffer *Buffer) CarriageReturn() {
+	buffer.terminalState.cursorX = buffer.lineStart()
+}
 
-	for {
-		line := buffer.getCurrentLine()
-		if line == nil {
-			break
-		}
-		if line.wrapped && buffer.terminalState.cursorY > 0 {
-			buffer.terminalState.cursorY--
-		} else {
-			break
-		}
+// lineStart returns the column which a carriage return moves the cursor to
+func (buffer *Buffer) lineStart() uint16 {
+	if buffer.hasHorizontalMargins() && uint(buffer.terminalState.cursorX) >= buffer.terminalState.leftMargin {
+		return uint16(buffer.terminalState.leftMargin)
 	}
-
-	buffer.terminalState.cursorX = 0
+	return 0
 }
 
 func (buffer *Buffer) Tab() {
-	for buffer.terminalState.cursorX < buffer.terminalState.viewWidth-1 { // @todo rightMargin
+	end := buffer.terminalState.viewWidth - 1
+	if buffer.hasHorizontalMargins() && buffer.inHorizontalMargins() {
+		end = uint16(buffer.terminalState.rightMargin)
+	}
+	part := tabStart
+	for buffer.terminalState.cursorX < end {
 		buffer.Write(' ')
+		if line := buffer.getCurrentLine(); buffer.terminalState.cursorX > 0 && int(buffer.terminalState.cursorX) <= len(line.cells) {
+			line.cells[buffer.terminalState.cursorX-1].tab = part
+		}
+		part = tabFill
 		if buffer.terminalState.IsTabSetAtCursor() {
 			break
 		}
@@ -748,17 +1019,13 @@ func (buffer *Buffer) NewLine() {
 func (buffer *Buffer) NewLineEx(forceCursorToMargin bool) {
 
 	if buffer.terminalState.IsNewLineMode() || forceCursorToMargin {
-		buffer.terminalState.cursorX = 0
+		buffer.terminalState.cursorX = buffer.lineStart()
 	}
 	buffer.Index()
 
-	for {
-		line := buffer.getCurrentLine()
-		if !line.wrapped {
-			break
-		}
-		buffer.Index()
-	}
+	// the line may have been wrapped onto before, but it is now reached by an explicit line feed. Write marks it as
+	// wrapped again if that's how it was reached.
+	buffer.getCurrentLine().setWrapped(false)
 }
 
 func (buffer *Buffer) IsNewLineMode() bool {
@@ -770,10 +1037,16 @@ func (buffer *Buffer) MovePosition(x int16, y int16) {
 	var toX uint16
 	var toY uint16
 
-	if int16(buffer.CursorColumn())+x < 0 {
+	// SetPosition takes a column relative to the left margin in Origin Mode
+	col := int16(buffer.CursorColumn())
+	if buffer.terminalState.OriginMode && buffer.hasHorizontalMargins() {
+		col -= int16(buffer.terminalState.leftMargin)
+	}
+
+	if col+x < 0 {
 		toX = 0
 	} else {
-		toX = uint16(int16(buffer.CursorColumn()) + x)
+		toX = uint16(col + x)
 	}
 
 	// should either use CursorLine() and SetPosition() or use absolutes, mind Origin Mode (DECOM)
@@ -787,26 +1060,32 @@ func (buffer *Buffer) MovePosition(x int16, y int16) {
 }
 
 func (buffer *Buffer) SetPosition(col uint16, line uint16) {
-	defer buffer.emitDisplayChange()
+	defer buffer.emitCursorChange()
 
 	useCol := col
 	useLine := line
 	maxLine := buffer.ViewHeight() - 1
+	maxCol := buffer.ViewWidth() - 1
 
 	if buffer.terminalState.OriginMode {
 		useLine += uint16(buffer.terminalState.topMargin)
 		maxLine = uint16(buffer.terminalState.bottomMargin)
-		// @todo left and right margins
+		if buffer.hasHorizontalMargins() {
+			useCol += uint16(buffer.terminalState.leftMargin)
+			maxCol = uint16(buffer.terminalState.rightMargin)
+		}
 	}
 	if useLine > maxLine {
 		useLine = maxLine
 	}
 
-	if useCol >= buffer.ViewWidth() {
-		useCol = buffer.ViewWidth() - 1
+	if useCol > maxCol {
+		useCol = maxCol
 		//logrus.Errorf("Cannot set cursor position: column %d is outside of the current view width (%d columns)", col, buffer.ViewWidth())
 	}
 
+	buffer.terminalState.marginWrapPending = false
+
 	buffer.terminalState.cursorX = useCol
 	buffer.terminalState.cursorY = useLine
 }
@@ -830,6 +1109,8 @@ func (buffer *Buffer) Clear() {
 	for i := 0; i < int(buffer.ViewHeight()); i++ {
 		buffer.lines = append(buffer.lines, newLine())
 	}
+	// the lines which were on screen are now in the scrollback
+	buffer.countScrollback()
 	buffer.SetPosition(0, 0) // do we need to set position?
 }
 
@@ -858,28 +1139,66 @@ func (buffer *Buffer) getViewLine(index uint16) *Line {
 	panic(fmt.Sprintf("Failed to retrieve line for %d", index))
 }
 
+// blankCells returns the cells of a line which has been entirely erased
+func (buffer *Buffer) blankCells() []Cell {
+	if !buffer.terminalState.hasEraseColour() {
+		return []Cell{}
+	}
+	cells := make([]Cell, buffer.ViewWidth())
+	for i := range cells {
+		cells[i] = buffer.terminalState.eraseCell()
+	}
+	return cells
+}
+
+func (buffer *Buffer) blankLine() Line {
+	line := newLine()
+	line.cells = buffer.blankCells()
+	return line
+}
+
+// eraseCells erases the cells on a line from 'from' up to but not including 'to'
+func (buffer *Buffer) eraseCells(line *Line, from int, to int) {
+	line.breakWide(from)
+	line.breakWide(to)
+	if to > len(line.cells) {
+		if !buffer.terminalState.hasEraseColour() {
+			// cells which don't exist already look erased
+			to = len(line.cells)
+		}
+		buffer.padCells(line, to)
+	}
+	for i := from; i < to; i++ {
+		line.cells[i] = buffer.terminalState.eraseCell()
+	}
+}
+
+// padCells appends cells which have never been written to, until the line has at least n cells
+func (buffer *Buffer) padCells(line *Line, n int) {
+	for len(line.cells) < n {
+		line.Append(Cell{attr: buffer.terminalState.defaultAttr})
+	}
+}
+
 func (buffer *Buffer) EraseLine() {
-	defer buffer.emitDisplayChange()
+	defer buffer.emitRowChange(buffer.terminalState.cursorY)
 	line := buffer.getCurrentLine()
-	line.cells = []Cell{}
+	line.cells = buffer.blankCells()
 }
 
 func (buffer *Buffer) EraseLineToCursor() {
-	defer buffer.emitDisplayChange()
+	defer buffer.emitRowChange(buffer.terminalState.cursorY)
 	line := buffer.getCurrentLine()
-	for i := 0; i <= int(buffer.terminalState.cursorX); i++ {
-		if i < len(line.cells) {
-			line.cells[i].erase(buffer.terminalState.CursorAttr.BgColour)
-		}
-	}
+	buffer.eraseCells(line, 0, int(buffer.terminalState.cursorX)+1)
 }
 
 func (buffer *Buffer) EraseLineFromCursor() {
-	defer buffer.emitDisplayChange()
+	defer buffer.emitRowChange(buffer.terminalState.cursorY)
 	line := buffer.getCurrentLine()
 
 	if len(line.cells) > 0 {
 		cx := buffer.terminalState.cursorX
+		line.breakWide(int(cx))
 		if int(cx) < len(line.cells) {
 			line.cells = line.cells[:buffer.terminalState.cursorX]
 		}
@@ -887,7 +1206,7 @@ func (buffer *Buffer) EraseLineFromCursor() {
 	max := int(buffer.ViewWidth()) - len(line.cells)
 
 	for i := 0; i < max; i++ {
-		line.Append(buffer.terminalState.DefaultCell(true))
+		line.Append(buffer.terminalState.eraseCell())
 	}
 
 }
@@ -897,43 +1216,53 @@ func (buffer *Buffer) EraseDisplay() {
 	for i := uint16(0); i < (buffer.ViewHeight()); i++ {
 		rawLine := buffer.convertViewLineToRawLine(i)
 		if int(rawLine) < len(buffer.lines) {
-			buffer.lines[int(rawLine)].cells = []Cell{}
+			buffer.lines[int(rawLine)].cells = buffer.blankCells()
 		}
 	}
 }
 
 func (buffer *Buffer) DeleteChars(n int) {
-	defer buffer.emitDisplayChange()
+	defer buffer.emitRowChange(buffer.terminalState.cursorY)
+
+	if buffer.hasHorizontalMargins() {
+		if buffer.inHorizontalMargins() {
+			buffer.deleteCellsInMargins(n)
+		}
+		return
+	}
 
 	line := buffer.getCurrentLine()
 	if int(buffer.terminalState.cursorX) >= len(line.cells) {
 		return
 	}
-	before := line.cells[:buffer.terminalState.cursorX]
 	if int(buffer.terminalState.cursorX)+n >= len(line.cells) {
 		n = len(line.cells) - int(buffer.terminalState.cursorX)
 	}
+	line.breakWide(int(buffer.terminalState.cursorX))
+	line.breakWide(int(buffer.terminalState.cursorX) + n)
+	before := line.cells[:buffer.terminalState.cursorX]
 	after := line.cells[int(buffer.terminalState.cursorX)+n:]
 	line.cells = append(before, after...)
+
+	// the cells shifted in at the end of the line are erased
+	buffer.eraseCells(line, len(line.cells), int(buffer.ViewWidth()))
 }
 
 func (buffer *Buffer) EraseCharacters(n int) {
-	defer buffer.emitDisplayChange()
+	defer buffer.emitRowChange(buffer.terminalState.cursorY)
 
 	line := buffer.getCurrentLine()
 
 	max := int(buffer.terminalState.cursorX) + n
-	if max > len(line.cells) {
-		max = len(line.cells)
+	if max > int(buffer.ViewWidth()) {
+		max = int(buffer.ViewWidth())
 	}
 
-	for i := int(buffer.terminalState.cursorX); i < max; i++ {
-		line.cells[i].erase(buffer.terminalState.CursorAttr.BgColour)
-	}
+	buffer.eraseCells(line, int(buffer.terminalState.cursorX), max)
 }
 
 func (buffer *Buffer) EraseDisplayFromCursor() {
-	defer buffer.emitDisplayChange()
+	defer buffer.emitRowsChange(buffer.terminalState.cursorY, buffer.ViewHeight()-1)
 	line := buffer.getCurrentLine()
 
 	max := int(buffer.terminalState.cursorX)
@@ -941,27 +1270,25 @@ func (buffer *Buffer) EraseDisplayFromCursor() {
 		max = len(line.cells)
 	}
 
+	line.breakWide(max)
 	line.cells = line.cells[:max]
+	buffer.eraseCells(line, max, int(buffer.ViewWidth()))
 
 	for rawLine := buffer.convertViewLineToRawLine(buffer.terminalState.cursorY) + 1; int(rawLine) < len(buffer.lines); rawLine++ {
-		buffer.lines[int(rawLine)].cells = []Cell{}
+		buffer.lines[int(rawLine)].cells = buffer.blankCells()
 	}
 }
 
 func (buffer *Buffer) EraseDisplayToCursor() {
-	defer buffer.emitDisplayChange()
+	defer buffer.emitRowsChange(0, buffer.terminalState.cursorY)
 	line := buffer.getCurrentLine()
 
-	for i := 0; i <= int(buffer.terminalState.cursorX); i++ {
-		if i >= len(line.cells) {
-			break
-		}
-		line.cells[i].erase(buffer.terminalState.CursorAttr.BgColour)
-	}
+	buffer.eraseCells(line, 0, int(buffer.terminalState.cursorX)+1)
+
 	for i := uint16(0); i < buffer.terminalState.cursorY; i++ {
 		rawLine := buffer.convertViewLineToRawLine(i)
 		if int(rawLine) < len(buffer.lines) {
-			buffer.lines[int(rawLine)].cells = []Cell{}
+			buffer.lines[int(rawLine)].cells = buffer.blankCells()
 		}
 	}
 }
@@ -976,102 +1303,172 @@ func (buffer *Buffer) ResizeView(width uint16, height uint16) {
 		return
 	}
 
-	// @todo scroll to bottom on resize
-	line := buffer.getCurrentLine()
-	cXFromEndOfLine := len(line.cells) - int(buffer.terminalState.cursorX+1)
-
-	cursorYMovement := 0
-
-	if width < buffer.terminalState.viewWidth { // wrap lines if we're shrinking
-		for i := 0; i < len(buffer.lines); i++ {
-			line := &buffer.lines[i]
-			//line.Cleanse()
-			if len(line.cells) > int(width) { // only try wrapping a line if it's too long
-				sillyCells := line.cells[width:] // grab the cells we need to wrap
-				line.cells = line.cells[:width]
-
-				// we need to move cut cells to the next line
-				// if the next line is wrapped anyway, we can push them onto the beginning of that line
-				// otherwise, we need add a new wrapped line
-				if i+1 < len(buffer.lines) {
-					nextLine := &buffer.lines[i+1]
-					if nextLine.wrapped {
-
-						nextLine.cells = append(sillyCells, nextLine.cells...)
-						continue
-					}
-				}
+	// the cursor's line must exist for the cursor to be kept on the same character
+	buffer.getCurrentLine()
+	col, row := buffer.reflow(int(buffer.terminalState.viewWidth), int(width), int(buffer.terminalState.cursorX), int(buffer.RawLine()))
 
-				if i+1 <= int(buffer.terminalState.cursorY) {
-					cursorYMovement++
-				}
+	buffer.terminalState.viewWidth = width
+	buffer.terminalState.viewHeight = height
 
-				newLine := newLine()
-				newLine.setWrapped(true)
-				newLine.cells = sillyCells
-				after := append([]Line{newLine}, buffer.lines[i+1:]...)
-				buffer.lines = append(buffer.lines[:i+1], after...)
+	// blank lines below the cursor are dropped rather than pushing it off the top of the view
+	for row < len(buffer.lines)-int(height) && buffer.lines[len(buffer.lines)-1].String() == "" {
+		buffer.lines = buffer.lines[:len(buffer.lines)-1]
+	}
+	if top := len(buffer.lines) - int(height); top > 0 {
+		row -= top
+	}
+	if row < 0 {
+		row = 0
+	}
+	if col >= int(width) && !buffer.terminalState.AutoWrap {
+		col = int(width) - 1
+	}
+	buffer.terminalState.cursorX = uint16(col)
+	buffer.terminalState.cursorY = uint16(row)
+	buffer.terminalState.marginWrapPending = false
 
-			}
-		}
-	} else if width > buffer.terminalState.viewWidth { // unwrap lines if we're growing
-		for i := 0; i < len(buffer.lines)-1; i++ {
-			line := &buffer.lines[i]
-			//line.Cleanse()
-			for offset := 1; i+offset < len(buffer.lines); offset++ {
-				nextLine := &buffer.lines[i+offset]
-				//nextLine.Cleanse()
-				if !nextLine.wrapped { // if the next line wasn't wrapped, we don't need to move characters back to this line
-					break
-				}
-				spaceOnLine := int(width) - len(line.cells)
-				if spaceOnLine <= 0 { // no more space to unwrap
-					break
-				}
-				moveCount := spaceOnLine
-				if moveCount > len(nextLine.cells) {
-					moveCount = len(nextLine.cells)
-				}
-				line.Append(nextLine.cells[:moveCount]...)
-				if moveCount == len(nextLine.cells) {
+	// positions in the lines have moved, so the selection and the view of the scrollback can't be kept
+	buffer.selectionStart = nil
+	buffer.selectionEnd = nil
+	buffer.isSelectionComplete = true
+	buffer.terminalState.SetScrollOffset(0)
 
-					if i+offset <= int(buffer.terminalState.cursorY) {
-						cursorYMovement--
-					}
+	buffer.terminalState.ResetVerticalMargins()
+	buffer.terminalState.ResetHorizontalMargins()
+	buffer.countScrollback()
+}
 
-					// if we unwrapped all cells off the next line, delete it
-					buffer.lines = append(buffer.lines[:i+offset], buffer.lines[i+offset+1:]...)
+// Reflow rewraps the lines of a buffer which wasn't active when the view was resized from oldWidth columns and
+// oldHeight rows, as ResizeView does for the active buffer. The saved cursor is kept on the same character, so that
+// it's restored to the right place when the buffer is switched back to.
+func (buffer *Buffer) Reflow(oldWidth uint16, oldHeight uint16) {
+	defer buffer.countScrollback()
+	if buffer.savedCursorAttr == nil {
+		buffer.reflow(int(oldWidth), int(buffer.ViewWidth()), -1, -1)
+		return
+	}
 
-					offset--
+	row := int(buffer.savedY)
+	if top := len(buffer.lines) - int(oldHeight); top > 0 {
+		row += top
+	}
+	// the saved cursor's line must exist for it to be kept on the same character
+	for len(buffer.lines) <= row {
+		buffer.lines = append(buffer.lines, newLine())
+	}
+	col, row := buffer.reflow(int(oldWidth), int(buffer.ViewWidth()), int(buffer.savedX), row)
 
-				} else {
-					// otherwise just remove the characters we moved up a line
-					nextLine.cells = nextLine.cells[moveCount:]
+	if top := len(buffer.lines) - int(buffer.ViewHeight()); top > 0 {
+		row -= top
+	}
+	if row < 0 {
+		row = 0
+	}
+	if col >= int(buffer.ViewWidth()) && !buffer.terminalState.AutoWrap {
+		col = int(buffer.ViewWidth()) - 1
+	}
+	buffer.savedX = uint16(col)
+	buffer.savedY = uint16(row)
+}
+
+// reflow rewraps lines which are oldWidth columns wide to width columns, joining the rows of each line which wrapped
+// onto the next and breaking them again at the new width. The position which the cell at (col, row) of the lines has
+// been moved to is returned, so that the cursor can be kept on the same character.
+func (buffer *Buffer) reflow(oldWidth int, width int, col int, row int) (int, int) {
+	reflowed := make([]Line, 0, len(buffer.lines))
+	newCol, newRow := col, row
+
+	for start := 0; start < len(buffer.lines); {
+		end := start + 1
+		for end < len(buffer.lines) && buffer.lines[end].wrapped {
+			end++
+		}
+
+		cells := []Cell{}
+		offset := -1
+		for i := start; i < end; i++ {
+			rowCells := buffer.lines[i].cells
+			if len(rowCells) > oldWidth {
+				rowCells = rowCells[:oldWidth]
+			}
+			if i == row {
+				offset = len(cells) + col
+			}
+			cells = append(cells, rowCells...)
+			// rows which wrap are padded to the full width, unless the last column was left empty because the wide
+			// character which begins the next row didn't fit there
+			if i+1 < end {
+				next := buffer.lines[i+1].cells
+				if len(rowCells) != oldWidth-1 || len(next) == 0 || next[0].wide != wideStart {
+					for len(cells) < (i+1-start)*oldWidth {
+						cells = append(cells, Cell{attr: buffer.terminalState.defaultAttr})
+					}
 				}
 			}
+		}
 
+		// trailing blanks which would be wrapped onto another row are dropped, but not those before the cursor
+		keep := len(cells)
+		for keep > width && keep > offset && isBlank(&cells[keep-1]) {
+			keep--
+		}
+		cells = cells[:keep]
+		for len(cells) < offset {
+			cells = append(cells, Cell{attr: buffer.terminalState.defaultAttr})
 		}
-	}
 
-	buffer.terminalState.viewWidth = width
-	buffer.terminalState.viewHeight = height
+		// the shell integration marks of every row joined are kept, on the line they're joined into
+		first := buffer.lines[start]
+		for i := start + 1; i < end; i++ {
+			if buffer.lines[i].HasMark(MarkPromptStart) && !first.HasMark(MarkPromptStart) {
+				first.promptState, first.exitCode = buffer.lines[i].promptState, buffer.lines[i].exitCode
+			}
+			first.marks |= buffer.lines[i].marks
+		}
+		first.cells = make([]Cell, 0, width)
+		rows := []Line{first}
+		for i := range cells {
+			current := &rows[len(rows)-1]
+			if len(current.cells) == width || cells[i].wide == wideStart && len(current.cells) == width-1 && width > 1 {
+				rows = append(rows, Line{wrapped: true, cells: make([]Cell, 0, width)})
+				current = &rows[len(rows)-1]
+			}
+			if i == offset {
+				newCol, newRow = len(current.cells), len(reflowed)+len(rows)-1
+			}
+			current.cells = append(current.cells, cells[i])
+		}
+		if offset >= 0 && offset >= len(cells) {
+			newCol, newRow = len(rows[len(rows)-1].cells), len(reflowed)+len(rows)-1
+		}
 
-	cY := uint16(len(buffer.lines) - 1)
-	if cY >= buffer.terminalState.viewHeight {
-		cY = buffer.terminalState.viewHeight - 1
+		reflowed = append(reflowed, rows...)
+		start = end
 	}
-	buffer.terminalState.cursorY = cY
 
-	// position cursorX
-	line = buffer.getCurrentLine()
-	buffer.terminalState.cursorX = uint16((len(line.cells) - cXFromEndOfLine) - 1)
+	buffer.lines = reflowed
+	if maxLines := int(buffer.getMaxLines()); len(buffer.lines) > maxLines {
+		newRow -= len(buffer.lines) - maxLines
+		buffer.discarded += uint64(len(buffer.lines) - maxLines)
+		buffer.lines = buffer.lines[len(buffer.lines)-maxLines:]
+	}
 
-	buffer.terminalState.ResetVerticalMargins()
+	return newCol, newRow
+}
+
+// isBlank returns true if nothing has been written to the cell
+func isBlank(cell *Cell) bool {
+	return cell.r == 0 && cell.wide == notWide && cell.image == nil
+}
+
+// DisableScrollback discards lines as they scroll off the top of the screen, rather than keeping them in the scrollback
+func (buffer *Buffer) DisableScrollback() {
+	buffer.noScrollback = true
 }
 
 func (buffer *Buffer) getMaxLines() uint64 {
 	result := buffer.terminalState.maxLines
-	if result < uint64(buffer.terminalState.viewHeight) {
+	if buffer.noScrollback || result < uint64(buffer.terminalState.viewHeight) {
 		result = uint64(buffer.terminalState.viewHeight)
 	}
 
@@ -1104,6 +1501,18 @@ func (buffer *Buffer) CompareViewLines(path string) bool {
 	return bytes.Equal(f, bufferContent)
 }
 
+// ReplaceColours changes the foreground and background colours of every cell using one of the colours being replaced,
+// where the cell's colour came from index
+func (buffer *Buffer) ReplaceColours(index ColourIndex, replacements map[[3]float32][3]float32) {
+	defer buffer.emitDisplayChange()
+
+	for i := range buffer.lines {
+		for j := range buffer.lines[i].cells {
+			buffer.lines[i].cells[j].attr.replaceColours(index, replacements)
+		}
+	}
+}
+
 func (buffer *Buffer) ReverseVideo() {
 	defer buffer.emitDisplayChange()
 
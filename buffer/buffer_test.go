@@ -116,6 +116,139 @@ func TestBufferWriteIncrementsCursorCorrectly(t *testing.T) {
 
 }
 
+func TestWritingAtLastColumnWithoutAutoWrap(t *testing.T) {
+	b := NewBuffer(NewTerminalState(5, 3, CellAttributes{}, 1000))
+	b.terminalState.LineFeedMode = false
+	b.terminalState.AutoWrap = false
+
+	b.Write([]rune("abcdefg")...)
+	assert.Equal(t, uint16(4), b.CursorColumn())
+	assert.Equal(t, uint16(0), b.CursorLine())
+	assert.False(t, b.inDoWrap())
+
+	b.Write([]rune("xyz")...)
+	assert.Equal(t, uint16(4), b.CursorColumn())
+	lines := b.GetVisibleLines()
+	require.Equal(t, 1, len(lines))
+	assert.Equal(t, "abcdz", lines[0].String())
+
+	// backspace moves left from the last column rather than treating it as a pending wrap
+	b.Backspace()
+	assert.Equal(t, uint16(3), b.CursorColumn())
+	b.Write('!')
+	assert.Equal(t, "abc!z", b.GetVisibleLines()[0].String())
+
+	// and doesn't move back onto a previous line which wrapped before auto-wrap was disabled
+	b.terminalState.AutoWrap = true
+	b.Write('1', '2')
+	b.terminalState.AutoWrap = false
+	b.CarriageReturn()
+	b.Backspace()
+	assert.Equal(t, uint16(0), b.CursorColumn())
+	assert.Equal(t, uint16(1), b.CursorLine())
+}
+
+func TestDisablingAutoWrapWithWrapPending(t *testing.T) {
+	b := NewBuffer(NewTerminalState(3, 3, CellAttributes{}, 1000))
+	b.terminalState.LineFeedMode = false
+
+	b.Write([]rune("abc")...)
+	assert.True(t, b.inDoWrap())
+
+	b.terminalState.AutoWrap = false
+	b.Write('d', 'e')
+	assert.Equal(t, uint16(2), b.CursorColumn())
+	lines := b.GetVisibleLines()
+	require.Equal(t, 1, len(lines))
+	assert.Equal(t, "abe", lines[0].String())
+}
+
+func TestWideCharacters(t *testing.T) {
+	b := NewBuffer(NewTerminalState(10, 3, CellAttributes{}, 1000))
+	b.terminalState.LineFeedMode = false
+
+	b.Write([]rune("a\u4e16\u754cb")...)
+	assert.Equal(t, uint16(6), b.CursorColumn())
+	cells := b.GetVisibleLines()[0].Cells()
+	require.Equal(t, 6, len(cells))
+	assert.Equal(t, 'a', cells[0].Rune())
+	assert.True(t, cells[1].IsWide())
+	assert.True(t, cells[2].IsWideSpacer())
+	assert.Equal(t, '\u754c', cells[3].Rune())
+	assert.True(t, cells[4].IsWideSpacer())
+	assert.Equal(t, 'b', cells[5].Rune())
+	assert.Equal(t, "a\u4e16\u754cb", b.GetVisibleLines()[0].String())
+
+	// so the characters after wide ones line up with the same columns on an ASCII line
+	b.CarriageReturn()
+	b.NewLine()
+	b.Write([]rune("abcdef")...)
+	assert.Equal(t, uint16(len(cells)), b.CursorColumn())
+	assert.Equal(t, 'f', b.GetVisibleLines()[1].Cells()[5].Rune())
+
+	// overwriting either half of a wide character erases the whole of it
+	b.SetPosition(2, 0)
+	b.Write('x')
+	b.SetPosition(3, 0)
+	b.Write('y')
+	assert.Equal(t, "a\x00xy\x00b", b.GetVisibleLines()[0].String())
+
+	// as does erasing half of one
+	b.SetPosition(1, 1)
+	b.Write('\u4e16')
+	b.SetPosition(2, 1)
+	b.EraseLineToCursor()
+	assert.Equal(t, "\x00\x00\x00def", b.GetVisibleLines()[1].String())
+	assert.False(t, b.GetVisibleLines()[1].Cells()[1].IsWide())
+
+	// spacers are left out of selected text
+	b.SetPosition(0, 0)
+	b.Write('\u4e16')
+	b.StartSelection(0, 0, SelectionChar)
+	b.ExtendSelection(3, 0, true)
+	assert.Equal(t, "\u4e16xy", b.GetSelectedText())
+
+	// a wide character which doesn't fit at the end of a line is wrapped to the next one
+	b.SetPosition(9, 2)
+	b.Write('\u4e16')
+	assert.Equal(t, uint16(2), b.CursorColumn())
+	assert.Equal(t, uint16(2), b.CursorLine())
+	lines := b.GetVisibleLines()
+	assert.True(t, lines[len(lines)-1].Cells()[0].IsWide())
+}
+
+func TestCombiningCharacters(t *testing.T) {
+	b := NewBuffer(NewTerminalState(10, 3, CellAttributes{}, 1000))
+	b.terminalState.LineFeedMode = false
+
+	b.Write([]rune("e\u0301x")...)
+	assert.Equal(t, uint16(2), b.CursorColumn())
+	cells := b.GetVisibleLines()[0].Cells()
+	require.Equal(t, 2, len(cells))
+	assert.Equal(t, 'e', cells[0].Rune())
+	assert.Equal(t, []rune{0x301}, cells[0].Combining())
+	assert.Equal(t, "e\u0301x", b.GetVisibleLines()[0].String())
+
+	// the characters of a joined emoji sequence share the cells of the first
+	b.Write([]rune("\U0001f469\u200d\U0001f4bb\U0001f3fdy")...)
+	assert.Equal(t, uint16(5), b.CursorColumn())
+	cells = b.GetVisibleLines()[0].Cells()
+	assert.True(t, cells[2].IsWide())
+	assert.Equal(t, []rune{0x200d, 0x1f4bb, 0x1f3fd}, cells[2].Combining())
+	assert.Equal(t, 'y', cells[4].Rune())
+
+	// overwriting a cell drops the marks attached to it
+	b.SetPosition(0, 0)
+	b.Write('a')
+	assert.Nil(t, b.GetVisibleLines()[0].Cells()[0].Combining())
+
+	// a mark with nothing before it gets a cell of its own
+	b.CarriageReturn()
+	b.NewLine()
+	b.Write(0x301)
+	assert.Equal(t, uint16(1), b.CursorColumn())
+}
+
 func TestWritingNewLineAsFirstRuneOnWrappedLine(t *testing.T) {
 	b := NewBuffer(NewTerminalState(3, 20, CellAttributes{}, 1000))
 	b.terminalState.LineFeedMode = false
@@ -619,6 +752,82 @@ goodbyegoodbye`
 	require.Equal(t, uint16(14), b.terminalState.cursorX)
 }
 
+func TestResizeViewKeepsCursorOnSameCharacter(t *testing.T) {
+	b := NewBuffer(NewTerminalState(10, 5, CellAttributes{}, 1000))
+	b.terminalState.LineFeedMode = false
+
+	b.Write([]rune("abcdefghijklmno")...)
+	b.CarriageReturn()
+	b.NewLine()
+	b.Write([]rune("below")...)
+
+	// the cursor is on the 'm' of the first line rather than at the end of the text
+	b.SetPosition(2, 1)
+	b.ResizeView(4, 5)
+	assert.Equal(t, []string{"abcd", "efgh", "ijkl", "mno", "belo", "w"}, bufferText(b))
+	assert.Equal(t, 'm', b.getCurrentLine().cells[b.CursorColumn()].Rune())
+
+	b.ResizeView(20, 5)
+	assert.Equal(t, []string{"abcdefghijklmno", "below"}, bufferText(b))
+	assert.Equal(t, uint16(12), b.CursorColumn())
+	assert.Equal(t, uint16(0), b.CursorLine())
+}
+
+func TestResizeViewWithWideCharacters(t *testing.T) {
+	b := NewBuffer(NewTerminalState(10, 5, CellAttributes{}, 1000))
+	b.terminalState.LineFeedMode = false
+
+	b.Write([]rune("ab\u4e16\u754cc")...)
+
+	// a wide character isn't split between rows, and the gap left for it is closed again when the rows are joined
+	b.ResizeView(3, 5)
+	assert.Equal(t, []string{"ab", "\u4e16", "\u754cc"}, bufferText(b))
+	assert.True(t, b.lines[1].cells[0].IsWide())
+	assert.Equal(t, uint16(3), b.CursorColumn())
+
+	b.ResizeView(10, 5)
+	assert.Equal(t, []string{"ab\u4e16\u754cc"}, bufferText(b))
+	assert.Equal(t, uint16(7), b.CursorColumn())
+}
+
+func TestReflowInactiveBuffer(t *testing.T) {
+	state := NewTerminalState(10, 5, CellAttributes{}, 1000)
+	state.LineFeedMode = false
+	b := NewBuffer(state)
+	b.Write([]rune("abcdefghijkl")...)
+
+	state.viewWidth = 6
+	b.Reflow(10, 5)
+	assert.Equal(t, []string{"abcdef", "ghijkl"}, bufferText(b))
+	assert.True(t, b.lines[1].wrapped)
+}
+
+func TestResizeViewKeepsMarksOfJoinedRows(t *testing.T) {
+	b := NewBuffer(NewTerminalState(4, 5, CellAttributes{}, 1000))
+	b.terminalState.LineFeedMode = false
+
+	b.MarkPromptStart()
+	b.Write([]rune("$ ls")...)
+	b.MarkOutputStart()
+	b.Write([]rune("ab")...)
+	b.MarkCommandEnd(1)
+	require.Equal(t, []string{"$ ls", "ab"}, bufferText(b))
+
+	b.ResizeView(10, 5)
+	require.Equal(t, []string{"$ lsab"}, bufferText(b))
+	assert.Equal(t, MarkPromptStart|MarkOutputStart|MarkCommandEnd, b.lines[0].Marks())
+	assert.Equal(t, PromptStateFailure, b.lines[0].PromptState())
+	assert.Equal(t, 1, b.lines[0].ExitCode())
+}
+
+func bufferText(b *Buffer) []string {
+	strs := []string{}
+	for _, line := range b.lines {
+		strs = append(strs, line.String())
+	}
+	return strs
+}
+
 /*
 hellohellohellohellohellohellohellohellohellohellohellohello
 goodbyegoo
@@ -640,6 +849,48 @@ func TestBufferMaxLines(t *testing.T) {
 	assert.Equal(t, "world", b.lines[1].String())
 }
 
+func TestScrolledViewFollowsOutput(t *testing.T) {
+	b := NewBuffer(NewTerminalState(80, 2, CellAttributes{}, 4))
+	b.terminalState.LineFeedMode = false
+
+	for _, line := range []string{"one", "two", "three"} {
+		b.Write([]rune(line)...)
+		b.NewLine()
+	}
+	b.terminalState.SetScrollOffset(1)
+	assert.Equal(t, "two", b.GetVisibleLines()[0].String())
+
+	// the view stays on the same lines as output arrives
+	b.Write([]rune("four")...)
+	b.NewLine()
+	assert.Equal(t, uint(2), b.terminalState.GetScrollOffset())
+	assert.Equal(t, "two", b.GetVisibleLines()[0].String())
+
+	// until the lines are discarded from the scrollback
+	b.Write([]rune("five")...)
+	b.NewLine()
+	assert.Equal(t, uint(2), b.terminalState.GetScrollOffset())
+	assert.Equal(t, "three", b.GetVisibleLines()[0].String())
+}
+
+func TestSelectionAcrossScrollback(t *testing.T) {
+	b := NewBuffer(NewTerminalState(80, 2, CellAttributes{}, 10))
+	b.terminalState.LineFeedMode = false
+
+	for _, line := range []string{"one", "two", "three", "four"} {
+		b.Write([]rune(line)...)
+		b.NewLine()
+	}
+
+	// start in the scrollback, then scroll back down to finish on the screen
+	b.terminalState.SetScrollOffset(2)
+	b.StartSelection(1, 0, SelectionChar)
+	b.terminalState.SetScrollOffset(0)
+	b.ExtendSelection(1, 0, true)
+
+	assert.Equal(t, "wo\nthree\nfo", b.GetSelectedText())
+}
+
 func makeBufferForTestingSelection() *Buffer {
 	b := NewBuffer(NewTerminalState(80, 10, CellAttributes{}, 10))
 	b.terminalState.LineFeedMode = false
@@ -711,3 +962,108 @@ func TestSelectingAfterText(t *testing.T) {
 	assert.Equal(t, end.Col, 79)
 	assert.Equal(t, end.Line, 3)
 }
+
+func TestWrapFlagTracksSoftWraps(t *testing.T) {
+	b := NewBuffer(NewTerminalState(5, 10, CellAttributes{}, 1000))
+	b.terminalState.LineFeedMode = false
+
+	b.Write([]rune("abcdefgh")...)
+	b.NewLine()
+	b.Write([]rune("ijk")...)
+	b.NewLine()
+	b.Write([]rune("lmnop")...)
+	b.NewLine()
+	b.Write([]rune("qrstuvwxyz0")...)
+
+	require.Equal(t, 7, len(b.lines))
+	assert.False(t, b.lines[0].wrapped)
+	assert.True(t, b.lines[1].wrapped)
+	assert.False(t, b.lines[2].wrapped)
+	// a line which exactly fills the row doesn't wrap until more text is written
+	assert.False(t, b.lines[3].wrapped)
+	assert.False(t, b.lines[4].wrapped)
+	assert.True(t, b.lines[5].wrapped)
+	assert.True(t, b.lines[6].wrapped)
+
+	b.StartSelection(0, 0, SelectionChar)
+	b.ExtendSelection(4, 6, true)
+	assert.Equal(t, "abcdefgh\nijk\nlmnop\nqrstuvwxyz0", b.GetSelectedText())
+}
+
+func TestLineFeedClearsWrapFlag(t *testing.T) {
+	b := NewBuffer(NewTerminalState(5, 10, CellAttributes{}, 1000))
+	b.terminalState.LineFeedMode = false
+
+	b.Write([]rune("abcdefg")...)
+	assert.Equal(t, uint16(1), b.CursorLine())
+	assert.True(t, b.lines[1].wrapped)
+
+	// overwrite the start of the first row, then move onto the second with a line feed rather than by wrapping
+	b.SetPosition(0, 0)
+	b.NewLine()
+	b.Write([]rune("xy")...)
+	assert.False(t, b.lines[1].wrapped)
+
+	b.StartSelection(0, 0, SelectionChar)
+	b.ExtendSelection(4, 1, true)
+	assert.Equal(t, "abcde\nxy", b.GetSelectedText())
+}
+
+func TestTabsAreMarked(t *testing.T) {
+	b := NewBuffer(NewTerminalState(20, 5, CellAttributes{}, 1000))
+
+	b.Write('a')
+	b.Tab()
+	b.Write('b', ' ')
+
+	line := b.GetVisibleLines()[0]
+	cells := line.Cells()
+	assert.False(t, cells[0].IsTab())
+	assert.True(t, cells[1].IsTabStart())
+	for i := 2; i < 4; i++ {
+		assert.True(t, cells[i].IsTab(), "cell %d", i)
+		assert.False(t, cells[i].IsTabStart(), "cell %d", i)
+	}
+	assert.False(t, cells[4].IsTab())
+	assert.False(t, cells[5].IsTab())
+
+	// overwriting a tab with text removes the mark
+	b.SetPosition(1, 0)
+	b.Write('x')
+	assert.False(t, b.GetVisibleLines()[0].Cells()[1].IsTab())
+}
+
+func TestSelectingWrappedLine(t *testing.T) {
+	b := NewBuffer(NewTerminalState(5, 10, CellAttributes{}, 1000))
+	b.terminalState.LineFeedMode = false
+
+	b.Write([]rune("one")...)
+	b.CarriageReturn()
+	b.NewLine()
+	b.Write([]rune("two wraps")...)
+	b.CarriageReturn()
+	b.NewLine()
+	b.Write([]rune("three")...)
+
+	// a triple click on either row of the wrapped line selects all of it
+	b.StartSelection(1, 2, SelectionLine)
+	assert.Equal(t, "two wraps", b.GetSelectedText())
+	b.StartSelection(1, 1, SelectionLine)
+	assert.Equal(t, "two wraps", b.GetSelectedText())
+}
+
+func TestWordSeparators(t *testing.T) {
+	b := NewBuffer(NewTerminalState(40, 10, CellAttributes{}, 1000))
+	b.Write([]rune("path/to/file.go:12 世界")...)
+
+	b.StartSelection(9, 0, SelectionWord)
+	assert.Equal(t, "path/to/file.go", b.GetSelectedText())
+
+	b.terminalState.WordSeparators = "/.:"
+	b.StartSelection(9, 0, SelectionWord)
+	assert.Equal(t, "file", b.GetSelectedText())
+
+	// both cells of a wide character are part of the word
+	b.StartSelection(20, 0, SelectionWord)
+	assert.Equal(t, "世界", b.GetSelectedText())
+}
@@ -5,15 +5,40 @@ import (
 )
 
 type Cell struct {
-	r     rune
-	attr  CellAttributes
-	image *image.RGBA
+	r         rune
+	combining []rune // combining characters attached to r
+	attr      CellAttributes
+	image     *image.RGBA
+	tab       tabPart
+	wide      widePart
+	link      *Hyperlink // set if the cell is part of a link (OSC 8)
 }
 
+// tabPart records whether a cell was filled by a tab, so that tabs can be told apart from spaces
+type tabPart uint8
+
+const (
+	notTab   tabPart = iota
+	tabStart         // the first cell filled by a tab
+	tabFill          // a following cell filled by the same tab
+)
+
+// widePart records whether a cell holds half of a double width character
+type widePart uint8
+
+const (
+	notWide    widePart = iota
+	wideStart           // the cell holding the character, which is drawn across it and the following cell
+	wideSpacer          // the following cell, which is left empty
+)
+
 type CellAttributes struct {
 	FgColour  [3]float32
 	BgColour  [3]float32
+	FgIndex   ColourIndex // where FgColour came from
+	BgIndex   ColourIndex // where BgColour came from
 	Bold      bool
+	Italic    bool
 	Dim       bool
 	Underline bool
 	Blink     bool
@@ -53,13 +78,82 @@ func (cell *Cell) Bg() [3]float32 {
 	return cell.attr.BgColour
 }
 
-func (cell *Cell) erase(bgColour [3]float32) {
-	cell.setRune(0)
-	cell.attr.BgColour = bgColour
-}
-
 func (cell *Cell) setRune(r rune) {
 	cell.r = r
+	cell.combining = nil
+	cell.tab = notTab
+	cell.wide = notWide
+}
+
+// Combining returns the combining characters attached to the cell's rune, which are drawn over it in the same cell
+func (cell *Cell) Combining() []rune {
+	return cell.combining
+}
+
+// combine attaches a combining character to the cell's rune
+func (cell *Cell) combine(r rune) {
+	// the slice is copied rather than appended to in place, as copies of the cell may share it
+	cell.combining = append(cell.combining[:len(cell.combining):len(cell.combining)], r)
+}
+
+// joined returns true if the last character attached to the cell is a zero width joiner, so the next character
+// written is joined to it as well
+func (cell *Cell) joined() bool {
+	return len(cell.combining) > 0 && cell.combining[len(cell.combining)-1] == zeroWidthJoiner
+}
+
+// IsTab returns true if the cell was filled by a tab
+func (cell *Cell) IsTab() bool {
+	return cell.tab != notTab
+}
+
+// IsTabStart returns true if the cell is the first one filled by a tab
+func (cell *Cell) IsTabStart() bool {
+	return cell.tab == tabStart
+}
+
+// IsWide returns true if the cell holds a double width character, which is drawn across it and the following cell
+func (cell *Cell) IsWide() bool {
+	return cell.wide == wideStart
+}
+
+// IsWideSpacer returns true if the cell is the second half of a double width character
+func (cell *Cell) IsWideSpacer() bool {
+	return cell.wide == wideSpacer
+}
+
+// A ColourIndex records where a colour of the cell attributes came from. Colours from the 256 colour palette keep their
+// number, so that bold text can be drawn in the bright variant of its colour whatever other colours have the same value.
+type ColourIndex int16
+
+const (
+	DefaultColour ColourIndex = 0  // the default foreground or background colour
+	TrueColour    ColourIndex = -1 // a colour set by its value (SGR 38;2 or 48;2)
+)
+
+// PaletteIndex returns the ColourIndex of colour n of the palette
+func PaletteIndex(n uint8) ColourIndex {
+	return ColourIndex(n) + 1
+}
+
+// Palette returns the number of the palette colour, or false if the colour isn't from the palette
+func (index ColourIndex) Palette() (uint8, bool) {
+	if index <= 0 {
+		return 0, false
+	}
+	return uint8(index - 1), true
+}
+
+// SetFg sets the foreground colour, which came from index
+func (cellAttr *CellAttributes) SetFg(colour [3]float32, index ColourIndex) {
+	cellAttr.FgColour = colour
+	cellAttr.FgIndex = index
+}
+
+// SetBg sets the background colour, which came from index
+func (cellAttr *CellAttributes) SetBg(colour [3]float32, index ColourIndex) {
+	cellAttr.BgColour = colour
+	cellAttr.BgIndex = index
 }
 
 func NewBackgroundCell(colour [3]float32) Cell {
@@ -71,7 +165,17 @@ func NewBackgroundCell(colour [3]float32) Cell {
 }
 
 func (cellAttr *CellAttributes) ReverseVideo() {
-	oldFgColour := cellAttr.FgColour
-	cellAttr.FgColour = cellAttr.BgColour
-	cellAttr.BgColour = oldFgColour
+	cellAttr.FgColour, cellAttr.BgColour = cellAttr.BgColour, cellAttr.FgColour
+	cellAttr.FgIndex, cellAttr.BgIndex = cellAttr.BgIndex, cellAttr.FgIndex
+}
+
+// replaceColours changes the foreground and background colours which came from index and have one of the values being
+// replaced. Colours from elsewhere are left alone, even if they have the same value.
+func (cellAttr *CellAttributes) replaceColours(index ColourIndex, replacements map[[3]float32][3]float32) {
+	if colour, ok := replacements[cellAttr.FgColour]; ok && cellAttr.FgIndex == index {
+		cellAttr.FgColour = colour
+	}
+	if colour, ok := replacements[cellAttr.BgColour]; ok && cellAttr.BgIndex == index {
+		cellAttr.BgColour = colour
+	}
 }
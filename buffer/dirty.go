@@ -0,0 +1,116 @@
+package buffer
+
+// DirtyRows is a bitmap of view rows which have changed since the renderer last collected them
+type DirtyRows struct {
+	all      bool
+	bits     []uint64
+	scrolled uint16 // how many rows the whole view moved up by, see Scroll
+}
+
+func (rows *DirtyRows) Set(row uint16) {
+	index := int(row / 64)
+	for index >= len(rows.bits) {
+		rows.bits = append(rows.bits, 0)
+	}
+	rows.bits[index] |= 1 << (row % 64)
+}
+
+// SetRange marks every row from 'from' to 'to' inclusive as dirty
+func (rows *DirtyRows) SetRange(from uint16, to uint16) {
+	for row := int(from); row <= int(to); row++ {
+		rows.Set(uint16(row))
+	}
+}
+
+func (rows *DirtyRows) SetAll() {
+	rows.all = true
+}
+
+// All returns true if every row should be redrawn
+func (rows *DirtyRows) All() bool {
+	return rows.all
+}
+
+func (rows *DirtyRows) IsSet(row uint16) bool {
+	if rows.all {
+		return true
+	}
+	index := int(row / 64)
+	if index >= len(rows.bits) {
+		return false
+	}
+	return rows.bits[index]&(1<<(row%64)) != 0
+}
+
+// Scroll records that every row of a view height rows tall moved up by n, so the renderer can move what it drew for
+// them rather than drawing them again. Rows marked already move up with them, and the rows uncovered at the bottom are
+// marked. Once the rows have moved by the height of the view, every row is dirty instead.
+func (rows *DirtyRows) Scroll(n uint16, height uint16) {
+	if rows.all || n == 0 {
+		return
+	}
+	if uint(rows.scrolled)+uint(n) >= uint(height) {
+		rows.all = true
+		return
+	}
+
+	moved := DirtyRows{scrolled: rows.scrolled + n}
+	for row := n; row < height; row++ {
+		if rows.IsSet(row) {
+			moved.Set(row - n)
+		}
+	}
+	moved.SetRange(height-n, height-1)
+	*rows = moved
+}
+
+// Scrolled returns how many rows the whole view moved up by before the rows marked were changed, which the renderer
+// must move what it drew up by first. It's zero when every row is dirty.
+func (rows *DirtyRows) Scrolled() uint16 {
+	if rows.all {
+		return 0
+	}
+	return rows.scrolled
+}
+
+// Any returns true if at least one row is dirty
+func (rows *DirtyRows) Any() bool {
+	if rows.all {
+		return true
+	}
+	for _, bits := range rows.bits {
+		if bits != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// TakeDirtyRows returns the view rows which have changed since the last call and resets them
+func (buffer *Buffer) TakeDirtyRows() DirtyRows {
+	rows := buffer.dirtyRows
+	buffer.dirtyRows = DirtyRows{}
+	return rows
+}
+
+// emitRowChange flags a change which is confined to a single view row
+func (buffer *Buffer) emitRowChange(viewRow uint16) {
+	buffer.dirty = true
+	buffer.dirtyRows.Set(viewRow)
+}
+
+func (buffer *Buffer) emitRowsChange(from uint16, to uint16) {
+	buffer.dirty = true
+	buffer.dirtyRows.SetRange(from, to)
+}
+
+// emitScroll flags that the content of every view row moved up by one row, and that a new row was added at the bottom
+func (buffer *Buffer) emitScroll() {
+	buffer.dirty = true
+	buffer.dirtyRows.Scroll(1, buffer.ViewHeight())
+}
+
+// emitCursorChange flags a cursor movement which doesn't alter the content of any row
+func (buffer *Buffer) emitCursorChange() {
+	buffer.dirty = true
+}
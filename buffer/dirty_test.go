@@ -0,0 +1,177 @@
+package buffer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirtyRowsAreTrackedPerRow(t *testing.T) {
+	b := NewBuffer(NewTerminalState(20, 5, CellAttributes{}, 1000))
+	b.SetPosition(0, 2)
+	b.Write([]rune("hello")...)
+
+	rows := b.TakeDirtyRows()
+	assert.False(t, rows.All())
+	assert.True(t, rows.IsSet(2))
+	assert.False(t, rows.IsSet(1))
+	assert.False(t, rows.IsSet(3))
+
+	rows = b.TakeDirtyRows()
+	assert.False(t, rows.Any())
+
+	// moving the cursor doesn't change any row content
+	b.SetPosition(3, 4)
+	rows = b.TakeDirtyRows()
+	assert.False(t, rows.Any())
+
+	b.EraseLine()
+	rows = b.TakeDirtyRows()
+	assert.False(t, rows.All())
+	assert.True(t, rows.IsSet(4))
+
+	b.SetPosition(0, 1)
+	b.EraseDisplayToCursor()
+	rows = b.TakeDirtyRows()
+	assert.True(t, rows.IsSet(0))
+	assert.True(t, rows.IsSet(1))
+	assert.False(t, rows.IsSet(2))
+
+	// scrolling moves every row up, so only the new row at the bottom is dirty
+	b.SetPosition(0, 4)
+	b.Index()
+	rows = b.TakeDirtyRows()
+	assert.False(t, rows.All())
+	assert.Equal(t, uint16(1), rows.Scrolled())
+	assert.True(t, rows.IsSet(4))
+	assert.False(t, rows.IsSet(3))
+}
+
+func TestDirtyRowsScroll(t *testing.T) {
+	rows := DirtyRows{}
+	rows.Set(0)
+	rows.Set(3)
+	rows.Scroll(2, 5)
+
+	// the rows marked before move up with the content, and the ones scrolled off the top are dropped
+	assert.Equal(t, uint16(2), rows.Scrolled())
+	assert.True(t, rows.IsSet(1))
+	assert.False(t, rows.IsSet(0))
+	assert.False(t, rows.IsSet(2))
+	assert.True(t, rows.IsSet(3))
+	assert.True(t, rows.IsSet(4))
+
+	// scrolling by the height of the view uncovers every row
+	rows.Scroll(3, 5)
+	assert.True(t, rows.All())
+	assert.Equal(t, uint16(0), rows.Scrolled())
+}
+
+func TestDirtyRowsWhenScrolledBack(t *testing.T) {
+	b := NewBuffer(NewTerminalState(20, 3, CellAttributes{}, 1000))
+	for i := 0; i < 5; i++ {
+		b.Index()
+	}
+	b.terminalState.SetScrollOffset(1)
+	b.TakeDirtyRows()
+
+	// the rows in view don't move, but aren't tracked separately from the ones which do
+	b.Index()
+	rows := b.TakeDirtyRows()
+	assert.True(t, rows.All())
+}
+
+func TestDirtyRowsWhenWrapping(t *testing.T) {
+	b := NewBuffer(NewTerminalState(5, 5, CellAttributes{}, 1000))
+	b.Write([]rune("abcdefg")...)
+
+	rows := b.TakeDirtyRows()
+	assert.False(t, rows.All())
+	assert.True(t, rows.IsSet(0))
+	assert.True(t, rows.IsSet(1))
+	assert.False(t, rows.IsSet(2))
+}
+
+func makeBufferForClockBenchmark() *Buffer {
+	b := NewBuffer(NewTerminalState(240, 80, CellAttributes{}, 1000))
+	for i := 0; i < 80; i++ {
+		b.SetPosition(0, uint16(i))
+		b.Write([]rune(strings.Repeat("lorem ipsum ", 20))...)
+	}
+	b.TakeDirtyRows()
+	return b
+}
+
+// renderRows walks the cells of the given rows as the renderer does when rasterising them
+func renderRows(b *Buffer, rows DirtyRows) int {
+	cells := 0
+	lines := b.GetVisibleLines()
+	for y := range lines {
+		if !rows.IsSet(uint16(y)) {
+			continue
+		}
+		var builder strings.Builder
+		for _, cell := range lines[y].Cells() {
+			builder.WriteRune(cell.Rune())
+			cells++
+		}
+	}
+	return cells
+}
+
+func tickClock(b *Buffer, i int) {
+	b.SetPosition(230, 0)
+	b.Write([]rune(fmt.Sprintf("%02d:%02d:%02d", i/3600%24, i/60%60, i%60))...)
+}
+
+func BenchmarkClockTickFullRedraw(b *testing.B) {
+	buffer := makeBufferForClockBenchmark()
+	all := DirtyRows{}
+	all.SetAll()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tickClock(buffer, i)
+		buffer.TakeDirtyRows()
+		renderRows(buffer, all)
+	}
+}
+
+func BenchmarkClockTickDirtyRows(b *testing.B) {
+	buffer := makeBufferForClockBenchmark()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tickClock(buffer, i)
+		renderRows(buffer, buffer.TakeDirtyRows())
+	}
+}
+
+func scrollLine(b *Buffer, i int) {
+	b.CarriageReturn()
+	b.Index()
+	b.Write([]rune(fmt.Sprintf("%6d lorem ipsum dolor sit amet", i))...)
+}
+
+func BenchmarkScrollingFullRedraw(b *testing.B) {
+	buffer := makeBufferForClockBenchmark()
+	buffer.SetPosition(0, 79)
+	all := DirtyRows{}
+	all.SetAll()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		scrollLine(buffer, i)
+		buffer.TakeDirtyRows()
+		renderRows(buffer, all)
+	}
+}
+
+func BenchmarkScrollingDirtyRows(b *testing.B) {
+	buffer := makeBufferForClockBenchmark()
+	buffer.SetPosition(0, 79)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		scrollLine(buffer, i)
+		renderRows(buffer, buffer.TakeDirtyRows())
+	}
+}
@@ -0,0 +1,42 @@
+package buffer
+
+// Hyperlink is the target of text which the application has marked as a link with OSC 8
+type Hyperlink struct {
+	ID  string // cells with the same id and URI are the same link, e.g. a link wrapped over two lines by a text editor
+	URI string
+}
+
+// Link returns the link which the cell is part of, or nil if it isn't a link
+func (cell *Cell) Link() *Hyperlink {
+	return cell.link
+}
+
+// SameLink returns true if a and b are both the same link. Links with an id are the same wherever they appear, but
+// a link without one is only the text written between the OSC 8 which started it and the one which ended it, so other
+// links to the same URI aren't mistaken for it.
+func SameLink(a *Hyperlink, b *Hyperlink) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	if a.ID == "" || b.ID == "" {
+		return a == b
+	}
+	return *a == *b
+}
+
+// SetHyperlink sets the link which text written from now on is part of, or nil to end the current link
+func (terminalState *TerminalState) SetHyperlink(link *Hyperlink) {
+	terminalState.hyperlink = link
+}
+
+// GetLinkAtPosition returns the link which the cell at the given position in the view is part of, or nil if it isn't
+// part of a link
+func (buffer *Buffer) GetLinkAtPosition(col uint16, viewRow uint16) *Hyperlink {
+	row := buffer.convertViewLineToRawLine(viewRow) - uint64(buffer.terminalState.scrollLinesFromBottom)
+
+	cell := buffer.GetRawCell(col, row)
+	if cell == nil {
+		return nil
+	}
+	return cell.link
+}
@@ -5,8 +5,11 @@ import (
 )
 
 type Line struct {
-	wrapped bool // whether line was wrapped onto from the previous one
-	cells   []Cell
+	wrapped     bool // whether line was wrapped onto from the previous one
+	cells       []Cell
+	marks       LineMark    // shell integration marks received on this line
+	promptState PromptState // state of the command whose prompt starts on this line
+	exitCode    int         // exit status of the command whose prompt starts on this line
 }
 
 func newLine() Line {
@@ -41,6 +44,16 @@ func (line *Line) Cleanse() {
 	line.cells = line.cells[:len(line.cells)-cut]
 }
 
+// breakWide replaces a double width character which straddles the boundary before cell n with blanks, so that
+// neither half of it is left when the cells on one side of the boundary are changed
+func (line *Line) breakWide(n int) {
+	if n <= 0 || n >= len(line.cells) || line.cells[n].wide != wideSpacer {
+		return
+	}
+	line.cells[n-1].setRune(0)
+	line.cells[n].setRune(0)
+}
+
 func (line *Line) setWrapped(wrapped bool) {
 	line.wrapped = wrapped
 }
@@ -48,7 +61,11 @@ func (line *Line) setWrapped(wrapped bool) {
 func (line *Line) String() string {
 	runes := []rune{}
 	for _, cell := range line.cells {
+		if cell.wide == wideSpacer {
+			continue
+		}
 		runes = append(runes, cell.r)
+		runes = append(runes, cell.combining...)
 	}
 	return strings.TrimRight(string(runes), "\x00 ")
 }
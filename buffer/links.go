@@ -0,0 +1,72 @@
+package buffer
+
+import (
+	"sort"
+	"strings"
+)
+
+// Link is a URL displayed on screen, at the view cell where it starts
+type Link struct {
+	Col uint16
+	Row uint16
+	URL string
+}
+
+// GetVisibleLinks returns the URLs displayed on screen, in reading order. Text the application has marked as a link
+// with OSC 8 is found along with its target, whatever the text says. Otherwise, unlike GetURLAtPosition, only text with
+// a scheme followed by :// is taken to be a URL, so that ordinary words containing a colon aren't picked up.
+func (buffer *Buffer) GetVisibleLinks() []Link {
+	links := []Link{}
+
+	for viewRow := uint16(0); viewRow < buffer.ViewHeight(); viewRow++ {
+		row := buffer.convertViewLineToRawLine(viewRow) - uint64(buffer.terminalState.scrollLinesFromBottom)
+		if int(row) >= len(buffer.lines) {
+			continue
+		}
+		cells := buffer.lines[row].cells
+		rowLinks := []Link{}
+
+		// a link which carries on from the row above is only found where it starts
+		var previous *Hyperlink
+		if row > 0 && buffer.lines[row].wrapped {
+			if above := buffer.lines[row-1].cells; len(above) > 0 {
+				previous = above[len(above)-1].link
+			}
+		}
+		for col := range cells {
+			link := cells[col].link
+			if link != nil && !SameLink(link, previous) {
+				rowLinks = append(rowLinks, Link{Col: uint16(col), Row: viewRow, URL: link.URI})
+			}
+			previous = link
+		}
+
+		for col := 0; col < len(cells); col++ {
+			if isRuneURLSelectionMarker(cells[col].Rune()) {
+				continue
+			}
+			start := col
+			for col < len(cells) && !isRuneURLSelectionMarker(cells[col].Rune()) {
+				col++
+			}
+
+			runes := make([]rune, col-start)
+			for i := range runes {
+				runes[i] = cells[start+i].Rune()
+			}
+			// brackets and punctuation around a URL in a sentence aren't part of it
+			text := string(runes)
+			candidate := strings.TrimLeft(text, "(<")
+			offset := len([]rune(text)) - len([]rune(candidate))
+			candidate = strings.TrimRight(candidate, ".,;:!?)>")
+			if strings.Contains(candidate, "://") && isURL(candidate) && cells[start+offset].link == nil {
+				rowLinks = append(rowLinks, Link{Col: uint16(start + offset), Row: viewRow, URL: candidate})
+			}
+		}
+
+		sort.Slice(rowLinks, func(i, j int) bool { return rowLinks[i].Col < rowLinks[j].Col })
+		links = append(links, rowLinks...)
+	}
+
+	return links
+}
@@ -0,0 +1,52 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetVisibleLinks(t *testing.T) {
+	b := NewBuffer(NewTerminalState(60, 3, CellAttributes{}, 1000))
+	b.terminalState.LineFeedMode = false
+
+	b.Write([]rune("see https://example.com/a?b=c. or note: this")...)
+	b.NewLine()
+	b.Write([]rune("(http://localhost:8080) and ftp://files.example.org")...)
+
+	assert.Equal(t, []Link{
+		{Col: 4, Row: 0, URL: "https://example.com/a?b=c"},
+		{Col: 1, Row: 1, URL: "http://localhost:8080"},
+		{Col: 28, Row: 1, URL: "ftp://files.example.org"},
+	}, b.GetVisibleLinks())
+}
+
+func TestGetVisibleHyperlinks(t *testing.T) {
+	b := NewBuffer(NewTerminalState(10, 3, CellAttributes{}, 1000))
+	b.terminalState.LineFeedMode = false
+
+	b.Write([]rune("a ")...)
+	b.terminalState.SetHyperlink(&Hyperlink{URI: "https://example.com/docs"})
+	b.Write([]rune("docs")...)
+	b.terminalState.SetHyperlink(nil)
+	b.Write([]rune(" ")...)
+	b.terminalState.SetHyperlink(&Hyperlink{URI: "file:///tmp/x"})
+	b.Write([]rune("http://wrapped")...)
+	b.terminalState.SetHyperlink(nil)
+
+	assert.Equal(t, []Link{
+		{Col: 2, Row: 0, URL: "https://example.com/docs"},
+		{Col: 7, Row: 0, URL: "file:///tmp/x"},
+	}, b.GetVisibleLinks(), "links should be found where they start, with their targets rather than their text")
+}
+
+func TestSameLink(t *testing.T) {
+	docs := &Hyperlink{URI: "https://example.com/docs"}
+	assert.True(t, SameLink(docs, docs))
+	assert.False(t, SameLink(docs, &Hyperlink{URI: "https://example.com/docs"}))
+	assert.False(t, SameLink(docs, nil))
+
+	// text with the same id is one link, even if it was written by separate OSC 8 sequences
+	assert.True(t, SameLink(&Hyperlink{ID: "1", URI: "https://example.com"}, &Hyperlink{ID: "1", URI: "https://example.com"}))
+	assert.False(t, SameLink(&Hyperlink{ID: "1", URI: "https://example.com"}, &Hyperlink{ID: "2", URI: "https://example.com"}))
+}
@@ -0,0 +1,234 @@
+package buffer
+
+import (
+	"strings"
+)
+
+// LineMark is a set of shell integration (OSC 133) marks received while the cursor was on a line
+type LineMark uint8
+
+const (
+	MarkPromptStart  LineMark = 1 << iota // OSC 133 ; A - the shell started drawing a prompt
+	MarkCommandStart                      // OSC 133 ; B - the prompt ended and command input began
+	MarkOutputStart                       // OSC 133 ; C - the command was submitted and output began
+	MarkCommandEnd                        // OSC 133 ; D - the command finished
+)
+
+// PromptState describes the progress of the command whose prompt starts on a line
+type PromptState uint8
+
+const (
+	PromptStateNone    PromptState = iota // the line does not start a prompt
+	PromptStateInput                      // the prompt is displayed and a command is being entered
+	PromptStateRunning                    // the command is executing
+	PromptStateSuccess                    // the command exited with status 0
+	PromptStateFailure                    // the command exited with a non-zero status
+)
+
+func (line *Line) Marks() LineMark {
+	return line.marks
+}
+
+func (line *Line) HasMark(mark LineMark) bool {
+	return line.marks&mark != 0
+}
+
+func (line *Line) PromptState() PromptState {
+	return line.promptState
+}
+
+// ExitCode returns the exit status reported for the command whose prompt starts on this line
+func (line *Line) ExitCode() int {
+	return line.exitCode
+}
+
+// findPromptLine returns the raw index of the closest line at or above the cursor which starts a prompt
+func (buffer *Buffer) findPromptLine() (uint64, bool) {
+	for i := int(buffer.RawLine()); i >= 0 && i < len(buffer.lines); i-- {
+		if buffer.lines[i].HasMark(MarkPromptStart) {
+			return uint64(i), true
+		}
+	}
+	return 0, false
+}
+
+func (buffer *Buffer) markCurrentLine(mark LineMark) *Line {
+	defer buffer.emitRowChange(buffer.terminalState.cursorY)
+	line := buffer.getCurrentLine()
+	line.marks |= mark
+	return line
+}
+
+// MarkPromptStart records that a new prompt is being drawn on the cursor line
+func (buffer *Buffer) MarkPromptStart() {
+	line := buffer.markCurrentLine(MarkPromptStart)
+	line.promptState = PromptStateInput
+	line.exitCode = 0
+}
+
+// MarkCommandStart records that the prompt has ended and command input starts at the cursor
+func (buffer *Buffer) MarkCommandStart() {
+	buffer.markCurrentLine(MarkCommandStart)
+}
+
+// MarkOutputStart records that the current command has been submitted and its output starts at the cursor
+func (buffer *Buffer) MarkOutputStart() {
+	buffer.markCurrentLine(MarkOutputStart)
+	if index, ok := buffer.findPromptLine(); ok {
+		buffer.lines[index].promptState = PromptStateRunning
+	}
+}
+
+// MarkCommandEnd records that the current command has finished with the given exit status
+func (buffer *Buffer) MarkCommandEnd(exitCode int) {
+	buffer.markCurrentLine(MarkCommandEnd)
+	index, ok := buffer.findPromptLine()
+	if !ok {
+		return
+	}
+	prompt := &buffer.lines[index]
+	if prompt.promptState != PromptStateRunning {
+		// no command was run from this prompt, e.g. an empty line was entered
+		return
+	}
+	prompt.exitCode = exitCode
+	if exitCode == 0 {
+		prompt.promptState = PromptStateSuccess
+	} else {
+		prompt.promptState = PromptStateFailure
+	}
+}
+
+// GetLastCommandOutput returns the output of the most recently finished command. The output of a command runs from
+// the line where it started (OSC 133 ; C) to the line before the one where it finished (OSC 133 ; D). If the shell
+// doesn't send these marks, the output is taken to be the lines between the current prompt and the previous line
+// starting with the same prompt text.
+func (buffer *Buffer) GetLastCommandOutput() (string, bool) {
+	if len(buffer.lines) == 0 {
+		return "", false
+	}
+	cursor := int(buffer.RawLine())
+	if cursor >= len(buffer.lines) {
+		cursor = len(buffer.lines) - 1
+	}
+
+	end := -1
+	for i := cursor; i >= 0; i-- {
+		line := &buffer.lines[i]
+		if end < 0 && line.HasMark(MarkCommandEnd) {
+			end = i
+		}
+		if end >= 0 && line.HasMark(MarkOutputStart) {
+			return buffer.linesText(i, end), true
+		}
+	}
+
+	// no marks received, so guess where the previous prompt was
+	prompt := promptText(buffer.lineTextBefore(cursor, int(buffer.terminalState.cursorX)))
+	if prompt == "" {
+		return "", false
+	}
+	for i := cursor - 1; i >= 0; i-- {
+		if strings.HasPrefix(buffer.lineTextBefore(i, len(buffer.lines[i].cells)), prompt) {
+			return buffer.linesText(i+1, cursor), true
+		}
+	}
+	return "", false
+}
+
+// commandBlock returns the first and last raw lines of the command whose prompt starts at or above the raw line index:
+// its prompt, input and output, up to the line before the next prompt, or the last line with any text if there isn't
+// a next prompt yet
+func (buffer *Buffer) commandBlock(index int) (int, int, bool) {
+	if index < 0 || index >= len(buffer.lines) {
+		return 0, 0, false
+	}
+
+	start := index
+	for !buffer.lines[start].HasMark(MarkPromptStart) {
+		if start == 0 {
+			return 0, 0, false
+		}
+		start--
+	}
+
+	end := len(buffer.lines) - 1
+	for i := index + 1; i < len(buffer.lines); i++ {
+		if buffer.lines[i].HasMark(MarkPromptStart) {
+			end = i - 1
+			break
+		}
+	}
+	for end > start && strings.TrimSpace(buffer.lineTextBefore(end, len(buffer.lines[end].cells))) == "" {
+		end--
+	}
+	return start, end, true
+}
+
+// SelectCommand selects the whole of the command shown on viewRow, from its prompt to the end of its output, returning
+// false if the row isn't part of a command marked by the shell. The selection extends into the scrollback if the
+// command doesn't fit on the screen.
+func (buffer *Buffer) SelectCommand(viewRow uint16) bool {
+	row := int(buffer.convertViewLineToRawLine(viewRow)) - int(buffer.terminalState.scrollLinesFromBottom)
+	start, end, ok := buffer.commandBlock(row)
+	if !ok {
+		return false
+	}
+
+	buffer.selectionMode = SelectionLine
+	buffer.selectionStart = &Position{Col: 0, Line: start}
+	buffer.selectionEnd = &Position{Col: int(buffer.ViewWidth()) - 1, Line: end}
+	buffer.isSelectionComplete = true
+
+	buffer.emitDisplayChange()
+	return true
+}
+
+// promptText returns the part of the text before the cursor which looks like a shell prompt, ending with the first
+// typical prompt character, so that a partly typed command is ignored
+func promptText(text string) string {
+	for i := 0; i+1 < len(text); i++ {
+		if strings.IndexByte("$#%>", text[i]) >= 0 && text[i+1] == ' ' {
+			return text[:i+1]
+		}
+	}
+	return strings.TrimRight(text, " ")
+}
+
+// lineTextBefore returns the text on a raw line to the left of col
+func (buffer *Buffer) lineTextBefore(index int, col int) string {
+	cells := buffer.lines[index].cells
+	if col > len(cells) {
+		col = len(cells)
+	}
+	runes := make([]rune, 0, col)
+	for i := 0; i < col; i++ {
+		switch {
+		case cells[i].IsWideSpacer():
+			// the double width character before it takes up both cells
+		case cells[i].Rune() == 0:
+			runes = append(runes, ' ')
+		default:
+			runes = append(runes, cells[i].Rune())
+		}
+	}
+	return string(runes)
+}
+
+// linesText returns the text of the raw lines from start up to but not including end, joining wrapped lines and
+// dropping trailing blank lines
+func (buffer *Buffer) linesText(start int, end int) string {
+	var builder strings.Builder
+	for i := start; i < end && i < len(buffer.lines); i++ {
+		line := &buffer.lines[i]
+		if i > start && !line.wrapped {
+			builder.WriteString("\n")
+		}
+		text := buffer.lineTextBefore(i, len(line.cells))
+		if i+1 >= end || !buffer.lines[i+1].wrapped {
+			text = strings.TrimRight(text, " ")
+		}
+		builder.WriteString(text)
+	}
+	return strings.TrimRight(builder.String(), "\n")
+}
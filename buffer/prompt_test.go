@@ -0,0 +1,156 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromptStateTransitions(t *testing.T) {
+	b := NewBuffer(NewTerminalState(20, 10, CellAttributes{}, 1000))
+
+	b.MarkPromptStart()
+	b.Write([]rune("$ ")...)
+	b.MarkCommandStart()
+	b.Write([]rune("false")...)
+	b.CarriageReturn()
+	b.NewLine()
+	b.MarkOutputStart()
+
+	assert.Equal(t, PromptStateRunning, b.lines[0].PromptState())
+
+	b.Write([]rune("output")...)
+	b.CarriageReturn()
+	b.NewLine()
+	b.MarkCommandEnd(1)
+
+	assert.Equal(t, PromptStateFailure, b.lines[0].PromptState())
+	assert.Equal(t, 1, b.lines[0].ExitCode())
+	assert.True(t, b.lines[0].HasMark(MarkPromptStart))
+	assert.True(t, b.lines[0].HasMark(MarkCommandStart))
+	assert.True(t, b.lines[1].HasMark(MarkOutputStart))
+	assert.Equal(t, PromptStateNone, b.lines[1].PromptState())
+	assert.True(t, b.lines[2].HasMark(MarkCommandEnd))
+
+	b.MarkPromptStart()
+	b.Write([]rune("$ ")...)
+	b.MarkCommandStart()
+	b.CarriageReturn()
+	b.NewLine()
+	b.MarkCommandEnd(0)
+
+	// nothing was run from the second prompt, so it has no exit status
+	assert.Equal(t, PromptStateInput, b.lines[2].PromptState())
+	assert.Equal(t, PromptStateFailure, b.lines[0].PromptState())
+}
+
+func writeLine(b *Buffer, text string) {
+	b.Write([]rune(text)...)
+	b.CarriageReturn()
+	b.NewLine()
+}
+
+func TestLastCommandOutputFromMarks(t *testing.T) {
+	b := NewBuffer(NewTerminalState(10, 10, CellAttributes{}, 1000))
+
+	b.MarkPromptStart()
+	b.Write([]rune("$ ")...)
+	b.MarkCommandStart()
+	writeLine(b, "ls")
+	b.MarkOutputStart()
+	writeLine(b, "a.txt")
+	writeLine(b, "a-very-long-name.txt")
+	b.MarkCommandEnd(0)
+	b.MarkPromptStart()
+	b.Write([]rune("$ ")...)
+	b.MarkCommandStart()
+
+	output, ok := b.GetLastCommandOutput()
+	assert.True(t, ok)
+	assert.Equal(t, "a.txt\na-very-long-name.txt", output)
+
+	// a command which is still running doesn't replace the output of the last one to finish
+	writeLine(b, "sleep 10")
+	b.MarkOutputStart()
+
+	output, ok = b.GetLastCommandOutput()
+	assert.True(t, ok)
+	assert.Equal(t, "a.txt\na-very-long-name.txt", output)
+}
+
+func TestLastCommandOutputWithoutMarks(t *testing.T) {
+	b := NewBuffer(NewTerminalState(20, 10, CellAttributes{}, 1000))
+
+	writeLine(b, "~ $ echo hi")
+	writeLine(b, "hi")
+	writeLine(b, "")
+	writeLine(b, "~ $ uname")
+	writeLine(b, "Linux")
+	b.Write([]rune("~ $ ech")...)
+
+	output, ok := b.GetLastCommandOutput()
+	assert.True(t, ok)
+	assert.Equal(t, "Linux", output)
+
+	b = NewBuffer(NewTerminalState(20, 10, CellAttributes{}, 1000))
+	writeLine(b, "hello")
+	_, ok = b.GetLastCommandOutput()
+	assert.False(t, ok)
+}
+
+func TestLastCommandOutputWithWideCharacters(t *testing.T) {
+	b := NewBuffer(NewTerminalState(20, 10, CellAttributes{}, 1000))
+
+	writeLine(b, "\u4e16 $ echo hi")
+	writeLine(b, "\u4e16\u754c")
+	b.Write([]rune("\u4e16 $ ")...)
+
+	output, ok := b.GetLastCommandOutput()
+	assert.True(t, ok)
+	assert.Equal(t, "\u4e16\u754c", output)
+}
+
+func TestLastCommandOutputOfEmptyBuffer(t *testing.T) {
+	b := NewBuffer(NewTerminalState(20, 10, CellAttributes{}, 1000))
+
+	output, ok := b.GetLastCommandOutput()
+	assert.False(t, ok)
+	assert.Equal(t, "", output)
+}
+
+func TestSelectCommand(t *testing.T) {
+	b := NewBuffer(NewTerminalState(10, 3, CellAttributes{}, 1000))
+	b.terminalState.LineFeedMode = false
+
+	b.MarkPromptStart()
+	b.Write([]rune("$ ")...)
+	b.MarkCommandStart()
+	writeLine(b, "ls")
+	b.MarkOutputStart()
+	writeLine(b, "a.txt")
+	writeLine(b, "b.txt")
+	writeLine(b, "")
+	b.MarkCommandEnd(0)
+	b.MarkPromptStart()
+	b.Write([]rune("$ ")...)
+	b.MarkCommandStart()
+	writeLine(b, "pwd")
+	b.MarkOutputStart()
+	b.Write([]rune("/home")...)
+
+	// only the trailing blank line of the first command is still on the screen
+	assert.True(t, b.SelectCommand(0))
+	assert.Equal(t, "$ ls\na.txt\nb.txt", b.GetSelectedText())
+
+	b.terminalState.SetScrollOffset(3)
+	assert.True(t, b.SelectCommand(1))
+	assert.Equal(t, "$ ls\na.txt\nb.txt", b.GetSelectedText())
+
+	b.terminalState.SetScrollOffset(0)
+	assert.True(t, b.SelectCommand(2))
+	assert.Equal(t, "$ pwd\n/home", b.GetSelectedText())
+
+	b.ClearSelection()
+	b.lines[0].marks = 0
+	assert.False(t, b.SelectCommand(0))
+}
@@ -0,0 +1,63 @@
+package buffer
+
+import "unsafe"
+
+var (
+	lineSize = uint64(unsafe.Sizeof(Line{}))
+	cellSize = uint64(unsafe.Sizeof(Cell{}))
+)
+
+// lineBytes returns roughly how much memory a line uses: the line itself, and its cells with their runes and
+// attributes. Combining characters and images are rare enough to be left out.
+func lineBytes(line *Line) uint64 {
+	return lineSize + uint64(len(line.cells))*cellSize
+}
+
+// scrollbackLines returns the number of lines above the screen
+func (buffer *Buffer) scrollbackLines() int {
+	if n := len(buffer.lines) - int(buffer.ViewHeight()); n > 0 {
+		return n
+	}
+	return 0
+}
+
+// ScrollbackUsage returns the number of lines in the scrollback, and roughly how many bytes of memory they use
+func (buffer *Buffer) ScrollbackUsage() (int, uint64) {
+	return buffer.scrollbackLines(), buffer.scrollbackBytes
+}
+
+// countScrollback works out the memory used by the scrollback again after lines have been moved into or out of it
+// other than by scrolling, which keeps count as it goes, and discards lines over the limits
+func (buffer *Buffer) countScrollback() {
+	buffer.scrollbackBytes = 0
+	for i := 0; i < buffer.scrollbackLines(); i++ {
+		buffer.scrollbackBytes += lineBytes(&buffer.lines[i])
+	}
+	buffer.trimScrollback()
+}
+
+// trimScrollback discards the oldest lines of the scrollback until there are no more lines than max_lines, and the
+// scrollback uses no more memory than max_scrollback_bytes, returning the number of lines discarded. The lines on
+// screen are always kept.
+func (buffer *Buffer) trimScrollback() int {
+	maxLines := int(buffer.getMaxLines())
+	maxBytes := buffer.terminalState.maxScrollbackBytes
+	scrollback := buffer.scrollbackLines()
+
+	discard := 0
+	for discard < scrollback && (len(buffer.lines)-discard > maxLines || maxBytes > 0 && buffer.scrollbackBytes > maxBytes) {
+		buffer.scrollbackBytes -= lineBytes(&buffer.lines[discard])
+		discard++
+	}
+	if discard > 0 {
+		buffer.lines = buffer.lines[:copy(buffer.lines, buffer.lines[discard:])]
+		buffer.discarded += uint64(discard)
+	}
+	return discard
+}
+
+// Discarded returns the number of lines discarded from the top of the buffer so far, which every line below them moved
+// up by. Line numbers kept from before can be corrected by the difference.
+func (buffer *Buffer) Discarded() uint64 {
+	return buffer.discarded
+}
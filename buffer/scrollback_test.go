@@ -0,0 +1,134 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeLines(b *Buffer, count int) {
+	for i := 0; i < count; i++ {
+		b.Write('x')
+		b.CarriageReturn()
+		b.NewLine()
+	}
+}
+
+func TestScrollbackUsage(t *testing.T) {
+	b := NewBuffer(NewTerminalState(10, 3, CellAttributes{}, 1000))
+	b.terminalState.LineFeedMode = false
+
+	writeLines(b, 2)
+	lines, bytes := b.ScrollbackUsage()
+	assert.Equal(t, 0, lines)
+	assert.Equal(t, uint64(0), bytes)
+
+	writeLines(b, 5)
+	lines, bytes = b.ScrollbackUsage()
+	assert.Equal(t, 5, lines)
+	assert.Equal(t, 5*(lineSize+1*cellSize), bytes)
+}
+
+func TestScrollbackLimitedByBytes(t *testing.T) {
+	b := NewBuffer(NewTerminalState(10, 3, CellAttributes{}, 1000))
+	b.terminalState.LineFeedMode = false
+	b.terminalState.SetMaxScrollbackBytes(4 * (lineSize + cellSize))
+
+	writeLines(b, 20)
+	lines, bytes := b.ScrollbackUsage()
+	assert.Equal(t, 4, lines)
+	assert.True(t, bytes <= 4*(lineSize+cellSize))
+	assert.Equal(t, 7, len(b.lines))
+}
+
+func TestScrollbackLimitedByLines(t *testing.T) {
+	b := NewBuffer(NewTerminalState(10, 3, CellAttributes{}, 5))
+	b.terminalState.LineFeedMode = false
+
+	writeLines(b, 20)
+	lines, bytes := b.ScrollbackUsage()
+	assert.Equal(t, 2, lines)
+	assert.Equal(t, 2*(lineSize+cellSize), bytes)
+}
+
+func TestNoScrollback(t *testing.T) {
+	b := NewBuffer(NewTerminalState(10, 3, CellAttributes{}, 0))
+	b.terminalState.LineFeedMode = false
+
+	writeLines(b, 20)
+	lines, bytes := b.ScrollbackUsage()
+	assert.Equal(t, 0, lines)
+	assert.Equal(t, uint64(0), bytes)
+	assert.Equal(t, 3, len(b.lines))
+}
+
+func TestScrollbackCountedAfterResize(t *testing.T) {
+	b := NewBuffer(NewTerminalState(10, 6, CellAttributes{}, 1000))
+	b.terminalState.LineFeedMode = false
+
+	writeLines(b, 10)
+	b.ResizeView(10, 3)
+	lines, bytes := b.ScrollbackUsage()
+	assert.Equal(t, len(b.lines)-3, lines)
+	var expected uint64
+	for i := 0; i < lines; i++ {
+		expected += lineBytes(&b.lines[i])
+	}
+	assert.Equal(t, expected, bytes)
+}
+
+func TestScrollbackCountedAfterClear(t *testing.T) {
+	b := NewBuffer(NewTerminalState(10, 3, CellAttributes{}, 4))
+	b.terminalState.LineFeedMode = false
+
+	writeLines(b, 10)
+	b.Clear()
+	writeLines(b, 3)
+
+	lines, bytes := b.ScrollbackUsage()
+	assert.Equal(t, 1, lines)
+	assert.Equal(t, lineBytes(&b.lines[0]), bytes)
+}
+
+func TestDiscardedLinesCounted(t *testing.T) {
+	b := NewBuffer(NewTerminalState(10, 3, CellAttributes{}, 5))
+	b.terminalState.LineFeedMode = false
+
+	writeLines(b, 2)
+	assert.Equal(t, uint64(0), b.Discarded())
+
+	// the 21 lines written and the blank one the cursor is on are more than the 5 kept
+	writeLines(b, 19)
+	assert.Equal(t, 5, len(b.lines))
+	assert.Equal(t, uint64(17), b.Discarded())
+}
+
+func TestScrollbackCountedAfterInsertingAndDeletingLines(t *testing.T) {
+	b := NewBuffer(NewTerminalState(10, 3, CellAttributes{}, 6))
+	b.terminalState.LineFeedMode = false
+
+	counted := func() uint64 {
+		var bytes uint64
+		for i := 0; i < b.scrollbackLines(); i++ {
+			bytes += lineBytes(&b.lines[i])
+		}
+		return bytes
+	}
+
+	writeLines(b, 2)
+	b.Write([]rune("longer line")...)
+	b.SetPosition(0, 1)
+	b.InsertLines(2)
+	_, bytes := b.ScrollbackUsage()
+	assert.Equal(t, counted(), bytes)
+
+	// inserting beyond max_lines discards the oldest lines
+	b.InsertLines(4)
+	assert.Equal(t, 6, len(b.lines))
+	_, bytes = b.ScrollbackUsage()
+	assert.Equal(t, counted(), bytes)
+
+	b.DeleteLines(2)
+	_, bytes = b.ScrollbackUsage()
+	assert.Equal(t, counted(), bytes)
+}
@@ -0,0 +1,121 @@
+package buffer
+
+import "unicode"
+
+// A Match is text found by Find, from the cell at Start to the cell at End inclusive. Lines are counted from the top of
+// the scrollback, as they are in Position.
+type Match struct {
+	Start Position
+	End   Position
+}
+
+// searchChar is a character of a line being searched, and the cell it's in
+type searchChar struct {
+	r   rune
+	pos Position
+}
+
+// Find returns every occurrence of query in the buffer, including the scrollback, from top to bottom. Lines which wrap
+// are searched as one, so that text which is split across rows is found. Unless caseSensitive is set, letters match
+// regardless of case.
+func (buffer *Buffer) Find(query string, caseSensitive bool) []Match {
+	needle := []rune(query)
+	if len(needle) == 0 {
+		return nil
+	}
+	if !caseSensitive {
+		for i := range needle {
+			needle[i] = unicode.ToLower(needle[i])
+		}
+	}
+
+	matches := []Match{}
+	for start := 0; start < len(buffer.lines); {
+		end := start + 1
+		for end < len(buffer.lines) && buffer.lines[end].wrapped {
+			end++
+		}
+
+		chars := buffer.searchChars(start, end, caseSensitive)
+		for i := 0; i+len(needle) <= len(chars); i++ {
+			found := true
+			for j := range needle {
+				if chars[i+j].r != needle[j] {
+					found = false
+					break
+				}
+			}
+			if found {
+				matches = append(matches, Match{Start: chars[i].pos, End: chars[i+len(needle)-1].pos})
+				i += len(needle) - 1
+			}
+		}
+
+		start = end
+	}
+
+	return matches
+}
+
+// searchChars returns the characters of the rows from start up to but not including end. Combining characters are
+// included along with the character they're attached to, and empty cells are spaces.
+func (buffer *Buffer) searchChars(start int, end int, caseSensitive bool) []searchChar {
+	chars := []searchChar{}
+	add := func(r rune, pos Position) {
+		if !caseSensitive {
+			r = unicode.ToLower(r)
+		}
+		chars = append(chars, searchChar{r: r, pos: pos})
+	}
+
+	for row := start; row < end; row++ {
+		cells := buffer.lines[row].cells
+		if len(cells) > int(buffer.ViewWidth()) {
+			cells = cells[:buffer.ViewWidth()]
+		}
+		for col := range cells {
+			cell := &cells[col]
+			if cell.IsWideSpacer() {
+				continue
+			}
+			pos := Position{Col: col, Line: row}
+			if cell.r == 0 {
+				add(' ', pos)
+				continue
+			}
+			add(cell.r, pos)
+			for _, r := range cell.combining {
+				add(r, pos)
+			}
+		}
+	}
+	return chars
+}
+
+// ViewRow returns the row of the view which shows line, or false if it isn't visible
+func (buffer *Buffer) ViewRow(line int) (uint16, bool) {
+	top := int(buffer.convertViewLineToRawLine(0)) - int(buffer.terminalState.scrollLinesFromBottom)
+	row := line - top
+	if row < 0 || row >= int(buffer.ViewHeight()) {
+		return 0, false
+	}
+	return uint16(row), true
+}
+
+// ScrollToLine scrolls the view so that line is in the middle of it, or as close as the scrollback allows, unless it's
+// already visible
+func (buffer *Buffer) ScrollToLine(line int) {
+	if _, ok := buffer.ViewRow(line); ok {
+		return
+	}
+
+	height := int(buffer.ViewHeight())
+	offset := len(buffer.lines) - line - height/2 - 1
+	if max := len(buffer.lines) - height; offset > max {
+		offset = max
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	buffer.terminalState.SetScrollOffset(uint(offset))
+}
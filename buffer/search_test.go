@@ -0,0 +1,68 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFind(t *testing.T) {
+	b := NewBuffer(NewTerminalState(10, 3, CellAttributes{}, 1000))
+	b.terminalState.LineFeedMode = false
+
+	for _, line := range []string{"error one", "warning", "ERROR two", "an error"} {
+		b.Write([]rune(line)...)
+		b.CarriageReturn()
+		b.NewLine()
+	}
+
+	matches := b.Find("error", false)
+	require.Equal(t, 3, len(matches))
+	assert.Equal(t, Match{Start: Position{Col: 0, Line: 0}, End: Position{Col: 4, Line: 0}}, matches[0])
+	assert.Equal(t, Position{Col: 0, Line: 2}, matches[1].Start)
+	assert.Equal(t, Position{Col: 3, Line: 3}, matches[2].Start)
+
+	assert.Equal(t, 2, len(b.Find("error", true)))
+	assert.Empty(t, b.Find("missing", false))
+	assert.Empty(t, b.Find("", false))
+}
+
+func TestFindAcrossWrappedRows(t *testing.T) {
+	b := NewBuffer(NewTerminalState(5, 3, CellAttributes{}, 1000))
+	b.terminalState.LineFeedMode = false
+
+	b.Write([]rune("abc世界def")...)
+
+	matches := b.Find("界d", false)
+	require.Equal(t, 1, len(matches))
+	assert.Equal(t, Match{Start: Position{Col: 0, Line: 1}, End: Position{Col: 2, Line: 1}}, matches[0])
+
+	matches = b.Find("c世", false)
+	require.Equal(t, 1, len(matches))
+	assert.Equal(t, Match{Start: Position{Col: 2, Line: 0}, End: Position{Col: 3, Line: 0}}, matches[0])
+}
+
+func TestScrollToLine(t *testing.T) {
+	b := NewBuffer(NewTerminalState(10, 3, CellAttributes{}, 1000))
+	b.terminalState.LineFeedMode = false
+
+	for i := 0; i < 10; i++ {
+		b.Write('x')
+		b.CarriageReturn()
+		b.NewLine()
+	}
+
+	_, visible := b.ViewRow(1)
+	assert.False(t, visible)
+
+	b.ScrollToLine(1)
+	row, visible := b.ViewRow(1)
+	assert.True(t, visible)
+	assert.Equal(t, uint16(1), row)
+
+	// lines which are already visible don't scroll the view
+	b.ScrollToLine(2)
+	row, _ = b.ViewRow(1)
+	assert.Equal(t, uint16(1), row)
+}
@@ -0,0 +1,75 @@
+package buffer
+
+import (
+	"strings"
+)
+
+// selectedBlock returns the text of each cell in each row of a block selection, with its combining characters, and with
+// cells which were never written to as spaces. The spacer after a double width character is empty, so each row keeps one entry for every column.
+func (buffer *Buffer) selectedBlock() [][]string {
+	start, end := buffer.getActualSelection()
+	if start == nil || end == nil || buffer.selectionMode != SelectionBlock {
+		return nil
+	}
+
+	rows := [][]string{}
+	for row := start.Line; row <= end.Line && row < len(buffer.lines); row++ {
+		cells := buffer.lines[row].cells
+		text := make([]string, 0, end.Col-start.Col+1)
+		for col := start.Col; col <= end.Col; col++ {
+			switch {
+			case col < len(cells) && cells[col].IsWideSpacer():
+				text = append(text, "")
+			case col >= len(cells) || cells[col].Rune() == 0:
+				text = append(text, " ")
+			default:
+				text = append(text, string(cells[col].Rune())+string(cells[col].Combining()))
+			}
+		}
+		rows = append(rows, text)
+	}
+	return rows
+}
+
+// GetSelectedTable returns a block selection as tab separated values, for pasting tabular output into a spreadsheet.
+// The boundaries between columns are runs of cells which are blank on every row of the selection.
+func (buffer *Buffer) GetSelectedTable() string {
+	rows := buffer.selectedBlock()
+	if len(rows) == 0 {
+		return ""
+	}
+
+	gap := make([]bool, len(rows[0]))
+	for col := range gap {
+		gap[col] = true
+		for _, row := range rows {
+			if row[col] != " " {
+				gap[col] = false
+				break
+			}
+		}
+	}
+
+	lines := make([]string, len(rows))
+	for i, row := range rows {
+		fields := []string{}
+		field := []string{}
+		for col, text := range row {
+			if !gap[col] {
+				field = append(field, text)
+				continue
+			}
+			if len(field) > 0 {
+				fields = append(fields, strings.TrimSpace(strings.Join(field, "")))
+				field = field[:0]
+			}
+		}
+		if len(field) > 0 {
+			fields = append(fields, strings.TrimSpace(strings.Join(field, "")))
+		}
+		// empty fields are kept so values stay in their columns, except at the end of a row
+		lines[i] = strings.TrimRight(strings.Join(fields, "\t"), "\t")
+	}
+
+	return strings.Join(lines, "\n")
+}
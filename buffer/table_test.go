@@ -0,0 +1,81 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeBufferForTestingTable() *Buffer {
+	b := NewBuffer(NewTerminalState(80, 10, CellAttributes{}, 10))
+	b.terminalState.LineFeedMode = false
+
+	for _, line := range []string{
+		"$ ps",
+		"  PID TTY          TIME CMD",
+		" 4012 pts/1    00:00:00 bash",
+		"31337 pts/1    00:00:12 vim",
+	} {
+		b.Write([]rune(line)...)
+		b.NewLine()
+	}
+
+	return b
+}
+
+func TestSelectingBlock(t *testing.T) {
+	b := makeBufferForTestingSelection()
+
+	b.StartSelection(6, 2, SelectionBlock)
+	b.ExtendSelection(4, 0, true)
+
+	assert.Equal(t, "qui\njum\nlaz", b.GetSelectedText())
+	assert.True(t, b.InSelection(5, 1))
+	assert.False(t, b.InSelection(7, 1))
+	assert.False(t, b.InSelection(3, 0))
+}
+
+func TestSelectedTable(t *testing.T) {
+	b := makeBufferForTestingTable()
+
+	b.StartSelection(0, 1, SelectionBlock)
+	b.ExtendSelection(40, 3, true)
+
+	assert.Equal(t, "PID\tTTY\tTIME\tCMD\n4012\tpts/1\t00:00:00\tbash\n31337\tpts/1\t00:00:12\tvim", b.GetSelectedTable())
+
+	// plain copying keeps the spacing
+	assert.Equal(t, "  PID TTY          TIME CMD\n 4012 pts/1    00:00:00 bash\n31337 pts/1    00:00:12 vim", b.GetSelectedText())
+}
+
+func TestSelectedBlockWithWideCharacters(t *testing.T) {
+	b := NewBuffer(NewTerminalState(80, 10, CellAttributes{}, 10))
+	b.terminalState.LineFeedMode = false
+	b.Write([]rune("name  \u4e16\u754c  x")...)
+
+	b.StartSelection(0, 0, SelectionBlock)
+	b.ExtendSelection(12, 0, true)
+
+	assert.Equal(t, "name  \u4e16\u754c  x", b.GetSelectedText())
+	assert.Equal(t, "name\t\u4e16\u754c\tx", b.GetSelectedTable())
+}
+
+func TestSelectedBlockWithCombiningCharacters(t *testing.T) {
+	b := NewBuffer(NewTerminalState(80, 10, CellAttributes{}, 10))
+	b.terminalState.LineFeedMode = false
+	b.Write([]rune("cafe\u0301  na\u0303o")...)
+
+	b.StartSelection(0, 0, SelectionBlock)
+	b.ExtendSelection(8, 0, true)
+
+	assert.Equal(t, "cafe\u0301  na\u0303o", b.GetSelectedText())
+	assert.Equal(t, "cafe\u0301\tna\u0303o", b.GetSelectedTable())
+}
+
+func TestSelectedTableRequiresBlockSelection(t *testing.T) {
+	b := makeBufferForTestingTable()
+
+	b.StartSelection(0, 1, SelectionChar)
+	b.ExtendSelection(40, 3, true)
+
+	assert.Equal(t, "", b.GetSelectedTable())
+}
@@ -1,39 +1,52 @@
 package buffer
 
+import "github.com/liamg/aminal/words"
+
 type TerminalState struct {
 	scrollLinesFromBottom uint
 	cursorX               uint16
 	cursorY               uint16
 	CursorAttr            CellAttributes
+	defaultAttr           CellAttributes // attributes of cells which have never been written to
 	viewHeight            uint16
 	viewWidth             uint16
 	topMargin             uint // see DECSTBM docs - this is for scrollable regions
 	bottomMargin          uint // see DECSTBM docs - this is for scrollable regions
+	leftMargin            uint // see DECSLRM docs - only in effect when LeftRightMarginMode is enabled
+	rightMargin           uint // see DECSLRM docs - only in effect when LeftRightMarginMode is enabled
+	marginWrapPending     bool // a character was written at the right margin, so the next one wraps to the left margin
+	LeftRightMarginMode   bool // DECLRMM - whether left and right margins can be set
 	ReplaceMode           bool // overwrite character at cursor or insert new
 	OriginMode            bool // see DECOM docs - whether cursor is positioned within the margins or not
 	LineFeedMode          bool
 	ScreenMode            bool // DECSCNM (black on white background)
 	AutoWrap              bool
 	maxLines              uint64
+	maxScrollbackBytes    uint64 // the most memory the scrollback may use, or 0 for no limit
 	tabStops              map[uint16]struct{}
 	Charsets              []*map[rune]rune // array of 2 charsets, nil means ASCII (no conversion)
 	CurrentCharset        int              // active charset index in Charsets array, valid values are 0 or 1
+	hyperlink             *Hyperlink       // the link which written text is part of (OSC 8), nil if there isn't one
+	WordSeparators        string           // characters which end a word selected by double clicking, as well as whitespace
 }
 
 // NewTerminalMode creates a new terminal state
 func NewTerminalState(viewCols uint16, viewLines uint16, attr CellAttributes, maxLines uint64) *TerminalState {
 	b := &TerminalState{
-		cursorX:      0,
-		cursorY:      0,
-		CursorAttr:   attr,
-		AutoWrap:     true,
-		maxLines:     maxLines,
-		viewWidth:    viewCols,
-		viewHeight:   viewLines,
-		topMargin:    0,
-		bottomMargin: uint(viewLines - 1),
-		Charsets:     []*map[rune]rune{nil, nil},
-		LineFeedMode: true,
+		cursorX:        0,
+		cursorY:        0,
+		CursorAttr:     attr,
+		defaultAttr:    attr,
+		AutoWrap:       true,
+		maxLines:       maxLines,
+		viewWidth:      viewCols,
+		viewHeight:     viewLines,
+		topMargin:      0,
+		bottomMargin:   uint(viewLines - 1),
+		rightMargin:    uint(viewCols - 1),
+		Charsets:       []*map[rune]rune{nil, nil},
+		LineFeedMode:   true,
+		WordSeparators: words.DefaultSeparators,
 	}
 	b.TabReset()
 	return b
@@ -52,6 +65,27 @@ func (terminalState *TerminalState) DefaultCell(applyEffects bool) Cell {
 	return Cell{attr: attr}
 }
 
+// ReplaceColours changes colours from index used by the cursor and by cells which have never been written to
+func (terminalState *TerminalState) ReplaceColours(index ColourIndex, replacements map[[3]float32][3]float32) {
+	terminalState.CursorAttr.replaceColours(index, replacements)
+	terminalState.defaultAttr.replaceColours(index, replacements)
+}
+
+// eraseCell returns a blank cell which takes the current background colour (background colour erase), as used for erased and scrolled in cells
+func (terminalState *TerminalState) eraseCell() Cell {
+	return Cell{attr: CellAttributes{
+		FgColour: terminalState.CursorAttr.FgColour,
+		BgColour: terminalState.CursorAttr.BgColour,
+		FgIndex:  terminalState.CursorAttr.FgIndex,
+		BgIndex:  terminalState.CursorAttr.BgIndex,
+	}}
+}
+
+// hasEraseColour returns true if erased cells look different to cells which have never been written to
+func (terminalState *TerminalState) hasEraseColour() bool {
+	return terminalState.CursorAttr.BgColour != terminalState.defaultAttr.BgColour
+}
+
 func (terminalState *TerminalState) SetVerticalMargins(top uint, bottom uint) {
 	terminalState.topMargin = top
 	terminalState.bottomMargin = bottom
@@ -62,6 +96,16 @@ func (terminalState *TerminalState) ResetVerticalMargins() {
 	terminalState.SetVerticalMargins(0, uint(terminalState.viewHeight-1))
 }
 
+func (terminalState *TerminalState) SetHorizontalMargins(left uint, right uint) {
+	terminalState.leftMargin = left
+	terminalState.rightMargin = right
+}
+
+// ResetHorizontalMargins resets left and right margins to extreme positions
+func (terminalState *TerminalState) ResetHorizontalMargins() {
+	terminalState.SetHorizontalMargins(0, uint(terminalState.viewWidth-1))
+}
+
 func (terminalState *TerminalState) IsNewLineMode() bool {
 	return terminalState.LineFeedMode == false
 }
@@ -119,6 +163,12 @@ func (terminalState *TerminalState) GetScrollOffset() uint {
 	return terminalState.scrollLinesFromBottom
 }
 
+// SetMaxScrollbackBytes limits roughly how much memory the lines scrolled off the top of the screen may use, discarding
+// the oldest beyond it, or removes the limit if max is 0
+func (terminalState *TerminalState) SetMaxScrollbackBytes(max uint64) {
+	terminalState.maxScrollbackBytes = max
+}
+
 func (terminalState *TerminalState) SetScrollOffset(offset uint) {
 	terminalState.scrollLinesFromBottom = offset
 }
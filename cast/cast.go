@@ -0,0 +1,152 @@
+// Package cast reads and writes terminal sessions in the asciinema v2 format: a line of JSON giving the size of the
+// terminal, followed by a line for each chunk of output, [time, "o", data], timed in seconds from the start.
+package cast
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Header is the first line of a cast
+type Header struct {
+	Version   int   `json:"version"`
+	Width     int   `json:"width"`
+	Height    int   `json:"height"`
+	Timestamp int64 `json:"timestamp,omitempty"` // when the recording started, in seconds since the Unix epoch
+}
+
+// Recorder writes the data written to it to a cast as output events, timed from when the recorder was created
+type Recorder struct {
+	writer  io.Writer
+	start   time.Time
+	partial []byte // the start of a UTF-8 character cut off at the end of the last write
+	lock    sync.Mutex
+}
+
+// NewRecorder starts a cast of a terminal width by height cells, writing its header to writer
+func NewRecorder(writer io.Writer, width int, height int) (*Recorder, error) {
+	start := time.Now()
+	header, err := json.Marshal(Header{Version: 2, Width: width, Height: height, Timestamp: start.Unix()})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(append(header, '\n')); err != nil {
+		return nil, err
+	}
+	return &Recorder{writer: writer, start: start}, nil
+}
+
+// Write records data as output. Casts hold text rather than bytes, so a UTF-8 character cut off at the end of data is
+// held back until the rest of it is written.
+func (recorder *Recorder) Write(data []byte) (int, error) {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+
+	n := len(data)
+	data = append(recorder.partial, data...)
+	recorder.partial = nil
+	if cut := partialRuneSuffix(data); cut > 0 {
+		recorder.partial = append([]byte{}, data[len(data)-cut:]...)
+		data = data[:len(data)-cut]
+	}
+	if len(data) == 0 {
+		return n, nil
+	}
+
+	elapsed := math.Round(time.Since(recorder.start).Seconds()*1e6) / 1e6
+	event, err := json.Marshal([]interface{}{elapsed, "o", string(data)})
+	if err != nil {
+		return 0, err
+	}
+	if _, err := recorder.writer.Write(append(event, '\n')); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// partialRuneSuffix returns the number of bytes at the end of data which are the start of a UTF-8 character
+func partialRuneSuffix(data []byte) int {
+	for n := 1; n <= utf8.UTFMax && n <= len(data); n++ {
+		if utf8.RuneStart(data[len(data)-n]) {
+			if utf8.FullRune(data[len(data)-n:]) {
+				return 0
+			}
+			return n
+		}
+	}
+	return 0
+}
+
+// Player reads the output of a cast, returning each chunk of it no sooner than it was recorded after the first read
+type Player struct {
+	Header  Header
+	reader  *bufio.Reader
+	start   time.Time
+	pending []byte // output of the last event which hasn't been read yet
+}
+
+// NewPlayer reads the header of the cast in reader
+func NewPlayer(reader io.Reader) (*Player, error) {
+	player := &Player{reader: bufio.NewReader(reader)}
+
+	line, err := player.reader.ReadBytes('\n')
+	if err != nil && (err != io.EOF || len(line) == 0) {
+		return nil, fmt.Errorf("Failed to read cast header: %s", err)
+	}
+	if err := json.Unmarshal(line, &player.Header); err != nil {
+		return nil, fmt.Errorf("Invalid cast header: %s", err)
+	}
+	if player.Header.Version != 2 {
+		return nil, fmt.Errorf("Unsupported cast version %d. Only asciinema v2 casts can be played", player.Header.Version)
+	}
+	return player, nil
+}
+
+// Read waits for the next output event, and reads its data. It returns io.EOF at the end of the cast.
+func (player *Player) Read(data []byte) (int, error) {
+	if player.start.IsZero() {
+		player.start = time.Now()
+	}
+
+	for len(player.pending) == 0 {
+		line, err := player.reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) == 0 {
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		var event []interface{}
+		if err := json.Unmarshal(line, &event); err != nil {
+			return 0, fmt.Errorf("Invalid cast event: %s", err)
+		}
+		if len(event) != 3 {
+			return 0, fmt.Errorf("Invalid cast event: %s", string(bytes.TrimSpace(line)))
+		}
+		at, isTime := event[0].(float64)
+		kind, isKind := event[1].(string)
+		output, isOutput := event[2].(string)
+		if !isTime || !isKind || !isOutput {
+			return 0, fmt.Errorf("Invalid cast event: %s", string(bytes.TrimSpace(line)))
+		}
+		if kind != "o" {
+			// input and other events don't change what's shown
+			continue
+		}
+
+		time.Sleep(time.Until(player.start.Add(time.Duration(at * float64(time.Second)))))
+		player.pending = []byte(output)
+	}
+
+	n := copy(data, player.pending)
+	player.pending = player.pending[n:]
+	return n, nil
+}
@@ -0,0 +1,83 @@
+package cast
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordingAndPlayingBack(t *testing.T) {
+	cast := &bytes.Buffer{}
+	recorder, err := NewRecorder(cast, 80, 24)
+	require.Nil(t, err)
+
+	_, err = recorder.Write([]byte("hello\r\n"))
+	require.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+	_, err = recorder.Write([]byte("\x1b[1mworld"))
+	require.Nil(t, err)
+
+	lines := strings.Split(strings.TrimSpace(cast.String()), "\n")
+	require.Equal(t, 3, len(lines))
+	assert.Contains(t, lines[0], `"version":2,"width":80,"height":24`)
+	assert.Contains(t, lines[1], `"o","hello\r\n"]`)
+	assert.Contains(t, lines[2], `"o","\u001b[1mworld"]`)
+
+	player, err := NewPlayer(bytes.NewReader(cast.Bytes()))
+	require.Nil(t, err)
+	assert.Equal(t, 80, player.Header.Width)
+	assert.Equal(t, 24, player.Header.Height)
+
+	start := time.Now()
+	output, err := ioutil.ReadAll(player)
+	require.Nil(t, err)
+	assert.Equal(t, "hello\r\n\x1b[1mworld", string(output))
+	assert.True(t, time.Since(start) >= 50*time.Millisecond, "output should be played back with its original timing")
+}
+
+func TestRecordingCharactersSplitAcrossWrites(t *testing.T) {
+	cast := &bytes.Buffer{}
+	recorder, err := NewRecorder(cast, 80, 24)
+	require.Nil(t, err)
+
+	euro := []byte("€")
+	_, err = recorder.Write(append([]byte("a"), euro[:2]...))
+	require.Nil(t, err)
+	_, err = recorder.Write(euro[2:])
+	require.Nil(t, err)
+
+	lines := strings.Split(strings.TrimSpace(cast.String()), "\n")
+	require.Equal(t, 3, len(lines))
+	assert.Contains(t, lines[1], `"o","a"]`)
+	assert.Contains(t, lines[2], `"o","€"]`)
+}
+
+func TestPlayingIgnoresInputEvents(t *testing.T) {
+	player, err := NewPlayer(strings.NewReader(`{"version": 2, "width": 10, "height": 5}
+[0.001, "i", "ls\r"]
+[0.002, "o", "file"]
+`))
+	require.Nil(t, err)
+
+	output, err := ioutil.ReadAll(player)
+	require.Nil(t, err)
+	assert.Equal(t, "file", string(output))
+}
+
+func TestPlayingInvalidCasts(t *testing.T) {
+	_, err := NewPlayer(strings.NewReader(`{"version": 1, "width": 10, "height": 5}`))
+	assert.NotNil(t, err)
+
+	_, err = NewPlayer(strings.NewReader(`not json`))
+	assert.NotNil(t, err)
+
+	player, err := NewPlayer(strings.NewReader("{\"version\": 2, \"width\": 10, \"height\": 5}\n[0, \"o\"]\n"))
+	require.Nil(t, err)
+	_, err = ioutil.ReadAll(player)
+	assert.NotNil(t, err)
+}
@@ -28,6 +28,11 @@ func getConfig() *config.Config {
 	shell := ""
 	debugMode := false
 	slomo := false
+	showControls := false
+	controlSocket := ""
+	shellIntegration := ""
+	record := ""
+	replay := ""
 
 	if flag.Parsed() == false {
 		flag.BoolVar(&showVersion, "version", showVersion, "Output version information")
@@ -35,6 +40,11 @@ func getConfig() *config.Config {
 		flag.StringVar(&shell, "shell", shell, "Specify the shell to use")
 		flag.BoolVar(&debugMode, "debug", debugMode, "Enable debug logging")
 		flag.BoolVar(&slomo, "slomo", slomo, "Render in slow motion (useful for debugging)")
+		flag.BoolVar(&showControls, "show-controls", showControls, "Display control characters as symbols instead of acting on them (useful for debugging)")
+		flag.StringVar(&controlSocket, "control-socket", controlSocket, "Accept commands to drive the terminal on a Unix domain socket at the given path")
+		flag.StringVar(&record, "record", record, "Record the session to an asciinema cast file at the given path")
+		flag.StringVar(&replay, "replay", replay, "Play back an asciinema cast file with its original timing instead of running a shell")
+		flag.StringVar(&shellIntegration, "generate-shell-integration", shellIntegration, "Output a script for bash, zsh or fish which reports prompts and the working directory to the terminal")
 
 		flag.Parse() // actual parsing and fetching flags from the command line
 	}
@@ -49,6 +59,16 @@ func getConfig() *config.Config {
 		os.Exit(0)
 	}
 
+	if shellIntegration != "" {
+		script, err := shellIntegrationScript(shellIntegration)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Print(script)
+		os.Exit(0)
+	}
+
 	var conf *config.Config
 	if ignoreConfig {
 		conf = &config.DefaultConfig
@@ -69,6 +89,22 @@ func getConfig() *config.Config {
 		conf.Slomo = slomo
 	}
 
+	if actuallyProvidedFlags["show-controls"] {
+		conf.ShowControls = showControls
+	}
+
+	if actuallyProvidedFlags["control-socket"] {
+		conf.ControlSocket = controlSocket
+	}
+
+	if actuallyProvidedFlags["record"] {
+		conf.Record = record
+	}
+
+	if actuallyProvidedFlags["replay"] {
+		conf.Replay = replay
+	}
+
 	return conf
 }
 
@@ -3,10 +3,26 @@ package config
 type UserAction string
 
 const (
-	ActionCopy        UserAction = "copy"
-	ActionPaste       UserAction = "paste"
-	ActionSearch      UserAction = "search"
-	ActionReportBug   UserAction = "report"
-	ActionToggleDebug UserAction = "debug"
-	ActionToggleSlomo UserAction = "slomo"
+	ActionCopy             UserAction = "copy"
+	ActionCopyTable        UserAction = "copy_table"
+	ActionCopyOutput       UserAction = "copy_output"
+	ActionPaste            UserAction = "paste"
+	ActionSearch           UserAction = "search"
+	ActionFind             UserAction = "find"
+	ActionReportBug        UserAction = "report"
+	ActionNextFont         UserAction = "next_font"
+	ActionLinkHints        UserAction = "link_hints"
+	ActionChooseShell      UserAction = "choose_shell"
+	ActionToggleDebug      UserAction = "debug"
+	ActionToggleSlomo      UserAction = "slomo"
+	ActionToggleControls   UserAction = "controls"
+	ActionToggleWhitespace UserAction = "whitespace"
+	ActionToggleRecording  UserAction = "record"
+	ActionFontBigger       UserAction = "font_bigger"
+	ActionFontSmaller      UserAction = "font_smaller"
+	ActionFontReset        UserAction = "font_reset"
+	ActionNewTab           UserAction = "new_tab"
+	ActionCloseTab         UserAction = "close_tab"
+	ActionNextTab          UserAction = "next_tab"
+	ActionPreviousTab      UserAction = "previous_tab"
 )
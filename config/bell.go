@@ -0,0 +1,32 @@
+package config
+
+import "fmt"
+
+// BellMode decides how the bell (BEL) is shown
+type BellMode string
+
+const (
+	BellModeNone    BellMode = "none"
+	BellModeVisual  BellMode = "visual"  // briefly flash the window
+	BellModeAudible BellMode = "audible" // play the system's alert sound
+	BellModeBoth    BellMode = "both"
+)
+
+func (mode *BellMode) UnmarshalText(data []byte) error {
+	switch m := BellMode(data); m {
+	case BellModeNone, BellModeVisual, BellModeAudible, BellModeBoth:
+		*mode = m
+		return nil
+	}
+	return fmt.Errorf("Invalid bell mode '%s'. Should be one of none, visual, audible or both", string(data))
+}
+
+// Visual returns true if the window should flash for the bell
+func (mode BellMode) Visual() bool {
+	return mode == BellModeVisual || mode == BellModeBoth
+}
+
+// Audible returns true if a sound should be played for the bell
+func (mode BellMode) Audible() bool {
+	return mode == BellModeAudible || mode == BellModeBoth
+}
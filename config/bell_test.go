@@ -0,0 +1,23 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBellModeParsing(t *testing.T) {
+	c, err := Parse([]byte("[bell]\nmode = \"both\""))
+	require.Nil(t, err)
+	assert.True(t, c.Bell.Mode.Visual())
+	assert.True(t, c.Bell.Mode.Audible())
+
+	c, err = Parse([]byte(``))
+	require.Nil(t, err)
+	assert.Equal(t, BellModeVisual, c.Bell.Mode)
+	assert.False(t, c.Bell.Mode.Audible())
+
+	_, err = Parse([]byte("[bell]\nmode = \"loud\""))
+	assert.NotNil(t, err)
+}
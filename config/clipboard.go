@@ -0,0 +1,21 @@
+package config
+
+import "fmt"
+
+// ClipboardPolicy decides whether applications may read the clipboard via OSC 52
+type ClipboardPolicy string
+
+const (
+	ClipboardPolicyPrompt ClipboardPolicy = "prompt" // ask the user each time
+	ClipboardPolicyAllow  ClipboardPolicy = "allow"
+	ClipboardPolicyDeny   ClipboardPolicy = "deny"
+)
+
+func (policy *ClipboardPolicy) UnmarshalText(data []byte) error {
+	switch p := ClipboardPolicy(data); p {
+	case ClipboardPolicyPrompt, ClipboardPolicyAllow, ClipboardPolicyDeny:
+		*policy = p
+		return nil
+	}
+	return fmt.Errorf("Invalid clipboard policy '%s'. Should be one of prompt, allow or deny", string(data))
+}
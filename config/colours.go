@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"math"
+	"strconv"
 	"strings"
 )
 
@@ -44,6 +45,16 @@ func (c *Colour) UnmarshalText(data []byte) error {
 	return err
 }
 
+// UnmarshalTOML decodes a colour as UnmarshalText does, as the decoder only uses UnmarshalText for struct fields and not
+// for the values of maps such as the palette
+func (c *Colour) UnmarshalTOML(data interface{}) error {
+	str, ok := data.(string)
+	if !ok {
+		return fmt.Errorf("Invalid colour format. Should be like #ffffff")
+	}
+	return c.UnmarshalText([]byte(str))
+}
+
 func (c Colour) MarshalText() (text []byte, err error) {
 	return []byte(fmt.Sprintf(
 		"#%02x%02x%02x",
@@ -74,4 +85,61 @@ type ColourScheme struct {
 	LightCyan    Colour `toml:"light_cyan"`
 	White        Colour `toml:"white"`
 	Selection    Colour `toml:"selection"`
+	CurrentLine  Colour `toml:"current_line"` // drawn faintly over the background of the cursor row if highlight_current_line is enabled
+
+	SelectionOpacity    float32 `toml:"selection_opacity"`    // of the selection colour drawn over the background of selected cells, from 0 to 1
+	SelectionForeground *Colour `toml:"selection_foreground"` // text colour of selected cells, which keep their own colour if this is unset
+
+	Palette map[string]Colour `toml:"palette"` // entries of the 256 colour palette by index, overriding the named colours above
+}
+
+// PaletteColour returns the colour configured for entry n of the 256 colour palette, if there is one
+func (scheme *ColourScheme) PaletteColour(n uint8) (Colour, bool) {
+	c, ok := scheme.Palette[strconv.Itoa(int(n))]
+	return c, ok
+}
+
+func (scheme *ColourScheme) validatePalette() error {
+	for index := range scheme.Palette {
+		if n, err := strconv.Atoi(index); err != nil || n < 0 || n > 255 || strconv.Itoa(n) != index {
+			return fmt.Errorf("Invalid palette index %q. Should be from 0 to 255", index)
+		}
+	}
+	return nil
+}
+
+// SelectionBackground returns the background of a selected cell with the background bg, blending the selection colour
+// over it so that the colours of the selection still show through
+func (scheme *ColourScheme) SelectionBackground(bg [3]float32) [3]float32 {
+	opacity := scheme.SelectionOpacity
+	if opacity <= 0 || opacity > 1 {
+		opacity = 1
+	}
+	return blend(scheme.Selection, bg, opacity)
+}
+
+// currentLineOpacity is low enough for the current line highlight to leave the background colours of cells recognisable
+const currentLineOpacity = 0.15
+
+// CurrentLineBackground returns the background of a cell on the cursor row with the background bg, when the current
+// line is highlighted
+func (scheme *ColourScheme) CurrentLineBackground(bg [3]float32) [3]float32 {
+	return blend(scheme.CurrentLine, bg, currentLineOpacity)
+}
+
+// blend returns colour drawn over bg with the given opacity
+func blend(colour Colour, bg [3]float32, opacity float32) [3]float32 {
+	var blended [3]float32
+	for i := range blended {
+		blended[i] = colour[i]*opacity + bg[i]*(1-opacity)
+	}
+	return blended
+}
+
+// SelectionText returns the text colour of a selected cell with the foreground fg
+func (scheme *ColourScheme) SelectionText(fg [3]float32) [3]float32 {
+	if scheme.SelectionForeground == nil {
+		return fg
+	}
+	return *scheme.SelectionForeground
 }
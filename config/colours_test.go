@@ -33,3 +33,61 @@ func TestColourTomlUnmarshalling(t *testing.T) {
 	assert.InDelta(t, 0.0, target.Purple[1], 0.01)
 	assert.InDelta(t, 1.0, target.Purple[2], 0.01)
 }
+
+func TestSelectionColours(t *testing.T) {
+	scheme := ColourScheme{
+		Selection:        Colour{1, 0.5, 0},
+		SelectionOpacity: 0.25,
+	}
+	fg := [3]float32{0.1, 0.2, 0.3}
+
+	assert.Equal(t, [3]float32{0.25, 0.125, 0.75}, scheme.SelectionBackground([3]float32{0, 0, 1}))
+	assert.Equal(t, fg, scheme.SelectionText(fg))
+
+	scheme.SelectionOpacity = 1
+	white := Colour{1, 1, 1}
+	scheme.SelectionForeground = &white
+	assert.Equal(t, [3]float32{1, 0.5, 0}, scheme.SelectionBackground([3]float32{0, 0, 1}))
+	assert.Equal(t, [3]float32(white), scheme.SelectionText(fg))
+}
+
+func TestCurrentLineBackground(t *testing.T) {
+	scheme := ColourScheme{CurrentLine: Colour{1, 1, 1}}
+
+	bg := scheme.CurrentLineBackground([3]float32{0, 0, 0.5})
+	assert.InDelta(t, currentLineOpacity, bg[0], 0.0001)
+	assert.InDelta(t, 0.5+currentLineOpacity/2, bg[2], 0.0001)
+	assert.Equal(t, [3]float32{1, 1, 1}, scheme.CurrentLineBackground([3]float32{1, 1, 1}))
+}
+
+func TestParseSelectionForeground(t *testing.T) {
+	c, err := Parse([]byte("[colours]\nselection_foreground = \"#ffffff\"\nselection_opacity = 0.8\n"))
+	require.Nil(t, err)
+	require.NotNil(t, c.ColourScheme.SelectionForeground)
+	assert.Equal(t, Colour{1, 1, 1}, *c.ColourScheme.SelectionForeground)
+	assert.Equal(t, float32(0.8), c.ColourScheme.SelectionOpacity)
+	assert.Nil(t, DefaultConfig.ColourScheme.SelectionForeground)
+
+	_, err = c.Encode()
+	assert.Nil(t, err)
+	_, err = DefaultConfig.Encode()
+	assert.Nil(t, err)
+}
+
+func TestPaletteConfig(t *testing.T) {
+	c, err := Parse([]byte("[colours.palette]\n1 = \"#ff0000\"\n200 = \"#00ff00\"\n"))
+	require.Nil(t, err)
+
+	colour, ok := c.ColourScheme.PaletteColour(200)
+	assert.True(t, ok)
+	assert.Equal(t, Colour{0, 1, 0}, colour)
+	_, ok = c.ColourScheme.PaletteColour(201)
+	assert.False(t, ok)
+	_, ok = DefaultConfig.ColourScheme.PaletteColour(200)
+	assert.False(t, ok)
+
+	for _, index := range []string{"256", "-1", "red", "012"} {
+		_, err := Parse([]byte("[colours.palette]\n\"" + index + "\" = \"#ff0000\"\n"))
+		assert.NotNil(t, err, index)
+	}
+}
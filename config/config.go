@@ -9,20 +9,94 @@ import (
 type Config struct {
 	DebugMode             bool             `toml:"debug"`
 	Slomo                 bool             `toml:"slomo"`
+	ShowControls          bool             `toml:"show_controls"`
+	ShowWhitespace        bool             `toml:"show_whitespace"`
+	BoldIsBright          bool             `toml:"bold_is_bright"`
+	BoldFont              bool             `toml:"bold_font"` // draw bold text in the bold face of the font
 	ColourScheme          ColourScheme     `toml:"colours"`
 	DPIScale              float32          `toml:"dpi-scale"`
+	FontSize              float32          `toml:"font_size"` // in points before DPI scaling, which the font_bigger and font_smaller actions change from
 	Shell                 string           `toml:"shell"`
 	KeyMapping            KeyMappingConfig `toml:"keys"`
 	SearchURL             string           `toml:"search_url"`
 	MaxLines              uint64           `toml:"max_lines"`
+	MaxScrollbackBytes    uint64           `toml:"max_scrollback_bytes"` // roughly the most memory the scrollback may use, or 0 for no limit
 	CopyAndPasteWithMouse bool             `toml:"copy_and_paste_with_mouse"`
+	Gutter                GutterConfig     `toml:"gutter"`
+	ForceCursorStyle      CursorShape      `toml:"force_cursor_style"`
+	CursorStyle           CursorShape      `toml:"cursor_style"`     // until the application sets one, and when it asks for the default
+	CursorBlink           bool             `toml:"cursor_blink"`     // blink the cursor when the application asks for it to blink
+	BlinkInterval         int              `toml:"blink_interval"`   // in milliseconds, how long blinking text and cursors are shown and hidden for, or 0 to never blink
+	BarCursorWidth        float32          `toml:"bar_cursor_width"` // in pixels, before DPI scaling
+	ControlSocket         string           `toml:"control_socket"`
+	Bell                  BellConfig       `toml:"bell"`
+	ClipboardRead         ClipboardPolicy  `toml:"clipboard_read"`
+	ClipboardWrite        bool             `toml:"clipboard_write"`    // allow applications to set the clipboard with OSC 52
+	ClipboardMaxSize      int              `toml:"clipboard_max_size"` // in bytes, the most an application may copy with OSC 52, or 0 for no limit
+	DimInactive           DimConfig        `toml:"dim_inactive"`
+	Fonts                 []FontConfig     `toml:"fonts"`
+	EmojiFont             string           `toml:"emoji_font"` // path to a colour emoji font, which is searched for if empty
+	Latin1                bool             `toml:"latin1"`     // decode output as ISO 8859-1 instead of UTF-8, which also enables 8-bit C1 controls
+	HighlightCurrentLine  bool             `toml:"highlight_current_line"`
+	PasteProtection       bool             `toml:"paste_protection"` // confirm pasting text which looks like it runs a privileged command
+	MultiClick            MultiClickConfig `toml:"multi_click"`
+	SubpixelAntialiasing  bool             `toml:"subpixel_antialiasing"` // for LCDs with red, green and blue subpixels from left to right
+	Record                string           `toml:"-"`                     // path of an asciinema cast to record the session to, set by --record
+	Replay                string           `toml:"-"`                     // path of an asciinema cast to play instead of running a shell, set by --replay
+}
+
+// MultiClickConfig controls which clicks count as double and triple clicks, which select words and lines
+type MultiClickConfig struct {
+	Interval       int     `toml:"interval"`        // in milliseconds, the longest time between the clicks
+	Distance       float32 `toml:"distance"`        // in pixels before DPI scaling, the furthest the pointer may move between the clicks
+	WordSeparators string  `toml:"word_separators"` // characters which end a word selected by double clicking, as well as whitespace
+}
+
+// FontConfig is a font which can be switched to with the next_font action, in addition to the built in font
+type FontConfig struct {
+	Regular    string `toml:"regular"`     // name of an installed font family, or path to a TTF file
+	Bold       string `toml:"bold"`        // as for Regular, the bold face of the regular font is used for bold text if this is empty
+	Italic     string `toml:"italic"`      // as for Bold, for italic text
+	BoldItalic string `toml:"bold_italic"` // as for Bold, for bold italic text
+}
+
+// DimConfig controls dimming of the terminal while its window doesn't have focus, so the active terminal is obvious
+type DimConfig struct {
+	Enabled bool    `toml:"enabled"`
+	Amount  float32 `toml:"amount"` // from 0 (unchanged) to 1 (black)
+}
+
+// BellConfig controls how the terminal responds to the bell (BEL)
+type BellConfig struct {
+	Mode        BellMode `toml:"mode"`
+	Mute        bool     `toml:"mute"`
+	MinInterval int      `toml:"min_interval"` // in milliseconds - bells rung sooner than this after the last one shown are ignored
+}
+
+// GutterConfig controls the optional column drawn to the left of the terminal which shows shell integration (OSC 133) marks
+type GutterConfig struct {
+	Enabled       bool    `toml:"enabled"`
+	Width         float32 `toml:"width"` // in pixels, before DPI scaling
+	ClickToSelect bool    `toml:"click_to_select"`
+	PromptColour  Colour  `toml:"prompt"`
+	RunningColour Colour  `toml:"running"`
+	SuccessColour Colour  `toml:"success"`
+	FailureColour Colour  `toml:"failure"`
 }
 
 type KeyMappingConfig map[string]string
 
 func Parse(data []byte) (*Config, error) {
 	c := DefaultConfig
+	// shortcuts in the file are merged with the defaults, which mustn't be changed themselves
+	c.KeyMapping = KeyMappingConfig{}
+	for action, keys := range DefaultConfig.KeyMapping {
+		c.KeyMapping[action] = keys
+	}
 	err := toml.Unmarshal(data, &c)
+	if err == nil {
+		err = c.ColourScheme.validatePalette()
+	}
 	if c.KeyMapping == nil {
 		c.KeyMapping = KeyMappingConfig(map[string]string{})
 	}
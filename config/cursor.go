@@ -0,0 +1,22 @@
+package config
+
+import "fmt"
+
+// CursorShape is the shape used to draw the cursor
+type CursorShape string
+
+const (
+	CursorShapeDefault   CursorShape = ""
+	CursorShapeBlock     CursorShape = "block"
+	CursorShapeUnderline CursorShape = "underline"
+	CursorShapeBar       CursorShape = "bar"
+)
+
+func (shape *CursorShape) UnmarshalText(data []byte) error {
+	switch s := CursorShape(data); s {
+	case CursorShapeDefault, CursorShapeBlock, CursorShapeUnderline, CursorShapeBar:
+		*shape = s
+		return nil
+	}
+	return fmt.Errorf("Invalid cursor shape '%s'. Should be one of block, underline or bar", string(data))
+}
@@ -0,0 +1,21 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForceCursorStyleParsing(t *testing.T) {
+	c, err := Parse([]byte(`force_cursor_style = "bar"`))
+	require.Nil(t, err)
+	assert.Equal(t, CursorShapeBar, c.ForceCursorStyle)
+
+	c, err = Parse([]byte(``))
+	require.Nil(t, err)
+	assert.Equal(t, CursorShapeDefault, c.ForceCursorStyle)
+
+	_, err = Parse([]byte(`force_cursor_style = "triangle"`))
+	assert.NotNil(t, err)
+}
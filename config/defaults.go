@@ -1,9 +1,15 @@
 package config
 
-import "runtime"
+import (
+	"runtime"
+
+	"github.com/liamg/aminal/words"
+)
 
 var DefaultConfig = Config{
-	DebugMode: false,
+	DebugMode:    false,
+	BoldIsBright: true,
+	BoldFont:     true,
 	ColourScheme: ColourScheme{
 		Cursor:       strToColourNoErr("#e8dfd6"),
 		Foreground:   strToColourNoErr("#e8dfd6"),
@@ -24,21 +30,71 @@ var DefaultConfig = Config{
 		LightMagenta: strToColourNoErr("#ff00ff"),
 		LightCyan:    strToColourNoErr("#00ffff"),
 		White:        strToColourNoErr("#ffffff"),
-		Selection:    strToColourNoErr("#333366"),
+		Selection:    strToColourNoErr("#5c6f9e"),
+		CurrentLine:  strToColourNoErr("#61778d"),
+
+		SelectionOpacity: 0.5,
 	},
 	KeyMapping:            KeyMappingConfig(map[string]string{}),
 	SearchURL:             "https://www.google.com/search?q=$QUERY",
 	MaxLines:              1000,
+	FontSize:              10,
 	CopyAndPasteWithMouse: true,
+	BarCursorWidth:        2,
+	CursorStyle:           CursorShapeBlock,
+	CursorBlink:           true,
+	BlinkInterval:         500,
+	Gutter: GutterConfig{
+		Enabled:       false,
+		Width:         6,
+		ClickToSelect: true,
+		PromptColour:  strToColourNoErr("#61778d"),
+		RunningColour: strToColourNoErr("#beb090"),
+		SuccessColour: strToColourNoErr("#7cbf9e"),
+		FailureColour: strToColourNoErr("#c2454e"),
+	},
+	ClipboardRead:    ClipboardPolicyPrompt,
+	ClipboardWrite:   true,
+	ClipboardMaxSize: 1 << 20,
+	Bell: BellConfig{
+		Mode:        BellModeVisual,
+		Mute:        false,
+		MinInterval: 500,
+	},
+	DimInactive: DimConfig{
+		Enabled: false,
+		Amount:  0.3,
+	},
+	MultiClick: MultiClickConfig{
+		Interval:       500,
+		Distance:       4,
+		WordSeparators: words.DefaultSeparators,
+	},
 }
 
 func init() {
 	DefaultConfig.KeyMapping[string(ActionCopy)] = addMod("c")
+	DefaultConfig.KeyMapping[string(ActionCopyTable)] = addMod("t")
+	DefaultConfig.KeyMapping[string(ActionCopyOutput)] = addMod("o")
 	DefaultConfig.KeyMapping[string(ActionPaste)] = addMod("v")
 	DefaultConfig.KeyMapping[string(ActionSearch)] = addMod("g")
 	DefaultConfig.KeyMapping[string(ActionToggleDebug)] = addMod("d")
 	DefaultConfig.KeyMapping[string(ActionToggleSlomo)] = addMod(";")
+	DefaultConfig.KeyMapping[string(ActionToggleControls)] = addMod("k")
+	DefaultConfig.KeyMapping[string(ActionToggleWhitespace)] = addMod("w")
 	DefaultConfig.KeyMapping[string(ActionReportBug)] = addMod("r")
+	DefaultConfig.KeyMapping[string(ActionFind)] = addMod("f")
+	DefaultConfig.KeyMapping[string(ActionNextFont)] = addMod("e")
+	DefaultConfig.KeyMapping[string(ActionLinkHints)] = addMod("u")
+	DefaultConfig.KeyMapping[string(ActionChooseShell)] = addMod("n")
+	DefaultConfig.KeyMapping[string(ActionToggleRecording)] = addMod("s")
+	DefaultConfig.KeyMapping[string(ActionFontBigger)] = addUnshiftedMod("=")
+	DefaultConfig.KeyMapping[string(ActionFontSmaller)] = addUnshiftedMod("minus")
+	DefaultConfig.KeyMapping[string(ActionFontReset)] = addUnshiftedMod("0")
+	DefaultConfig.KeyMapping[string(ActionNewTab)] = addMod("enter")
+	DefaultConfig.KeyMapping[string(ActionCloseTab)] = addMod("q")
+	DefaultConfig.KeyMapping[string(ActionNextTab)] = addUnshiftedMod("pagedown")
+	DefaultConfig.KeyMapping[string(ActionPreviousTab)] = addUnshiftedMod("pageup")
 }
 
 func addMod(keys string) string {
@@ -50,3 +106,12 @@ func addMod(keys string) string {
 
 	return standardMod + keys
 }
+
+// addUnshiftedMod adds the modifier other applications use without shift for shortcuts such as zooming and switching
+// tabs
+func addUnshiftedMod(keys string) string {
+	if runtime.GOOS == "darwin" {
+		return "super + " + keys
+	}
+	return "ctrl + " + keys
+}
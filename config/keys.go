@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/go-gl/glfw/v3.2/glfw"
@@ -9,7 +10,8 @@ import (
 
 type KeyCombination struct {
 	mods glfw.ModifierKey
-	char rune
+	char rune     // the character the key types, or 0 for a named key
+	key  glfw.Key // a named key, such as enter or f5, which doesn't type a character
 }
 
 type KeyMod string
@@ -22,61 +24,180 @@ const (
 )
 
 var modMap = map[KeyMod]glfw.ModifierKey{
-	ctrl:  glfw.ModControl,
-	alt:   glfw.ModAlt,
-	shift: glfw.ModShift,
-	super: glfw.ModSuper,
+	ctrl:      glfw.ModControl,
+	"control": glfw.ModControl,
+	alt:       glfw.ModAlt,
+	"option":  glfw.ModAlt,
+	shift:     glfw.ModShift,
+	super:     glfw.ModSuper,
+	"cmd":     glfw.ModSuper,
+	"command": glfw.ModSuper,
 }
 
-// keyStr e.g. "ctrl + alt + a"
+// charNames are names for the characters which can't be written in a shortcut
+var charNames = map[string]rune{
+	"plus":  '+',
+	"minus": '-',
+	"space": ' ',
+}
+
+// namedKeys are the keys which don't type a character, by the names used for them in shortcuts
+var namedKeys = map[string]glfw.Key{
+	"enter":     glfw.KeyEnter,
+	"tab":       glfw.KeyTab,
+	"backspace": glfw.KeyBackspace,
+	"escape":    glfw.KeyEscape,
+	"insert":    glfw.KeyInsert,
+	"delete":    glfw.KeyDelete,
+	"home":      glfw.KeyHome,
+	"end":       glfw.KeyEnd,
+	"pageup":    glfw.KeyPageUp,
+	"pagedown":  glfw.KeyPageDown,
+	"up":        glfw.KeyUp,
+	"down":      glfw.KeyDown,
+	"left":      glfw.KeyLeft,
+	"right":     glfw.KeyRight,
+	"kp_add":    glfw.KeyKPAdd,
+	"kp_minus":  glfw.KeyKPSubtract,
+	"f1":        glfw.KeyF1,
+	"f2":        glfw.KeyF2,
+	"f3":        glfw.KeyF3,
+	"f4":        glfw.KeyF4,
+	"f5":        glfw.KeyF5,
+	"f6":        glfw.KeyF6,
+	"f7":        glfw.KeyF7,
+	"f8":        glfw.KeyF8,
+	"f9":        glfw.KeyF9,
+	"f10":       glfw.KeyF10,
+	"f11":       glfw.KeyF11,
+	"f12":       glfw.KeyF12,
+}
+
+// keyStr e.g. "ctrl + alt + a", or "ctrl+shift+plus". Every key but the function keys needs a modifier, so that
+// shortcuts can't take over typing, or keys such as tab and enter which applications need.
 func parseKeyCombination(keyStr string) (*KeyCombination, error) {
 
 	var mods glfw.ModifierKey
-	var key rune
+	var char rune
+	var key glfw.Key
+	found := false
 
 	keys := strings.Split(keyStr, "+")
 	for _, k := range keys {
 		k = strings.ToLower(strings.TrimSpace(k))
 		mod, ok := modMap[KeyMod(k)]
 		if ok {
-			mods = mods + mod
+			mods |= mod
 			continue
 		}
 
-		if key > 0 {
-			return nil, fmt.Errorf("Multiple non-modifier keys specified in keyboard shortcut")
+		if k == "" {
+			return nil, fmt.Errorf("Empty key in keyboard shortcut")
 		}
 
-		key = rune(k[0])
+		if found {
+			return nil, fmt.Errorf("Multiple non-modifier keys specified in keyboard shortcut")
+		}
+		found = true
+
+		if named, ok := namedKeys[k]; ok {
+			key = named
+		} else if r, ok := charNames[k]; ok {
+			char = r
+		} else if runes := []rune(k); len(runes) == 1 {
+			char = runes[0]
+		} else {
+			return nil, fmt.Errorf("Unknown key '%s' in keyboard shortcut", k)
+		}
 	}
 
-	if key == 0 {
+	if !found {
 		return nil, fmt.Errorf("No non-modifier key specified in keyboard shortcut")
 	}
 
-	if mods == 0 {
+	if mods == 0 && (char != 0 || key < glfw.KeyF1 || key > glfw.KeyF12) {
 		return nil, fmt.Errorf("No modifier key specified in keyboard shortcut")
 	}
 
 	return &KeyCombination{
 		mods: mods,
-		char: key,
+		char: char,
+		key:  key,
 	}, nil
 }
 
 func (combi KeyCombination) Match(pressedMods glfw.ModifierKey, pressedChar rune) bool {
-	return pressedChar == combi.char && pressedMods == combi.mods
+	return combi.char != 0 && pressedChar == combi.char && pressedMods == combi.mods
+}
+
+// MatchKey returns true if the combination is a named key, and it's the key pressed
+func (combi KeyCombination) MatchKey(pressedMods glfw.ModifierKey, pressedKey glfw.Key) bool {
+	return combi.char == 0 && pressedKey == combi.key && pressedMods == combi.mods
 }
 
+// GenerateActionMap parses the shortcut for each action. Actions with invalid shortcuts are left out, and returned in
+// the error, so that one mistake doesn't stop the others working. Actions with an empty shortcut are unbound. When two
+// actions have the same shortcut, one which has been changed from its default keeps it and the other is unbound, which
+// is also returned in the error.
 func (keyMapConfig KeyMappingConfig) GenerateActionMap() (map[UserAction]*KeyCombination, error) {
+	actions := make([]string, 0, len(keyMapConfig))
+	for actionStr := range keyMapConfig {
+		actions = append(actions, actionStr)
+	}
+	sort.Strings(actions)
+
 	m := map[UserAction]*KeyCombination{}
-	for actionStr, keyStr := range keyMapConfig {
+	invalid := []string{}
+	for _, actionStr := range actions {
+		keyStr := keyMapConfig[actionStr]
+		if strings.TrimSpace(keyStr) == "" {
+			continue
+		}
 		combi, err := parseKeyCombination(keyStr)
 		if err != nil {
-			return nil, err
+			invalid = append(invalid, fmt.Sprintf("%s = '%s': %s", actionStr, keyStr, err))
+			continue
 		}
 		m[UserAction(actionStr)] = combi
 	}
 
+	// shortcuts the user has changed are bound first, then the defaults, so that the user's take precedence
+	sort.SliceStable(actions, func(i, j int) bool {
+		return !isDefaultShortcut(actions[i], m[UserAction(actions[i])]) && isDefaultShortcut(actions[j], m[UserAction(actions[j])])
+	})
+	bound := map[KeyCombination]UserAction{}
+	conflicts := []string{}
+	for _, actionStr := range actions {
+		combi, ok := m[UserAction(actionStr)]
+		if !ok {
+			continue
+		}
+		if other, ok := bound[*combi]; ok {
+			conflicts = append(conflicts, fmt.Sprintf("%s = '%s' is unbound, as %s has the same keys", actionStr, keyMapConfig[actionStr], other))
+			delete(m, UserAction(actionStr))
+			continue
+		}
+		bound[*combi] = UserAction(actionStr)
+	}
+
+	problems := []string{}
+	if len(invalid) > 0 {
+		problems = append(problems, fmt.Sprintf("Invalid keyboard shortcuts: %s", strings.Join(invalid, "; ")))
+	}
+	if len(conflicts) > 0 {
+		problems = append(problems, fmt.Sprintf("Conflicting keyboard shortcuts: %s", strings.Join(conflicts, "; ")))
+	}
+	if len(problems) > 0 {
+		return m, fmt.Errorf("%s", strings.Join(problems, ". "))
+	}
 	return m, nil
 }
+
+// isDefaultShortcut returns true if combi is the default shortcut for action
+func isDefaultShortcut(action string, combi *KeyCombination) bool {
+	if combi == nil {
+		return false
+	}
+	defaultCombi, err := parseKeyCombination(DefaultConfig.KeyMapping[action])
+	return err == nil && *defaultCombi == *combi
+}
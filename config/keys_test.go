@@ -25,3 +25,63 @@ func TestKeyCombinations(t *testing.T) {
 	assert.False(t, combi.Match(glfw.ModControl^glfw.ModAlt^glfw.ModShift, 'f'))
 
 }
+
+func TestNamedKeysAndModifierAliases(t *testing.T) {
+	combi, err := parseKeyCombination("ctrl+shift+plus")
+	require.Nil(t, err)
+	assert.True(t, combi.Match(glfw.ModControl|glfw.ModShift, '+'))
+
+	combi, err = parseKeyCombination("Cmd + Option + F5")
+	require.Nil(t, err)
+	assert.True(t, combi.MatchKey(glfw.ModSuper|glfw.ModAlt, glfw.KeyF5))
+	assert.False(t, combi.Match(glfw.ModSuper|glfw.ModAlt, 'f'))
+
+	combi, err = parseKeyCombination("f11")
+	require.Nil(t, err, "function keys don't need a modifier")
+	assert.True(t, combi.MatchKey(0, glfw.KeyF11))
+}
+
+func TestInvalidKeyCombinations(t *testing.T) {
+	for _, keys := range []string{"", "ctrl", "a", "tab", "enter", "up", "ctrl + ", "ctrl + a + b", "ctrl + enterr"} {
+		_, err := parseKeyCombination(keys)
+		assert.NotNil(t, err, keys)
+	}
+}
+
+func TestInvalidShortcutsDontStopTheOthersWorking(t *testing.T) {
+	shortcuts, err := KeyMappingConfig{
+		"copy":  "ctrl + shift + c",
+		"paste": "ctrl + shift + ",
+		"find":  "",
+	}.GenerateActionMap()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "paste")
+	assert.NotNil(t, shortcuts[ActionCopy])
+	assert.Nil(t, shortcuts[ActionPaste])
+	assert.Nil(t, shortcuts[ActionFind], "an empty shortcut should unbind the action")
+}
+
+func TestConflictingShortcutsUnbindTheDefault(t *testing.T) {
+	c, err := Parse([]byte("[keys]\nfind = \"" + DefaultConfig.KeyMapping[string(ActionNextFont)] + "\""))
+	require.Nil(t, err)
+
+	for i := 0; i < 10; i++ {
+		shortcuts, err := c.KeyMapping.GenerateActionMap()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "next_font")
+		assert.NotNil(t, shortcuts[ActionFind], "the shortcut the user set should win")
+		assert.Nil(t, shortcuts[ActionNextFont], "the default shortcut with the same keys should be unbound")
+		assert.NotNil(t, shortcuts[ActionCopy])
+	}
+
+	_, err = DefaultConfig.KeyMapping.GenerateActionMap()
+	assert.Nil(t, err, "the default shortcuts shouldn't conflict")
+}
+
+func TestShortcutsAreMergedWithDefaults(t *testing.T) {
+	c, err := Parse([]byte("[keys]\ncopy = \"alt + c\""))
+	require.Nil(t, err)
+	assert.Equal(t, "alt + c", c.KeyMapping[string(ActionCopy)])
+	assert.Equal(t, DefaultConfig.KeyMapping[string(ActionPaste)], c.KeyMapping[string(ActionPaste)])
+	assert.NotEqual(t, "alt + c", DefaultConfig.KeyMapping[string(ActionCopy)], "the defaults shouldn't be changed")
+}
@@ -0,0 +1,184 @@
+// Package control implements a line based protocol for driving a terminal externally over a Unix domain socket.
+//
+// Each request is a single line containing a command, optionally followed by a space and an argument:
+//
+//	send-keys <text>   write text to the pty as if it had been typed
+//	paste <text>       write text to the pty as a paste, using bracketed paste if the application enabled it
+//	capture-pane       return the contents of the visible lines of the terminal
+//	set-title <title>  set the window title
+//
+// Text arguments may contain Go escape sequences such as \n, \t, \x1b or \u2603.
+//
+// Each request is answered with "OK <n>" followed by n lines of output, or with "ERROR <message>".
+package control
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/liamg/aminal/terminal"
+	"go.uber.org/zap"
+)
+
+type Server struct {
+	terminal func() *terminal.Terminal // returns the terminal of the active tab, which commands are sent to
+	logger   *zap.SugaredLogger
+	listener net.Listener
+}
+
+type commandHandler func(server *Server, arg string) ([]string, error)
+
+var commands = map[string]commandHandler{
+	"send-keys":    sendKeysHandler,
+	"paste":        pasteHandler,
+	"capture-pane": capturePaneHandler,
+	"set-title":    setTitleHandler,
+}
+
+// Listen creates the control socket at path, for commands to the terminal the terminal func returns when each one is
+// received. Serve must be called to start accepting connections.
+func Listen(path string, terminal func() *terminal.Terminal, logger *zap.SugaredLogger) (*Server, error) {
+
+	// remove a socket left behind by a previous instance which didn't shut down cleanly
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("Failed to remove stale control socket %s: %s", path, err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create control socket %s: %s", path, err)
+	}
+
+	return &Server{
+		terminal: terminal,
+		logger:   logger,
+		listener: listener,
+	}, nil
+}
+
+// Serve accepts connections until the server is closed, it should be run on a goroutine
+func (server *Server) Serve() {
+	for {
+		conn, err := server.listener.Accept()
+		if err != nil {
+			server.logger.Debugf("Control socket closed: %s", err)
+			return
+		}
+		go server.handle(conn)
+	}
+}
+
+// Close stops accepting connections and removes the socket
+func (server *Server) Close() error {
+	return server.listener.Close()
+}
+
+func (server *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	writer := bufio.NewWriter(conn)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+
+		output, err := server.Execute(line)
+		if err != nil {
+			server.logger.Debugf("Control command %q failed: %s", line, err)
+			fmt.Fprintf(writer, "ERROR %s\n", err)
+		} else {
+			fmt.Fprintf(writer, "OK %d\n", len(output))
+			for _, out := range output {
+				fmt.Fprintf(writer, "%s\n", out)
+			}
+		}
+
+		if err := writer.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// Execute runs a single command line and returns the lines of output it produced
+func (server *Server) Execute(line string) ([]string, error) {
+	name := line
+	arg := ""
+	if i := strings.IndexByte(line, ' '); i >= 0 {
+		name = line[:i]
+		arg = line[i+1:]
+	}
+
+	handler, ok := commands[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown command: %s", name)
+	}
+
+	return handler(server, arg)
+}
+
+// unescape interprets Go escape sequences in a text argument
+func unescape(arg string) (string, error) {
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for i := 0; i < len(arg); i++ {
+		switch arg[i] {
+		case '\\':
+			quoted.WriteByte('\\')
+			if i+1 < len(arg) {
+				i++
+				quoted.WriteByte(arg[i])
+			}
+		case '"':
+			// bare quotes don't need to be escaped by the client
+			quoted.WriteString(`\"`)
+		default:
+			quoted.WriteByte(arg[i])
+		}
+	}
+	quoted.WriteByte('"')
+
+	text, err := strconv.Unquote(quoted.String())
+	if err != nil {
+		return "", fmt.Errorf("Invalid escape sequence in %q", arg)
+	}
+	return text, nil
+}
+
+func sendKeysHandler(server *Server, arg string) ([]string, error) {
+	text, err := unescape(arg)
+	if err != nil {
+		return nil, err
+	}
+	return nil, server.terminal().Write([]byte(text))
+}
+
+func pasteHandler(server *Server, arg string) ([]string, error) {
+	text, err := unescape(arg)
+	if err != nil {
+		return nil, err
+	}
+	return nil, server.terminal().Paste([]byte(text))
+}
+
+func capturePaneHandler(server *Server, arg string) ([]string, error) {
+	lines := server.terminal().GetVisibleLines()
+	output := make([]string, len(lines))
+	for i := range lines {
+		output[i] = strings.Replace(lines[i].String(), "\x00", " ", -1)
+	}
+	return output, nil
+}
+
+func setTitleHandler(server *Server, arg string) ([]string, error) {
+	server.terminal().SetTitle(arg)
+	return nil, nil
+}
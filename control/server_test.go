@@ -0,0 +1,127 @@
+package control
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/liamg/aminal/config"
+	"github.com/liamg/aminal/platform"
+	"github.com/liamg/aminal/terminal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type testPty struct {
+	output bytes.Buffer
+}
+
+func (pty *testPty) Read(b []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (pty *testPty) Write(b []byte) (int, error) {
+	return pty.output.Write(b)
+}
+
+func (pty *testPty) Close() error {
+	return nil
+}
+
+func (pty *testPty) Resize(x int, y int) error {
+	return nil
+}
+
+func (pty *testPty) CreateGuestProcess(imagePath string) (platform.Process, error) {
+	return nil, errors.New("Processes cannot be created on a test pty")
+}
+
+func (pty *testPty) GetPlatformDependentSettings() platform.PlatformDependentSettings {
+	return platform.PlatformDependentSettings{}
+}
+
+func newTestServer(t *testing.T) (*Server, *testPty) {
+	pty := &testPty{}
+	conf := config.DefaultConfig
+	term := terminal.New(pty, zap.NewNop().Sugar(), &conf)
+	require.Nil(t, term.SetSize(10, 2))
+	return &Server{terminal: func() *terminal.Terminal { return term }, logger: zap.NewNop().Sugar()}, pty
+}
+
+func TestCommands(t *testing.T) {
+	server, pty := newTestServer(t)
+
+	_, err := server.Execute(`send-keys echo "hi"\n`)
+	require.Nil(t, err)
+	assert.Equal(t, "echo \"hi\"\n", pty.output.String())
+
+	pty.output.Reset()
+	server.terminal().SetBracketedPasteMode(true)
+	_, err = server.Execute(`paste a\tb`)
+	require.Nil(t, err)
+	assert.Equal(t, "\x1b[200~a\tb\x1b[201~", pty.output.String())
+
+	server.terminal().ActiveBuffer().Write([]rune("hello")...)
+	output, err := server.Execute("capture-pane")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"hello"}, output)
+
+	_, err = server.Execute("set-title my title")
+	require.Nil(t, err)
+	assert.Equal(t, "my title", server.terminal().GetTitle())
+
+	_, err = server.Execute("frobnicate")
+	assert.NotNil(t, err)
+
+	_, err = server.Execute(`send-keys \q`)
+	assert.NotNil(t, err)
+}
+
+func TestCommandsGoToCurrentTerminal(t *testing.T) {
+	server, first := newTestServer(t)
+	second := &testPty{}
+	conf := config.DefaultConfig
+	term := terminal.New(second, zap.NewNop().Sugar(), &conf)
+	server.terminal = func() *terminal.Terminal { return term }
+
+	_, err := server.Execute("send-keys ls")
+	require.Nil(t, err)
+	assert.Equal(t, "", first.output.String())
+	assert.Equal(t, "ls", second.output.String())
+}
+
+func TestSocketProtocol(t *testing.T) {
+	dir, err := ioutil.TempDir("", "aminal-control")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	server, _ := newTestServer(t)
+	path := filepath.Join(dir, "control.sock")
+	listening, err := Listen(path, server.terminal, server.logger)
+	require.Nil(t, err)
+	defer listening.Close()
+	go listening.Serve()
+
+	server.terminal().ActiveBuffer().Write([]rune("hello")...)
+
+	conn, err := net.Dial("unix", path)
+	require.Nil(t, err)
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	_, err = conn.Write([]byte("capture-pane\nnope\n"))
+	require.Nil(t, err)
+
+	for _, expected := range []string{"OK 1\n", "hello\n", "ERROR Unknown command: nope\n"} {
+		line, err := reader.ReadString('\n')
+		require.Nil(t, err)
+		assert.Equal(t, expected, line)
+	}
+}
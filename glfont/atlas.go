@@ -0,0 +1,113 @@
+package glfont
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+
+	"github.com/go-gl/gl/all-core/gl"
+)
+
+// atlasPadding is the space left after each glyph in the atlas, so that filtering doesn't blend in its neighbours
+const atlasPadding = 1
+
+const (
+	initialAtlasSize = 512
+	maxAtlasSize     = 4096
+)
+
+// shelfPacker places glyphs in an atlas in shelves, rows as tall as the tallest glyph in them, left to right
+type shelfPacker struct {
+	width       int
+	height      int
+	x           int // where the next glyph goes on the current shelf
+	y           int
+	shelfHeight int
+}
+
+// place returns where a glyph width by height pixels goes, or false if there's no room left for it
+func (packer *shelfPacker) place(width int, height int) (int, int, bool) {
+	width += atlasPadding
+	height += atlasPadding
+	if packer.x+width > packer.width {
+		packer.x = 0
+		packer.y += packer.shelfHeight
+		packer.shelfHeight = 0
+	}
+	if width > packer.width || packer.y+height > packer.height {
+		return 0, 0, false
+	}
+
+	x, y := packer.x, packer.y
+	packer.x += width
+	if height > packer.shelfHeight {
+		packer.shelfHeight = height
+	}
+	return x, y, true
+}
+
+// resetAtlas empties the atlas, making it size pixels square
+func (f *Font) resetAtlas(size int) {
+	f.atlas = image.NewRGBA(image.Rect(0, 0, size, size))
+	f.packer = shelfPacker{width: size, height: size}
+
+	if f.texture == 0 {
+		gl.GenTextures(1, &f.texture)
+		gl.BindTexture(gl.TEXTURE_2D, f.texture)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	}
+	f.uploadAtlas()
+}
+
+// growAtlas doubles the size of the atlas, keeping the glyphs where they are. Glyphs are positioned in pixels, so the
+// ones already queued are still drawn from the right place.
+func (f *Font) growAtlas() {
+	size := f.packer.width * 2
+	atlas := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(atlas, f.atlas.Bounds(), f.atlas, image.ZP, draw.Src)
+	f.atlas = atlas
+	f.packer.width = size
+	f.packer.height = size
+	f.uploadAtlas()
+}
+
+func (f *Font) uploadAtlas() {
+	gl.BindTexture(gl.TEXTURE_2D, f.texture)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, int32(f.atlas.Rect.Dx()), int32(f.atlas.Rect.Dy()), 0,
+		gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(f.atlas.Pix))
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+}
+
+// addToAtlas copies a glyph into the atlas, returning where it was put. When the atlas can grow no more, the glyphs
+// queued are drawn and every glyph is removed to make room, as most of them are likely to be out of use.
+func (f *Font) addToAtlas(glyph *image.RGBA) (int, int, error) {
+	width, height := glyph.Rect.Dx(), glyph.Rect.Dy()
+	if width+atlasPadding > maxAtlasSize || height+atlasPadding > maxAtlasSize {
+		return 0, 0, fmt.Errorf("glyph is too big for the atlas: %dx%d", width, height)
+	}
+	if f.texture == 0 {
+		f.resetAtlas(initialAtlasSize)
+	}
+
+	for {
+		if x, y, ok := f.packer.place(width, height); ok {
+			draw.Draw(f.atlas, image.Rect(x, y, x+width, y+height), glyph, image.ZP, draw.Src)
+			gl.BindTexture(gl.TEXTURE_2D, f.texture)
+			gl.TexSubImage2D(gl.TEXTURE_2D, 0, int32(x), int32(y), int32(width), int32(height),
+				gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(glyph.Pix))
+			gl.BindTexture(gl.TEXTURE_2D, 0)
+			return x, y, nil
+		}
+
+		if f.packer.width < maxAtlasSize {
+			f.growAtlas()
+			continue
+		}
+		f.Flush()
+		f.characters = map[rune]*character{}
+		f.resetAtlas(f.packer.width)
+	}
+}
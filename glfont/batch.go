@@ -0,0 +1,63 @@
+package glfont
+
+// vertexSize is the number of floats in each vertex of a glyph quad: the position, the position in the atlas in
+// pixels, and the colour
+const vertexSize = 8
+
+// maxBatchColours is how many colours a batch keeps the vertex buffers of between frames
+const maxBatchColours = 64
+
+// a glyphBatch collects the quads of the glyphs queued to be drawn together by Flush, grouped by colour, as glyphs
+// drawn with subpixel antialiasing are drawn a colour at a time
+type glyphBatch struct {
+	runs     []colourRun
+	index    map[color]int // the run of each colour
+	last     int           // the run the last quad was added to
+	vertices int
+}
+
+// a colourRun is the vertices of the quads in a batch with the same colour
+type colourRun struct {
+	colour   color
+	vertices []float32
+}
+
+// add queues the quad from x1, y1 to x2, y2 on screen, showing the part of the atlas from u1, v1 to u2, v2
+func (batch *glyphBatch) add(x1, y1, x2, y2, u1, v1, u2, v2 float32, c color) {
+	// neighbouring glyphs are usually the same colour
+	if batch.last >= len(batch.runs) || batch.runs[batch.last].colour != c {
+		if batch.index == nil {
+			batch.index = map[color]int{}
+		}
+		i, ok := batch.index[c]
+		if !ok {
+			i = len(batch.runs)
+			batch.index[c] = i
+			batch.runs = append(batch.runs, colourRun{colour: c})
+		}
+		batch.last = i
+	}
+
+	run := &batch.runs[batch.last]
+	run.vertices = append(run.vertices,
+		x1, y1, u1, v1, c.r, c.g, c.b, c.a,
+		x2, y1, u2, v1, c.r, c.g, c.b, c.a,
+		x1, y2, u1, v2, c.r, c.g, c.b, c.a,
+		x1, y2, u1, v2, c.r, c.g, c.b, c.a,
+		x2, y1, u2, v1, c.r, c.g, c.b, c.a,
+		x2, y2, u2, v2, c.r, c.g, c.b, c.a,
+	)
+	batch.vertices += 6
+}
+
+// reset empties the batch, keeping the memory of the colours used for the next frame unless there are a lot of them
+func (batch *glyphBatch) reset() {
+	if len(batch.runs) > maxBatchColours {
+		*batch = glyphBatch{}
+		return
+	}
+	for i := range batch.runs {
+		batch.runs[i].vertices = batch.runs[i].vertices[:0]
+	}
+	batch.vertices = 0
+}
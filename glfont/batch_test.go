@@ -0,0 +1,76 @@
+package glfont
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShelfPacker(t *testing.T) {
+	packer := shelfPacker{width: 20, height: 20}
+
+	x, y, ok := packer.place(8, 5)
+	assert.True(t, ok)
+	assert.Equal(t, 0, x)
+	assert.Equal(t, 0, y)
+
+	// glyphs are padded so they don't touch
+	x, y, ok = packer.place(8, 9)
+	assert.True(t, ok)
+	assert.Equal(t, 9, x)
+	assert.Equal(t, 0, y)
+
+	// the next shelf starts below the tallest glyph on the one before
+	x, y, ok = packer.place(8, 5)
+	assert.True(t, ok)
+	assert.Equal(t, 0, x)
+	assert.Equal(t, 10, y)
+
+	_, _, ok = packer.place(8, 10)
+	assert.False(t, ok)
+	_, _, ok = packer.place(30, 1)
+	assert.False(t, ok)
+}
+
+func TestBatchGroupsByColour(t *testing.T) {
+	red := color{r: 1, a: 1}
+	blue := color{b: 1, a: 1}
+
+	batch := glyphBatch{}
+	batch.add(0, 0, 1, 1, 0, 0, 1, 1, red)
+	batch.add(1, 0, 2, 1, 0, 0, 1, 1, blue)
+	batch.add(2, 0, 3, 1, 0, 0, 1, 1, red)
+
+	assert.Equal(t, 18, batch.vertices)
+	assert.Equal(t, 2, len(batch.runs))
+	assert.Equal(t, red, batch.runs[0].colour)
+	assert.Equal(t, 2*6*vertexSize, len(batch.runs[0].vertices))
+	assert.Equal(t, float32(2), batch.runs[0].vertices[6*vertexSize])
+	assert.Equal(t, blue, batch.runs[1].colour)
+	assert.Equal(t, 6*vertexSize, len(batch.runs[1].vertices))
+
+	// the colours are kept for the next frame, without their vertices
+	batch.reset()
+	assert.Equal(t, 0, batch.vertices)
+	assert.Equal(t, 2, len(batch.runs))
+	assert.Empty(t, batch.runs[0].vertices)
+}
+
+// queueScreen queues a quad for every cell of a 200x50 screen, in the handful of colours a busy screen has
+func queueScreen(batch *glyphBatch) {
+	colours := []color{{1, 1, 1, 1}, {1, 0, 0, 1}, {0, 1, 0, 1}, {0.5, 0.5, 1, 1}}
+	for row := 0; row < 50; row++ {
+		for col := 0; col < 200; col++ {
+			x, y := float32(col*9), float32(row*18)
+			batch.add(x, y, x+9, y+18, 0, 0, 9, 18, colours[(row+col/10)%len(colours)])
+		}
+	}
+}
+
+func BenchmarkFullScreenBatch(b *testing.B) {
+	batch := glyphBatch{}
+	for i := 0; i < b.N; i++ {
+		queueScreen(&batch)
+		batch.reset()
+	}
+}
@@ -0,0 +1,453 @@
+package glfont
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	imagecolor "image/color"
+	"image/png"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// colourFace extracts the glyphs of a colour emoji font. Glyphs are either PNG bitmaps, stored in the CBDT/CBLC tables
+// (Noto Color Emoji) or the sbix table (Apple Color Emoji), or layers of outlines filled with colours from a palette,
+// stored in the COLR/CPAL tables (Segoe UI Emoji). Only version 0 of COLR is supported.
+type colourFace struct {
+	tables    map[string][]byte
+	numGlyphs int
+	cmap      func(r rune) uint16
+	outlines  *truetype.Font // only needed for COLR glyphs
+	glyphBuf  truetype.GlyphBuf
+}
+
+func parseColourFace(data []byte) (*colourFace, error) {
+	tables, err := readTables(data)
+	if err != nil {
+		return nil, err
+	}
+
+	face := &colourFace{
+		tables:    tables,
+		numGlyphs: int(u16(tables["maxp"], 4)),
+	}
+
+	hasBitmaps := len(tables["CBDT"]) > 0 && len(tables["CBLC"]) > 0 || len(tables["sbix"]) > 0
+	hasLayers := len(tables["COLR"]) > 0 && len(tables["CPAL"]) > 0
+	if !hasBitmaps && !hasLayers {
+		return nil, fmt.Errorf("Font has no colour glyphs (CBDT, sbix or COLR tables)")
+	}
+
+	face.cmap, err = parseCmap(tables["cmap"])
+	if err != nil {
+		return nil, err
+	}
+
+	if hasLayers {
+		// the layers are ordinary glyphs, and must be outlines for the layers to be drawn
+		if face.outlines, err = truetype.Parse(data); err != nil {
+			if !hasBitmaps {
+				return nil, err
+			}
+			face.outlines = nil
+		}
+	}
+
+	return face, nil
+}
+
+// readTables returns the tables of the font in data, or of the first font if data is a font collection
+func readTables(data []byte) (map[string][]byte, error) {
+	offset := 0
+	if len(data) >= 16 && string(data[:4]) == "ttcf" {
+		offset = int(u32(data, 12))
+	}
+
+	numTables := int(u16(data, offset+4))
+	if numTables == 0 || len(data) < offset+12+16*numTables {
+		return nil, fmt.Errorf("Invalid font file")
+	}
+
+	tables := map[string][]byte{}
+	for i := 0; i < numTables; i++ {
+		record := offset + 12 + 16*i
+		start := int(u32(data, record+8))
+		table := slice(data, start, start+int(u32(data, record+12)))
+		if table == nil {
+			return nil, fmt.Errorf("Invalid offset for table %q", string(data[record:record+4]))
+		}
+		tables[string(data[record:record+4])] = table
+	}
+	return tables, nil
+}
+
+// parseCmap returns a lookup function for the best Unicode subtable in a cmap table, which must use format 4 (the BMP
+// only) or format 12 (all of Unicode)
+func parseCmap(cmap []byte) (func(r rune) uint16, error) {
+	var best []byte
+	for i := 0; i < int(u16(cmap, 2)); i++ {
+		platform, encoding := u16(cmap, 4+8*i), u16(cmap, 6+8*i)
+		unicode := platform == 0 || platform == 3 && (encoding == 1 || encoding == 10)
+		subtable := slice(cmap, int(u32(cmap, 8+8*i)), len(cmap))
+		if !unicode || len(subtable) < 2 {
+			continue
+		}
+		switch u16(subtable, 0) {
+		case 12:
+			best = subtable
+		case 4:
+			if best == nil {
+				best = subtable
+			}
+		}
+	}
+
+	switch u16(best, 0) {
+	case 12:
+		groups := slice(best, 16, 16+12*int(u32(best, 12)))
+		return func(r rune) uint16 {
+			for i := 0; i+12 <= len(groups); i += 12 {
+				start, end := rune(u32(groups, i)), rune(u32(groups, i+4))
+				if r >= start && r <= end {
+					return uint16(u32(groups, i+8) + uint32(r-start))
+				}
+			}
+			return 0
+		}, nil
+	case 4:
+		segments := int(u16(best, 6)) / 2
+		return func(r rune) uint16 {
+			if r > 0xffff {
+				return 0
+			}
+			c := uint16(r)
+			for i := 0; i < segments; i++ {
+				end, start := u16(best, 14+2*i), u16(best, 16+2*segments+2*i)
+				if c < start || c > end {
+					continue
+				}
+				delta := u16(best, 16+4*segments+2*i)
+				rangeOffsetAt := 16 + 6*segments + 2*i
+				rangeOffset := int(u16(best, rangeOffsetAt))
+				if rangeOffset == 0 {
+					return c + delta
+				}
+				glyph := u16(best, rangeOffsetAt+rangeOffset+2*int(c-start))
+				if glyph == 0 {
+					return 0
+				}
+				return glyph + delta
+			}
+			return 0
+		}, nil
+	}
+
+	return nil, fmt.Errorf("Font has no supported Unicode character map")
+}
+
+// glyph returns the colour glyph for r drawn as close as possible to size pixels high, or nil if the font has no
+// colour glyph for it. Layers which are drawn in the text colour use foreground.
+func (face *colourFace) glyph(r rune, size int, foreground imagecolor.Color) (image.Image, error) {
+	index := face.cmap(r)
+	if index == 0 {
+		return nil, nil
+	}
+
+	if face.outlines != nil {
+		if img, err := face.layeredGlyph(index, size, foreground); img != nil || err != nil {
+			return img, err
+		}
+	}
+
+	data := face.sbixGlyph(index, size)
+	if data == nil {
+		data = face.cbdtGlyph(index, size)
+	}
+	if data == nil {
+		return nil, nil
+	}
+	return png.Decode(bytes.NewReader(data))
+}
+
+// sbixGlyph returns the PNG data for a glyph from the strike closest to size, or nil if there is none
+func (face *colourFace) sbixGlyph(index uint16, size int) []byte {
+	sbix := face.tables["sbix"]
+	if sbix == nil || int(index) >= face.numGlyphs {
+		return nil
+	}
+
+	numStrikes := int(u32(sbix, 4))
+	if numStrikes == 0 || 8+4*numStrikes > len(sbix) {
+		return nil
+	}
+	strikes := make([]int, numStrikes)
+	for i := range strikes {
+		strikes[i] = int(u16(sbix, int(u32(sbix, 8+4*i))))
+	}
+	strike := int(u32(sbix, 8+4*closestSize(strikes, size)))
+
+	// a dupe glyph points at another glyph with the same image
+	for dupes := 0; dupes < 2; dupes++ {
+		offsets := strike + 4 + 4*int(index)
+		data := slice(sbix, strike+int(u32(sbix, offsets)), strike+int(u32(sbix, offsets+4)))
+		if len(data) < 8 {
+			return nil
+		}
+		switch string(data[4:8]) {
+		case "png ":
+			return data[8:]
+		case "dupe":
+			index = u16(data, 8)
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// cbdtGlyph returns the PNG data for a glyph from the bitmap size closest to size, or nil if there is none
+func (face *colourFace) cbdtGlyph(index uint16, size int) []byte {
+	cblc, cbdt := face.tables["CBLC"], face.tables["CBDT"]
+	if cblc == nil || cbdt == nil {
+		return nil
+	}
+
+	// bitmap size records are 48 bytes long, and each covers a range of glyphs
+	records, sizes := []int{}, []int{}
+	for i := 0; i < int(u32(cblc, 4)) && 8+48*i < len(cblc); i++ {
+		record := 8 + 48*i
+		if index >= u16(cblc, record+40) && index <= u16(cblc, record+42) {
+			records = append(records, record)
+			sizes = append(sizes, int(u16(cblc, record+44)&0xff)) // ppemY
+		}
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	record := records[closestSize(sizes, size)]
+
+	array := int(u32(cblc, record))
+	for i := 0; i < int(u32(cblc, record+8)) && array+8*i < len(cblc); i++ {
+		first, last := u16(cblc, array+8*i), u16(cblc, array+8*i+2)
+		if index < first || index > last {
+			continue
+		}
+
+		subtable := array + int(u32(cblc, array+8*i+4))
+		imageFormat := u16(cblc, subtable+2)
+		imageData := int(u32(cblc, subtable+4))
+		start, end, ok := glyphDataRange(cblc, subtable, int(index-first), index)
+		if !ok {
+			return nil
+		}
+		data := slice(cbdt, imageData+start, imageData+end)
+
+		// each image format has different metrics before the data length and the PNG
+		switch imageFormat {
+		case 17:
+			data = slice(data, 5, len(data))
+		case 18:
+			data = slice(data, 8, len(data))
+		case 19:
+		default:
+			return nil
+		}
+		return slice(data, 4, 4+int(u32(data, 0)))
+	}
+	return nil
+}
+
+// glyphDataRange returns the range of a glyph's data relative to the image data offset of a CBLC index subtable
+func glyphDataRange(cblc []byte, subtable int, position int, index uint16) (int, int, bool) {
+	switch u16(cblc, subtable) {
+	case 1:
+		offset := subtable + 8 + 4*position
+		return int(u32(cblc, offset)), int(u32(cblc, offset+4)), true
+	case 2:
+		size := int(u32(cblc, subtable+8))
+		return size * position, size * (position + 1), true
+	case 3:
+		offset := subtable + 8 + 2*position
+		return int(u16(cblc, offset)), int(u16(cblc, offset+2)), true
+	case 4:
+		for i := 0; i < int(u32(cblc, subtable+8)) && subtable+12+4*i < len(cblc); i++ {
+			pair := subtable + 12 + 4*i
+			if u16(cblc, pair) == index {
+				return int(u16(cblc, pair+2)), int(u16(cblc, pair+6)), true
+			}
+		}
+	case 5:
+		size := int(u32(cblc, subtable+8))
+		for i := 0; i < int(u32(cblc, subtable+20)) && subtable+24+2*i < len(cblc); i++ {
+			if u16(cblc, subtable+24+2*i) == index {
+				return size * i, size * (i + 1), true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// layeredGlyph draws a COLR glyph size pixels high, or returns nil if the glyph isn't made of layers
+func (face *colourFace) layeredGlyph(index uint16, size int, foreground imagecolor.Color) (image.Image, error) {
+	colr, cpal := face.tables["COLR"], face.tables["CPAL"]
+
+	var firstLayer, numLayers int
+	baseGlyphs := int(u32(colr, 4))
+	for i := 0; i < int(u16(colr, 2)); i++ {
+		record := baseGlyphs + 6*i
+		if u16(colr, record) == index {
+			firstLayer, numLayers = int(u16(colr, record+2)), int(u16(colr, record+4))
+			break
+		}
+	}
+	if numLayers == 0 {
+		return nil, nil
+	}
+
+	scale := fixed.I(size)
+	bounds := face.outlines.Bounds(scale)
+	height := int((bounds.Max.Y - bounds.Min.Y).Ceil())
+	width := int(face.outlines.HMetric(scale, truetype.Index(index)).AdvanceWidth.Ceil())
+	if width <= 0 || height <= 0 {
+		return nil, nil
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	layers := int(u32(colr, 8))
+	for i := firstLayer; i < firstLayer+numLayers; i++ {
+		layer, paletteIndex := u16(colr, layers+4*i), u16(colr, layers+4*i+2)
+		if err := face.glyphBuf.Load(face.outlines, scale, truetype.Index(layer), font.HintingNone); err != nil {
+			return nil, err
+		}
+
+		var colour imagecolor.Color = foreground
+		if paletteIndex != 0xffff {
+			colour = paletteColour(cpal, int(paletteIndex))
+		}
+
+		rasterizer := vector.NewRasterizer(width, height)
+		start := 0
+		for _, end := range face.glyphBuf.Ends {
+			drawContour(rasterizer, face.glyphBuf.Points[start:end], float32(bounds.Max.Y)/64)
+			start = end
+		}
+		rasterizer.Draw(img, img.Bounds(), image.NewUniform(colour), image.Point{})
+	}
+
+	return img, nil
+}
+
+// paletteColour returns a colour from the first palette of a CPAL table
+func paletteColour(cpal []byte, index int) imagecolor.Color {
+	record := int(u32(cpal, 8)) + 4*(int(u16(cpal, 12))+index)
+	bgra := slice(cpal, record, record+4)
+	if bgra == nil {
+		return imagecolor.Black
+	}
+	return imagecolor.NRGBA{R: bgra[2], G: bgra[1], B: bgra[0], A: bgra[3]}
+}
+
+// drawContour adds a TrueType contour, made of quadratic curves whose control points may be implied between two
+// consecutive off curve points, to a path. top is the highest point of any glyph, which is drawn at the top of the image.
+func drawContour(rasterizer *vector.Rasterizer, points []truetype.Point, top float32) {
+	if len(points) == 0 {
+		return
+	}
+
+	x := func(p truetype.Point) float32 { return float32(p.X) / 64 }
+	y := func(p truetype.Point) float32 { return top - float32(p.Y)/64 }
+	onCurve := func(p truetype.Point) bool { return p.Flags&1 != 0 }
+	midpoint := func(a, b truetype.Point) (float32, float32) { return (x(a) + x(b)) / 2, (y(a) + y(b)) / 2 }
+
+	// start on a point which is on the curve, or halfway between two which aren't
+	first := 0
+	for first < len(points) && !onCurve(points[first]) {
+		first++
+	}
+	var startX, startY float32
+	if first == len(points) {
+		first = 0
+		startX, startY = midpoint(points[len(points)-1], points[0])
+	} else {
+		startX, startY = x(points[first]), y(points[first])
+	}
+	rasterizer.MoveTo(startX, startY)
+
+	var control *truetype.Point
+	for i := 1; i <= len(points); i++ {
+		p := points[(first+i)%len(points)]
+		switch {
+		case onCurve(p) && control == nil:
+			rasterizer.LineTo(x(p), y(p))
+		case onCurve(p):
+			rasterizer.QuadTo(x(*control), y(*control), x(p), y(p))
+			control = nil
+		case control != nil:
+			mx, my := midpoint(*control, p)
+			rasterizer.QuadTo(x(*control), y(*control), mx, my)
+			control = &p
+		default:
+			control = &p
+		}
+	}
+	if control != nil {
+		rasterizer.QuadTo(x(*control), y(*control), startX, startY)
+	}
+	rasterizer.ClosePath()
+}
+
+// closestSize returns the index of the smallest of sizes which is at least size, or of the largest if none are
+func closestSize(sizes []int, size int) int {
+	best := 0
+	for i, s := range sizes {
+		smaller := sizes[best] < size
+		if smaller && s > sizes[best] || !smaller && s >= size && s < sizes[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+// fitImage scales img to fit a width x height box keeping its shape, centred in the box
+func fitImage(img image.Image, width int, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	size := img.Bounds().Size()
+	if size.X == 0 || size.Y == 0 {
+		return dst
+	}
+
+	w, h := width, size.Y*width/size.X
+	if h > height {
+		w, h = size.X*height/size.Y, height
+	}
+	x, y := (width-w)/2, (height-h)/2
+	draw.CatmullRom.Scale(dst, image.Rect(x, y, x+w, y+h), img, img.Bounds(), draw.Over, nil)
+	return dst
+}
+
+// u16 reads a big endian uint16 at offset, or returns 0 if it's out of range, so truncated tables act as if empty
+func u16(b []byte, offset int) uint16 {
+	if offset < 0 || offset+2 > len(b) {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b[offset:])
+}
+
+func u32(b []byte, offset int) uint32 {
+	if offset < 0 || offset+4 > len(b) {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b[offset:])
+}
+
+func slice(b []byte, start int, end int) []byte {
+	if start < 0 || end < start || end > len(b) {
+		return nil
+	}
+	return b[start:end]
+}
@@ -0,0 +1,251 @@
+package glfont
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	imagecolor "image/color"
+	"image/png"
+	"sort"
+	"testing"
+
+	"github.com/golang/freetype/truetype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+type tableWriter struct {
+	bytes.Buffer
+}
+
+func (w *tableWriter) u16(values ...int) *tableWriter {
+	for _, v := range values {
+		_ = binary.Write(w, binary.BigEndian, uint16(v))
+	}
+	return w
+}
+
+func (w *tableWriter) u32(values ...int) *tableWriter {
+	for _, v := range values {
+		_ = binary.Write(w, binary.BigEndian, uint32(v))
+	}
+	return w
+}
+
+// buildFont assembles an sfnt file from the given tables
+func buildFont(tables map[string][]byte) []byte {
+	tags := []string{}
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	header := &tableWriter{}
+	header.u32(0x00010000).u16(len(tags), 0, 0, 0)
+	offset := 12 + 16*len(tags)
+	data := &tableWriter{}
+	for _, tag := range tags {
+		header.WriteString(tag)
+		header.u32(0, offset+data.Len(), len(tables[tag]))
+		data.Write(tables[tag])
+	}
+	return append(header.Bytes(), data.Bytes()...)
+}
+
+// testCmap maps U+1F600 to glyph 1 and U+1F601 to glyph 2 using a format 12 subtable
+func testCmap() []byte {
+	w := &tableWriter{}
+	w.u16(0, 1).u16(3, 10).u32(12)
+	w.u16(12, 0).u32(16+12, 0, 1).u32(0x1f600, 0x1f601, 1)
+	return w.Bytes()
+}
+
+func testMaxp(numGlyphs int) []byte {
+	return (&tableWriter{}).u32(0x00005000).u16(numGlyphs).Bytes()
+}
+
+func testPNG(t *testing.T, width int, height int, c imagecolor.Color) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	require.Nil(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestParseCmapFormat4(t *testing.T) {
+	// two segments: 'A'-'C' mapped to glyphs 10-12 by delta, and the required final 0xffff segment
+	w := &tableWriter{}
+	w.u16(0, 1).u16(3, 1).u32(12)
+	w.u16(4, 0, 0, 4, 0, 0, 0)
+	w.u16('C', 0xffff).u16(0).u16('A', 0xffff).u16(10-'A', 1).u16(0, 0)
+
+	cmap, err := parseCmap(w.Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, uint16(10), cmap('A'))
+	assert.Equal(t, uint16(12), cmap('C'))
+	assert.Equal(t, uint16(0), cmap('D'))
+	assert.Equal(t, uint16(0), cmap(0x1f600))
+}
+
+func TestSbixGlyphs(t *testing.T) {
+	small, large := testPNG(t, 20, 20, imagecolor.NRGBA{R: 255, A: 255}), testPNG(t, 40, 40, imagecolor.NRGBA{B: 255, A: 255})
+
+	strike := func(ppem int, image []byte) []byte {
+		// glyph 0 is empty, glyph 1 is the image, glyph 2 is a dupe of glyph 1
+		glyph := (&tableWriter{}).u16(0, 0)
+		glyph.WriteString("png ")
+		glyph.Write(image)
+		dupe := (&tableWriter{}).u16(0, 0)
+		dupe.WriteString("dupe")
+		dupe.u16(1)
+
+		dataStart := 4 + 4*4
+		w := &tableWriter{}
+		w.u16(ppem, 72).u32(dataStart, dataStart, dataStart+glyph.Len(), dataStart+glyph.Len()+dupe.Len())
+		w.Write(glyph.Bytes())
+		w.Write(dupe.Bytes())
+		return w.Bytes()
+	}
+	smallStrike, largeStrike := strike(20, small), strike(40, large)
+	sbix := &tableWriter{}
+	sbix.u16(1, 1).u32(2, 16, 16+len(smallStrike))
+	sbix.Write(smallStrike)
+	sbix.Write(largeStrike)
+
+	face, err := parseColourFace(buildFont(map[string][]byte{
+		"cmap": testCmap(),
+		"maxp": testMaxp(3),
+		"sbix": sbix.Bytes(),
+	}))
+	require.Nil(t, err)
+
+	assert.Equal(t, small, face.sbixGlyph(1, 16))
+	assert.Equal(t, small, face.sbixGlyph(1, 20))
+	assert.Equal(t, large, face.sbixGlyph(1, 21))
+	assert.Equal(t, large, face.sbixGlyph(2, 100))
+	assert.Nil(t, face.sbixGlyph(0, 20))
+
+	img, err := face.glyph(0x1f601, 30, imagecolor.White)
+	require.Nil(t, err)
+	require.NotNil(t, img)
+	assert.Equal(t, image.Pt(40, 40), img.Bounds().Size())
+
+	img, err = face.glyph('a', 30, imagecolor.White)
+	assert.Nil(t, err)
+	assert.Nil(t, img)
+}
+
+func TestCbdtGlyphs(t *testing.T) {
+	red := testPNG(t, 8, 8, imagecolor.NRGBA{R: 255, A: 255})
+	green := testPNG(t, 8, 8, imagecolor.NRGBA{G: 255, A: 255})
+
+	// image format 17: small glyph metrics, then the length of the PNG
+	cbdt := (&tableWriter{}).u16(3, 0)
+	glyphStart := cbdt.Len()
+	for _, data := range [][]byte{red, green} {
+		cbdt.Write([]byte{8, 8, 0, 8, 8})
+		cbdt.u32(len(data))
+		cbdt.Write(data)
+	}
+	secondGlyph := glyphStart + 9 + len(red)
+
+	// one bitmap size covering glyphs 1 and 2 with an index subtable in format 1
+	cblc := (&tableWriter{}).u16(3, 0).u32(1)
+	arrayOffset := 8 + 48
+	cblc.u32(arrayOffset, 0, 1, 0)
+	cblc.Write(make([]byte, 24))
+	cblc.u16(1, 2)
+	cblc.Write([]byte{109, 109, 32, 1})
+	cblc.u16(1, 2).u32(8)
+	cblc.u16(1, 17).u32(glyphStart).u32(0, secondGlyph-glyphStart, cbdt.Len()-glyphStart)
+
+	face, err := parseColourFace(buildFont(map[string][]byte{
+		"cmap": testCmap(),
+		"maxp": testMaxp(3),
+		"CBDT": cbdt.Bytes(),
+		"CBLC": cblc.Bytes(),
+	}))
+	require.Nil(t, err)
+
+	assert.Equal(t, red, face.cbdtGlyph(1, 20))
+	assert.Equal(t, green, face.cbdtGlyph(2, 20))
+	assert.Nil(t, face.cbdtGlyph(3, 20))
+
+	img, err := face.glyph(0x1f601, 20, imagecolor.White)
+	require.Nil(t, err)
+	require.NotNil(t, img)
+	r, g, _, _ := img.At(4, 4).RGBA()
+	assert.Equal(t, uint32(0), r)
+	assert.Equal(t, uint32(0xffff), g)
+}
+
+func TestFontWithoutColourGlyphs(t *testing.T) {
+	_, err := parseColourFace(buildFont(map[string][]byte{
+		"cmap": testCmap(),
+		"maxp": testMaxp(3),
+	}))
+	assert.NotNil(t, err)
+
+	_, err = parseColourFace([]byte("not a font"))
+	assert.NotNil(t, err)
+}
+
+func TestPaletteColour(t *testing.T) {
+	// two palettes of two colours, with the second palette first in the colour records
+	cpal := (&tableWriter{}).u16(0, 2, 2, 4).u32(16).u16(2, 0)
+	cpal.Write([]byte{0, 0, 0xff, 0xff, 0xff, 0, 0, 0x80, 0x10, 0x20, 0x30, 0xff, 0, 0xff, 0, 0xff})
+
+	assert.Equal(t, imagecolor.NRGBA{R: 0x30, G: 0x20, B: 0x10, A: 0xff}, paletteColour(cpal.Bytes(), 0))
+	assert.Equal(t, imagecolor.NRGBA{G: 0xff, A: 0xff}, paletteColour(cpal.Bytes(), 1))
+	assert.Equal(t, imagecolor.Black, paletteColour(cpal.Bytes(), 5))
+}
+
+func TestDrawContour(t *testing.T) {
+	pt := func(x, y int, on bool) truetype.Point {
+		p := truetype.Point{X: fixed.I(x), Y: fixed.I(y)}
+		if on {
+			p.Flags = 1
+		}
+		return p
+	}
+
+	// a square in font coordinates, where y increases upwards, starting on an off curve point
+	rasterizer := vector.NewRasterizer(10, 10)
+	drawContour(rasterizer, []truetype.Point{pt(2, 8, false), pt(2, 2, true), pt(8, 2, true), pt(8, 8, true)}, 10)
+	img := image.NewAlpha(image.Rect(0, 0, 10, 10))
+	rasterizer.Draw(img, img.Bounds(), image.Opaque, image.Point{})
+
+	assert.Equal(t, uint8(0xff), img.AlphaAt(4, 5).A)
+	assert.Equal(t, uint8(0), img.AlphaAt(5, 1).A)
+	assert.Equal(t, uint8(0), img.AlphaAt(9, 5).A)
+}
+
+func TestFitImage(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 2; y++ {
+			img.Set(x, y, imagecolor.NRGBA{R: 255, A: 255})
+		}
+	}
+
+	fitted := fitImage(img, 10, 10)
+	assert.Equal(t, image.Rect(0, 0, 10, 10), fitted.Bounds())
+	assert.Equal(t, imagecolor.RGBA{R: 255, A: 255}, fitted.RGBAAt(5, 5))
+	assert.Equal(t, imagecolor.RGBA{}, fitted.RGBAAt(5, 1))
+	assert.Equal(t, imagecolor.RGBA{}, fitted.RGBAAt(5, 8))
+
+	assert.Equal(t, image.Rect(0, 0, 3, 3), fitImage(image.NewRGBA(image.Rectangle{}), 3, 3).Bounds())
+}
+
+func TestClosestSize(t *testing.T) {
+	assert.Equal(t, 1, closestSize([]int{20, 40, 160}, 30))
+	assert.Equal(t, 0, closestSize([]int{20, 40, 160}, 20))
+	assert.Equal(t, 2, closestSize([]int{20, 40, 160}, 200))
+	assert.Equal(t, 1, closestSize([]int{160, 40}, 10))
+}
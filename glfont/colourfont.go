@@ -0,0 +1,195 @@
+package glfont
+
+import (
+	"image"
+	imagecolor "image/color"
+	"io"
+	"io/ioutil"
+
+	"github.com/go-gl/gl/all-core/gl"
+)
+
+// A ColourFont draws the glyphs of a colour emoji font, which a Font can only draw in a single colour if at all.
+// Glyphs are scaled to fit the box they're drawn in rather than drawn at a font size.
+type ColourFont struct {
+	face     *colourFace
+	program  uint32
+	vao      uint32
+	vbo      uint32
+	textures map[colourGlyphKey]uint32 // 0 if the font has no image for the glyph
+	covered  map[rune]bool
+}
+
+type colourGlyphKey struct {
+	r          rune
+	width      int
+	height     int
+	foreground [3]float32
+}
+
+// LoadColourFont loads a colour emoji font, returning an error if it has no colour glyphs
+func LoadColourFont(reader io.Reader, windowWidth int, windowHeight int) (*ColourFont, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	face, err := parseColourFace(data)
+	if err != nil {
+		return nil, err
+	}
+
+	program, err := newProgram(vertexFontShader, fragmentColourFontShader)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &ColourFont{
+		face:     face,
+		program:  program,
+		textures: map[colourGlyphKey]uint32{},
+		covered:  map[rune]bool{},
+	}
+	f.UpdateResolution(windowWidth, windowHeight)
+
+	gl.GenVertexArrays(1, &f.vao)
+	gl.GenBuffers(1, &f.vbo)
+	gl.BindVertexArray(f.vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, f.vbo)
+
+	gl.BufferData(gl.ARRAY_BUFFER, 6*4*4, nil, gl.STATIC_DRAW)
+
+	vertAttrib := uint32(gl.GetAttribLocation(f.program, gl.Str("vert\x00")))
+	gl.EnableVertexAttribArray(vertAttrib)
+	gl.VertexAttribPointer(vertAttrib, 2, gl.FLOAT, false, 4*4, gl.PtrOffset(0))
+	defer gl.DisableVertexAttribArray(vertAttrib)
+
+	texCoordAttrib := uint32(gl.GetAttribLocation(f.program, gl.Str("vertTexCoord\x00")))
+	gl.EnableVertexAttribArray(texCoordAttrib)
+	gl.VertexAttribPointer(texCoordAttrib, 2, gl.FLOAT, false, 4*4, gl.PtrOffset(2*4))
+	defer gl.DisableVertexAttribArray(texCoordAttrib)
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+	gl.BindVertexArray(0)
+
+	return f, nil
+}
+
+func (f *ColourFont) Free() {
+	for _, texture := range f.textures {
+		if texture != 0 {
+			gl.DeleteTextures(1, &texture)
+		}
+	}
+
+	gl.DeleteBuffers(1, &f.vbo)
+	gl.DeleteVertexArrays(1, &f.vao)
+	gl.DeleteProgram(f.program)
+
+	f.vbo = 0
+	f.vao = 0
+	f.program = 0
+}
+
+func (f *ColourFont) UpdateResolution(windowWidth int, windowHeight int) {
+	gl.UseProgram(f.program)
+	resUniform := gl.GetUniformLocation(f.program, gl.Str("resolution\x00"))
+	gl.Uniform2f(resUniform, float32(windowWidth), float32(windowHeight))
+	gl.UseProgram(0)
+}
+
+// HasGlyph returns true if the font has a colour glyph for r
+func (f *ColourFont) HasGlyph(r rune) bool {
+	covered, ok := f.covered[r]
+	if !ok {
+		covered = f.face.cmap(r) != 0
+		f.covered[r] = covered
+	}
+	return covered
+}
+
+// Draw draws the glyph for r scaled to fit the box with its top left corner at x, y, returning false if the font has
+// no glyph for r. Parts of the glyph in the text colour, which only layered glyphs have, are drawn in foreground.
+func (f *ColourFont) Draw(r rune, x float32, y float32, width float32, height float32, foreground [3]float32, alpha float32) bool {
+	if !f.HasGlyph(r) {
+		return false
+	}
+
+	key := colourGlyphKey{r: r, width: int(width), height: int(height), foreground: foreground}
+	texture, ok := f.textures[key]
+	if !ok {
+		texture = f.loadGlyph(key)
+		f.textures[key] = texture
+	}
+	if texture == 0 {
+		return false
+	}
+
+	// the glyph images are premultiplied
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+
+	gl.UseProgram(f.program)
+	gl.Uniform1f(gl.GetUniformLocation(f.program, gl.Str("alpha\x00")), alpha)
+
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindVertexArray(f.vao)
+
+	x2, y2 := x+float32(key.width), y+float32(key.height)
+	var vertices = []float32{
+		x, y, 0.0, 0.0,
+		x2, y, 1.0, 0.0,
+		x, y2, 0.0, 1.0,
+		x, y2, 0.0, 1.0,
+		x2, y, 1.0, 0.0,
+		x2, y2, 1.0, 1.0}
+
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+	gl.BindBuffer(gl.ARRAY_BUFFER, f.vbo)
+	gl.BufferSubData(gl.ARRAY_BUFFER, 0, len(vertices)*4, gl.Ptr(vertices))
+	gl.DrawArrays(gl.TRIANGLES, 0, 6)
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+
+	gl.BindVertexArray(0)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	gl.UseProgram(0)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	gl.Disable(gl.BLEND)
+
+	return true
+}
+
+// loadGlyph creates a texture for a glyph, or returns 0 if the font has no image for it
+func (f *ColourFont) loadGlyph(key colourGlyphKey) uint32 {
+	if key.width <= 0 || key.height <= 0 {
+		return 0
+	}
+
+	foreground := imagecolor.NRGBA{
+		R: uint8(key.foreground[0] * 255),
+		G: uint8(key.foreground[1] * 255),
+		B: uint8(key.foreground[2] * 255),
+		A: 255,
+	}
+	img, err := f.face.glyph(key.r, key.height, foreground)
+	if err != nil || img == nil {
+		return 0
+	}
+	rgba := fitImage(img, key.width, key.height)
+
+	return newColourTexture(rgba)
+}
+
+func newColourTexture(rgba *image.RGBA) uint32 {
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, int32(rgba.Rect.Dx()), int32(rgba.Rect.Dy()), 0,
+		gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(rgba.Pix))
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	return texture
+}
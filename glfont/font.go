@@ -17,17 +17,24 @@ const DPI = 72
 
 // A Font allows rendering of text to an OpenGL context.
 type Font struct {
-	characters  map[rune]*character
-	vao         uint32
-	vbo         uint32
-	program     uint32
-	texture     uint32 // Holds the glyph texture id.
-	color       color
-	ttf         *truetype.Font
-	ttfFace     font.Face
-	scale       float32
-	linePadding float32
-	lineHeight  float32
+	characters      map[rune]*character
+	vao             uint32
+	vbo             uint32
+	program         uint32
+	texture         uint32      // Holds the glyph texture id.
+	atlas           *image.RGBA // a copy of the glyph texture, see addToAtlas
+	packer          shelfPacker
+	batch           glyphBatch // glyphs queued to be drawn by Flush
+	color           color
+	ttf             *truetype.Font
+	ttfFace         font.Face
+	scale           float32
+	linePadding     float32
+	lineHeight      float32
+	subpixel        bool // glyphs have a coverage for each subpixel, see SetSubpixelAntialiasing
+	syntheticBold   bool // glyphs are emboldened, see SetSyntheticStyle
+	syntheticItalic bool // glyphs are slanted, see SetSyntheticStyle
+	glyphBuf        truetype.GlyphBuf
 }
 
 type color struct {
@@ -58,9 +65,9 @@ func LoadFont(reader io.Reader, scale float32, windowWidth int, windowHeight int
 }
 
 func (f *Font) Free() {
-	for _, chr := range f.characters {
-		gl.DeleteTextures(1, &chr.textureID)
-	}
+	gl.DeleteTextures(1, &f.texture)
+	f.texture = 0
+	f.clearGlyphs()
 
 	gl.DeleteBuffers(1, &f.vbo)
 	gl.DeleteVertexArrays(1, &f.vao)
@@ -96,88 +103,108 @@ func (f *Font) LinePadding() float32 {
 	return f.linePadding
 }
 
-//Printf draws a string to the screen, takes a list of arguments like printf
+// Print draws text straight away, as Queue and Flush do
 func (f *Font) Print(x, y float32, text string) error {
+	if err := f.Queue(x, y, text); err != nil {
+		return err
+	}
+	f.Flush()
+	return nil
+}
 
-	indices := []rune(text)
+// Queue adds text starting at x on the baseline y to the glyphs drawn by the next Flush, in the current colour
+func (f *Font) Queue(x, y float32, text string) error {
+	for _, r := range text {
+		ch, err := f.GetRune(r)
+		if err != nil {
+			return err
+		}
+		f.queueGlyph(ch, x, y)
 
-	if len(indices) == 0 {
-		return nil
+		// Now advance cursors for next glyph (note that advance is number of 1/64 pixels)
+		x += float32((ch.advance >> 6)) // Bitshift by 6 to get value in pixels (2^6 = 64 (divide amount of 1/64th pixels by 64 to get amount of pixels))
 	}
+	return nil
+}
 
-	//setup blending mode
-	gl.Enable(gl.BLEND)
-	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+// QueueCentred queues r centred horizontally in the width pixels from x, whatever its advance and bearing. Fonts
+// position combining marks in different ways, and this places them over the character they're attached to.
+func (f *Font) QueueCentred(x, y, width float32, r rune) error {
+	ch, err := f.GetRune(r)
+	if err != nil {
+		return err
+	}
+	f.queueGlyph(ch, x+(width-float32(ch.width))/2-float32(ch.bearingH), y)
+	return nil
+}
 
-	// Activate corresponding render state
-	gl.UseProgram(f.program)
-	//set text color
-	gl.Uniform4f(gl.GetUniformLocation(f.program, gl.Str("textColor\x00")), f.color.r, f.color.g, f.color.b, f.color.a)
-	//set screen resolution
-	//resUniform := gl.GetUniformLocation(f.program, gl.Str("resolution\x00"))
-	//gl.Uniform2f(resUniform, float32(2560), float32(1440))
+func (f *Font) queueGlyph(ch *character, x, y float32) {
+	xpos := x + float32(ch.bearingH)
+	ypos := y - float32(ch.height-ch.bearingV)
+	f.batch.add(
+		xpos, ypos, xpos+float32(ch.width), ypos+float32(ch.height),
+		float32(ch.x), float32(ch.y), float32(ch.x+ch.width), float32(ch.y+ch.height),
+		f.color,
+	)
+}
 
+// Flush draws the glyphs queued since the last Flush. They're drawn with a single draw call, or with subpixel
+// antialiasing, one for each colour.
+func (f *Font) Flush() {
+	if f.batch.vertices == 0 {
+		return
+	}
+
+	gl.Enable(gl.BLEND)
+	gl.UseProgram(f.program)
+	gl.Uniform1i(gl.GetUniformLocation(f.program, gl.Str("subpixel\x00")), boolToInt(f.subpixel))
 	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, f.texture)
 	gl.BindVertexArray(f.vao)
-
-	// Iterate through all characters in string
-	for i := range indices {
-
-		//get rune
-		runeIndex := indices[i]
-
-		//find rune in fontChar list
-		ch, err := f.GetRune(runeIndex)
-		if err != nil {
-			return err // @todo ignore errors?
+	gl.BindBuffer(gl.ARRAY_BUFFER, f.vbo)
+
+	if f.subpixel {
+		// the shader outputs the coverage of each subpixel, which mixes the text colour with the background per channel,
+		// so the colour is a blending constant
+		gl.BlendFunc(gl.CONSTANT_COLOR, gl.ONE_MINUS_SRC_COLOR)
+		for _, run := range f.batch.runs {
+			if len(run.vertices) == 0 {
+				continue
+			}
+			gl.BlendColor(run.colour.r, run.colour.g, run.colour.b, 1)
+			gl.BufferData(gl.ARRAY_BUFFER, len(run.vertices)*4, gl.Ptr(run.vertices), gl.STREAM_DRAW)
+			gl.DrawArrays(gl.TRIANGLES, 0, int32(len(run.vertices)/vertexSize))
 		}
-
-		//calculate position and size for current rune
-		xpos := x + float32(ch.bearingH)
-		ypos := y - float32(+ch.height-ch.bearingV)
-		w := float32(ch.width)
-		h := float32(ch.height)
-
-		//set quad positions
-		var x1 = xpos
-		var x2 = xpos + w
-		var y1 = ypos
-		var y2 = ypos + h
-
-		//setup quad array
-		var vertices = []float32{
-			//  X, Y, Z, U, V
-			// Front
-			x1, y1, 0.0, 0.0,
-			x2, y1, 1.0, 0.0,
-			x1, y2, 0.0, 1.0,
-			x1, y2, 0.0, 1.0,
-			x2, y1, 1.0, 0.0,
-			x2, y2, 1.0, 1.0}
-
-		// Render glyph texture over quad
-		gl.BindTexture(gl.TEXTURE_2D, ch.textureID)
-		// Update content of VBO memory
-		gl.BindBuffer(gl.ARRAY_BUFFER, f.vbo)
-
-		//BufferSubData(target Enum, offset int, data []byte)
-		gl.BufferSubData(gl.ARRAY_BUFFER, 0, len(vertices)*4, gl.Ptr(vertices)) // Be sure to use glBufferSubData and not glBufferData
-		// Render quad
-		gl.DrawArrays(gl.TRIANGLES, 0, 24)
-
-		gl.BindBuffer(gl.ARRAY_BUFFER, 0)
-		// Now advance cursors for next glyph (note that advance is number of 1/64 pixels)
-		x += float32((ch.advance >> 6)) // Bitshift by 6 to get value in pixels (2^6 = 64 (divide amount of 1/64th pixels by 64 to get amount of pixels))
-
+	} else {
+		// every colour is copied into the one buffer
+		gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+		gl.BufferData(gl.ARRAY_BUFFER, f.batch.vertices*vertexSize*4, nil, gl.STREAM_DRAW)
+		offset := 0
+		for _, run := range f.batch.runs {
+			if len(run.vertices) == 0 {
+				continue
+			}
+			gl.BufferSubData(gl.ARRAY_BUFFER, offset, len(run.vertices)*4, gl.Ptr(run.vertices))
+			offset += len(run.vertices) * 4
+		}
+		gl.DrawArrays(gl.TRIANGLES, 0, int32(f.batch.vertices))
 	}
+	f.batch.reset()
 
 	//clear opengl textures and programs
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
 	gl.BindVertexArray(0)
 	gl.BindTexture(gl.TEXTURE_2D, 0)
 	gl.UseProgram(0)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
 	gl.Disable(gl.BLEND)
+}
 
-	return nil
+func boolToInt(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
 }
 
 //Width returns the width of a piece of text in pixels
@@ -231,6 +258,20 @@ func (f *Font) MaxY() float32 {
 	return float32(b.Max.Y)
 }
 
+// clearGlyphs empties the atlas of the glyphs drawn so far, so that they're drawn again when they're next used
+func (f *Font) clearGlyphs() {
+	f.batch.reset()
+	f.characters = map[rune]*character{}
+	if f.texture != 0 {
+		f.resetAtlas(f.packer.width)
+	}
+}
+
+// HasGlyph returns true if the font has a glyph for r
+func (f *Font) HasGlyph(r rune) bool {
+	return f.ttf.Index(r) != 0
+}
+
 func (f *Font) GetRune(r rune) (*character, error) {
 
 	cc, ok := f.characters[r]
@@ -272,44 +313,55 @@ func (f *Font) GetRune(r rune) (*character, error) {
 	char.bearingV = gdescent
 	char.bearingH = (int(gBnd.Min.X) >> 6)
 
-	//create image to draw glyph
-	fg, bg := image.White, image.Black
-	rect := image.Rect(0, 0, int(gw), int(gh))
-	rgba := image.NewRGBA(rect)
-	draw.Draw(rgba, rgba.Bounds(), bg, image.ZP, draw.Src)
-
-	//create a freetype context for drawing
-	c := freetype.NewContext()
-	c.SetDPI(DPI)
-	c.SetFont(f.ttf)
-	c.SetFontSize(float64(f.scale))
-	c.SetClip(rgba.Bounds())
-	c.SetDst(rgba)
-	c.SetSrc(fg)
-	c.SetHinting(font.HintingFull)
-
 	//set the glyph dot
 	px := 0 - (int(gBnd.Min.X) >> 6)
 	py := (gAscent)
-	pt := freetype.Pt(px, py)
 
-	// Draw the text from mask to image
-	if _, err := c.DrawString(string(r), pt); err != nil {
-		return nil, err
+	var rgba *image.RGBA
+	if f.subpixel {
+		var err error
+		if rgba, err = f.subpixelGlyph(r, int(gw), int(gh), px, py); err != nil {
+			return nil, err
+		}
+	} else {
+		//create image to draw glyph
+		fg, bg := image.White, image.Black
+		rect := image.Rect(0, 0, int(gw), int(gh))
+		rgba = image.NewRGBA(rect)
+		draw.Draw(rgba, rgba.Bounds(), bg, image.ZP, draw.Src)
+
+		//create a freetype context for drawing
+		c := freetype.NewContext()
+		c.SetDPI(DPI)
+		c.SetFont(f.ttf)
+		c.SetFontSize(float64(f.scale))
+		c.SetClip(rgba.Bounds())
+		c.SetDst(rgba)
+		c.SetSrc(fg)
+		c.SetHinting(font.HintingFull)
+
+		// Draw the text from mask to image
+		if _, err := c.DrawString(string(r), freetype.Pt(px, py)); err != nil {
+			return nil, err
+		}
+	}
+
+	if f.syntheticItalic {
+		var left int
+		rgba, left = slant(rgba, py, italicSlant)
+		char.bearingH += left
+	}
+	if f.syntheticBold {
+		rgba = embolden(rgba)
 	}
+	char.width = rgba.Rect.Dx()
 
-	// Generate texture
-	var texture uint32
-	gl.GenTextures(1, &texture)
-	gl.BindTexture(gl.TEXTURE_2D, texture)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
-	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, int32(rgba.Rect.Dx()), int32(rgba.Rect.Dy()), 0,
-		gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(rgba.Pix))
-
-	char.textureID = texture
+	x, y, err := f.addToAtlas(rgba)
+	if err != nil {
+		return nil, err
+	}
+	char.x = x
+	char.y = y
 
 	f.characters[r] = char
 
@@ -69,15 +69,36 @@ func compileShader(source string, shaderType uint32) (uint32, error) {
 
 var fragmentFontShader = `#version 150 core
 in vec2 fragTexCoord;
+in vec4 fragColour;
 out vec4 outputColor;
 
 uniform sampler2D tex;
-uniform vec4 textColor;
+uniform bool subpixel;
 
 void main()
 {    
-    vec4 sampled = vec4(1.0, 1.0, 1.0, texture(tex, fragTexCoord).r);
-    outputColor = textColor * sampled;
+    // glyphs are positioned in the atlas in pixels
+    vec2 texCoord = fragTexCoord / vec2(textureSize(tex, 0));
+    if (subpixel) {
+        // the coverage of each subpixel, which is blended with the text colour as a constant
+        outputColor = vec4(texture(tex, texCoord).rgb * fragColour.a, 1.0);
+        return;
+    }
+    vec4 sampled = vec4(1.0, 1.0, 1.0, texture(tex, texCoord).r);
+    outputColor = fragColour * sampled;
+}` + "\x00"
+
+// glyphs of colour fonts are drawn in their own colours, from textures with premultiplied alpha
+var fragmentColourFontShader = `#version 150 core
+in vec2 fragTexCoord;
+out vec4 outputColor;
+
+uniform sampler2D tex;
+uniform float alpha;
+
+void main()
+{
+    outputColor = texture(tex, fragTexCoord) * alpha;
 }` + "\x00"
 
 var vertexFontShader = `#version 150 core
@@ -88,11 +109,15 @@ in vec2 vert;
 //pass through to fragTexCoord
 in vec2 vertTexCoord;
 
+//pass through to fragColour, for glyphs drawn in the colour of each vertex
+in vec4 vertColour;
+
 //window res
 uniform vec2 resolution;
 
 //pass to frag
 out vec2 fragTexCoord;
+out vec4 fragColour;
 
 void main() {
    // convert the rectangle from pixels to 0.0 to 1.0
@@ -105,6 +130,7 @@ void main() {
    vec2 clipSpace = zeroToTwo - 1.0;
 
    fragTexCoord = vertTexCoord;
+   fragColour = vertColour;
 
    gl_Position = vec4(clipSpace * vec2(1, -1), 0, 1);
 }` + "\x00"
@@ -0,0 +1,83 @@
+package glfont
+
+import (
+	"image"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// lcdFilter spreads the coverage of each subpixel over its neighbours, as an unfiltered glyph has coloured fringes.
+// These are the weights of FreeType's default LCD filter.
+var lcdFilter = [5]float32{0x08 / 256.0, 0x4d / 256.0, 0x56 / 256.0, 0x4d / 256.0, 0x08 / 256.0}
+
+// SetSubpixelAntialiasing switches between drawing glyphs with a separate coverage for the red, green and blue
+// subpixels of each pixel, which is sharper on LCDs with subpixels in that order, and grayscale antialiasing
+func (f *Font) SetSubpixelAntialiasing(enabled bool) {
+	if f.subpixel == enabled {
+		return
+	}
+	f.subpixel = enabled
+	f.clearGlyphs()
+}
+
+// subpixelGlyph draws the glyph for r width by height pixels with the origin at x, y, in the same place a grayscale
+// glyph would be, storing the coverage of each subpixel in the red, green and blue channels
+func (f *Font) subpixelGlyph(r rune, width int, height int, x int, y int) (*image.RGBA, error) {
+	if err := f.glyphBuf.Load(f.ttf, fixed.Int26_6(f.scale*64), f.ttf.Index(r), font.HintingFull); err != nil {
+		return nil, err
+	}
+
+	// the outline is stretched to three times the width, so each pixel of the rasterized glyph is a subpixel
+	points := make([]truetype.Point, len(f.glyphBuf.Points))
+	for i, p := range f.glyphBuf.Points {
+		p.X = (p.X + fixed.I(x)) * 3
+		points[i] = p
+	}
+
+	rasterizer := vector.NewRasterizer(width*3, height)
+	start := 0
+	for _, end := range f.glyphBuf.Ends {
+		drawContour(rasterizer, points[start:end], float32(y))
+		start = end
+	}
+	coverage := image.NewAlpha(image.Rect(0, 0, width*3, height))
+	rasterizer.Draw(coverage, coverage.Bounds(), image.Opaque, image.Point{})
+
+	return filterSubpixels(coverage), nil
+}
+
+// filterSubpixels combines the coverage of every three subpixels into a pixel, applying lcdFilter. The alpha of each
+// pixel is the greatest coverage of its subpixels.
+func filterSubpixels(coverage *image.Alpha) *image.RGBA {
+	width, height := coverage.Rect.Dx()/3, coverage.Rect.Dy()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			pixel := img.Pix[img.PixOffset(x, y):]
+			var alpha uint8
+			for channel := 0; channel < 3; channel++ {
+				var value float32
+				for i, weight := range lcdFilter {
+					subpixel := x*3 + channel + i - len(lcdFilter)/2
+					if subpixel >= 0 && subpixel < width*3 {
+						value += weight * float32(coverage.AlphaAt(subpixel, y).A)
+					}
+				}
+				if value > 255 {
+					value = 255
+				}
+				pixel[channel] = uint8(value + 0.5)
+				if pixel[channel] > alpha {
+					alpha = pixel[channel]
+				}
+			}
+			pixel[3] = alpha
+		}
+	}
+
+	return img
+}
@@ -0,0 +1,35 @@
+package glfont
+
+import (
+	"image"
+	imagecolor "image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterSubpixels(t *testing.T) {
+	// three pixels, with only the subpixels of the middle one covered
+	coverage := image.NewAlpha(image.Rect(0, 0, 9, 1))
+	for x := 3; x < 6; x++ {
+		coverage.SetAlpha(x, 0, imagecolor.Alpha{A: 255})
+	}
+
+	img := filterSubpixels(coverage)
+	assert.Equal(t, image.Rect(0, 0, 3, 1), img.Bounds())
+
+	// the filter spreads the coverage two subpixels either side
+	assert.Equal(t, imagecolor.RGBA{R: 0, G: 8, B: 85, A: 85}, img.RGBAAt(0, 0))
+	assert.Equal(t, imagecolor.RGBA{R: 85, G: 8, B: 0, A: 85}, img.RGBAAt(2, 0))
+	middle := img.RGBAAt(1, 0)
+	assert.Equal(t, middle.R, middle.B)
+	assert.True(t, middle.G > middle.R)
+	assert.Equal(t, middle.G, middle.A)
+
+	// fully covered pixels are opaque in every channel
+	for x := 0; x < 9; x++ {
+		coverage.SetAlpha(x, 0, imagecolor.Alpha{A: 255})
+	}
+	img = filterSubpixels(coverage)
+	assert.Equal(t, imagecolor.RGBA{R: 255, G: 255, B: 255, A: 255}, img.RGBAAt(1, 0))
+}
@@ -0,0 +1,84 @@
+package glfont
+
+import (
+	"image"
+	"math"
+)
+
+// italicSlant is how far a synthesized italic glyph leans, in pixels across per pixel up
+const italicSlant = 0.2
+
+// SetSyntheticStyle emboldens or slants every glyph, which stands in for a bold or italic face that the font doesn't
+// have
+func (f *Font) SetSyntheticStyle(bold bool, italic bool) {
+	if f.syntheticBold == bold && f.syntheticItalic == italic {
+		return
+	}
+	f.syntheticBold = bold
+	f.syntheticItalic = italic
+	f.clearGlyphs()
+}
+
+// embolden widens a glyph by a pixel, drawing it over itself one pixel to the right
+func embolden(img *image.RGBA) *image.RGBA {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	bold := image.NewRGBA(image.Rect(0, 0, width+1, height))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x <= width; x++ {
+			pixel := bold.Pix[bold.PixOffset(x, y):]
+			for i := 0; i < 4; i++ {
+				var value uint8
+				if x < width {
+					value = img.Pix[img.PixOffset(x, y)+i]
+				}
+				if x > 0 && img.Pix[img.PixOffset(x-1, y)+i] > value {
+					value = img.Pix[img.PixOffset(x-1, y)+i]
+				}
+				pixel[i] = value
+			}
+		}
+	}
+	return bold
+}
+
+// slant shears a glyph whose baseline is the given row, so that each row is moved right by slope pixels for every
+// pixel it's above the baseline, and left for every pixel it's below. It returns the slanted glyph, which is wider,
+// and how many pixels to the left of the original it starts.
+func slant(img *image.RGBA, baseline int, slope float64) (*image.RGBA, int) {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	shift := func(y int) float64 {
+		return (float64(baseline-y) - 0.5) * slope
+	}
+
+	left := int(math.Floor(shift(height - 1)))
+	if left > 0 {
+		left = 0
+	}
+	right := int(math.Ceil(shift(0)))
+	if right < 0 {
+		right = 0
+	}
+	slanted := image.NewRGBA(image.Rect(0, 0, width+right-left, height))
+
+	for y := 0; y < height; y++ {
+		// each pixel is spread over the two it falls between
+		offset := shift(y) - float64(left)
+		whole := int(math.Floor(offset))
+		fraction := offset - float64(whole)
+		for x := 0; x < slanted.Rect.Dx(); x++ {
+			pixel := slanted.Pix[slanted.PixOffset(x, y):]
+			for i := 0; i < 4; i++ {
+				var value float64
+				if from := x - whole; from >= 0 && from < width {
+					value += float64(img.Pix[img.PixOffset(from, y)+i]) * (1 - fraction)
+				}
+				if from := x - whole - 1; from >= 0 && from < width {
+					value += float64(img.Pix[img.PixOffset(from, y)+i]) * fraction
+				}
+				pixel[i] = uint8(value + 0.5)
+			}
+		}
+	}
+	return slanted, left
+}
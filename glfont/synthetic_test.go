@@ -0,0 +1,53 @@
+package glfont
+
+import (
+	"image"
+	imagecolor "image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var opaqueWhite = imagecolor.RGBA{R: 255, G: 255, B: 255, A: 255}
+
+func TestEmbolden(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 1))
+	img.SetRGBA(1, 0, opaqueWhite)
+
+	bold := embolden(img)
+	assert.Equal(t, image.Rect(0, 0, 4, 1), bold.Bounds())
+	assert.Equal(t, imagecolor.RGBA{}, bold.RGBAAt(0, 0))
+	assert.Equal(t, opaqueWhite, bold.RGBAAt(1, 0))
+	assert.Equal(t, opaqueWhite, bold.RGBAAt(2, 0))
+	assert.Equal(t, imagecolor.RGBA{}, bold.RGBAAt(3, 0))
+}
+
+func TestSlant(t *testing.T) {
+	// a vertical line two pixels wide, one row of which is below the baseline
+	img := image.NewRGBA(image.Rect(0, 0, 2, 5))
+	for y := 0; y < 5; y++ {
+		img.SetRGBA(0, y, opaqueWhite)
+		img.SetRGBA(1, y, opaqueWhite)
+	}
+
+	slanted, left := slant(img, 4, 0.5)
+	assert.Equal(t, -1, left)
+	assert.Equal(t, image.Rect(0, 0, 5, 5), slanted.Bounds())
+
+	// every row moves half a pixel further right than the one below it, and no coverage is lost
+	previous := -1.0
+	for y := 4; y >= 0; y-- {
+		var coverage, moment float64
+		for x := 0; x < slanted.Rect.Dx(); x++ {
+			value := float64(slanted.RGBAAt(x, y).R)
+			coverage += value
+			moment += value * float64(x)
+		}
+		assert.InDelta(t, 510, coverage, 2)
+		centre := moment / coverage
+		if y < 4 {
+			assert.InDelta(t, 0.5, centre-previous, 0.01)
+		}
+		previous = centre
+	}
+}
@@ -10,12 +10,13 @@ import (
 )
 
 type character struct {
-	textureID uint32 // ID handle of the glyph texture
-	width     int    //glyph width
-	height    int    //glyph height
-	advance   int    //glyph advance
-	bearingH  int    //glyph bearing horizontal
-	bearingV  int    //glyph bearing vertical
+	x        int // position of the glyph in the atlas
+	y        int
+	width    int //glyph width
+	height   int //glyph height
+	advance  int //glyph advance
+	bearingH int //glyph bearing horizontal
+	bearingV int //glyph bearing vertical
 }
 
 //LoadTrueTypeFont builds a set of textures based on a ttf files glyphs
@@ -48,18 +49,21 @@ func LoadTrueTypeFont(program uint32, r io.Reader, scale float32) (*Font, error)
 	gl.BindVertexArray(f.vao)
 	gl.BindBuffer(gl.ARRAY_BUFFER, f.vbo)
 
-	gl.BufferData(gl.ARRAY_BUFFER, 6*4*4, nil, gl.STATIC_DRAW)
-
 	vertAttrib := uint32(gl.GetAttribLocation(f.program, gl.Str("vert\x00")))
 	gl.EnableVertexAttribArray(vertAttrib)
-	gl.VertexAttribPointer(vertAttrib, 2, gl.FLOAT, false, 4*4, gl.PtrOffset(0))
+	gl.VertexAttribPointer(vertAttrib, 2, gl.FLOAT, false, vertexSize*4, gl.PtrOffset(0))
 	defer gl.DisableVertexAttribArray(vertAttrib)
 
 	texCoordAttrib := uint32(gl.GetAttribLocation(f.program, gl.Str("vertTexCoord\x00")))
 	gl.EnableVertexAttribArray(texCoordAttrib)
-	gl.VertexAttribPointer(texCoordAttrib, 2, gl.FLOAT, false, 4*4, gl.PtrOffset(2*4))
+	gl.VertexAttribPointer(texCoordAttrib, 2, gl.FLOAT, false, vertexSize*4, gl.PtrOffset(2*4))
 	defer gl.DisableVertexAttribArray(texCoordAttrib)
 
+	colourAttrib := uint32(gl.GetAttribLocation(f.program, gl.Str("vertColour\x00")))
+	gl.EnableVertexAttribArray(colourAttrib)
+	gl.VertexAttribPointer(colourAttrib, 4, gl.FLOAT, false, vertexSize*4, gl.PtrOffset(4*4))
+	defer gl.DisableVertexAttribArray(colourAttrib)
+
 	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
 	gl.BindVertexArray(0)
 
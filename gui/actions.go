@@ -9,12 +9,28 @@ import (
 )
 
 var actionMap = map[config.UserAction]func(gui *GUI){
-	config.ActionCopy:        actionCopy,
-	config.ActionPaste:       actionPaste,
-	config.ActionToggleDebug: actionToggleDebug,
-	config.ActionSearch:      actionSearchSelection,
-	config.ActionToggleSlomo: actionToggleSlomo,
-	config.ActionReportBug:   actionReportBug,
+	config.ActionCopy:             actionCopy,
+	config.ActionCopyTable:        actionCopyTable,
+	config.ActionCopyOutput:       actionCopyOutput,
+	config.ActionPaste:            actionPaste,
+	config.ActionToggleDebug:      actionToggleDebug,
+	config.ActionSearch:           actionSearchSelection,
+	config.ActionFind:             actionFind,
+	config.ActionToggleSlomo:      actionToggleSlomo,
+	config.ActionToggleControls:   actionToggleControls,
+	config.ActionToggleWhitespace: actionToggleWhitespace,
+	config.ActionReportBug:        actionReportBug,
+	config.ActionNextFont:         actionNextFont,
+	config.ActionLinkHints:        actionLinkHints,
+	config.ActionChooseShell:      actionChooseShell,
+	config.ActionToggleRecording:  actionToggleRecording,
+	config.ActionFontBigger:       actionFontBigger,
+	config.ActionFontSmaller:      actionFontSmaller,
+	config.ActionFontReset:        actionFontReset,
+	config.ActionNewTab:           actionNewTab,
+	config.ActionCloseTab:         actionCloseTab,
+	config.ActionNextTab:          actionNextTab,
+	config.ActionPreviousTab:      actionPreviousTab,
 }
 
 func actionCopy(gui *GUI) {
@@ -25,9 +41,27 @@ func actionCopy(gui *GUI) {
 	}
 }
 
+// actionCopyTable copies a block selection as tab separated values
+func actionCopyTable(gui *GUI) {
+	table := gui.terminal.ActiveBuffer().GetSelectedTable()
+
+	if table != "" {
+		gui.window.SetClipboardString(table)
+	}
+}
+
+// actionCopyOutput copies the output of the last command without having to select it
+func actionCopyOutput(gui *GUI) {
+	output, ok := gui.terminal.ActiveBuffer().GetLastCommandOutput()
+
+	if ok && output != "" {
+		gui.window.SetClipboardString(output)
+	}
+}
+
 func actionPaste(gui *GUI) {
 	if s, err := gui.window.GetClipboardString(); err == nil {
-		_ = gui.terminal.Paste([]byte(s))
+		gui.paste(s)
 	}
 }
 
@@ -47,6 +81,59 @@ func actionToggleSlomo(gui *GUI) {
 	gui.config.Slomo = !gui.config.Slomo
 }
 
+func actionToggleControls(gui *GUI) {
+	gui.config.ShowControls = !gui.config.ShowControls
+}
+
+func actionToggleWhitespace(gui *GUI) {
+	gui.config.ShowWhitespace = !gui.config.ShowWhitespace
+	gui.terminal.SetDirty()
+}
+
+func actionFind(gui *GUI) {
+	gui.showSearch()
+}
+
+func actionNextFont(gui *GUI) {
+	gui.nextFont()
+}
+
+func actionFontBigger(gui *GUI) {
+	gui.setFontSize(gui.fontScale + 1)
+}
+
+func actionFontSmaller(gui *GUI) {
+	gui.setFontSize(gui.fontScale - 1)
+}
+
+func actionFontReset(gui *GUI) {
+	gui.setFontSize(gui.config.FontSize)
+}
+
+func actionNewTab(gui *GUI) {
+	gui.openTab("")
+}
+
+func actionCloseTab(gui *GUI) {
+	gui.closeTab(gui.tabs[gui.activeTab])
+}
+
+func actionNextTab(gui *GUI) {
+	gui.stepTab(1)
+}
+
+func actionPreviousTab(gui *GUI) {
+	gui.stepTab(-1)
+}
+
+func actionLinkHints(gui *GUI) {
+	gui.showLinkHints()
+}
+
+func actionChooseShell(gui *GUI) {
+	gui.showShellMenu()
+}
+
 func actionReportBug(gui *GUI) {
 	gui.launchTarget("https://github.com/liamg/aminal/issues/new/choose")
 }
@@ -0,0 +1,62 @@
+package gui
+
+import "github.com/go-gl/gl/all-core/gl"
+
+// rectBatch collects solid rectangles, such as cell backgrounds and underlines, to be drawn with a single draw call
+type rectBatch struct {
+	vao       uint32
+	points    uint32 // vertex buffers
+	colours   uint32
+	positions []float32 // x, y, z of each vertex, in clip space
+	rgb       []float32 // colour of each vertex
+}
+
+func (batch *rectBatch) add(points [18]float32, colour [3]float32) {
+	batch.positions = append(batch.positions, points[:]...)
+	for i := 0; i < 6; i++ {
+		batch.rgb = append(batch.rgb, colour[0], colour[1], colour[2])
+	}
+}
+
+// flush draws the rectangles added since the last flush with the given program
+func (batch *rectBatch) flush(program uint32, colourAttr uint32) {
+	if len(batch.positions) == 0 {
+		return
+	}
+
+	if batch.vao == 0 {
+		gl.GenVertexArrays(1, &batch.vao)
+		gl.GenBuffers(1, &batch.points)
+		gl.GenBuffers(1, &batch.colours)
+	}
+
+	gl.UseProgram(program)
+	gl.BindVertexArray(batch.vao)
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, batch.points)
+	gl.BufferData(gl.ARRAY_BUFFER, len(batch.positions)*4, gl.Ptr(batch.positions), gl.STREAM_DRAW)
+	gl.EnableVertexAttribArray(0)
+	gl.VertexAttribPointer(0, 3, gl.FLOAT, false, 0, nil)
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, batch.colours)
+	gl.BufferData(gl.ARRAY_BUFFER, len(batch.rgb)*4, gl.Ptr(batch.rgb), gl.STREAM_DRAW)
+	gl.EnableVertexAttribArray(colourAttr)
+	gl.VertexAttribPointer(colourAttr, 3, gl.FLOAT, false, 0, nil)
+
+	gl.DrawArrays(gl.TRIANGLES, 0, int32(len(batch.positions)/3))
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+	gl.BindVertexArray(0)
+
+	batch.positions = batch.positions[:0]
+	batch.rgb = batch.rgb[:0]
+}
+
+func (batch *rectBatch) free() {
+	if batch.vao != 0 {
+		gl.DeleteVertexArrays(1, &batch.vao)
+		gl.DeleteBuffers(1, &batch.points)
+		gl.DeleteBuffers(1, &batch.colours)
+		batch.vao = 0
+	}
+}
@@ -0,0 +1,117 @@
+package gui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/liamg/aminal/config"
+	"github.com/liamg/aminal/terminal"
+)
+
+const clipboardPreviewLength = 60
+
+// handleClipboardRequest applies an OSC 52 clipboard request from the application, asking the user first if it wants to read the clipboard
+func (gui *GUI) handleClipboardRequest(request terminal.ClipboardRequest) {
+	if !request.Read {
+		gui.window.SetClipboardString(request.Data)
+		return
+	}
+
+	switch gui.config.ClipboardRead {
+	case config.ClipboardPolicyAllow:
+		gui.replyToClipboardRead(request)
+	case config.ClipboardPolicyPrompt:
+		if gui.overlay != nil {
+			gui.logger.Infof("Denied clipboard read while another overlay is shown")
+			return
+		}
+		gui.setOverlay(newConfirmation(gui.clipboardReadMessage(), func(allowed bool) {
+			if allowed {
+				gui.replyToClipboardRead(request)
+			} else {
+				gui.logger.Infof("Clipboard read denied by user")
+			}
+		}))
+	default:
+		gui.logger.Infof("Denied clipboard read as clipboard_read is %q", gui.config.ClipboardRead)
+	}
+}
+
+func (gui *GUI) clipboardReadMessage() string {
+	contents, err := gui.window.GetClipboardString()
+	if err != nil {
+		contents = ""
+	}
+
+	preview := strings.Join(strings.Fields(contents), " ")
+	if len([]rune(preview)) > clipboardPreviewLength {
+		preview = string([]rune(preview)[:clipboardPreviewLength]) + "..."
+	}
+
+	return fmt.Sprintf("An application is asking to read your clipboard (%d characters):\n\n%s\n\nAllow? Press Y to send the clipboard contents, or N to deny.", len([]rune(contents)), preview)
+}
+
+func (gui *GUI) replyToClipboardRead(request terminal.ClipboardRequest) {
+	contents, err := gui.window.GetClipboardString()
+	if err != nil {
+		gui.logger.Errorf("Failed to read clipboard: %s", err)
+		return
+	}
+	if err := gui.terminal.ReplyToClipboardRead(request, contents); err != nil {
+		gui.logger.Errorf("Failed to send clipboard contents: %s", err)
+	}
+}
+
+// paste pastes text into the terminal. If paste_protection is enabled and the text looks like it runs a
+// privileged command, the user is asked to confirm first, even if the application has enabled bracketed paste.
+func (gui *GUI) paste(text string) {
+	if gui.config.PasteProtection {
+		if line, ok := terminal.PrivilegedPasteLine(text); ok {
+			gui.setOverlay(newConfirmation(privilegedPasteMessage(line), func(allowed bool) {
+				if allowed {
+					_ = gui.terminal.Paste([]byte(text))
+				} else {
+					gui.logger.Infof("Paste cancelled by user")
+				}
+			}))
+			return
+		}
+	}
+	_ = gui.terminal.Paste([]byte(text))
+}
+
+func privilegedPasteMessage(line string) string {
+	if len([]rune(line)) > clipboardPreviewLength {
+		line = string([]rune(line)[:clipboardPreviewLength]) + "..."
+	}
+	return fmt.Sprintf("The text you are pasting has more than one line, and runs a command with elevated privileges:\n\n%s\n\nPaste it? Press Y to paste, or N to cancel.", line)
+}
+
+// confirmation is an overlay which asks the user a yes/no question, and takes over keyboard input until answered
+type confirmation struct {
+	message  string
+	callback func(bool)
+}
+
+func newConfirmation(message string, callback func(bool)) *confirmation {
+	return &confirmation{
+		message:  message,
+		callback: callback,
+	}
+}
+
+func (c *confirmation) render(gui *GUI) {
+	gui.textbox(2, 2, c.message, [3]float32{1, 1, 1}, [3]float32{0.2, 0.2, 0.4})
+}
+
+func (c *confirmation) handleKey(gui *GUI, key glfw.Key, mods glfw.ModifierKey) {
+	switch key {
+	case glfw.KeyY:
+		gui.setOverlay(nil)
+		c.callback(true)
+	case glfw.KeyN, glfw.KeyEscape:
+		gui.setOverlay(nil)
+		c.callback(false)
+	}
+}
@@ -1,49 +1,115 @@
 package gui
 
-import "github.com/liamg/aminal/glfont"
+import (
+	"github.com/liamg/aminal/buffer"
+	"github.com/liamg/aminal/glfont"
+)
+
+// fontStyle is which face of a font text is drawn with
+type fontStyle int
+
+const (
+	styleRegular fontStyle = 0
+	styleBold    fontStyle = 1
+	styleItalic  fontStyle = 2
+
+	styleBoldItalic = styleBold | styleItalic
+)
+
+// fontStyles is every style, in the order the faces of a font are stored
+var fontStyles = [...]fontStyle{styleRegular, styleBold, styleItalic, styleBoldItalic}
+
+// attrStyle returns the style to draw text with the given attributes in. Bold text is drawn in the regular face if
+// bold_font is disabled, leaving bold_is_bright to mark it out by its colour.
+func (gui *GUI) attrStyle(attr buffer.CellAttributes) fontStyle {
+	style := styleRegular
+	if attr.Bold && gui.config.BoldFont {
+		style |= styleBold
+	}
+	if attr.Italic {
+		style |= styleItalic
+	}
+	return style
+}
+
+func (style fontStyle) bold() bool {
+	return style&styleBold != 0
+}
+
+func (style fontStyle) italic() bool {
+	return style&styleItalic != 0
+}
+
+// fontFaces holds a face of a font for each style, indexed by the style
+type fontFaces [len(fontStyles)]*glfont.Font
+
+func (faces *fontFaces) Free() {
+	for i, face := range faces {
+		if face != nil {
+			face.Free()
+			faces[i] = nil
+		}
+	}
+}
 
 type FontMap struct {
-	defaultFont     *glfont.Font
-	defaultBoldFont *glfont.Font
+	faces     fontFaces
+	emojiFont *glfont.ColourFont // nil if there is no colour emoji font
 }
 
-func NewFontMap(defaultFont *glfont.Font, defaultBoldFont *glfont.Font) *FontMap {
+func NewFontMap(faces fontFaces) *FontMap {
 	return &FontMap{
-		defaultFont:     defaultFont,
-		defaultBoldFont: defaultBoldFont,
+		faces: faces,
 	}
 }
 
 func (fm *FontMap) Free() {
-	if fm.defaultFont != nil {
-		fm.defaultFont.Free()
-		fm.defaultFont = nil
-	}
+	fm.faces.Free()
 
-	if fm.defaultBoldFont != nil {
-		fm.defaultBoldFont.Free()
-		fm.defaultBoldFont = nil
+	if fm.emojiFont != nil {
+		fm.emojiFont.Free()
+		fm.emojiFont = nil
 	}
 }
 
-func (fm *FontMap) AssignFonts(defaultFont *glfont.Font, defaultBoldFont *glfont.Font) {
-	fm.defaultFont.Free()
-	fm.defaultBoldFont.Free()
-
-	fm.defaultFont = defaultFont
-	fm.defaultBoldFont = defaultBoldFont
+func (fm *FontMap) AssignFonts(faces fontFaces) {
+	fm.faces.Free()
+	fm.faces = faces
 }
 
 func (fm *FontMap) UpdateResolution(w int, h int) {
-	fm.defaultFont.UpdateResolution(w, h)
-	fm.defaultBoldFont.UpdateResolution(w, h)
+	for _, face := range fm.faces {
+		face.UpdateResolution(w, h)
+	}
+	if fm.emojiFont != nil {
+		fm.emojiFont.UpdateResolution(w, h)
+	}
+}
+
+// Flush draws the text queued with every face
+func (fm *FontMap) Flush() {
+	for _, face := range fm.faces {
+		face.Flush()
+	}
 }
 
 func (fm *FontMap) DefaultFont() *glfont.Font {
 
-	return fm.defaultFont
+	return fm.faces[styleRegular]
+}
+
+// Face returns the face text in the given style is drawn with
+func (fm *FontMap) Face(style fontStyle) *glfont.Font {
+	return fm.faces[style]
 }
 
-func (fm *FontMap) BoldFont() *glfont.Font {
-	return fm.defaultBoldFont
+func (fm *FontMap) EmojiFont() *glfont.ColourFont {
+	return fm.emojiFont
+}
+
+func (fm *FontMap) SetEmojiFont(emojiFont *glfont.ColourFont) {
+	if fm.emojiFont != nil {
+		fm.emojiFont.Free()
+	}
+	fm.emojiFont = emojiFont
 }
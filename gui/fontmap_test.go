@@ -0,0 +1,19 @@
+package gui
+
+import (
+	"testing"
+
+	"github.com/liamg/aminal/buffer"
+	"github.com/liamg/aminal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttrStyle(t *testing.T) {
+	conf := config.DefaultConfig
+	gui := &GUI{config: &conf}
+
+	assert.Equal(t, styleBoldItalic, gui.attrStyle(buffer.CellAttributes{Bold: true, Italic: true}))
+
+	conf.BoldFont = false
+	assert.Equal(t, styleItalic, gui.attrStyle(buffer.CellAttributes{Bold: true, Italic: true}), "bold_font = false should keep bold text in the regular face")
+}
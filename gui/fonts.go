@@ -3,9 +3,15 @@ package gui
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/gobuffalo/packr"
+	"github.com/liamg/aminal/config"
 	"github.com/liamg/aminal/glfont"
+	"github.com/liamg/aminal/platform"
 )
 
 func (gui *GUI) getPackedFont(name string) (*glfont.Font, error) {
@@ -15,7 +21,7 @@ func (gui *GUI) getPackedFont(name string) (*glfont.Font, error) {
 		return nil, fmt.Errorf("packaged font '%s' could not be read: %s", name, err)
 	}
 
-	font, err := glfont.LoadFont(bytes.NewReader(fontBytes), gui.fontScale*gui.dpiScale/gui.scale(), gui.width, gui.height)
+	font, err := gui.loadFont(bytes.NewReader(fontBytes))
 	if err != nil {
 		return nil, fmt.Errorf("font '%s' failed to load: %v", name, err)
 	}
@@ -23,27 +29,257 @@ func (gui *GUI) getPackedFont(name string) (*glfont.Font, error) {
 	return font, nil
 }
 
-func (gui *GUI) loadFonts() error {
+func (gui *GUI) getFontFile(path string) (*glfont.Font, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("font '%s' could not be read: %s", path, err)
+	}
+	defer file.Close()
 
-	// from https://github.com/ryanoasis/nerd-fonts/tree/master/patched-fonts/Hack
+	font, err := gui.loadFont(file)
+	if err != nil {
+		return nil, fmt.Errorf("font '%s' failed to load: %v", path, err)
+	}
+
+	return font, nil
+}
 
-	defaultFont, err := gui.getPackedFont("Hack Regular Nerd Font Complete.ttf")
+// getNamedFont loads a font given the name of a font family installed on the system, whose face in the given style is
+// used, or failing that the path of a font file, which is used whatever the style
+func (gui *GUI) getNamedFont(name string, style fontStyle) (*glfont.Font, error) {
+	path, err := platform.FindFont(name, style.bold(), style.italic())
+	if err == nil {
+		gui.logger.Debugf("Found font file %s for '%s'", path, name)
+		return gui.getFontFile(path)
+	}
+	if _, statErr := os.Stat(name); statErr != nil {
+		return nil, fmt.Errorf("font '%s' is neither an installed font family nor a font file: %s", name, err)
+	}
+	return gui.getFontFile(name)
+}
+
+func (gui *GUI) loadFont(reader io.Reader) (*glfont.Font, error) {
+	font, err := glfont.LoadFont(reader, gui.fontScale*gui.dpiScale/gui.scale(), gui.width, gui.height)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	font.SetSubpixelAntialiasing(gui.config.SubpixelAntialiasing)
+	return font, nil
+}
+
+func (gui *GUI) loadFonts() error {
 
-	boldFont, err := gui.getPackedFont("Hack Bold Nerd Font Complete.ttf")
+	var faces fontFaces
+	var err error
+
+	if gui.fontIndex == 0 {
+		faces, err = gui.getBuiltInFont()
+	} else {
+		faces, err = gui.getConfiguredFont(gui.config.Fonts[gui.fontIndex-1])
+	}
 	if err != nil {
 		return err
 	}
 
 	if gui.fontMap == nil {
-		gui.fontMap = NewFontMap(defaultFont, boldFont)
+		gui.fontMap = NewFontMap(faces)
 	} else {
-		gui.fontMap.AssignFonts(defaultFont, boldFont)
+		gui.fontMap.AssignFonts(faces)
+	}
+
+	// the emoji font is drawn at any size, so it only needs to be loaded once
+	if !gui.emojiFontLoaded {
+		gui.emojiFontLoaded = true
+		gui.fontMap.SetEmojiFont(gui.loadEmojiFont())
+	} else if emojiFont := gui.fontMap.EmojiFont(); emojiFont != nil {
+		emojiFont.UpdateResolution(gui.width, gui.height)
+	}
+
+	return nil
+}
+
+// emojiFontPaths are where colour emoji fonts are usually installed, and are searched if emoji_font isn't set
+var emojiFontPaths = []string{
+	"/usr/share/fonts/truetype/noto/NotoColorEmoji.ttf",
+	"/usr/share/fonts/noto/NotoColorEmoji.ttf",
+	"/usr/share/fonts/google-noto-emoji/NotoColorEmoji.ttf",
+	"/usr/share/fonts/TTF/NotoColorEmoji.ttf",
+	"/usr/local/share/fonts/NotoColorEmoji.ttf",
+	"/System/Library/Fonts/Apple Color Emoji.ttc",
+	`C:\Windows\Fonts\seguiemj.ttf`,
+}
+
+// loadEmojiFont loads the colour font emoji are drawn with, returning nil if there isn't one, in which case emoji are
+// drawn in the text colour if the text font has them
+func (gui *GUI) loadEmojiFont() *glfont.ColourFont {
+	paths := emojiFontPaths
+	if gui.config.EmojiFont != "" {
+		paths = []string{gui.config.EmojiFont}
 	}
 
-	// add special non-ascii fonts here
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			if gui.config.EmojiFont != "" {
+				gui.logger.Errorf("Emoji font '%s' could not be read: %s", path, err)
+			}
+			continue
+		}
+		font, err := glfont.LoadColourFont(file, gui.width, gui.height)
+		file.Close()
+		if err != nil {
+			gui.logger.Errorf("Emoji font '%s' failed to load: %v", path, err)
+			continue
+		}
+		gui.logger.Infof("Using emoji font %s", path)
+		return font
+	}
 
 	return nil
 }
+
+// getBuiltInFont loads the faces of Hack, which has no italic faces, so they're the regular and bold faces slanted
+func (gui *GUI) getBuiltInFont() (fontFaces, error) {
+	// from https://github.com/ryanoasis/nerd-fonts/tree/master/patched-fonts/Hack
+	files := map[fontStyle]string{
+		styleRegular:    "Hack Regular Nerd Font Complete.ttf",
+		styleBold:       "Hack Bold Nerd Font Complete.ttf",
+		styleItalic:     "Hack Regular Nerd Font Complete.ttf",
+		styleBoldItalic: "Hack Bold Nerd Font Complete.ttf",
+	}
+
+	var faces fontFaces
+	for _, style := range fontStyles {
+		face, err := gui.getPackedFont(files[style])
+		if err != nil {
+			faces.Free()
+			return faces, err
+		}
+		face.SetSyntheticStyle(false, style.italic())
+		faces[style] = face
+	}
+	return faces, nil
+}
+
+func (gui *GUI) getConfiguredFont(font config.FontConfig) (fontFaces, error) {
+	var faces fontFaces
+	for _, style := range fontStyles {
+		face, err := gui.getConfiguredFace(font, style)
+		if err != nil {
+			faces.Free()
+			return faces, err
+		}
+		faces[style] = face
+	}
+	return faces, nil
+}
+
+// getConfiguredFace loads the face of a configured font for a style: the one given for the style if there is one, or
+// else the face for the style in the regular font's family. When the family has no such face, or the regular font is
+// a file, the regular face is emboldened or slanted instead.
+func (gui *GUI) getConfiguredFace(font config.FontConfig, style fontStyle) (*glfont.Font, error) {
+	var name string
+	switch style {
+	case styleBold:
+		name = font.Bold
+	case styleItalic:
+		name = font.Italic
+	case styleBoldItalic:
+		name = font.BoldItalic
+	}
+	if name != "" {
+		return gui.getNamedFont(name, style)
+	}
+
+	if style != styleRegular {
+		path, err := platform.FindFont(font.Regular, style.bold(), style.italic())
+		if err == nil {
+			gui.logger.Debugf("Found font file %s for '%s'", path, font.Regular)
+			return gui.getFontFile(path)
+		}
+		gui.logger.Debugf("Synthesizing a face for '%s': %s", font.Regular, err)
+	}
+
+	face, err := gui.getNamedFont(font.Regular, styleRegular)
+	if err != nil {
+		return nil, err
+	}
+	face.SetSyntheticStyle(style.bold(), style.italic())
+	return face, nil
+}
+
+// fontName returns the name of the font in use, as shown when switching fonts
+func (gui *GUI) fontName() string {
+	if gui.fontIndex == 0 {
+		return "Hack (built in)"
+	}
+	path := gui.config.Fonts[gui.fontIndex-1].Regular
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// the range of font sizes, in points, which zooming is limited to
+const (
+	minFontSize = 4
+	maxFontSize = 72
+)
+
+// clampFontSize limits size to the range zooming allows
+func clampFontSize(size float32) float32 {
+	if size < minFontSize {
+		return minFontSize
+	}
+	if size > maxFontSize {
+		return maxFontSize
+	}
+	return size
+}
+
+// setFontSize changes the size of the font in points, which takes effect before the next frame
+func (gui *GUI) setFontSize(size float32) {
+	size = clampFontSize(size)
+	if size == gui.fontScale {
+		return
+	}
+	gui.fontScale = size
+	gui.fontsStale = true
+	gui.showNotice(fmt.Sprintf("Font size: %g", size))
+}
+
+// reloadFonts loads the fonts at the current size and DPI scale, and fits as many cells into the window as there's
+// room for at that size, resizing the pty to match
+func (gui *GUI) reloadFonts() {
+	gui.resizeLock.Lock()
+	defer gui.resizeLock.Unlock()
+
+	gui.fontsStale = false
+	if err := gui.loadFonts(); err != nil {
+		gui.logger.Errorf("Failed to load fonts: %s", err)
+		return
+	}
+	gui.relayout()
+}
+
+// nextFont switches to the next of the configured fonts, or back to the built in font after the last one
+func (gui *GUI) nextFont() {
+	gui.resizeLock.Lock()
+	defer gui.resizeLock.Unlock()
+
+	previous := gui.fontIndex
+	gui.fontIndex = (gui.fontIndex + 1) % (len(gui.config.Fonts) + 1)
+
+	if err := gui.loadFonts(); err != nil {
+		gui.logger.Warnf("Failed to switch font, using the built in font instead: %s", err)
+		gui.fontIndex = 0
+		if err := gui.loadFonts(); err != nil {
+			gui.logger.Errorf("Failed to load the built in font: %s", err)
+			gui.fontIndex = previous
+			return
+		}
+		gui.relayout()
+		gui.showNotice(fmt.Sprintf("Failed to switch font, using the built in font: %s", err))
+		return
+	}
+
+	gui.relayout()
+	gui.showNotice(fmt.Sprintf("Font: %s", gui.fontName()))
+}
@@ -0,0 +1,88 @@
+package gui
+
+import (
+	"github.com/go-gl/gl/all-core/gl"
+)
+
+// framebuffer is an offscreen render target which keeps the rendered cell grid between frames, so only dirty rows need to be redrawn
+type framebuffer struct {
+	fbo     uint32
+	texture uint32
+	width   int
+	height  int
+	scratch *framebuffer // what's moved by scroll is copied through this, as a framebuffer can't be copied onto itself
+}
+
+// bind makes the framebuffer the target for drawing, (re)creating it if the window size has changed. Returns true if the previous contents were lost.
+func (frame *framebuffer) bind(width int, height int) bool {
+	created := false
+
+	if frame.fbo == 0 || frame.width != width || frame.height != height {
+		frame.free()
+
+		gl.GenTextures(1, &frame.texture)
+		gl.BindTexture(gl.TEXTURE_2D, frame.texture)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+		gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, int32(width), int32(height), 0, gl.RGBA, gl.UNSIGNED_BYTE, nil)
+		gl.BindTexture(gl.TEXTURE_2D, 0)
+
+		gl.GenFramebuffers(1, &frame.fbo)
+		gl.BindFramebuffer(gl.FRAMEBUFFER, frame.fbo)
+		gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, frame.texture, 0)
+
+		frame.width = width
+		frame.height = height
+		created = true
+	}
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, frame.fbo)
+	return created
+}
+
+// present copies the framebuffer to the window and makes the window the target for drawing again
+func (frame *framebuffer) present() {
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, frame.fbo)
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, 0)
+	gl.BlitFramebuffer(
+		0, 0, int32(frame.width), int32(frame.height),
+		0, 0, int32(frame.width), int32(frame.height),
+		gl.COLOR_BUFFER_BIT, gl.NEAREST,
+	)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+}
+
+// scroll moves the pixel rows of the framebuffer from bottom up to top up by n pixels, counting up from the bottom as
+// OpenGL does. The rows moved over are lost, and the n rows from bottom are left as they were, to be drawn again.
+func (frame *framebuffer) scroll(bottom int32, top int32, n int32) {
+	if frame.scratch == nil {
+		frame.scratch = &framebuffer{}
+	}
+	frame.scratch.bind(frame.width, frame.height)
+	width := int32(frame.width)
+
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, frame.fbo)
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, frame.scratch.fbo)
+	gl.BlitFramebuffer(0, bottom, width, top-n, 0, bottom+n, width, top, gl.COLOR_BUFFER_BIT, gl.NEAREST)
+
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, frame.scratch.fbo)
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, frame.fbo)
+	gl.BlitFramebuffer(0, bottom+n, width, top, 0, bottom+n, width, top, gl.COLOR_BUFFER_BIT, gl.NEAREST)
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, frame.fbo)
+}
+
+func (frame *framebuffer) free() {
+	if frame.fbo != 0 {
+		gl.DeleteFramebuffers(1, &frame.fbo)
+		frame.fbo = 0
+	}
+	if frame.texture != 0 {
+		gl.DeleteTextures(1, &frame.texture)
+		frame.texture = 0
+	}
+	if frame.scratch != nil {
+		frame.scratch.free()
+		frame.scratch = nil
+	}
+}
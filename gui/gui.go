@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"unsafe"
@@ -28,7 +29,12 @@ type GUI struct {
 	window            *glfw.Window
 	logger            *zap.SugaredLogger
 	config            *config.Config
-	terminal          *terminal.Terminal
+	terminal          *terminal.Terminal // the terminal of the active tab
+	tabs              []*tab
+	activeTab         int
+	newTab            NewTabFunc
+	events            tabEvents
+	program           uint32
 	width             int //window width in pixels
 	height            int //window height in pixels
 	appliedWidth      int
@@ -36,24 +42,44 @@ type GUI struct {
 	resizeCache       *ResizeCache // resize cache formed by resizeToTerminal()
 	dpiScale          float32
 	fontMap           *FontMap
-	fontScale         float32
+	fontScale         float32 // the font size in points, before DPI scaling
+	fontsStale        bool    // the font size or DPI scale has changed, so the fonts are reloaded before the next frame
+	fontIndex         int     // 0 is the built in font, otherwise the index of the configured font plus one
+	emojiFontLoaded   bool
 	renderer          *OpenGLRenderer
 	colourAttr        uint32
 	mouseDown         bool
 	overlay           overlay
+	overlayKeys       map[glfw.Key]bool // keys pressed while an input overlay was shown
 	terminalAlpha     float32
 	showDebugInfo     bool
 	keyboardShortcuts map[config.UserAction]*config.KeyCombination
 	resizeLock        *sync.Mutex
+	activeTerminal    atomic.Value // the terminal of the active tab, for ActiveTerminal
 	handCursor        *glfw.Cursor
 	arrowCursor       *glfw.Cursor
 	defaultCell       *buffer.Cell
+	frame             framebuffer
+	lastCursorRow     uint
+	bellUntil         time.Time // the visual bell is shown until this time
+	unfocused         bool
+	noticeText        string
+	noticeUntil       time.Time // the notice is shown until this time
+	recordingPath     string    // where the session is being recorded to, or was last recorded to
+	pacer             *framePacer
+	hoveredLink       *buffer.Hyperlink // the OSC 8 link under the mouse pointer, which is underlined
+	blinkStart        time.Time         // the cursor is shown for the first half of each blink from this time
+	cursorHidden      bool              // a blinking cursor is in the hidden half of its blink
+	textHidden        bool              // blinking text is in the hidden half of its blink
+	textBlinks        bool              // blinking text has been drawn since it was last shown or hidden
 
 	prevLeftClickX                  uint16
 	prevLeftClickY                  uint16
+	prevLeftClickPos                [2]float64 // where the last click was in window coordinates, as the cell may be the same for clicks some distance apart
 	leftClickTime                   time.Time
 	leftClickCount                  int // number of clicks in a serie - single click, double click, or triple click
 	mouseMovedAfterSelectionStarted bool
+	gutterPressed                   bool // the left mouse button was pressed in the gutter, so the release isn't handled either
 	internalResize                  bool
 }
 
@@ -137,13 +163,22 @@ func (g *GUI) calculateDpiScale() float32 {
 	return monitorDpi / standardDpi
 }
 
-func New(config *config.Config, terminal *terminal.Terminal, logger *zap.SugaredLogger) (*GUI, error) {
+// New creates the GUI for a window with a tab for terminal, in which process is running. New tabs are started by newTab,
+// or can't be opened if it's nil.
+func New(config *config.Config, terminal *terminal.Terminal, process platform.Process, newTab NewTabFunc, logger *zap.SugaredLogger) (*GUI, error) {
 	shortcuts, err := config.KeyMapping.GenerateActionMap()
 	if err != nil {
-		return nil, err
+		// the valid shortcuts still work
+		logger.Errorf("%s", err)
+	}
+	for action := range shortcuts {
+		if _, ok := actionMap[action]; !ok {
+			logger.Errorf("Unknown action '%s' in keyboard shortcuts", action)
+			delete(shortcuts, action)
+		}
 	}
 
-	return &GUI{
+	gui := &GUI{
 		config:            config,
 		logger:            logger,
 		width:             800,
@@ -152,12 +187,23 @@ func New(config *config.Config, terminal *terminal.Terminal, logger *zap.Sugared
 		appliedHeight:     0,
 		dpiScale:          1,
 		terminal:          terminal,
-		fontScale:         10.0,
+		fontScale:         clampFontSize(config.FontSize),
 		terminalAlpha:     1,
 		keyboardShortcuts: shortcuts,
 		resizeLock:        &sync.Mutex{},
 		internalResize:    false,
-	}, nil
+		pacer:             newFramePacer(defaultRefreshRate),
+		newTab:            newTab,
+		events:            newTabEvents(),
+	}
+	gui.tabs = []*tab{makeTab(terminal, process)}
+	gui.activeTerminal.Store(terminal)
+	return gui, nil
+}
+
+// ActiveTerminal returns the terminal of the active tab. Unlike the rest of the GUI it may be called from any goroutine.
+func (gui *GUI) ActiveTerminal() *terminal.Terminal {
+	return gui.activeTerminal.Load().(*terminal.Terminal)
 }
 
 // inspired by https://kylewbanks.com/blog/tutorial-opengl-with-golang-part-1-hello-opengl
@@ -178,7 +224,7 @@ func (gui *GUI) resizeToTerminal(newCols uint, newRows uint) {
 	gui.resizeLock.Lock()
 	defer gui.resizeLock.Unlock()
 
-	cols, rows := gui.renderer.GetTermSize()
+	cols, rows := gui.gridSize()
 	if cols == newCols && rows == newRows {
 		return
 	}
@@ -186,7 +232,7 @@ func (gui *GUI) resizeToTerminal(newCols uint, newRows uint) {
 	gui.logger.Debugf("Initiating GUI resize to columns=%d rows=%d", newCols, newRows)
 
 	gui.logger.Debugf("Calculating size...")
-	width, height := gui.renderer.GetRectangleSize(newCols, newRows)
+	width, height := gui.renderer.GetRectangleSize(newCols, newRows+gui.tabBarRows())
 
 	roundedWidth := int(math.Ceil(float64(width)))
 	roundedHeight := int(math.Ceil(float64(height)))
@@ -200,9 +246,9 @@ func (gui *GUI) resizeToTerminal(newCols uint, newRows uint) {
 }
 
 func (gui *GUI) generateDefaultCell(reverse bool) {
-	color := gui.config.ColourScheme.Background
+	color := gui.terminal.Colours().Background
 	if reverse {
-		color = gui.config.ColourScheme.Foreground
+		color = gui.terminal.Colours().Foreground
 	}
 	cell := buffer.NewBackgroundCell(color)
 	gui.renderer.backgroundColour = color
@@ -215,9 +261,87 @@ func (gui *GUI) generateDefaultCell(reverse bool) {
 	)
 }
 
+// ringBell shows the bell as the config's bell mode says to
+func (gui *GUI) ringBell() {
+	if gui.config.Bell.Mode.Visual() {
+		gui.ringVisualBell()
+	}
+	if gui.config.Bell.Mode.Audible() {
+		go func() {
+			if err := platform.Beep(); err != nil {
+				gui.logger.Debugf("%s", err)
+			}
+		}()
+	}
+}
+
+// ringVisualBell briefly inverts the colours of the terminal
+func (gui *GUI) ringVisualBell() {
+	const duration = time.Millisecond * 100
+	gui.bellUntil = time.Now().Add(duration)
+	gui.terminal.SetDirty()
+	time.AfterFunc(duration, gui.terminal.SetDirty)
+}
+
+// showNotice briefly shows a message at the bottom of the terminal
+func (gui *GUI) showNotice(text string) {
+	const duration = time.Second * 2
+	gui.noticeText = text
+	gui.noticeUntil = time.Now().Add(duration)
+	gui.terminal.SetDirty()
+	time.AfterFunc(duration, gui.terminal.SetDirty)
+}
+
+// blinkInterval returns how long blinking text and cursors are shown and then hidden for, or 0 if nothing blinks
+func (gui *GUI) blinkInterval() time.Duration {
+	const minInterval, maxInterval = 100, 5000
+	interval := gui.config.BlinkInterval
+	if interval <= 0 {
+		return 0
+	}
+	if interval < minInterval {
+		interval = minInterval
+	} else if interval > maxInterval {
+		interval = maxInterval
+	}
+	return time.Duration(interval) * time.Millisecond
+}
+
+// cursorBlinkHidden returns true if a blinking cursor is in the hidden half of its blink. Nothing blinks while the
+// window is unfocused, so an idle terminal doesn't keep redrawing.
+func (gui *GUI) cursorBlinkHidden(now time.Time) bool {
+	interval := gui.blinkInterval()
+	if interval == 0 || !gui.config.CursorBlink || !gui.terminal.Modes().BlinkingCursor || gui.unfocused {
+		return false
+	}
+	return now.Sub(gui.blinkStart)/interval%2 == 1
+}
+
+// textBlinkHidden returns true if text with the blink attribute (SGR 5) is in the hidden half of its blink
+func (gui *GUI) textBlinkHidden(now time.Time) bool {
+	interval := gui.blinkInterval()
+	if interval == 0 || gui.unfocused {
+		return false
+	}
+	return time.Duration(now.UnixNano())/interval%2 == 1
+}
+
+// resetCursorBlink shows the cursor from the start of its blink, so it's visible while typing
+func (gui *GUI) resetCursorBlink() {
+	gui.blinkStart = time.Now()
+}
+
+// cursorShape returns the configured cursor shape if one is forced, otherwise the one requested by the application
+func (gui *GUI) cursorShape() config.CursorShape {
+	if gui.config.ForceCursorStyle != config.CursorShapeDefault {
+		return gui.config.ForceCursorStyle
+	}
+	return gui.terminal.Modes().CursorShape
+}
+
 func (gui *GUI) getCursorBg(cell *buffer.Cell) (bg [3]float32) {
-	if gui.config.ColourScheme.Cursor != cell.Bg() {
-		bg = gui.config.ColourScheme.Cursor
+	if gui.terminal.Colours().Cursor != cell.Bg() {
+		bg = gui.terminal.Colours().Cursor
 	} else {
 		bg = cell.Fg()
 	}
@@ -253,19 +377,32 @@ func (gui *GUI) resize(w *glfw.Window, width int, height int) {
 	gui.appliedHeight = height
 
 	gui.logger.Debugf("Updating font resolutions...")
-	gui.loadFonts()
+	if err := gui.loadFonts(); err != nil {
+		gui.logger.Errorf("Failed to load fonts: %s", err)
+	}
+
+	gui.relayout()
+}
+
+// relayout recalculates the cell size from the current fonts, and the size of the terminal in cells from the window size
+func (gui *GUI) relayout() {
+	// every cell must be drawn again, whether or not the cell size changed
+	gui.terminal.SetDirty()
 
 	gui.logger.Debugf("Setting renderer area...")
+	gui.renderer.SetGutterWidth(gui.gutterWidth())
 	gui.renderer.SetArea(0, 0, gui.width, gui.height)
 
-	if gui.resizeCache != nil && gui.resizeCache.Width == width && gui.resizeCache.Height == height {
+	if gui.resizeCache != nil && gui.resizeCache.Width == gui.width && gui.resizeCache.Height == gui.height {
 		gui.logger.Debugf("No need to resize internal terminal!")
 	} else {
 		gui.logger.Debugf("Calculating size in cols/rows...")
-		cols, rows := gui.renderer.GetTermSize()
-		gui.logger.Debugf("Resizing internal terminal...")
-		if err := gui.terminal.SetSize(cols, rows); err != nil {
-			gui.logger.Errorf("Failed to resize terminal to %d cols, %d rows: %s", cols, rows, err)
+		cols, rows := gui.gridSize()
+		gui.logger.Debugf("Resizing internal terminals...")
+		for _, t := range gui.tabs {
+			if err := t.terminal.SetSize(cols, rows); err != nil {
+				gui.logger.Errorf("Failed to resize terminal to %d cols, %d rows: %s", cols, rows, err)
+			}
 		}
 	}
 
@@ -274,7 +411,9 @@ func (gui *GUI) resize(w *glfw.Window, width int, height int) {
 	gui.logger.Debugf("Setting viewport size...")
 	gl.Viewport(0, 0, int32(gui.width), int32(gui.height))
 
-	gui.terminal.SetCharSize(gui.renderer.cellWidth, gui.renderer.cellHeight)
+	for _, t := range gui.tabs {
+		t.terminal.SetCharSize(gui.renderer.cellWidth, gui.renderer.cellHeight)
+	}
 
 	gui.logger.Debugf("Resize complete!")
 
@@ -282,6 +421,65 @@ func (gui *GUI) resize(w *glfw.Window, width int, height int) {
 	gui.window.SwapBuffers()
 }
 
+// gutterWidth returns the width of the shell integration gutter in pixels, or 0 if it is disabled
+func (gui *GUI) gutterWidth() float32 {
+	if !gui.config.Gutter.Enabled {
+		return 0
+	}
+	return gui.config.Gutter.Width * gui.dpiScale / gui.scale()
+}
+
+// multiClickInterval returns the longest time between clicks which count as a double or triple click
+func (gui *GUI) multiClickInterval() time.Duration {
+	const minInterval, maxInterval = 100, 2000
+	interval := gui.config.MultiClick.Interval
+	if interval < minInterval {
+		interval = minInterval
+	} else if interval > maxInterval {
+		interval = maxInterval
+	}
+	return time.Duration(interval) * time.Millisecond
+}
+
+// multiClickDistance returns the furthest the pointer may move between clicks which count as a double or triple click,
+// in window coordinates
+func (gui *GUI) multiClickDistance() float64 {
+	const minDistance, maxDistance = 0, 50
+	distance := gui.config.MultiClick.Distance
+	if distance < minDistance {
+		distance = minDistance
+	} else if distance > maxDistance {
+		distance = maxDistance
+	}
+	return float64(distance * gui.dpiScale / gui.scale())
+}
+
+// barCursorWidth returns the width of the bar cursor in pixels
+func (gui *GUI) barCursorWidth() float32 {
+	const minWidth, maxWidth = 1, 8
+	width := gui.config.BarCursorWidth
+	if width < minWidth {
+		width = minWidth
+	} else if width > maxWidth {
+		width = maxWidth
+	}
+	return width * gui.dpiScale / gui.scale()
+}
+
+func (gui *GUI) getGutterColour(line *buffer.Line) (config.Colour, bool) {
+	switch line.PromptState() {
+	case buffer.PromptStateInput:
+		return gui.config.Gutter.PromptColour, true
+	case buffer.PromptStateRunning:
+		return gui.config.Gutter.RunningColour, true
+	case buffer.PromptStateSuccess:
+		return gui.config.Gutter.SuccessColour, true
+	case buffer.PromptStateFailure:
+		return gui.config.Gutter.FailureColour, true
+	}
+	return config.Colour{}, false
+}
+
 func (gui *GUI) getTermSize() (uint, uint) {
 	if gui.renderer == nil {
 		return 0, 0
@@ -312,6 +510,7 @@ func (gui *GUI) Render() error {
 		return fmt.Errorf("Failed to initialise OpenGL: %s", err)
 	}
 
+	gui.program = program
 	gui.colourAttr = uint32(gl.GetAttribLocation(program, gl.Str("inColour\x00")))
 	gl.BindFragDataLocation(program, 0, gl.Str("outColour\x00"))
 
@@ -320,11 +519,8 @@ func (gui *GUI) Render() error {
 		return fmt.Errorf("Failed to load font: %s", err)
 	}
 
-	titleChan := make(chan bool, 1)
-	resizeChan := make(chan bool, 1)
-	reverseChan := make(chan bool, 1)
-
 	gui.renderer = NewOpenGLRenderer(gui.config, gui.fontMap, 0, 0, gui.width, gui.height, gui.colourAttr, program)
+	gui.renderer.SetGutterWidth(gui.gutterWidth())
 
 	gui.window.SetFramebufferSizeCallback(gui.resize)
 	gui.window.SetKeyCallback(gui.key)
@@ -336,7 +532,11 @@ func (gui *GUI) Render() error {
 		gui.terminal.SetDirty()
 	})
 	gui.window.SetFocusCallback(func(w *glfw.Window, focused bool) {
-		if focused {
+		gui.unfocused = !focused
+		if err := gui.terminal.ReportFocus(focused); err != nil {
+			gui.logger.Errorf("Failed to report focus: %s", err)
+		}
+		if focused || gui.config.DimInactive.Enabled {
 			gui.terminal.SetDirty()
 		}
 	})
@@ -350,15 +550,13 @@ func (gui *GUI) Render() error {
 		gui.resize(gui.window, w, h)
 	}
 
-	gui.logger.Debugf("Starting pty read handling...")
-
-	go func() {
-		err := gui.terminal.Read()
-		if err != nil {
-			gui.logger.Errorf("Read from pty failed: %s", err)
+	if gui.config.Record != "" {
+		if err := gui.startRecording(gui.config.Record); err != nil {
+			return err
 		}
-		gui.Close()
-	}()
+	}
+	// the window was closed, or the last shell exited - make sure the shells and their children don't outlive the window
+	defer gui.closeTabs()
 
 	gui.logger.Debugf("Starting render...")
 
@@ -368,9 +566,19 @@ func (gui *GUI) Render() error {
 	gl.Disable(gl.DEPTH_TEST)
 	gl.TexParameterf(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
 
-	gui.terminal.AttachTitleChangeHandler(titleChan)
-	gui.terminal.AttachResizeHandler(resizeChan)
-	gui.terminal.AttachReverseHandler(reverseChan)
+	gui.logger.Debugf("Starting pty read handling...")
+	for _, t := range gui.tabs {
+		gui.startTab(t)
+	}
+
+	// wake the render loop as soon as there is output to show
+	go func() {
+		for range gui.events.dirty {
+			glfw.PostEmptyEvent()
+		}
+	}()
+
+	gui.updateRefreshRate()
 
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
@@ -382,8 +590,6 @@ func (gui *GUI) Render() error {
 		}
 	}()
 
-	gui.terminal.SetProgram(program)
-
 	latestVersion := ""
 
 	go func() {
@@ -396,39 +602,85 @@ func (gui *GUI) Render() error {
 
 	startTime := time.Now()
 	showMessage := true
+	pending := false // a frame needs rendering, but may be held back by the frame pacer
 
 	for !gui.window.ShouldClose() {
 
 		forceRedraw := false
 
 		select {
-		case <-titleChan:
-			gui.window.SetTitle(gui.terminal.GetTitle())
-		case <-resizeChan:
-			cols, rows := gui.terminal.GetSize()
-			gui.resizeToTerminal(uint(cols), uint(rows))
-		case reverse := <-reverseChan:
-			gui.generateDefaultCell(reverse)
+		case <-gui.events.title:
+			// any tab's title may have changed, and the tab bar shows them all
+			gui.updateTitle()
 			forceRedraw = true
+		case t := <-gui.events.resize:
+			gui.tabResized(t)
+		case <-gui.events.reverse:
+			gui.generateDefaultCell(gui.terminal.ReverseVideo())
+			gui.terminal.SetDirty()
+			forceRedraw = true
+		case <-gui.events.bell:
+			gui.ringBell()
+		case r := <-gui.events.clipboard:
+			if r.request.Read && r.tab != gui.tabs[gui.activeTab] {
+				gui.logger.Infof("Denied clipboard read from a tab in the background")
+			} else {
+				gui.handleClipboardRequest(r.request)
+			}
+		case t := <-gui.events.exited:
+			gui.closeTab(t)
 		default:
 			// this is more efficient than glfw.PollEvents()
-			glfw.WaitEventsTimeout(0.02) // up to 50fps on no input, otherwise higher
+			glfw.WaitEventsTimeout(gui.pacer.timeout(time.Now(), pending).Seconds())
+		}
+
+		if gui.fontsStale {
+			// however many times the size changed since the last frame, the fonts are only reloaded once
+			gui.reloadFonts()
 		}
 
 		if gui.terminal.CheckDirty() || forceRedraw {
+			pending = true
+			gui.recheckHover()
+		}
+		if hidden := gui.cursorBlinkHidden(time.Now()); hidden != gui.cursorHidden {
+			// the cursor row is redrawn in every frame
+			gui.cursorHidden = hidden
+			pending = true
+		}
+		if hidden := gui.textBlinkHidden(time.Now()); hidden != gui.textHidden {
+			gui.textHidden = hidden
+			if gui.textBlinks {
+				// blinking text may be anywhere on screen, and is looked for again as it's redrawn
+				gui.textBlinks = false
+				gui.terminal.SetDirty()
+				pending = true
+			}
+		}
+
+		if pending && gui.pacer.canRender(time.Now()) {
+			pending = false
 
 			gui.redraw()
 
 			if gui.showDebugInfo {
+				scrollbackLines, scrollbackBytes := gui.terminal.ActiveBuffer().ScrollbackUsage()
 				gui.textbox(2, 2, fmt.Sprintf(`Cursor:      %d,%d
 View Size:   %d,%d
 Buffer Size: %d lines
+Scrollback:  %d lines, %d KiB
+Latency:     %s
+Frame Limit: %d fps
 `,
 					gui.terminal.GetLogicalCursorX(),
 					gui.terminal.GetLogicalCursorY(),
 					gui.terminal.ActiveBuffer().ViewWidth(),
 					gui.terminal.ActiveBuffer().ViewHeight(),
 					gui.terminal.ActiveBuffer().Height(),
+					scrollbackLines,
+					scrollbackBytes/1024,
+					gui.pacer.latency.Round(100*time.Microsecond),
+					time.Second/gui.pacer.interval,
 				),
 					[3]float32{1, 1, 1},
 					[3]float32{0.8, 0, 0},
@@ -458,6 +710,7 @@ Buffer Size: %d lines
 			}
 
 			gui.SwapBuffers()
+			gui.pacer.rendered(time.Now())
 		}
 
 	}
@@ -467,32 +720,115 @@ Buffer Size: %d lines
 
 }
 
+// redrawRows decides which rows of the cell grid must be drawn this frame, and clears them
+func (gui *GUI) redrawRows(lines []buffer.Line, lineCount int, cursorRow uint) []bool {
+	dirty := gui.terminal.TakeDirtyRows()
+	offset := gui.terminal.GetScrollOffset()
+	rows := make([]bool, lineCount)
+
+	full := gui.frame.bind(gui.width, gui.height) || dirty.All()
+
+	// when the rows have scrolled, what was drawn for them is moved up rather than drawn again, unless the view is
+	// scrolled back, as the rows in it are marked as if it weren't
+	scrolled := uint(dirty.Scrolled())
+	if !full && scrolled > 0 {
+		full = offset != 0 || !gui.scrollFrame(scrolled, uint(lineCount))
+	}
+
+	if full {
+		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT | gl.STENCIL_BUFFER_BIT)
+		for y := range rows {
+			rows[y] = true
+		}
+		return rows
+	}
+
+	for y := range rows {
+		// rows in the scrollback above the view can only change when everything is dirty
+		viewRow := y - int(offset)
+		rows[y] = (viewRow >= 0 && dirty.IsSet(uint16(viewRow))) || uint(y) == cursorRow || uint(y)+scrolled == gui.lastCursorRow
+		if rows[y] {
+			gui.renderer.ClearRow(uint(y))
+		}
+	}
+	return rows
+}
+
+// scrollFrame moves the rows of cells drawn in the frame up by n rows, returning false if they can't be moved exactly as
+// the rows aren't a whole number of pixels tall. Rows moved off the top are lost, and row count - n onwards must be
+// drawn again.
+func (gui *GUI) scrollFrame(n uint, rowCount uint) bool {
+	height := gui.renderer.CellHeight()
+	if height != float32(math.Floor(float64(height))) || n >= rowCount {
+		return false
+	}
+	bottom, _ := gui.renderer.RowPixels(rowCount - 1)
+	_, top := gui.renderer.RowPixels(0)
+	gui.frame.scroll(bottom, top, int32(n)*int32(height))
+	return true
+}
+
+// hasJoinedParts returns true if the marks attached to an emoji are more than variation selectors, which only choose
+// how it's drawn
+func hasJoinedParts(marks []rune) bool {
+	for _, mark := range marks {
+		if mark != 0xfe0e && mark != 0xfe0f {
+			return true
+		}
+	}
+	return false
+}
+
 func (gui *GUI) redraw() {
-	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT | gl.STENCIL_BUFFER_BIT)
 	lines := gui.terminal.GetVisibleLines()
 	lineCount := int(gui.terminal.ActiveBuffer().ViewHeight())
 	colCount := int(gui.terminal.ActiveBuffer().ViewWidth())
 	cx := uint(gui.terminal.GetLogicalCursorX())
 	cy := uint(gui.terminal.GetLogicalCursorY()) + uint(gui.terminal.GetScrollOffset())
+	cursorShape := gui.cursorShape()
+	showCursor := gui.terminal.Modes().ShowCursor && !gui.cursorHidden
+	blockCursor := showCursor && cursorShape == config.CursorShapeBlock
+	rows := gui.redrawRows(lines, lineCount, cy)
+	gui.lastCursorRow = cy
+	// a block cursor over a double width character covers both of its cells
+	wideCursor := false
+	if int(cy) < len(lines) && int(cx) < len(lines[cy].Cells()) {
+		wideCursor = lines[cy].Cells()[cx].IsWide()
+	}
+	// the current line highlight would get in the way of full screen applications, which use the alternate screen
+	highlightLine := gui.config.HighlightCurrentLine && gui.terminal.UsingMainBuffer()
 	var colour *config.Colour
 	for y := 0; y < lineCount; y++ {
-		if y < len(lines) {
+		if y < len(lines) && rows[y] {
+			if gui.config.Gutter.Enabled {
+				if gutterColour, ok := gui.getGutterColour(&lines[y]); ok {
+					gui.renderer.DrawGutterMarker(uint(y), gutterColour)
+				}
+			}
+
 			cells := lines[y].Cells()
+			trailingStart, trailingEnd := 0, 0
+			if gui.config.ShowWhitespace {
+				trailingStart, trailingEnd = trailingWhitespace(cells)
+			}
 			for x := 0; x < colCount; x++ {
 
 				cursor := false
-				if gui.terminal.Modes().ShowCursor {
-					cursor = cx == uint(x) && cy == uint(y)
+				if blockCursor {
+					cursor = (cx == uint(x) || wideCursor && cx+1 == uint(x)) && cy == uint(y)
 				}
 
-				if gui.terminal.ActiveBuffer().InSelection(uint16(x), uint16(y)) {
-					colour = &gui.config.ColourScheme.Selection
+				selected := gui.terminal.ActiveBuffer().InSelection(uint16(x), uint16(y))
+				if x >= trailingStart && x < trailingEnd {
+					colour = &trailingWhitespaceColour
 				} else {
 					colour = nil
 				}
 
+				currentLine := highlightLine && cy == uint(y)
+
 				cell := gui.defaultCell
-				if colour != nil || cursor || x < len(cells) {
+				if selected || colour != nil || cursor || currentLine || x < len(cells) {
 
 					if x < len(cells) {
 						cell = &cells[x]
@@ -505,6 +841,20 @@ func (gui *GUI) redraw() {
 					if cursor {
 						var bgColour config.Colour = gui.getCursorBg(cell)
 						colour = &bgColour
+					} else if selected {
+						bg := cell.Bg()
+						if colour != nil {
+							bg = *colour
+						}
+						var bgColour config.Colour = gui.terminal.Colours().SelectionBackground(bg)
+						colour = &bgColour
+					} else if currentLine {
+						bg := cell.Bg()
+						if colour != nil {
+							bg = *colour
+						}
+						var bgColour config.Colour = gui.terminal.Colours().CurrentLineBackground(bg)
+						colour = &bgColour
 					}
 
 					gui.renderer.DrawCellBg(*cell, uint(x), uint(y), colour, false)
@@ -513,12 +863,15 @@ func (gui *GUI) redraw() {
 			}
 		}
 	}
+	// the backgrounds are drawn before the text over them
+	gui.renderer.Flush()
+
 	for y := 0; y < lineCount; y++ {
 
-		if y < len(lines) {
+		if y < len(lines) && rows[y] {
 
 			var builder strings.Builder
-			bold := false
+			style := styleRegular
 			dim := false
 			col := 0
 			colour := [3]float32{0, 0, 0}
@@ -527,9 +880,13 @@ func (gui *GUI) redraw() {
 			for x := 0; x < colCount; x++ {
 				if x < len(cells) {
 					cell := cells[x]
+					if cell.IsWideSpacer() {
+						// the character in the previous cell has been drawn across this one
+						continue
+					}
 
 					cursor := false
-					if gui.terminal.Modes().ShowCursor {
+					if blockCursor {
 						cursor = cx == uint(x) && cy == uint(y)
 					}
 
@@ -538,24 +895,88 @@ func (gui *GUI) redraw() {
 						newFg = gui.getCursorFg(&cell)
 					} else {
 						newFg = cell.Fg()
+						if cell.Attr().Bold && !cell.Attr().Inverse {
+							newFg = gui.terminal.BoldColour(cell.Attr())
+						}
+						if gui.terminal.ActiveBuffer().InSelection(uint16(x), uint16(y)) {
+							newFg = gui.terminal.Colours().SelectionText(newFg)
+						}
 					}
 
-					if builder.Len() > 0 && (cell.Attr().Dim != dim || cell.Attr().Bold != bold || colour != newFg) {
+					if builder.Len() > 0 && (cell.Attr().Dim != dim || gui.attrStyle(cell.Attr()) != style || colour != newFg) {
 						var alpha float32 = 1.0
 						if dim {
 							alpha = 0.5
 						}
-						gui.renderer.DrawCellText(builder.String(), uint(col), uint(y), alpha, colour, bold)
+						gui.renderer.DrawCellText(builder.String(), uint(col), uint(y), alpha, colour, style)
 						col = x
 						builder.Reset()
 					}
 					dim = cell.Attr().Dim
 					colour = newFg
-					bold = cell.Attr().Bold
+					style = gui.attrStyle(cell.Attr())
 					r := cell.Rune()
+					marks := cell.Combining()
+					if cell.Attr().Blink && r != 0 {
+						gui.textBlinks = true
+						if gui.textHidden {
+							r = 0
+							marks = nil
+						}
+					}
 					if r == 0 {
 						r = ' '
+					} else if gui.config.ShowWhitespace {
+						if marker, ok := whitespaceMarker(&cell); ok {
+							r = marker
+						}
+					}
+
+					// the colour font has no glyphs for emoji sequences, so an emoji with parts joined to it, such as a
+					// skin tone or a ZWJ sequence, is drawn with the marks below rather than losing the parts
+					if gui.renderer.IsEmoji(r) && !hasJoinedParts(marks) {
+						var alpha float32 = 1.0
+						if dim {
+							alpha = 0.5
+						}
+						if builder.Len() > 0 {
+							gui.renderer.DrawCellText(builder.String(), uint(col), uint(y), alpha, colour, style)
+							builder.Reset()
+						}
+						col = x
+
+						columns := uint(1)
+						if cell.IsWide() {
+							columns = 2
+						}
+						if gui.renderer.DrawCellEmoji(r, uint(x), uint(y), columns, alpha, colour) {
+							col = x + int(columns)
+							continue
+						}
 					}
+
+					if cell.IsWide() || len(marks) > 0 {
+						// wide glyphs are wider than a cell, and combining marks are drawn over the glyph they're
+						// attached to, so the cell is drawn on its own at its column and the text after it starts after
+						// the cells it takes up
+						var alpha float32 = 1.0
+						if dim {
+							alpha = 0.5
+						}
+						if builder.Len() > 0 {
+							gui.renderer.DrawCellText(builder.String(), uint(col), uint(y), alpha, colour, style)
+							builder.Reset()
+						}
+						columns := 1
+						if cell.IsWide() {
+							columns = 2
+						}
+						gui.renderer.DrawCellText(string(r), uint(x), uint(y), alpha, colour, style)
+						gui.renderer.DrawCellMarks(marks, uint(x), uint(y), uint(columns), alpha, colour, style)
+						col = x + columns
+						continue
+					}
+
 					builder.WriteRune(r)
 				}
 			}
@@ -564,15 +985,17 @@ func (gui *GUI) redraw() {
 				if dim {
 					alpha = 0.5
 				}
-				gui.renderer.DrawCellText(builder.String(), uint(col), uint(y), alpha, colour, bold)
+				gui.renderer.DrawCellText(builder.String(), uint(col), uint(y), alpha, colour, style)
 			}
 		}
 
 	}
+	gui.renderer.Flush()
+
 	// underlines
 	for y := 0; y < lineCount; y++ {
 
-		if y < len(lines) {
+		if y < len(lines) && rows[y] {
 
 			span := 0
 			colour := [3]float32{0, 0, 0}
@@ -582,13 +1005,14 @@ func (gui *GUI) redraw() {
 
 			for x = 0; x < colCount && x < len(cells); x++ {
 				cell := cells[x]
-				if span > 0 && (!cell.Attr().Underline || colour != cell.Fg()) {
+				underline := cell.Attr().Underline || gui.isHoveredLink(cell.Link())
+				if span > 0 && (!underline || colour != cell.Fg()) {
 					gui.renderer.DrawUnderline(span, uint(x-span), uint(y), colour)
 					span = 0
 				}
 
 				colour = cell.Fg()
-				if cell.Attr().Underline {
+				if underline {
 					span++
 				}
 			}
@@ -598,7 +1022,30 @@ func (gui *GUI) redraw() {
 		}
 
 	}
+
+	if showCursor && !blockCursor && cy < uint(lineCount) {
+		gui.renderer.DrawCursor(cx, cy, gui.terminal.Colours().Cursor, cursorShape, gui.barCursorWidth())
+	}
+
+	gui.renderer.Flush()
+	gui.renderer.FreeTextures(lines)
+	gui.frame.present()
+	gui.renderTabBar()
+
+	if gui.unfocused && gui.config.DimInactive.Enabled {
+		gui.renderer.Dim(gui.config.DimInactive.Amount)
+	}
+
+	if time.Now().Before(gui.bellUntil) {
+		gui.renderer.Invert()
+	}
+
 	gui.renderOverlay()
+
+	if time.Now().Before(gui.noticeUntil) {
+		_, h := gui.terminal.GetSize()
+		gui.textbox(2, bottomRow(h), gui.noticeText, [3]float32{1, 1, 1}, [3]float32{0.2, 0.2, 0.4})
+	}
 }
 
 func (gui *GUI) createWindow() (*glfw.Window, error) {
@@ -739,9 +1186,33 @@ func (gui *GUI) Screenshot(path string) {
 }
 
 func (gui *GUI) windowPosChangeCallback(w *glfw.Window, xpos int, ypos int) {
-	gui.SetDPIScale()
+	gui.updateDPIScale()
+	gui.updateRefreshRate()
 }
 
 func (gui *GUI) monitorChangeCallback(monitor *glfw.Monitor, event glfw.MonitorEvent) {
+	gui.updateDPIScale()
+	gui.updateRefreshRate()
+}
+
+// updateDPIScale recalculates the DPI scale, reloading the fonts if it's changed, as it does when the window is moved
+// to a monitor with a different resolution
+func (gui *GUI) updateDPIScale() {
+	previous := gui.dpiScale
 	gui.SetDPIScale()
+	if gui.dpiScale != previous {
+		gui.fontsStale = true
+		gui.terminal.SetDirty()
+	}
+}
+
+// updateRefreshRate limits output driven frames to the refresh rate of the monitor the window is on
+func (gui *GUI) updateRefreshRate() {
+	refreshRate := 0
+	if monitor := gui.GetMonitor(); monitor != nil {
+		if mode := monitor.GetVideoMode(); mode != nil {
+			refreshRate = mode.RefreshRate
+		}
+	}
+	gui.pacer.setRefreshRate(refreshRate)
 }
@@ -3,12 +3,23 @@ package gui
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/go-gl/glfw/v3.2/glfw"
 )
 
 // send typed runes straight through to the pty
 func (gui *GUI) char(w *glfw.Window, r rune) {
+	gui.pacer.input(time.Now())
+	if o, ok := gui.overlay.(textInputOverlay); ok {
+		o.handleChar(gui, r)
+		return
+	}
+	if _, ok := gui.overlay.(inputOverlay); ok {
+		return
+	}
+	gui.terminal.ScrollToEnd()
+	gui.resetCursorBlink()
 	gui.terminal.Write([]byte(string(r)))
 }
 
@@ -45,6 +56,22 @@ func getModStr(mods glfw.ModifierKey) string {
 }
 
 func (gui *GUI) key(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+	gui.pacer.input(time.Now())
+
+	if o, ok := gui.overlay.(inputOverlay); ok {
+		switch action {
+		case glfw.Press:
+			gui.overlayKeys[key] = true
+		case glfw.Release:
+			// handled on release so the character typed by the key press can't reach the pty. Keys which were already
+			// down when the overlay was shown, such as the shortcut which showed it, are ignored.
+			if gui.overlayKeys[key] {
+				delete(gui.overlayKeys, key)
+				o.handleKey(gui, key, mods)
+			}
+		}
+		return
+	}
 
 	if action == glfw.Repeat || action == glfw.Press {
 
@@ -54,19 +81,36 @@ func (gui *GUI) key(w *glfw.Window, key glfw.Key, scancode int, action glfw.Acti
 			}
 		}
 
+		// the scrollback is paged through without the application seeing the keys
+		if modsPressed(mods, glfw.ModShift) {
+			switch key {
+			case glfw.KeyPageUp:
+				gui.terminal.ScrollPageUp()
+				return
+			case glfw.KeyPageDown:
+				gui.terminal.ScrollPageDown()
+				return
+			}
+		}
+
 		// get key name to handle alternative keyboard layouts
 		name := glfw.GetKeyName(key, scancode)
+		// keys which trigger a shortcut aren't passed on to the application
+		for userAction, combination := range gui.keyboardShortcuts {
+			if combination.MatchKey(mods, key) || len(name) == 1 && combination.Match(mods, rune(strings.ToLower(name)[0])) {
+				actionMap[userAction](gui)
+				return
+			}
+		}
+
+		// typing returns to the bottom of the scrollback, unlike output from the application
+		if key < glfw.KeyLeftShift || key > glfw.KeyRightSuper {
+			gui.terminal.ScrollToEnd()
+			gui.resetCursorBlink()
+		}
+
 		if len(name) == 1 {
 			r := rune(strings.ToLower(name)[0])
-			for userAction, shortcut := range gui.keyboardShortcuts {
-				if shortcut.Match(mods, r) {
-					f, ok := actionMap[userAction]
-					if ok {
-						f(gui)
-						break
-					}
-				}
-			}
 
 			// standard ctrl codes e.g. ^C
 			if modsPressed(mods, glfw.ModControl) {
@@ -208,9 +252,15 @@ func (gui *GUI) key(w *glfw.Window, key glfw.Key, scancode int, action glfw.Acti
 				0x1b,
 			})
 		case glfw.KeyTab:
-			gui.terminal.Write([]byte{
-				0x09,
-			})
+			if modStr == "2" {
+				gui.terminal.Write([]byte("\x1b[Z")) // backtab (CBT)
+			} else if mods&glfw.ModShift != 0 {
+				gui.terminal.Write([]byte(fmt.Sprintf("\x1b[1;%sZ", modStr)))
+			} else {
+				gui.terminal.Write([]byte{
+					0x09,
+				})
+			}
 		case glfw.KeyEnter:
 			gui.terminal.WriteReturn()
 		case glfw.KeyKPEnter:
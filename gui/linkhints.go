@@ -0,0 +1,113 @@
+package gui
+
+import (
+	"strings"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/liamg/aminal/buffer"
+)
+
+// hintAlphabet is the set of keys used for labels, home row first
+const hintAlphabet = "asdfghjklqwertyuiopzxcvbnm"
+
+var (
+	hintLabelFg = [3]float32{0, 0, 0}
+	hintLabelBg = [3]float32{1, 0.85, 0.2}
+	hintTypedFg = [3]float32{0.6, 0.45, 0}
+)
+
+// linkHints is an overlay which labels every link on screen, so that a link can be opened by typing its label, or
+// copied by typing the label with shift held
+type linkHints struct {
+	links  []buffer.Link
+	labels []string
+	typed  string
+}
+
+func newLinkHints(links []buffer.Link) *linkHints {
+	return &linkHints{
+		links:  links,
+		labels: hintLabels(len(links)),
+	}
+}
+
+// hintLabels returns n labels, none of which is a prefix of another, so that each can be typed without confirmation
+func hintLabels(n int) []string {
+	alphabet := []rune(hintAlphabet)
+	labels := make([]string, n)
+	if n <= len(alphabet) {
+		for i := range labels {
+			labels[i] = string(alphabet[i])
+		}
+		return labels
+	}
+
+	// two letter labels are enough for every link on any reasonably sized screen
+	for i := range labels {
+		labels[i] = string(alphabet[(i/len(alphabet))%len(alphabet)]) + string(alphabet[i%len(alphabet)])
+	}
+	return labels
+}
+
+func (hints *linkHints) render(gui *GUI) {
+	for i, link := range hints.links {
+		label := hints.labels[i]
+		if !strings.HasPrefix(label, hints.typed) {
+			continue
+		}
+		for j, r := range label {
+			col := uint(link.Col) + uint(j)
+			row := uint(link.Row)
+			gui.renderer.DrawCellBg(buffer.NewBackgroundCell(hintLabelBg), col, row, nil, true)
+			fg := hintLabelFg
+			if j < len(hints.typed) {
+				fg = hintTypedFg
+			}
+			gui.renderer.DrawCellText(string(r), col, row, 1, fg, styleBold)
+		}
+	}
+}
+
+func (hints *linkHints) handleKey(gui *GUI, key glfw.Key, mods glfw.ModifierKey) {
+	if key == glfw.KeyEscape {
+		gui.setOverlay(nil)
+		return
+	}
+	if key < glfw.KeyA || key > glfw.KeyZ {
+		return
+	}
+
+	hints.typed += strings.ToLower(string(rune(key)))
+	defer gui.terminal.SetDirty()
+
+	matched := false
+	for i, label := range hints.labels {
+		if label == hints.typed {
+			gui.setOverlay(nil)
+			if mods&glfw.ModShift != 0 {
+				gui.window.SetClipboardString(hints.links[i].URL)
+				gui.showNotice("Copied link to clipboard")
+			} else {
+				gui.launchTarget(hints.links[i].URL)
+			}
+			return
+		}
+		if strings.HasPrefix(label, hints.typed) {
+			matched = true
+		}
+	}
+
+	if !matched {
+		gui.setOverlay(nil)
+	}
+}
+
+// showLinkHints labels the links on screen, unless there aren't any
+func (gui *GUI) showLinkHints() {
+	links := gui.terminal.ActiveBuffer().GetVisibleLinks()
+	if len(links) == 0 {
+		gui.showNotice("No links on screen")
+		return
+	}
+	gui.setOverlay(newLinkHints(links))
+}
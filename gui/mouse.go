@@ -1,16 +1,34 @@
 package gui
 
 import (
-	"fmt"
 	"math"
+	"time"
 
 	"github.com/go-gl/glfw/v3.2/glfw"
 	"github.com/liamg/aminal/buffer"
 	"github.com/liamg/aminal/terminal"
-	"time"
 )
 
 func (gui *GUI) glfwScrollCallback(w *glfw.Window, xoff float64, yoff float64) {
+	gui.pacer.input(time.Now())
+
+	if yoff == 0 {
+		// horizontal scrolling isn't reported, and there's nothing to scroll sideways
+		return
+	}
+
+	// applications tracking the mouse scroll themselves, such as to move through a file in an editor
+	if gui.terminal.GetMouseMode() != terminal.MouseModeNone {
+		button := terminal.MouseButtonWheelDown
+		if yoff > 0 {
+			button = terminal.MouseButtonWheelUp
+		}
+		x, y := gui.convertMouseCoordinates(w.GetCursorPos())
+		if err := gui.terminal.ReportMouse(button, terminal.MousePress, gui.heldModifiers(), int(x)+1, int(y)+1); err != nil {
+			gui.logger.Errorf("Failed to report mouse wheel: %s", err)
+		}
+		return
+	}
 
 	if yoff > 0 {
 		gui.terminal.ScreenScrollUp(1)
@@ -41,41 +59,133 @@ func (gui *GUI) mouseMoveCallback(w *glfw.Window, px float64, py float64) {
 
 	if gui.mouseDown {
 		gui.terminal.ActiveBuffer().ExtendSelection(x, y, false)
-	} else {
+	} else if _, input := gui.overlay.(inputOverlay); !input {
+		// overlays which take over input are only closed by the user answering them
+		gui.showHover(x, y)
+	}
 
-		hint := gui.terminal.ActiveBuffer().GetHintAtPosition(x, y)
-		if hint != nil {
-			gui.setOverlay(newAnnotation(hint))
-		} else {
-			gui.setOverlay(nil)
-		}
+	gui.updatePointer(x, y)
+
+	// the modifier keys are polled for every move, so they're only looked up when the application is tracking the mouse
+	if gui.terminal.GetMouseMode() == terminal.MouseModeNone {
+		return
 	}
+	if err := gui.terminal.ReportMouse(terminal.MouseButtonNone, terminal.MouseMotion, gui.heldModifiers(), int(x)+1, int(y)+1); err != nil {
+		gui.logger.Errorf("Failed to report mouse motion: %s", err)
+	}
+}
 
-	if url := gui.terminal.ActiveBuffer().GetURLAtPosition(x, y); url != "" {
-		w.SetCursor(gui.getHandCursor())
+// showHover shows the target of the link under the mouse pointer, or the hint for the word under it if it isn't a link
+func (gui *GUI) showHover(x uint16, y uint16) {
+	link := gui.terminal.ActiveBuffer().GetLinkAtPosition(x, y)
+	if !gui.isHoveredLink(link) {
+		// the link may be anywhere on screen, e.g. wrapped onto the next line, so everything is redrawn
+		gui.hoveredLink = link
+		gui.terminal.SetDirty()
+	}
+
+	if link != nil {
+		// replaced as the pointer moves, so the tooltip follows it
+		gui.setOverlay(newLinkTooltip(link.URI, x, y))
+		return
+	}
+
+	if hint := gui.terminal.ActiveBuffer().GetHintAtPosition(x, y); hint != nil {
+		gui.setOverlay(newAnnotation(hint))
+	} else if gui.overlay != nil {
+		gui.setOverlay(nil)
+	}
+}
+
+// updatePointer shows the hand pointer over links, and the arrow everywhere else
+func (gui *GUI) updatePointer(x uint16, y uint16) {
+	if url := gui.terminal.ActiveBuffer().GetURLAtPosition(x, y); url != "" || gui.hoveredLink != nil {
+		gui.window.SetCursor(gui.getHandCursor())
 	} else {
-		w.SetCursor(gui.getArrowCursor())
+		gui.window.SetCursor(gui.getArrowCursor())
 	}
 }
 
-func (gui *GUI) convertMouseCoordinates(px float64, py float64) (uint16, uint16) {
-	scale := gui.scale()
-	px = px / float64(scale)
-	py = py / float64(scale)
-	x := uint16(math.Floor((px - float64(gui.renderer.areaX)) / float64(gui.renderer.CellWidth())))
-	y := uint16(math.Floor((py - float64(gui.renderer.areaY)) / float64(gui.renderer.CellHeight())))
+// recheckHover looks again at the link under the mouse pointer after the view changes, e.g. as output scrolls the
+// hovered link away from under it
+func (gui *GUI) recheckHover() {
+	if gui.hoveredLink == nil || gui.mouseDown {
+		return
+	}
+	if _, input := gui.overlay.(inputOverlay); input {
+		return
+	}
+	x, y := gui.convertMouseCoordinates(gui.window.GetCursorPos())
+	gui.showHover(x, y)
+	gui.updatePointer(x, y)
+}
+
+// isHoveredLink returns true if link is the same link as the one under the mouse pointer
+func (gui *GUI) isHoveredLink(link *buffer.Hyperlink) bool {
+	if link == nil || gui.hoveredLink == nil {
+		return link == gui.hoveredLink
+	}
+	return buffer.SameLink(link, gui.hoveredLink)
+}
+
+// heldModifiers returns the modifier keys currently held, for events such as mouse motion which don't include them
+func (gui *GUI) heldModifiers() terminal.MouseModifiers {
+	var mod glfw.ModifierKey
+	pressed := func(keys ...glfw.Key) bool {
+		for _, key := range keys {
+			if gui.window.GetKey(key) == glfw.Press {
+				return true
+			}
+		}
+		return false
+	}
+	if pressed(glfw.KeyLeftShift, glfw.KeyRightShift) {
+		mod |= glfw.ModShift
+	}
+	if pressed(glfw.KeyLeftSuper, glfw.KeyRightSuper) {
+		mod |= glfw.ModSuper
+	}
+	if pressed(glfw.KeyLeftControl, glfw.KeyRightControl) {
+		mod |= glfw.ModControl
+	}
+	return mouseModifiers(mod)
+}
 
+// convertMouseCoordinates returns the cell under the point px, py in window coordinates. Points beyond the edges of the
+// cell grid, in the gutter or the space left over below and to the right of it, are in the nearest cell on the edge.
+func (gui *GUI) convertMouseCoordinates(px float64, py float64) (uint16, uint16) {
+	scale := float64(gui.scale())
+	activeBuffer := gui.terminal.ActiveBuffer()
+	x := cellIndex(px/scale-float64(gui.renderer.GridX()), float64(gui.renderer.CellWidth()), activeBuffer.ViewWidth())
+	y := cellIndex(py/scale-float64(gui.renderer.areaY), float64(gui.renderer.CellHeight()), activeBuffer.ViewHeight())
 	return x, y
 }
 
-func (gui *GUI) updateLeftClickCount(x uint16, y uint16) int {
+// cellIndex returns the index of the cell a distance from the start of a row or column of count cells, each size
+// long, clamped to the cells there are
+func cellIndex(distance float64, size float64, count uint16) uint16 {
+	if count == 0 || size <= 0 || distance <= 0 {
+		return 0
+	}
+	index := math.Floor(distance / size)
+	if index >= float64(count) {
+		return count - 1
+	}
+	return uint16(index)
+}
+
+// updateLeftClickCount counts a click at the cell x, y, which is at px, py in window coordinates, returning 2 or 3 for
+// the second or third click of a double or triple click
+func (gui *GUI) updateLeftClickCount(x uint16, y uint16, px float64, py float64) int {
 	defer func() {
 		gui.leftClickTime = time.Now()
 		gui.prevLeftClickX = x
 		gui.prevLeftClickY = y
+		gui.prevLeftClickPos = [2]float64{px, py}
 	}()
 
-	if gui.prevLeftClickX == x && gui.prevLeftClickY == y && time.Since(gui.leftClickTime) < time.Millisecond*500 {
+	moved := math.Hypot(px-gui.prevLeftClickPos[0], py-gui.prevLeftClickPos[1])
+	if moved <= gui.multiClickDistance() && time.Since(gui.leftClickTime) < gui.multiClickInterval() {
 		gui.leftClickCount++
 		if gui.leftClickCount > 3 {
 			gui.leftClickCount = 3
@@ -88,30 +198,48 @@ func (gui *GUI) updateLeftClickCount(x uint16, y uint16) int {
 }
 
 func (gui *GUI) mouseButtonCallback(w *glfw.Window, button glfw.MouseButton, action glfw.Action, mod glfw.ModifierKey) {
+	gui.pacer.input(time.Now())
 
 	if gui.overlay != nil {
-		if button == glfw.MouseButtonRight && action == glfw.Release {
+		// a confirmation must be answered, e.g. one shown by pasting with the right button just before it's released
+		if _, confirming := gui.overlay.(*confirmation); !confirming && button == glfw.MouseButtonRight && action == glfw.Release {
 			gui.setOverlay(nil)
 		}
 		return
 	}
 
 	// before we forward clicks on (below), we need to handle them locally for url clicking, text highlighting etc.
-	x, y := gui.convertMouseCoordinates(w.GetCursorPos())
+	px, py := w.GetCursorPos()
+	if button == glfw.MouseButtonLeft && gui.handleTabBarClick(action, px, py) {
+		return
+	}
+	x, y := gui.convertMouseCoordinates(px, py)
 	tx := int(x) + 1 // vt100 is 1 indexed
 	ty := int(y) + 1
 
 	activeBuffer := gui.terminal.ActiveBuffer()
 
+	if button == glfw.MouseButtonLeft && gui.handleGutterClick(action, px, py, y) {
+		return
+	}
+
 	switch button {
 	case glfw.MouseButtonLeft:
+		if gui.terminal.GetMouseMode() == terminal.MouseModeVT200Highlight {
+			// the application highlights the region dragged over instead of the text being selected
+			break
+		}
 		if action == glfw.Press {
 			gui.mouseDown = true
 
-			clickCount := gui.updateLeftClickCount(x, y)
+			clickCount := gui.updateLeftClickCount(x, y, px, py)
 			switch clickCount {
 			case 1:
-				activeBuffer.StartSelection(x, y, buffer.SelectionChar)
+				if mod&glfw.ModAlt > 0 {
+					activeBuffer.StartSelection(x, y, buffer.SelectionBlock)
+				} else {
+					activeBuffer.StartSelection(x, y, buffer.SelectionChar)
+				}
 			case 2:
 				activeBuffer.StartSelection(x, y, buffer.SelectionWord)
 			case 3:
@@ -132,7 +260,15 @@ func (gui *GUI) mouseButtonCallback(w *glfw.Window, button glfw.MouseButton, act
 
 			// Do copy to clipboard *or* open URL, but not both.
 			handled := false
-			if gui.config.CopyAndPasteWithMouse {
+			ctrlClick := mod&glfw.ModControl > 0 && !gui.mouseMovedAfterSelectionStarted && gui.terminal.GetMouseMode() == terminal.MouseModeNone
+			if link := activeBuffer.GetLinkAtPosition(x, y); link != nil && ctrlClick {
+				// links marked by the application only open with ctrl held, as their text needn't look like a link, and
+				// applications tracking the mouse get the click instead
+				activeBuffer.ClearSelection()
+				go gui.launchTarget(link.URI)
+				handled = true
+			}
+			if !handled && gui.config.CopyAndPasteWithMouse {
 				selectedText := activeBuffer.GetSelectedText()
 				if selectedText != "" {
 					gui.window.SetClipboardString(selectedText)
@@ -152,121 +288,71 @@ func (gui *GUI) mouseButtonCallback(w *glfw.Window, button glfw.MouseButton, act
 			str, err := gui.window.GetClipboardString()
 			if err == nil {
 				activeBuffer.ClearSelection()
-				_ = gui.terminal.Paste([]byte(str))
+				gui.paste(str)
 			}
 		}
 	}
 
-	// https://www.xfree86.org/4.8.0/ctlseqs.html
-
-	/*
-		Parameters (such as pointer position and button number) for all mouse tracking escape sequences
-		generated by xterm encode numeric parameters in a single character as value+32. For example,
-		! specifies the value 1. The upper left character position on the terminal is denoted as 1,1.
-	*/
-
-	switch gui.terminal.GetMouseMode() {
-	case terminal.MouseModeNone:
-
-		// handle clicks locally
-
+	// https://invisible-island.net/xterm/ctlseqs/ctlseqs.html#h2-Mouse-Tracking
+	var reportButton terminal.MouseButton
+	switch button {
+	case glfw.MouseButtonLeft:
+		reportButton = terminal.MouseButtonLeft
+	case glfw.MouseButtonMiddle:
+		reportButton = terminal.MouseButtonMiddle
+	case glfw.MouseButtonRight:
+		reportButton = terminal.MouseButtonRight
+	default:
 		return
-	case terminal.MouseModeX10: //X10 compatibility mode
-
-		/*
-			X10 compatibility mode sends an escape sequence only on button press, encoding the location and the mouse button pressed.
-
-			It is enabled by specifying parameter 9 to DECSET.
-
-			On button press, xterm sends CSI M C b C x C y (6 characters).
-
-			C b is button−1.
-
-			C x and C y are the x and y coordinates of the mouse when the button was pressed.
-		*/
-
-		if action == glfw.Press {
-			b := rune(button)
-			packet := fmt.Sprintf("\x1b[M%c%c%c", (rune(b + 32)), (rune(tx + 32)), (rune(ty + 32)))
-
-			gui.terminal.Write([]byte(packet))
-		}
-	case terminal.MouseModeVT200: // normal
-		/*
-
-			Normal tracking mode sends an escape sequence on both button press and release.
-
-			Modifier key (shift, ctrl, meta) information is also sent.
-
-			It is enabled by specifying parameter 1000 to DECSET.
+	}
 
-			On button press or release, xterm sends CSI M C b C x C y .
+	reportAction := terminal.MousePress
+	if action == glfw.Release {
+		reportAction = terminal.MouseRelease
+	}
 
-			The low two bits of C b encode button information: 0=MB1 pressed, 1=MB2 pressed, 2=MB3 pressed, 3=release.
+	if err := gui.terminal.ReportMouse(reportButton, reportAction, mouseModifiers(mod), tx, ty); err != nil {
+		gui.logger.Errorf("Failed to report mouse event: %s", err)
+	}
+}
 
-			The next three bits encode the modifiers which were down when the button was pressed and are added together: 4=Shift, 8=Meta, 16=Control.
+// handleGutterClick selects the command alongside a click in the gutter, or copies it on a double click, returning true
+// if the click was handled and so isn't a selection in the cell grid or reported to the application
+func (gui *GUI) handleGutterClick(action glfw.Action, px float64, py float64, row uint16) bool {
+	if action == glfw.Release {
+		pressed := gui.gutterPressed
+		gui.gutterPressed = false
+		return pressed
+	}
 
-			Note however that the shift and control bits are normally unavailable because xterm uses the control modifier with mouse for popup menus,
-			and the shift modifier is used in the default translations for button events. The Meta modifier recognized by xterm is the mod1 mask, and
-			is not necessarily the "Meta" key (see xmodmap).
+	if !gui.config.Gutter.Enabled || !gui.config.Gutter.ClickToSelect || float32(px)/gui.scale() >= gui.renderer.GridX() {
+		return false
+	}
 
-			C x and C y are the x and y coordinates of the mouse event, encoded as in X10 mode.
+	activeBuffer := gui.terminal.ActiveBuffer()
+	if !activeBuffer.SelectCommand(row) {
+		return false
+	}
+	gui.gutterPressed = true
 
-			Wheel mice may return buttons 4 and 5. Those buttons are represented by the same event codes as buttons 1 and 2 respectively, except that 64 is added to the event code. Release events for the wheel buttons are not reported.
-		*/
-		var b byte
-		if action == glfw.Press {
-			switch button {
-			case glfw.MouseButton1:
-				b = 0
-			case glfw.MouseButton2:
-				b = 1
-			case glfw.MouseButton3:
-				b = 2
-			default:
-				return
-			}
-		} else if action == glfw.Release {
-			b = 3
-		} else {
-			return
-		}
-		if mod&glfw.ModShift > 0 {
-			b |= 4
-		}
-		if mod&glfw.ModSuper > 0 {
-			b |= 8
+	if gui.updateLeftClickCount(0, row, px, py) > 1 {
+		if text := activeBuffer.GetSelectedText(); text != "" {
+			gui.window.SetClipboardString(text)
 		}
-		if mod&glfw.ModControl > 0 {
-			b |= 16
-		}
-
-		packet := fmt.Sprintf("\x1b[M%c%c%c", (rune(b + 32)), (rune(tx + 32)), (rune(ty + 32)))
-		gui.logger.Infof("Sending mouse packet: '%v'", packet)
-		gui.terminal.Write([]byte(packet))
-
-	case terminal.MouseModeVT200Highlight:
-		/*
-		   Mouse highlight tracking notifies a program of a button press, receives a range of lines from the program, highlights the region covered by the mouse within that range until button release, and then sends the program the release coordinates. It is enabled by specifying parameter 1001 to DECSET. Highlighting is performed only for button 1, though other button events can be received. Warning: use of this mode requires a cooperating program or it will hang xterm. On button press, the same information as for normal tracking is generated; xterm then waits for the program to send mouse tracking information. All X events are ignored until the proper escape sequence is received from the pty: CSI P s ; P s ; P s ; P s ; P s T . The parameters are func, startx, starty, firstrow, and lastrow. func is non-zero to initiate highlight tracking and zero to abort. startx and starty give the starting x and y location for the highlighted region. The ending location tracks the mouse, but will never be above row firstrow and will always be above row lastrow. (The top of the screen is row 1.) When the button is released, xterm reports the ending position one of two ways: if the start and end coordinates are valid text locations: CSI t C x C y . If either coordinate is past the end of the line: CSI T C x C y C x C y C x C y . The parameters are startx, starty, endx, endy, mousex, and mousey. startx, starty, endx, and endy give the starting and ending character positions of the region. mousex and mousey give the location of the mouse at button up, which may not be over a character.
-		*/
-		panic("VT200 mouse highlight mode not supported")
-
-	case terminal.MouseModeButtonEvent:
-		/*
-		   Button-event tracking is essentially the same as normal tracking, but xterm also reports button-motion events. Motion events are reported only if the mouse pointer has moved to a different character cell. It is enabled by specifying parameter 1002 to DECSET. On button press or release, xterm sends the same codes used by normal tracking mode. On button-motion events, xterm adds 32 to the event code (the third character, C b ). The other bits of the event code specify button and modifier keys as in normal mode. For example, motion into cell x,y with button 1 down is reported as CSI M @ C x C y . ( @ = 32 + 0 (button 1) + 32 (motion indicator) ). Similarly, motion with button 3 down is reported as CSI M B C x C y . ( B = 32 + 2 (button 3) + 32 (motion indicator) ).
-		*/
-		panic("Mouse button event mode not supported")
-
-	case terminal.MouseModeAnyEvent:
-		/*
-		   Any-event mode is the same as button-event mode, except that all motion events are reported, even if no mouse button is down. It is enabled by specifying 1003 to DECSET.
-
-
-		*/
-		panic("Mouse any event mode not supported")
-
-	default:
-		panic("Unsupported mouse mode")
 	}
+	return true
+}
 
+func mouseModifiers(mod glfw.ModifierKey) terminal.MouseModifiers {
+	var mods terminal.MouseModifiers
+	if mod&glfw.ModShift > 0 {
+		mods |= terminal.MouseModShift
+	}
+	if mod&glfw.ModSuper > 0 {
+		mods |= terminal.MouseModMeta
+	}
+	if mod&glfw.ModControl > 0 {
+		mods |= terminal.MouseModControl
+	}
+	return mods
 }
@@ -0,0 +1,33 @@
+package gui
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCellIndexAtWindowCorners(t *testing.T) {
+	// an 80x24 grid of 9x18 cells, after a 12 pixel gutter, in a window with 7 pixels of padding right and below it
+	const gridX, cellWidth, cellHeight = 12.0, 9.0, 18.0
+	const width, height = gridX + 80*cellWidth + 7, 24*cellHeight + 7
+	cell := func(px, py float64) [2]uint16 {
+		return [2]uint16{cellIndex(px-gridX, cellWidth, 80), cellIndex(py, cellHeight, 24)}
+	}
+
+	assert.Equal(t, [2]uint16{0, 0}, cell(0, 0))
+	assert.Equal(t, [2]uint16{79, 0}, cell(width-1, 0))
+	assert.Equal(t, [2]uint16{0, 23}, cell(0, height-1))
+	assert.Equal(t, [2]uint16{79, 23}, cell(width-1, height-1))
+
+	// points outside the window, as reported while dragging, are clamped too
+	assert.Equal(t, [2]uint16{0, 0}, cell(-50, -50))
+	assert.Equal(t, [2]uint16{79, 23}, cell(width+50, height+50))
+
+	assert.Equal(t, [2]uint16{1, 1}, cell(gridX+cellWidth, cellHeight))
+	assert.Equal(t, [2]uint16{0, 0}, cell(gridX+cellWidth-0.5, cellHeight-0.5))
+}
+
+func TestCellIndexWithoutCells(t *testing.T) {
+	assert.Equal(t, uint16(0), cellIndex(100, 9, 0))
+	assert.Equal(t, uint16(0), cellIndex(100, 0, 80))
+}
@@ -1,12 +1,27 @@
 package gui
 
+import "github.com/go-gl/glfw/v3.2/glfw"
+
 type overlay interface {
 	render(gui *GUI)
 }
 
+// inputOverlay is an overlay which takes over keyboard input while it is shown
+type inputOverlay interface {
+	overlay
+	handleKey(gui *GUI, key glfw.Key, mods glfw.ModifierKey)
+}
+
+// textInputOverlay is an input overlay which is typed into, and is given the characters typed as well as the keys
+type textInputOverlay interface {
+	inputOverlay
+	handleChar(gui *GUI, r rune)
+}
+
 func (gui *GUI) setOverlay(m overlay) {
 	defer gui.terminal.SetDirty()
 	gui.overlay = m
+	gui.overlayKeys = map[glfw.Key]bool{}
 }
 
 func (gui *GUI) renderOverlay() {
@@ -15,4 +30,5 @@ func (gui *GUI) renderOverlay() {
 	}
 
 	gui.overlay.render(gui)
+	gui.renderer.Flush()
 }
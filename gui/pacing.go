@@ -0,0 +1,73 @@
+package gui
+
+import (
+	"time"
+)
+
+const defaultRefreshRate = 60
+
+// inputs older than this are assumed to have produced no output, and don't count towards latency
+const maxInputLatency = time.Second
+
+// idleTimeout is the longest the render loop waits for events before checking for changes again
+const idleTimeout = 20 * time.Millisecond
+
+// framePacer decides when frames are rendered. Frames following user input are rendered immediately to keep latency
+// low, while frames caused by output alone are limited to the refresh rate of the monitor, coalescing bursty output.
+type framePacer struct {
+	interval  time.Duration // minimum time between frames caused by output
+	lastFrame time.Time
+	inputAt   time.Time     // when input was received which hasn't been displayed yet, zero if there is none
+	latency   time.Duration // time from the most recent input until the frame which displayed it
+}
+
+func newFramePacer(refreshRate int) *framePacer {
+	pacer := &framePacer{}
+	pacer.setRefreshRate(refreshRate)
+	return pacer
+}
+
+func (pacer *framePacer) setRefreshRate(refreshRate int) {
+	if refreshRate <= 0 {
+		refreshRate = defaultRefreshRate
+	}
+	pacer.interval = time.Second / time.Duration(refreshRate)
+}
+
+// input records that the user typed, clicked or scrolled, so the next frame should be rendered without delay
+func (pacer *framePacer) input(now time.Time) {
+	if pacer.inputAt.IsZero() || now.Sub(pacer.inputAt) > maxInputLatency {
+		pacer.inputAt = now
+	}
+}
+
+// canRender returns true if a pending frame may be rendered now
+func (pacer *framePacer) canRender(now time.Time) bool {
+	return !pacer.inputAt.IsZero() || now.Sub(pacer.lastFrame) >= pacer.interval
+}
+
+// rendered records that a frame was shown
+func (pacer *framePacer) rendered(now time.Time) {
+	if !pacer.inputAt.IsZero() {
+		if latency := now.Sub(pacer.inputAt); latency <= maxInputLatency {
+			pacer.latency = latency
+		}
+		pacer.inputAt = time.Time{}
+	}
+	pacer.lastFrame = now
+}
+
+// timeout returns how long to wait for events before the render loop should check for changes again
+func (pacer *framePacer) timeout(now time.Time, pending bool) time.Duration {
+	if !pending {
+		return idleTimeout
+	}
+	wait := pacer.interval - now.Sub(pacer.lastFrame)
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > idleTimeout {
+		wait = idleTimeout
+	}
+	return wait
+}
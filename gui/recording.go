@@ -0,0 +1,48 @@
+package gui
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+)
+
+// startRecording records the session to an asciinema cast at path
+func (gui *GUI) startRecording(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("Failed to create recording: %s", err)
+	}
+	if err := gui.terminal.StartRecording(file); err != nil {
+		file.Close()
+		return err
+	}
+	gui.recordingPath = path
+	return nil
+}
+
+// actionToggleRecording starts recording the session to a cast in the home directory, named after the time, or stops
+// the recording
+func actionToggleRecording(gui *GUI) {
+	if gui.terminal.IsRecording() {
+		if err := gui.terminal.StopRecording(); err != nil {
+			gui.showNotice(fmt.Sprintf("Failed to save recording: %s", err))
+			return
+		}
+		gui.showNotice(fmt.Sprintf("Saved recording to %s", gui.recordingPath))
+		return
+	}
+
+	dir := os.TempDir()
+	if usr, err := user.Current(); err == nil && usr.HomeDir != "" {
+		dir = usr.HomeDir
+	}
+	path := filepath.Join(dir, fmt.Sprintf("aminal-%s.cast", time.Now().Format("20060102-150405")))
+	if err := gui.startRecording(path); err != nil {
+		gui.logger.Errorf("%s", err)
+		gui.showNotice(err.Error())
+		return
+	}
+	gui.showNotice(fmt.Sprintf("Recording to %s", path))
+}
@@ -7,7 +7,6 @@ import (
 	"github.com/go-gl/gl/all-core/gl"
 	"github.com/liamg/aminal/buffer"
 	"github.com/liamg/aminal/config"
-	"github.com/liamg/aminal/glfont"
 )
 
 type OpenGLRenderer struct {
@@ -23,9 +22,11 @@ type OpenGLRenderer struct {
 	config           *config.Config
 	colourAttr       uint32
 	program          uint32
-	textureMap       map[*image.RGBA]uint32
+	textureMap       map[*image.RGBA]uint32 // textures of the images on screen, which FreeTextures deletes once they're not
 	fontMap          *FontMap
 	backgroundColour [3]float32
+	gutterWidth      float32   // width in pixels of the shell integration gutter to the left of the cell grid
+	rects            rectBatch // rectangles queued to be drawn by Flush
 }
 
 type rectangle struct {
@@ -49,24 +50,7 @@ func (r *OpenGLRenderer) CellHeight() float32 {
 func (r *OpenGLRenderer) newRectangleEx(x float32, y float32, width float32, height float32, colourAttr uint32) *rectangle {
 
 	rect := &rectangle{}
-
-	halfAreaWidth := float32(r.areaWidth / 2)
-	halfAreaHeight := float32(r.areaHeight / 2)
-
-	x = (x - halfAreaWidth) / halfAreaWidth
-	y = -(y - (halfAreaHeight)) / halfAreaHeight
-	w := width / halfAreaWidth
-	h := height / halfAreaHeight
-
-	rect.points = [18]float32{
-		x, y, 0,
-		x, y + h, 0,
-		x + w, y + h, 0,
-
-		x + w, y, 0,
-		x, y, 0,
-		x + w, y + h, 0,
-	}
+	rect.points = r.rectPoints(x, y, width, height)
 
 	rect.colourAttr = colourAttr
 	rect.prog = r.program
@@ -91,8 +75,39 @@ func (r *OpenGLRenderer) newRectangleEx(x float32, y float32, width float32, hei
 	return rect
 }
 
-func (r *OpenGLRenderer) newRectangle(x float32, y float32, colourAttr uint32) *rectangle {
-	return r.newRectangleEx(x, y, r.cellWidth, r.cellHeight, colourAttr)
+// rectPoints returns the vertices of the two triangles making up a rectangle, in clip space. y is the bottom of the
+// rectangle, counted down from the top of the area.
+func (r *OpenGLRenderer) rectPoints(x float32, y float32, width float32, height float32) [18]float32 {
+	halfAreaWidth := float32(r.areaWidth / 2)
+	halfAreaHeight := float32(r.areaHeight / 2)
+
+	x = (x - halfAreaWidth) / halfAreaWidth
+	y = -(y - (halfAreaHeight)) / halfAreaHeight
+	w := width / halfAreaWidth
+	h := height / halfAreaHeight
+
+	return [18]float32{
+		x, y, 0,
+		x, y + h, 0,
+		x + w, y + h, 0,
+
+		x + w, y, 0,
+		x, y, 0,
+		x + w, y + h, 0,
+	}
+}
+
+// queueRect adds a rectangle to those drawn by the next Flush
+func (r *OpenGLRenderer) queueRect(x float32, y float32, width float32, height float32, colour [3]float32) {
+	r.rects.add(r.rectPoints(x, y, width, height), colour)
+}
+
+// Flush draws everything queued since the last Flush: the rectangles first, then the text over them. Backgrounds,
+// text, underlines, gutter markers and cursors are queued rather than drawn straight away, so that a screenful of them
+// takes a handful of draw calls.
+func (r *OpenGLRenderer) Flush() {
+	r.rects.flush(r.program, r.colourAttr)
+	r.fontMap.Flush()
 }
 
 func (rect *rectangle) Draw() {
@@ -159,11 +174,36 @@ func (r *OpenGLRenderer) Free() {
 	r.textureMap = map[*image.RGBA]uint32{}
 
 	r.fontMap.Free()
+	r.rects.free()
 
 	gl.DeleteProgram(r.program)
 	r.program = 0
 }
 
+// FreeTextures deletes the textures of images which are no longer in any of lines, the lines on screen, so that images
+// which have been overwritten or discarded from the scrollback don't use up memory. Images which are scrolled back into
+// view are given new textures.
+func (r *OpenGLRenderer) FreeTextures(lines []buffer.Line) {
+	if len(r.textureMap) == 0 {
+		return
+	}
+	visible := map[*image.RGBA]bool{}
+	for i := range lines {
+		cells := lines[i].Cells()
+		for j := range cells {
+			if img := cells[j].Image(); img != nil {
+				visible[img] = true
+			}
+		}
+	}
+	for img, tex := range r.textureMap {
+		if !visible[img] {
+			gl.DeleteTextures(1, &tex)
+			delete(r.textureMap, img)
+		}
+	}
+}
+
 func (r *OpenGLRenderer) GetTermSize() (uint, uint) {
 	return r.termCols, r.termRows
 }
@@ -177,32 +217,119 @@ func (r *OpenGLRenderer) SetArea(areaX int, areaY int, areaWidth int, areaHeight
 	_, r.cellHeight = f.MaxSize()
 	r.cellWidth, _ = f.Size("X")
 	//= f.LineHeight()   // includes vertical padding
-	r.termCols = uint(math.Floor(float64(float32(r.areaWidth) / r.cellWidth)))
+	r.termCols = uint(math.Floor(float64((float32(r.areaWidth) - r.gutterWidth) / r.cellWidth)))
 	r.termRows = uint(math.Floor(float64(float32(r.areaHeight) / r.cellHeight)))
 }
 
+// SetGutterWidth reserves space to the left of the cell grid for the gutter. SetArea must be called afterwards to recalculate the terminal size.
+func (r *OpenGLRenderer) SetGutterWidth(width float32) {
+	r.gutterWidth = width
+}
+
+// GridX returns the x position in pixels of the left edge of the cell grid
+func (r *OpenGLRenderer) GridX() float32 {
+	return float32(r.areaX) + r.gutterWidth
+}
+
+func (r *OpenGLRenderer) cellX(col uint) float32 {
+	return r.GridX() + float32(col)*r.cellWidth
+}
+
 func (r *OpenGLRenderer) GetRectangleSize(col uint, row uint) (float32, float32) {
-	x := float32(float32(col) * r.cellWidth)
+	x := float32(float32(col)*r.cellWidth) + r.gutterWidth
 	y := float32(float32(row) * r.cellHeight)
 
 	return x, y
 }
 
-func (r *OpenGLRenderer) getRectangle(col uint, row uint) *rectangle {
-	x := float32(float32(col) * r.cellWidth)
-	y := float32(float32(row)*r.cellHeight) + r.cellHeight
+// DrawGutterMarker draws a marker in the gutter alongside the given row
+func (r *OpenGLRenderer) DrawGutterMarker(row uint, colour [3]float32) {
+	if r.gutterWidth <= 0 {
+		return
+	}
 
-	return r.newRectangle(x, y, r.colourAttr)
+	// leave a small gap between the marker and the cell grid
+	width := r.gutterWidth * 2 / 3
+	x := float32(r.areaX) + (r.gutterWidth-width)/2
+	y := float32(row+1) * r.cellHeight
+
+	r.queueRect(x, y, width, r.cellHeight, colour)
 }
 
-func (r *OpenGLRenderer) DrawCursor(col uint, row uint, colour config.Colour) {
-	rect := r.getRectangle(col, row)
-	rect.setColour(colour)
+// Dim darkens everything drawn so far by amount, from 0 (unchanged) to 1 (black)
+func (r *OpenGLRenderer) Dim(amount float32) {
+	r.Flush()
+	amount = float32(math.Min(1, math.Max(0, float64(amount))))
+	width := float32(r.areaWidth)
+	height := float32(r.areaHeight)
+
+	// scale the colours already in the framebuffer, whatever colour the rectangle is
+	gl.Enable(gl.BLEND)
+	gl.BlendColor(1-amount, 1-amount, 1-amount, 1)
+	gl.BlendFunc(gl.ZERO, gl.CONSTANT_COLOR)
+
+	rect := r.newRectangleEx(float32(r.areaX), float32(r.areaY)+height, width, height, r.colourAttr)
+	rect.setColour([3]float32{0, 0, 0})
 	rect.Draw()
+	rect.Free()
+
+	gl.Disable(gl.BLEND)
+}
+
+// Invert inverts the colours of everything drawn so far
+func (r *OpenGLRenderer) Invert() {
+	r.Flush()
+	width := float32(r.areaWidth)
+	height := float32(r.areaHeight)
+
+	// a white rectangle blended with one minus the colour already in the framebuffer
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.ONE_MINUS_DST_COLOR, gl.ZERO)
 
+	rect := r.newRectangleEx(float32(r.areaX), float32(r.areaY)+height, width, height, r.colourAttr)
+	rect.setColour([3]float32{1, 1, 1})
+	rect.Draw()
 	rect.Free()
+
+	gl.Disable(gl.BLEND)
+}
+
+// ClearRow clears a single row of the cell grid to the background colour, including the gutter alongside it
+func (r *OpenGLRenderer) ClearRow(row uint) {
+	bottom, top := r.RowPixels(row)
+
+	gl.Enable(gl.SCISSOR_TEST)
+	gl.Scissor(0, bottom, int32(r.areaWidth), top-bottom)
+	gl.Clear(gl.COLOR_BUFFER_BIT)
+	gl.Disable(gl.SCISSOR_TEST)
+}
+
+// RowPixels returns the first pixel row a row of cells covers and the one after its last, counted up from the bottom
+// of the window as OpenGL counts them
+func (r *OpenGLRenderer) RowPixels(row uint) (int32, int32) {
+	top := int32(math.Floor(float64(float32(row) * r.cellHeight)))
+	bottom := int32(math.Ceil(float64(float32(row+1) * r.cellHeight)))
+	return int32(r.areaHeight) - bottom, int32(r.areaHeight) - top
+}
+
+// DrawCursor draws an underline or bar cursor at (col, row) - block cursors are drawn as part of the cell. Bar cursors
+// are barWidth pixels wide, but no wider than the cell.
+func (r *OpenGLRenderer) DrawCursor(col uint, row uint, colour config.Colour, shape config.CursorShape, barWidth float32) {
+	x := r.cellX(col)
+	y := float32(row+1) * r.cellHeight
+	width, height := r.cellWidth, r.cellHeight
+
+	switch shape {
+	case config.CursorShapeUnderline:
+		height = float32(math.Max(1, float64(r.cellHeight/8)))
+	case config.CursorShapeBar:
+		width = float32(math.Max(1, math.Min(float64(barWidth), float64(r.cellWidth))))
+	}
+
+	r.queueRect(x, y, width, height, colour)
 }
 
+// DrawCellBg queues the background of a cell, unless it's the same as the window's, to be drawn by the next Flush
 func (r *OpenGLRenderer) DrawCellBg(cell buffer.Cell, col uint, row uint, colour *config.Colour, force bool) {
 
 	var bg [3]float32
@@ -214,11 +341,7 @@ func (r *OpenGLRenderer) DrawCellBg(cell buffer.Cell, col uint, row uint, colour
 	}
 
 	if bg != r.backgroundColour || force {
-		rect := r.getRectangle(col, row)
-		rect.setColour(bg)
-		rect.Draw()
-
-		rect.Free()
+		r.queueRect(r.cellX(col), float32(row+1)*r.cellHeight, r.cellWidth, r.cellHeight, bg)
 	}
 
 }
@@ -226,36 +349,68 @@ func (r *OpenGLRenderer) DrawCellBg(cell buffer.Cell, col uint, row uint, colour
 // DrawUnderline draws a line under 'span' characters starting at (col, row)
 func (r *OpenGLRenderer) DrawUnderline(span int, col uint, row uint, colour [3]float32) {
 	//calculate coordinates
-	x := float32(float32(col) * r.cellWidth)
+	x := r.cellX(col)
 	y := (float32(row+1))*r.cellHeight + r.fontMap.DefaultFont().MinY()*0.25
 
 	thickness := r.cellHeight / 16
 	if thickness < 1 {
 		thickness = 1
 	}
-	rect := r.newRectangleEx(x, y, r.cellWidth*float32(span), thickness, r.colourAttr)
+	r.queueRect(x, y, r.cellWidth*float32(span), thickness, colour)
+}
 
-	rect.setColour(colour)
-	rect.Draw()
+// DrawCellText queues text starting in the cell at (col, row) to be drawn by the next Flush
+func (r *OpenGLRenderer) DrawCellText(text string, col uint, row uint, alpha float32, colour [3]float32, style fontStyle) {
 
-	rect.Free()
+	f := r.fontMap.Face(style)
+
+	f.SetColor(colour[0], colour[1], colour[2], alpha)
+
+	x := r.cellX(col)
+	y := float32(r.areaY) + (float32(row+1) * r.cellHeight) + f.MinY()
+
+	f.Queue(x, y, text)
 }
 
-func (r *OpenGLRenderer) DrawCellText(text string, col uint, row uint, alpha float32, colour [3]float32, bold bool) {
+// DrawCellMarks draws combining marks over the character in the given number of cells from (col, row). Marks which
+// the font has no glyph for, such as joiners and variation selectors, aren't drawn.
+func (r *OpenGLRenderer) DrawCellMarks(marks []rune, col uint, row uint, columns uint, alpha float32, colour [3]float32, style fontStyle) {
 
-	var f *glfont.Font
-	if bold {
-		f = r.fontMap.BoldFont()
-	} else {
-		f = r.fontMap.DefaultFont()
-	}
+	f := r.fontMap.Face(style)
 
 	f.SetColor(colour[0], colour[1], colour[2], alpha)
 
-	x := float32(r.areaX) + float32(col)*r.cellWidth
+	x := r.cellX(col)
 	y := float32(r.areaY) + (float32(row+1) * r.cellHeight) + f.MinY()
 
-	f.Print(x, y, text)
+	for _, mark := range marks {
+		if f.HasGlyph(mark) {
+			f.QueueCentred(x, y, float32(columns)*r.cellWidth, mark)
+		}
+	}
+}
+
+// IsEmoji returns true if ch should be drawn with DrawCellEmoji, because it's an emoji which the colour emoji font has
+// a glyph for. Pictographs which the text font has are only drawn in colour if they are in the Unicode emoji blocks.
+func (r *OpenGLRenderer) IsEmoji(ch rune) bool {
+	emojiFont := r.fontMap.EmojiFont()
+	if emojiFont == nil || ch < 0x80 {
+		return false
+	}
+	return (ch >= 0x1f000 || !r.fontMap.DefaultFont().HasGlyph(ch)) && emojiFont.HasGlyph(ch)
+}
+
+// DrawCellEmoji draws ch from the colour emoji font scaled to fit the given number of cells, returning false if the
+// font has no image for it, in which case it should be drawn as text instead
+func (r *OpenGLRenderer) DrawCellEmoji(ch rune, col uint, row uint, columns uint, alpha float32, colour [3]float32) bool {
+	emojiFont := r.fontMap.EmojiFont()
+	if emojiFont == nil {
+		return false
+	}
+
+	x := r.cellX(col)
+	y := float32(r.areaY) + float32(row)*r.cellHeight
+	return emojiFont.Draw(ch, x, y, float32(columns)*r.cellWidth, r.cellHeight, colour, alpha)
 }
 
 func (r *OpenGLRenderer) DrawCellImage(cell buffer.Cell, col uint, row uint) {
@@ -266,9 +421,8 @@ func (r *OpenGLRenderer) DrawCellImage(cell buffer.Cell, col uint, row uint) {
 		return
 	}
 
-	ix := float32(col) * r.cellWidth
+	ix := r.cellX(col)
 	iy := float32(r.areaHeight) - (float32(row+1) * r.cellHeight)
-	iy -= float32(cell.Image().Bounds().Size().Y)
 	gl.UseProgram(r.program)
 
 	var tex uint32
@@ -311,8 +465,9 @@ func (r *OpenGLRenderer) DrawCellImage(cell buffer.Cell, col uint, row uint) {
 
 	gl.FramebufferTexture2D(gl.READ_FRAMEBUFFER, gl.COLOR_ATTACHMENT0,
 		gl.TEXTURE_2D, tex, 0)
+	// the rows of the image are top to bottom, and the framebuffer's are bottom to top, so the image is flipped
 	gl.BlitFramebuffer(0, 0, int32(w), int32(h),
-		int32(ix), int32(iy), int32(ix+w), int32(iy+h),
+		int32(ix), int32(iy+h), int32(ix+w), int32(iy),
 		gl.COLOR_BUFFER_BIT, gl.LINEAR)
 	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, 0)
 	gl.DeleteFramebuffers(1, &readFboId)
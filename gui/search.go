@@ -0,0 +1,208 @@
+package gui
+
+import (
+	"fmt"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/liamg/aminal/buffer"
+)
+
+var (
+	searchMatchFg   = [3]float32{0, 0, 0}
+	searchMatchBg   = [3]float32{0.6, 0.5, 0.15}
+	searchCurrentBg = [3]float32{1, 0.85, 0.2}
+)
+
+// search is an overlay which finds text on screen and in the scrollback as it's typed, highlighting every match and
+// scrolling to the current one. Searching starts from the bottom, and moves to older matches.
+type search struct {
+	query         []rune
+	caseSensitive bool
+	matches       []buffer.Match
+	current       int // index of the current match, or -1 if there are none
+
+	// the buffer searched, the lines it had discarded and its width when it was searched, so the matches can be kept on
+	// the lines they were found on as new output discards old lines
+	buffer    *buffer.Buffer
+	discarded uint64
+	width     uint16
+}
+
+// showSearch opens the search prompt
+func (gui *GUI) showSearch() {
+	gui.setOverlay(&search{current: -1})
+}
+
+func (s *search) handleChar(gui *GUI, r rune) {
+	s.query = append(s.query, r)
+	s.update(gui)
+}
+
+func (s *search) handleKey(gui *GUI, key glfw.Key, mods glfw.ModifierKey) {
+	switch key {
+	case glfw.KeyEscape:
+		gui.setOverlay(nil)
+	case glfw.KeyBackspace:
+		if len(s.query) > 0 {
+			s.query = s.query[:len(s.query)-1]
+			s.update(gui)
+		}
+	case glfw.KeyTab:
+		s.caseSensitive = !s.caseSensitive
+		s.update(gui)
+	case glfw.KeyEnter, glfw.KeyKPEnter:
+		if mods&glfw.ModShift != 0 {
+			s.step(gui, 1)
+		} else {
+			s.step(gui, -1)
+		}
+	case glfw.KeyUp:
+		s.step(gui, -1)
+	case glfw.KeyDown:
+		s.step(gui, 1)
+	}
+}
+
+// update searches for the query again after it's changed, staying on the current match if it still matches, or
+// moving to the nearest one above it
+func (s *search) update(gui *GUI) {
+	defer gui.terminal.SetDirty()
+
+	var from *buffer.Position
+	if s.current >= 0 && s.current < len(s.matches) {
+		from = &s.matches[s.current].Start
+	}
+
+	b := gui.terminal.ActiveBuffer()
+	matches := b.Find(string(s.query), s.caseSensitive)
+	current := len(matches) - 1
+	if from != nil {
+		for current > 0 && positionAfter(matches[current].Start, *from) {
+			current--
+		}
+	}
+
+	s.matches = matches
+	s.current = current
+	s.buffer, s.discarded, s.width = b, b.Discarded(), b.ViewWidth()
+	s.show(gui)
+}
+
+// sync moves the matches up by the lines discarded from the top of the buffer since it was searched, dropping the
+// matches which were on them. After the lines have been rewrapped, or the other screen buffer is shown, it's searched
+// again instead.
+func (s *search) sync(gui *GUI) {
+	b := gui.terminal.ActiveBuffer()
+	if len(s.query) == 0 || b == s.buffer && b.Discarded() == s.discarded && b.ViewWidth() == s.width {
+		return
+	}
+	if b != s.buffer || b.ViewWidth() != s.width {
+		s.update(gui)
+		return
+	}
+
+	s.matches, s.current = shiftMatches(s.matches, s.current, int(b.Discarded()-s.discarded))
+	s.discarded = b.Discarded()
+}
+
+// shiftMatches moves matches up by shift lines, dropping those which started on the lines above, and returns the index
+// of the current match among the ones kept. The current match moves to the oldest kept if it was dropped.
+func shiftMatches(matches []buffer.Match, current int, shift int) ([]buffer.Match, int) {
+	kept := matches[:0]
+	for i, match := range matches {
+		if match.Start.Line < shift {
+			if i <= current {
+				current--
+			}
+			continue
+		}
+		match.Start.Line -= shift
+		match.End.Line -= shift
+		kept = append(kept, match)
+	}
+	if current < 0 && len(kept) > 0 {
+		current = 0
+	}
+	return kept, current
+}
+
+// step moves to the match delta matches after the current one, wrapping around at the top and bottom
+func (s *search) step(gui *GUI, delta int) {
+	s.sync(gui)
+	if len(s.matches) == 0 {
+		return
+	}
+	defer gui.terminal.SetDirty()
+
+	s.current = (s.current + delta + len(s.matches)) % len(s.matches)
+	s.show(gui)
+}
+
+// show scrolls to the current match
+func (s *search) show(gui *GUI) {
+	if s.current >= 0 {
+		gui.terminal.ActiveBuffer().ScrollToLine(s.matches[s.current].Start.Line)
+	}
+}
+
+func positionAfter(a buffer.Position, b buffer.Position) bool {
+	return a.Line > b.Line || a.Line == b.Line && a.Col > b.Col
+}
+
+func (s *search) render(gui *GUI) {
+	s.sync(gui)
+	b := gui.terminal.ActiveBuffer()
+
+	for i, match := range s.matches {
+		bg := searchMatchBg
+		if i == s.current {
+			bg = searchCurrentBg
+		}
+
+		for line := match.Start.Line; line <= match.End.Line; line++ {
+			row, ok := b.ViewRow(line)
+			if !ok {
+				continue
+			}
+			from, to := 0, int(b.ViewWidth())-1
+			if line == match.Start.Line {
+				from = match.Start.Col
+			}
+			if line == match.End.Line {
+				to = match.End.Col
+				if cell := b.GetRawCell(uint16(to), uint64(line)); cell != nil && cell.IsWide() {
+					to++
+				}
+			}
+
+			// the backgrounds are all drawn first, as wide characters are drawn across the following cell
+			for col := from; col <= to; col++ {
+				gui.renderer.DrawCellBg(buffer.NewBackgroundCell(bg), uint(col), uint(row), nil, true)
+			}
+			for col := from; col <= to; col++ {
+				cell := b.GetRawCell(uint16(col), uint64(line))
+				if cell == nil || cell.Rune() == 0 {
+					continue
+				}
+				columns := uint(1)
+				if cell.IsWide() {
+					columns = 2
+				}
+				gui.renderer.DrawCellText(string(cell.Rune()), uint(col), uint(row), 1, searchMatchFg, gui.attrStyle(cell.Attr()))
+				gui.renderer.DrawCellMarks(cell.Combining(), uint(col), uint(row), columns, 1, searchMatchFg, gui.attrStyle(cell.Attr()))
+			}
+		}
+	}
+
+	status := "no matches"
+	if len(s.query) == 0 {
+		status = "type to search"
+	} else if len(s.matches) > 0 {
+		status = fmt.Sprintf("%d/%d", s.current+1, len(s.matches))
+	}
+	if s.caseSensitive {
+		status += ", case sensitive"
+	}
+	_, h := gui.terminal.GetSize()
+	gui.textbox(2, bottomRow(h), fmt.Sprintf("Find: %s_ (%s)", string(s.query), status), [3]float32{1, 1, 1}, [3]float32{0.2, 0.2, 0.4})
+}
@@ -0,0 +1,26 @@
+package gui
+
+import (
+	"testing"
+
+	"github.com/liamg/aminal/buffer"
+	"github.com/stretchr/testify/assert"
+)
+
+func match(line int, col int) buffer.Match {
+	return buffer.Match{Start: buffer.Position{Line: line, Col: col}, End: buffer.Position{Line: line, Col: col + 2}}
+}
+
+func TestShiftMatches(t *testing.T) {
+	matches, current := shiftMatches([]buffer.Match{match(1, 0), match(4, 3), match(9, 1)}, 1, 2)
+	assert.Equal(t, []buffer.Match{match(2, 3), match(7, 1)}, matches)
+	assert.Equal(t, 0, current, "the current match stays the same one")
+
+	matches, current = shiftMatches(matches, 0, 5)
+	assert.Equal(t, []buffer.Match{match(2, 1)}, matches)
+	assert.Equal(t, 0, current, "the oldest match kept becomes current when the current one is discarded")
+
+	matches, current = shiftMatches(matches, 0, 3)
+	assert.Empty(t, matches)
+	assert.Equal(t, -1, current)
+}
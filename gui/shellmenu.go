@@ -0,0 +1,96 @@
+package gui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/liamg/aminal/platform"
+	"github.com/riywo/loginshell"
+)
+
+// shellMenu is an overlay which lists the shells on this machine, so that a new tab running any of them can be
+// opened without changing the configured shell
+type shellMenu struct {
+	shells   []string
+	current  string // the shell new tabs run by default
+	selected int
+}
+
+func newShellMenu(shells []string, current string) *shellMenu {
+	menu := &shellMenu{current: current}
+	if current != "" {
+		menu.shells = append(menu.shells, current)
+	}
+	for _, shell := range shells {
+		if shell != current {
+			menu.shells = append(menu.shells, shell)
+		}
+	}
+	return menu
+}
+
+func (menu *shellMenu) render(gui *GUI) {
+	var builder strings.Builder
+	builder.WriteString("Open a new tab running:\n\n")
+	for i, shell := range menu.shells {
+		marker := "  "
+		if i == menu.selected {
+			marker = "> "
+		}
+		builder.WriteString(fmt.Sprintf("%s%d. %s", marker, i+1, shell))
+		if shell == menu.current {
+			builder.WriteString(" (default)")
+		}
+		builder.WriteString("\n")
+	}
+	builder.WriteString("\nUse the arrow keys or numbers to choose a shell, enter to open it, or escape to cancel.")
+	gui.textbox(2, 2, builder.String(), [3]float32{1, 1, 1}, [3]float32{0.2, 0.2, 0.4})
+}
+
+func (menu *shellMenu) handleKey(gui *GUI, key glfw.Key, mods glfw.ModifierKey) {
+	defer gui.terminal.SetDirty()
+
+	switch {
+	case key == glfw.KeyEscape:
+		gui.setOverlay(nil)
+	case key == glfw.KeyUp || key == glfw.KeyK:
+		if menu.selected > 0 {
+			menu.selected--
+		}
+	case key == glfw.KeyDown || key == glfw.KeyJ:
+		if menu.selected < len(menu.shells)-1 {
+			menu.selected++
+		}
+	case key == glfw.KeyEnter || key == glfw.KeyKPEnter:
+		gui.setOverlay(nil)
+		gui.openTab(menu.shells[menu.selected])
+	case key >= glfw.Key1 && key <= glfw.Key9:
+		if i := int(key - glfw.Key1); i < len(menu.shells) {
+			gui.setOverlay(nil)
+			gui.openTab(menu.shells[i])
+		}
+	}
+}
+
+// defaultShell returns the shell which new tabs run unless another is chosen
+func (gui *GUI) defaultShell() string {
+	if gui.config.Shell != "" {
+		return gui.config.Shell
+	}
+	shell, err := loginshell.Shell()
+	if err != nil {
+		return ""
+	}
+	return shell
+}
+
+// showShellMenu lets the user choose a shell to open a new tab with
+func (gui *GUI) showShellMenu() {
+	menu := newShellMenu(platform.DetectShells(), gui.defaultShell())
+	if len(menu.shells) == 0 {
+		gui.showNotice("No shells found")
+		return
+	}
+	gui.setOverlay(menu)
+}
@@ -0,0 +1,401 @@
+package gui
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/liamg/aminal/buffer"
+	"github.com/liamg/aminal/platform"
+	"github.com/liamg/aminal/terminal"
+)
+
+// how long a tab's shell has to exit after it's sent SIGHUP when the tab or the window is closed
+const hangupTimeout = time.Second
+
+var (
+	tabBarFg       = [3]float32{0.7, 0.7, 0.7}
+	tabBarBg       = [3]float32{0.12, 0.12, 0.16}
+	tabBarActiveFg = [3]float32{1, 1, 1}
+	tabBarActiveBg = [3]float32{0.2, 0.2, 0.4}
+)
+
+// NewTabFunc starts a shell in a new terminal, for a new tab. The configured shell is started if shell is empty.
+type NewTabFunc func(shell string) (*terminal.Terminal, platform.Process, error)
+
+// A tab is a terminal in the window and the shell running in it. Only the active tab is shown, but the others keep
+// reading what their shells output.
+type tab struct {
+	terminal  *terminal.Terminal
+	process   platform.Process
+	clipboard chan terminal.ClipboardRequest
+	resize    chan bool
+	closed    chan struct{} // closed when the tab is closed
+
+	// resizeRequested is set when the application in the tab asked for another size while the tab was in the
+	// background, so that the window takes that size when the tab is shown
+	resizeRequested bool
+}
+
+// tabEvents are the channels the terminals of every tab signal the render loop on
+type tabEvents struct {
+	title     chan bool
+	resize    chan *tab // the tab's terminal has changed size
+	reverse   chan bool
+	bell      chan bool
+	dirty     chan bool
+	clipboard chan tabClipboardRequest
+	exited    chan *tab // the tab's shell has exited, or its pty can't be read
+}
+
+// tabClipboardRequest is an OSC 52 request from the application in a tab
+type tabClipboardRequest struct {
+	tab     *tab
+	request terminal.ClipboardRequest
+}
+
+func newTabEvents() tabEvents {
+	return tabEvents{
+		title:     make(chan bool, 1),
+		resize:    make(chan *tab, 1),
+		reverse:   make(chan bool, 1),
+		bell:      make(chan bool, 1),
+		dirty:     make(chan bool, 1),
+		clipboard: make(chan tabClipboardRequest, 1),
+		exited:    make(chan *tab, 1),
+	}
+}
+
+func makeTab(t *terminal.Terminal, process platform.Process) *tab {
+	return &tab{
+		terminal:  t,
+		process:   process,
+		clipboard: make(chan terminal.ClipboardRequest, 1),
+		resize:    make(chan bool, 1),
+		closed:    make(chan struct{}),
+	}
+}
+
+// startTab connects a tab's terminal to the render loop and starts reading its shell's output. The tab is closed when
+// the shell exits.
+func (gui *GUI) startTab(newTab *tab) {
+	t := newTab.terminal
+	t.SetProgram(gui.program)
+	t.SetCharSize(gui.renderer.cellWidth, gui.renderer.cellHeight)
+	t.AttachTitleChangeHandler(gui.events.title)
+	t.AttachResizeHandler(newTab.resize)
+	t.AttachReverseHandler(gui.events.reverse)
+	t.AttachBellHandler(gui.events.bell)
+	t.AttachDirtyHandler(gui.events.dirty)
+	t.AttachClipboardHandler(newTab.clipboard)
+
+	go func() {
+		for {
+			select {
+			case request := <-newTab.clipboard:
+				gui.events.clipboard <- tabClipboardRequest{tab: newTab, request: request}
+			case <-newTab.resize:
+				gui.events.resize <- newTab
+			case <-newTab.closed:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		err := t.Read()
+		if newTab.isClosed() {
+			return
+		}
+		if err != nil {
+			gui.logger.Errorf("Read from pty failed: %s", err)
+		}
+		gui.tabExited(newTab)
+	}()
+
+	go func() {
+		if err := newTab.process.Wait(); err != nil {
+			gui.logger.Infof("Guest process exited: %s", err)
+		}
+		if !newTab.isClosed() {
+			gui.tabExited(newTab)
+		}
+	}()
+}
+
+// tabExited tells the render loop that a tab is finished with, and wakes it up to close the tab
+func (gui *GUI) tabExited(t *tab) {
+	gui.events.exited <- t
+	glfw.PostEmptyEvent()
+}
+
+func (t *tab) isClosed() bool {
+	select {
+	case <-t.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// hangup ends the tab's shell and everything it started, and closes its pty
+func (t *tab) hangup(gui *GUI) {
+	if err := t.process.Hangup(hangupTimeout); err != nil {
+		gui.logger.Errorf("Failed to hang up guest process: %s", err)
+	}
+	_ = t.process.Close()
+	_ = t.terminal.Close()
+}
+
+// openTab starts a shell in a new tab, and shows it. The configured shell is started if shell is empty.
+func (gui *GUI) openTab(shell string) {
+	if gui.newTab == nil {
+		return
+	}
+	t, process, err := gui.newTab(shell)
+	if err != nil {
+		gui.logger.Errorf("Failed to open tab: %s", err)
+		gui.showNotice("Failed to open a new tab")
+		return
+	}
+	newTab := makeTab(t, process)
+	gui.tabs = append(gui.tabs, newTab)
+	gui.startTab(newTab)
+	gui.showTab(len(gui.tabs) - 1)
+	// the tab bar appears with the second tab, and the new terminal has to be sized to fit the window
+	gui.relayout()
+}
+
+// closeTab closes a tab and hangs up its shell. The window is closed along with the last tab.
+func (gui *GUI) closeTab(t *tab) {
+	i := gui.tabIndex(t)
+	if i < 0 {
+		return
+	}
+	if len(gui.tabs) == 1 {
+		// the last shell is hung up by closeTabs as the window closes
+		gui.Close()
+		return
+	}
+	close(t.closed)
+	go t.hangup(gui)
+	gui.tabs = append(gui.tabs[:i], gui.tabs[i+1:]...)
+
+	if i < gui.activeTab {
+		gui.activeTab--
+	} else if i == gui.activeTab {
+		if gui.activeTab == len(gui.tabs) {
+			gui.activeTab--
+		}
+		// the closed terminal isn't told it's lost focus
+		gui.terminal = nil
+		gui.showTab(gui.activeTab)
+	}
+	// the tab bar disappears along with the second to last tab
+	gui.relayout()
+}
+
+// closeTabs hangs up the shells of every tab as the window closes, waiting for them to exit
+func (gui *GUI) closeTabs() {
+	var wait sync.WaitGroup
+	for _, t := range gui.tabs {
+		close(t.closed)
+		wait.Add(1)
+		go func(t *tab) {
+			defer wait.Done()
+			t.hangup(gui)
+		}(t)
+	}
+	gui.tabs = nil
+	wait.Wait()
+}
+
+func (gui *GUI) tabIndex(t *tab) int {
+	for i, other := range gui.tabs {
+		if other == t {
+			return i
+		}
+	}
+	return -1
+}
+
+// showTab makes the tab at index i the one shown in the window. Overlays belong to the tab they were shown over, so
+// they're closed.
+func (gui *GUI) showTab(i int) {
+	previous := gui.terminal
+	gui.activeTab = i
+	gui.terminal = gui.tabs[i].terminal
+	gui.activeTerminal.Store(gui.terminal)
+
+	if previous != nil && previous != gui.terminal && !gui.unfocused {
+		if err := previous.ReportFocus(false); err != nil {
+			gui.logger.Errorf("Failed to report focus: %s", err)
+		}
+		if err := gui.terminal.ReportFocus(true); err != nil {
+			gui.logger.Errorf("Failed to report focus: %s", err)
+		}
+	}
+
+	gui.setOverlay(nil)
+	gui.mouseDown = false
+	gui.hoveredLink = nil
+	gui.updateTitle()
+	gui.generateDefaultCell(gui.terminal.ReverseVideo())
+
+	// a terminal which asked for another size while it was in the background gets it now, by resizing the window, and
+	// one which is the wrong size for any other reason is put back to the size of the window
+	cols, rows := gui.gridSize()
+	if c, r := gui.terminal.GetSize(); uint(c) != cols || uint(r) != rows {
+		if gui.tabs[i].resizeRequested {
+			gui.resizeToTerminal(uint(c), uint(r))
+		} else if err := gui.terminal.SetSize(cols, rows); err != nil {
+			gui.logger.Errorf("Failed to resize terminal to %d cols, %d rows: %s", cols, rows, err)
+		}
+	}
+	gui.tabs[i].resizeRequested = false
+	gui.terminal.SetDirty()
+}
+
+// tabResized handles a change in the size of a tab's terminal. The window is resized to fit the active terminal when
+// the application asks for another size, e.g. with DECCOLM, and a request from a tab in the background waits until the
+// tab is shown. Changes the gui made itself already fit the window, so they're ignored.
+func (gui *GUI) tabResized(t *tab) {
+	if gui.tabIndex(t) < 0 {
+		return
+	}
+	cols, rows := t.terminal.GetSize()
+	if gridCols, gridRows := gui.gridSize(); uint(cols) == gridCols && uint(rows) == gridRows {
+		return
+	}
+	if t != gui.tabs[gui.activeTab] {
+		t.resizeRequested = true
+		return
+	}
+	gui.resizeToTerminal(uint(cols), uint(rows))
+}
+
+// stepTab shows the tab delta tabs after the active one, wrapping around at either end
+func (gui *GUI) stepTab(delta int) {
+	if len(gui.tabs) < 2 {
+		return
+	}
+	gui.showTab((gui.activeTab + delta + len(gui.tabs)) % len(gui.tabs))
+}
+
+// updateTitle sets the window title to the active terminal's title
+func (gui *GUI) updateTitle() {
+	title := gui.terminal.GetTitle()
+	if title == "" {
+		title = "Aminal"
+	}
+	gui.window.SetTitle(title)
+}
+
+// tabBarRows returns the number of rows the tab bar takes up at the bottom of the window. It's only shown when there's
+// more than one tab.
+func (gui *GUI) tabBarRows() uint {
+	if len(gui.tabs) > 1 {
+		return 1
+	}
+	return 0
+}
+
+// gridSize returns the size in cells of the terminals, which fill the window apart from the tab bar
+func (gui *GUI) gridSize() (uint, uint) {
+	cols, rows := gui.renderer.GetTermSize()
+	if bar := gui.tabBarRows(); rows > bar {
+		rows -= bar
+	}
+	return cols, rows
+}
+
+// renderTabBar draws the tab bar in the row below the terminal, with the title of each tab
+func (gui *GUI) renderTabBar() {
+	if gui.tabBarRows() == 0 {
+		return
+	}
+	row := uint(gui.terminal.ActiveBuffer().ViewHeight())
+	cols := int(gui.terminal.ActiveBuffer().ViewWidth())
+
+	titles := make([]string, len(gui.tabs))
+	for i, t := range gui.tabs {
+		titles[i] = t.terminal.GetTitle()
+	}
+	labels := tabLabels(titles, cols)
+	width := tabWidth(len(labels), cols)
+
+	for col := 0; col < cols; col++ {
+		bg := tabBarBg
+		if col/width == gui.activeTab {
+			bg = tabBarActiveBg
+		}
+		gui.renderer.DrawCellBg(buffer.NewBackgroundCell(bg), uint(col), row, nil, true)
+	}
+	gui.renderer.Flush()
+
+	for i, label := range labels {
+		fg := tabBarFg
+		if i == gui.activeTab {
+			fg = tabBarActiveFg
+		}
+		gui.renderer.DrawCellText(label, uint(i*width), row, 1, fg, styleRegular)
+	}
+	gui.renderer.Flush()
+}
+
+// handleTabBarClick shows the tab clicked on in the tab bar, returning false if the mouse isn't over the tab bar
+func (gui *GUI) handleTabBarClick(action glfw.Action, px float64, py float64) bool {
+	if gui.tabBarRows() == 0 || gui.mouseDown {
+		// a drag which started in the terminal ends there, wherever the button is released
+		return false
+	}
+	scale := float64(gui.scale())
+	row := (py/scale - float64(gui.renderer.areaY)) / float64(gui.renderer.CellHeight())
+	if row < float64(gui.terminal.ActiveBuffer().ViewHeight()) {
+		return false
+	}
+	if action == glfw.Press {
+		col := cellIndex(px/scale-float64(gui.renderer.GridX()), float64(gui.renderer.CellWidth()), gui.terminal.ActiveBuffer().ViewWidth())
+		gui.showTab(tabAt(int(col), len(gui.tabs), int(gui.terminal.ActiveBuffer().ViewWidth())))
+	}
+	return true
+}
+
+// tabWidth returns the number of columns each of count tabs takes up in a tab bar cols wide
+func tabWidth(count int, cols int) int {
+	if count == 0 || cols < count {
+		return 1
+	}
+	return cols / count
+}
+
+// tabAt returns the index of the tab at col in a tab bar cols wide with count tabs
+func tabAt(col int, count int, cols int) int {
+	i := col / tabWidth(count, cols)
+	if i >= count {
+		return count - 1
+	}
+	return i
+}
+
+// tabLabels returns the label for each tab in a tab bar cols wide, given their titles: the tab's number and title,
+// padded or cut short to fit its share of the bar
+func tabLabels(titles []string, cols int) []string {
+	width := tabWidth(len(titles), cols)
+	labels := make([]string, len(titles))
+	for i, title := range titles {
+		label := []rune(fmt.Sprintf(" %d", i+1))
+		if title != "" {
+			label = append(label, []rune(": "+title)...)
+		}
+		if len(label) > width {
+			label = append(label[:width-1], '…')
+		}
+		for len(label) < width {
+			label = append(label, ' ')
+		}
+		labels[i] = string(label)
+	}
+	return labels
+}
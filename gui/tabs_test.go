@@ -0,0 +1,23 @@
+package gui
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTabLabels(t *testing.T) {
+	labels := tabLabels([]string{"vim main.go", "", "a title far too long to fit"}, 45)
+	assert.Equal(t, []string{
+		" 1: vim main.go",
+		" 2             ",
+		" 3: a title fa…",
+	}, labels)
+}
+
+func TestTabAt(t *testing.T) {
+	assert.Equal(t, 0, tabAt(0, 3, 80))
+	assert.Equal(t, 0, tabAt(25, 3, 80))
+	assert.Equal(t, 1, tabAt(26, 3, 80))
+	assert.Equal(t, 2, tabAt(79, 3, 80), "the columns left over after sharing out the bar are in the last tab")
+}
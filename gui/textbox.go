@@ -6,6 +6,14 @@ import (
 	"github.com/liamg/aminal/buffer"
 )
 
+// bottomRow returns the row for a textbox near the bottom of a terminal with the given number of rows
+func bottomRow(rows int) uint16 {
+	if rows < 3 {
+		return 0
+	}
+	return uint16(rows - 3)
+}
+
 func (gui *GUI) textbox(col uint16, row uint16, text string, fg [3]float32, bg [3]float32) {
 
 	lines := []string{}
@@ -35,6 +43,8 @@ func (gui *GUI) textbox(col uint16, row uint16, text string, fg [3]float32, bg [
 			line = word
 			for len(line) > maxWidth {
 				// break word into bits
+				lines = append(lines, line[:maxWidth])
+				line = line[maxWidth:]
 			}
 		}
 
@@ -84,8 +94,9 @@ DONE:
 			gui.renderer.DrawCellBg(buffer.NewBackgroundCell(bg), uint(hx), uint(hy), nil, true)
 		}
 	}
+	gui.renderer.Flush()
 
-	x := float32(col) * gui.renderer.cellWidth
+	x := gui.renderer.cellX(uint(col))
 
 	f := gui.fontMap.DefaultFont()
 	f.SetColor(fg[0], fg[1], fg[2], 1)
@@ -0,0 +1,48 @@
+package gui
+
+// linkTooltip is an overlay which shows the target of the link (OSC 8) under the mouse pointer, as the text of a
+// link needn't be its URI
+type linkTooltip struct {
+	uri string
+	col uint16 // the cell under the pointer
+	row uint16
+}
+
+func newLinkTooltip(uri string, col uint16, row uint16) *linkTooltip {
+	return &linkTooltip{
+		uri: uri,
+		col: col,
+		row: row,
+	}
+}
+
+func (t *linkTooltip) render(gui *GUI) {
+	width := int(gui.terminal.ActiveBuffer().ViewWidth())
+	height := int(gui.terminal.ActiveBuffer().ViewHeight())
+
+	// keep the URI on one line, as the textbox pads it with a space on the left and a row above and below
+	text := []rune(t.uri)
+	maxLength := width - 6
+	if maxLength < 4 || height < 4 {
+		return
+	}
+	if len(text) > maxLength {
+		text = append(text[:maxLength-3], []rune("...")...)
+	}
+
+	col := int(t.col)
+	if col+len(text)+2 > width {
+		col = width - len(text) - 2
+	}
+
+	// below the pointer, or above it if there isn't room
+	row := int(t.row) + 2
+	if row+1 >= height {
+		row = int(t.row) - 2
+	}
+	if row < 1 {
+		row = 1
+	}
+
+	gui.textbox(uint16(col), uint16(row), string(text), [3]float32{1, 1, 1}, [3]float32{0.15, 0.15, 0.15})
+}
@@ -0,0 +1,37 @@
+package gui
+
+import (
+	"github.com/liamg/aminal/buffer"
+	"github.com/liamg/aminal/config"
+)
+
+// trailingWhitespaceColour highlights the whitespace at the end of lines when show_whitespace is enabled
+var trailingWhitespaceColour = config.Colour{0.5, 0.15, 0.15}
+
+// whitespaceMarker returns the glyph drawn in place of a space or tab when show_whitespace is enabled
+func whitespaceMarker(cell *buffer.Cell) (rune, bool) {
+	switch {
+	case cell.IsTabStart():
+		return '→', true
+	case cell.IsTab():
+		return ' ', true
+	case cell.Rune() == ' ':
+		return '·', true
+	}
+	return 0, false
+}
+
+// trailingWhitespace returns the columns where the spaces and tabs at the end of a line start and end, which are the
+// same if the line doesn't end with whitespace. Cells which have never been written to aren't whitespace, and are
+// skipped before looking for it.
+func trailingWhitespace(cells []buffer.Cell) (int, int) {
+	end := len(cells)
+	for end > 0 && cells[end-1].Rune() == 0 {
+		end--
+	}
+	start := end
+	for start > 0 && cells[start-1].Rune() == ' ' {
+		start--
+	}
+	return start, end
+}
@@ -0,0 +1,25 @@
+package gui
+
+import (
+	"testing"
+
+	"github.com/liamg/aminal/buffer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrailingWhitespace(t *testing.T) {
+	b := buffer.NewBuffer(buffer.NewTerminalState(10, 3, buffer.CellAttributes{}, 1000))
+	b.Write([]rune("a b  ")...)
+	start, end := trailingWhitespace(b.GetVisibleLines()[0].Cells())
+	assert.Equal(t, 3, start)
+	assert.Equal(t, 5, end)
+
+	b.EraseLineFromCursor()
+	start, end = trailingWhitespace(b.GetVisibleLines()[0].Cells())
+	assert.Equal(t, 3, start, "cells which were erased aren't highlighted")
+	assert.Equal(t, 5, end, "cells which were erased aren't highlighted")
+
+	b.Write('c')
+	start, end = trailingWhitespace(b.GetVisibleLines()[0].Cells())
+	assert.Equal(t, start, end, "a line which doesn't end with whitespace has none highlighted")
+}
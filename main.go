@@ -2,10 +2,13 @@ package main
 
 import (
 	"fmt"
+	"github.com/liamg/aminal/config"
+	"github.com/liamg/aminal/control"
 	"github.com/liamg/aminal/gui"
 	"github.com/liamg/aminal/platform"
 	"github.com/liamg/aminal/terminal"
 	"github.com/riywo/loginshell"
+	"go.uber.org/zap"
 	"os"
 	"runtime"
 )
@@ -29,11 +32,19 @@ func initialize(unitTestfunc callback) {
 	}
 	defer logger.Sync()
 
-	logger.Infof("Allocating pty...")
-
-	pty, err := platform.NewPty(80, 25)
-	if err != nil {
-		logger.Fatalf("Failed to allocate pty: %s", err)
+	var pty platform.Pty
+	var replay *replayPty
+	if conf.Replay != "" {
+		logger.Infof("Opening cast to replay...")
+		if replay, err = newReplayPty(conf.Replay); err != nil {
+			logger.Fatalf("Failed to replay %s: %s", conf.Replay, err)
+		}
+		pty = replay
+	} else {
+		logger.Infof("Allocating pty...")
+		if pty, err = platform.NewPty(80, 25); err != nil {
+			logger.Fatalf("Failed to allocate pty: %s", err)
+		}
 	}
 
 	shellStr := conf.Shell
@@ -53,28 +64,62 @@ func initialize(unitTestfunc callback) {
 		pty.Close()
 		logger.Fatalf("Failed to start your shell: %s", err)
 	}
-	defer guestProcess.Close()
+
+	// tabs opened later run the same shell, unless another is chosen from the shell menu
+	newTab := func(shell string) (*terminal.Terminal, platform.Process, error) {
+		if shell == "" {
+			shell = shellStr
+		}
+		return startShell(shell, conf, logger)
+	}
 
 	logger.Infof("Creating terminal...")
 	terminal := terminal.New(pty, logger, conf)
+	if replay != nil {
+		replay.resize = func(width int, height int) {
+			if err := terminal.SetSize(uint(width), uint(height)); err != nil {
+				logger.Errorf("Failed to resize terminal for replay: %s", err)
+			}
+		}
+	}
 
-	g, err := gui.New(conf, terminal, logger)
+	g, err := gui.New(conf, terminal, guestProcess, newTab, logger)
 	if err != nil {
 		logger.Fatalf("Cannot start: %s", err)
 	}
 
+	if conf.ControlSocket != "" {
+		// commands go to whichever tab is active
+		server, err := control.Listen(conf.ControlSocket, g.ActiveTerminal, logger)
+		if err != nil {
+			logger.Fatalf("Cannot start control socket: %s", err)
+		}
+		defer server.Close()
+		go server.Serve()
+	}
+
 	if unitTestfunc != nil {
 		go unitTestfunc(terminal, g)
-	} else {
-		go func() {
-			if err := guestProcess.Wait(); err != nil {
-				logger.Fatalf("Failed to wait for guest process: %s", err)
-			}
-			g.Close()
-		}()
 	}
 
+	// the window closes when the shell in its last tab exits, and the shells still running are hung up when it closes
 	if err := g.Render(); err != nil {
 		logger.Fatalf("Render error: %s", err)
 	}
 }
+
+// startShell allocates a pty and starts the shell in it, for a new tab
+func startShell(shell string, conf *config.Config, logger *zap.SugaredLogger) (*terminal.Terminal, platform.Process, error) {
+	logger.Infof("Allocating pty...")
+	pty, err := platform.NewPty(80, 25)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to allocate pty: %s", err)
+	}
+	process, err := pty.CreateGuestProcess(shell)
+	if err != nil {
+		pty.Close()
+		return nil, nil, fmt.Errorf("Failed to start your shell: %s", err)
+	}
+	logger.Infof("Creating terminal...")
+	return terminal.New(pty, logger, conf), process, nil
+}
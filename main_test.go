@@ -74,7 +74,10 @@ func validateScreen(img string) {
 func TestMain(m *testing.M) {
 	flag.Parse()
 
-	go m.Run()
+	// the main goroutine is looped to run the GUI tests, so the tests exit once they have all run
+	go func() {
+		os.Exit(m.Run())
+	}()
 
 	for f := range tests {
 		f()
@@ -190,8 +193,3 @@ func TestSixel(t *testing.T) {
 		initialize(testFunc)
 	})
 }
-
-// Last Test should terminate main goroutine since it's infinity looped to execute others GUI tests in main goroutine
-func TestExit(t *testing.T) {
-	os.Exit(0)
-}
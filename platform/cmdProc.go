@@ -1,21 +1,67 @@
+// +build !windows
+
 package platform
 
 import (
 	"os/exec"
+	"sync"
+	"syscall"
+	"time"
 )
 
 type cmdProc struct {
-	cmd *exec.Cmd
+	cmd      *exec.Cmd
+	waitOnce sync.Once
+	exited   chan struct{}
+	waitErr  error
 }
 
 func newCmdProc(c *exec.Cmd) *cmdProc {
 	return &cmdProc{
-		cmd: c,
+		cmd:    c,
+		exited: make(chan struct{}),
 	}
 }
 
+// startWaiting reaps the process in the background, exec.Cmd.Wait can only be called once
+func (p *cmdProc) startWaiting() {
+	p.waitOnce.Do(func() {
+		cmd := p.cmd
+		go func() {
+			p.waitErr = cmd.Wait()
+			close(p.exited)
+		}()
+	})
+}
+
 func (p *cmdProc) Wait() error {
-	return p.cmd.Wait()
+	p.startWaiting()
+	<-p.exited
+	return p.waitErr
+}
+
+// Hangup sends SIGHUP to the process group of the shell, and kills the group if the shell hasn't exited within timeout
+func (p *cmdProc) Hangup(timeout time.Duration) error {
+	if p == nil || p.cmd == nil || p.cmd.Process == nil {
+		return nil
+	}
+	p.startWaiting()
+
+	// the shell is started with its own session, so its process group id is its pid
+	group := -p.cmd.Process.Pid
+	if err := syscall.Kill(group, syscall.SIGHUP); err != nil && err != syscall.ESRCH {
+		return err
+	}
+
+	select {
+	case <-p.exited:
+		return nil
+	case <-time.After(timeout):
+		if err := syscall.Kill(group, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+			return err
+		}
+		return nil
+	}
 }
 
 func (p *cmdProc) Close() error {
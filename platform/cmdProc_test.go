@@ -0,0 +1,44 @@
+// +build !windows
+
+package platform
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startTestProc(t *testing.T, script string) *cmdProc {
+	proc := newCmdProc(exec.Command("/bin/sh", "-c", script))
+	proc.cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	require.Nil(t, proc.cmd.Start())
+	return proc
+}
+
+func TestHangupEndsProcess(t *testing.T) {
+	proc := startTestProc(t, "sleep 30")
+
+	start := time.Now()
+	require.Nil(t, proc.Hangup(5*time.Second))
+	assert.True(t, time.Since(start) < 5*time.Second)
+
+	// the exit status is still available to anyone waiting on the process
+	assert.NotNil(t, proc.Wait())
+}
+
+func TestHangupKillsProcessIgnoringSIGHUP(t *testing.T) {
+	proc := startTestProc(t, "trap '' HUP; sleep 30")
+	time.Sleep(100 * time.Millisecond) // give the shell time to install the trap
+
+	require.Nil(t, proc.Hangup(100*time.Millisecond))
+
+	select {
+	case <-proc.exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Process was not killed")
+	}
+}
@@ -0,0 +1,12 @@
+// +build darwin
+
+package platform
+
+import (
+	"os/exec"
+)
+
+// Beep plays the system alert sound
+func Beep() error {
+	return exec.Command("osascript", "-e", "beep").Run()
+}
@@ -0,0 +1,95 @@
+// +build darwin
+
+package platform
+
+/*
+#cgo LDFLAGS: -framework CoreText -framework CoreFoundation
+#include <limits.h>
+#include <stdlib.h>
+#include <CoreText/CoreText.h>
+
+// matchTraits returns the symbolic traits of a font, such as whether it's bold or italic
+static CTFontSymbolicTraits matchTraits(CTFontDescriptorRef descriptor) {
+	CTFontSymbolicTraits symbolic = 0;
+	CFDictionaryRef traits = CTFontDescriptorCopyAttribute(descriptor, kCTFontTraitsAttribute);
+	if (traits != NULL) {
+		CFNumberRef number = CFDictionaryGetValue(traits, kCTFontSymbolicTrait);
+		if (number != NULL) {
+			CFNumberGetValue(number, kCFNumberSInt32Type, &symbolic);
+		}
+		CFRelease(traits);
+	}
+	return symbolic;
+}
+
+// findFont writes the path of the font file for a font family to path, returning 0 if the family isn't installed or
+// has no face with the given symbolic traits
+static int findFont(const char *family, CTFontSymbolicTraits symbolic, char *path, int size) {
+	CFStringRef name = CFStringCreateWithCString(NULL, family, kCFStringEncodingUTF8);
+	if (name == NULL) {
+		return 0;
+	}
+
+	CFMutableDictionaryRef attributes = CFDictionaryCreateMutable(NULL, 0, &kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+	CFDictionarySetValue(attributes, kCTFontFamilyNameAttribute, name);
+	if (symbolic != 0) {
+		CFNumberRef number = CFNumberCreate(NULL, kCFNumberSInt32Type, &symbolic);
+		CFMutableDictionaryRef traits = CFDictionaryCreateMutable(NULL, 0, &kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+		CFDictionarySetValue(traits, kCTFontSymbolicTrait, number);
+		CFDictionarySetValue(attributes, kCTFontTraitsAttribute, traits);
+		CFRelease(traits);
+		CFRelease(number);
+	}
+	CTFontDescriptorRef descriptor = CTFontDescriptorCreateWithAttributes(attributes);
+
+	// only the family must match, so that another font isn't substituted, and the closest face in it is used
+	const void *mandatory[] = {kCTFontFamilyNameAttribute};
+	CFSetRef mandatorySet = CFSetCreate(NULL, mandatory, 1, &kCFTypeSetCallBacks);
+	CTFontDescriptorRef match = CTFontDescriptorCreateMatchingFontDescriptor(descriptor, mandatorySet);
+
+	int found = 0;
+	if (match != NULL && (matchTraits(match) & symbolic) == symbolic) {
+		CFURLRef url = CTFontDescriptorCopyAttribute(match, kCTFontURLAttribute);
+		if (url != NULL) {
+			found = CFURLGetFileSystemRepresentation(url, true, (UInt8 *)path, size);
+			CFRelease(url);
+		}
+		CFRelease(match);
+	}
+
+	CFRelease(mandatorySet);
+	CFRelease(descriptor);
+	CFRelease(attributes);
+	CFRelease(name);
+	return found;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// FindFont returns the path of the font file for a font family installed on the system, looked up with Core Text.
+// Families without a face in the given style aren't found, rather than another face being used for it.
+func FindFont(family string, bold bool, italic bool) (string, error) {
+	cFamily := C.CString(family)
+	defer C.free(unsafe.Pointer(cFamily))
+
+	var traits C.CTFontSymbolicTraits
+	if bold {
+		traits |= C.kCTFontBoldTrait
+	}
+	if italic {
+		traits |= C.kCTFontItalicTrait
+	}
+
+	path := (*C.char)(C.malloc(C.PATH_MAX))
+	defer C.free(unsafe.Pointer(path))
+
+	if C.findFont(cFamily, traits, path, C.PATH_MAX) == 0 {
+		return "", fmt.Errorf("Font '%s' not found", family)
+	}
+	return C.GoString(path), nil
+}
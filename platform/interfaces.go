@@ -2,6 +2,7 @@ package platform
 
 import (
 	"io"
+	"time"
 )
 
 // Process represents a child process by pid or HPROCESS in a platform-independent way
@@ -9,6 +10,8 @@ type Process interface {
 	io.Closer
 
 	Wait() error
+	// Hangup asks the process to exit as its terminal is closing, and kills it if it's still running after timeout
+	Hangup(timeout time.Duration) error
 	// TODO: make useful stuff here
 }
 
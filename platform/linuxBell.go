@@ -0,0 +1,24 @@
+// +build linux freebsd netbsd openbsd
+
+package platform
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// bellCommands play the desktop's bell sound, in the order they're tried
+var bellCommands = [][]string{
+	{"canberra-gtk-play", "--id=bell"},
+	{"paplay", "/usr/share/sounds/freedesktop/stereo/bell.oga"},
+}
+
+// Beep plays the desktop's alert sound
+func Beep() error {
+	for _, command := range bellCommands {
+		if err := exec.Command(command[0], command[1:]...).Run(); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("Failed to play the bell sound: neither canberra-gtk-play nor paplay worked")
+}
@@ -0,0 +1,84 @@
+// +build linux freebsd netbsd openbsd
+
+package platform
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// the weights and slants fontconfig gives fonts, from fontconfig.h
+const (
+	fcWeightSemibold = 180
+	fcSlantItalic    = 100
+)
+
+// FindFont returns the path of the font file for a font family installed on the system, looked up with fontconfig.
+// Families without a face in the given style aren't found, rather than another face being used for it.
+func FindFont(family string, bold bool, italic bool) (string, error) {
+	pattern := fontconfigEscape(family)
+	if bold {
+		pattern += ":bold"
+	}
+	if italic {
+		pattern += ":italic"
+	}
+	output, err := exec.Command("fc-match", "--format=%{family}\n%{weight}\n%{slant}\n%{file}", pattern).Output()
+	if err != nil {
+		return "", fmt.Errorf("Failed to look up font '%s' with fc-match: %s", family, err)
+	}
+	return parseFontMatch(string(output), family, bold, italic)
+}
+
+// fontconfigEscape escapes the characters which have a meaning in a fontconfig pattern
+func fontconfigEscape(family string) string {
+	var builder strings.Builder
+	for _, r := range family {
+		if strings.ContainsRune(`\-:,`, r) {
+			builder.WriteRune('\\')
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// parseFontMatch parses the family names, weight, slant and file output by fc-match. fc-match substitutes another font
+// for a family which isn't installed, and the closest face for a style the family doesn't have, so the family and
+// style matched are checked against the ones asked for.
+func parseFontMatch(output string, family string, bold bool, italic bool) (string, error) {
+	lines := strings.SplitN(output, "\n", 4)
+	if len(lines) < 4 || lines[3] == "" {
+		return "", fmt.Errorf("Font '%s' not found", family)
+	}
+
+	found := false
+	for _, name := range strings.Split(lines[0], ",") {
+		if strings.EqualFold(strings.TrimSpace(name), family) {
+			found = true
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("Font '%s' not found, the closest match is '%s'", family, lines[0])
+	}
+
+	if bold && fontconfigValue(lines[1]) < fcWeightSemibold {
+		return "", fmt.Errorf("Font '%s' has no bold face", family)
+	}
+	if italic && fontconfigValue(lines[2]) < fcSlantItalic {
+		return "", fmt.Errorf("Font '%s' has no italic face", family)
+	}
+	return lines[3], nil
+}
+
+// fontconfigValue parses a number output by fc-match. Variable fonts have a range such as "[40 210]" instead, in
+// which case the top of the range is returned.
+func fontconfigValue(value string) float64 {
+	fields := strings.Fields(strings.Trim(value, "[]"))
+	if len(fields) == 0 {
+		return 0
+	}
+	number, _ := strconv.ParseFloat(fields[len(fields)-1], 64)
+	return number
+}
@@ -0,0 +1,44 @@
+// +build linux freebsd netbsd openbsd
+
+package platform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFontMatch(t *testing.T) {
+	path, err := parseFontMatch("DejaVu Sans Mono,DejaVu Sans Mono Book\n80\n0\n/usr/share/fonts/DejaVuSansMono.ttf", "dejavu sans mono", false, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "/usr/share/fonts/DejaVuSansMono.ttf", path)
+
+	// fc-match falls back to another font for a family which isn't installed
+	_, err = parseFontMatch("DejaVu Sans\n80\n0\n/usr/share/fonts/DejaVuSans.ttf", "JetBrains Mono", false, false)
+	assert.NotNil(t, err)
+
+	_, err = parseFontMatch("", "JetBrains Mono", false, false)
+	assert.NotNil(t, err)
+}
+
+func TestParseFontMatchStyle(t *testing.T) {
+	path, err := parseFontMatch("DejaVu Sans Mono\n200\n110\n/usr/share/fonts/DejaVuSansMono-BoldOblique.ttf", "DejaVu Sans Mono", true, true)
+	assert.Nil(t, err)
+	assert.Equal(t, "/usr/share/fonts/DejaVuSansMono-BoldOblique.ttf", path)
+
+	// fc-match falls back to the regular face for a style the family doesn't have
+	_, err = parseFontMatch("Hack\n80\n0\n/usr/share/fonts/Hack-Regular.ttf", "Hack", true, false)
+	assert.NotNil(t, err)
+	_, err = parseFontMatch("Hack\n200\n0\n/usr/share/fonts/Hack-Bold.ttf", "Hack", true, true)
+	assert.NotNil(t, err)
+
+	// variable fonts cover a range of weights
+	path, err = parseFontMatch("Inter\n[40 210]\n0\n/usr/share/fonts/Inter.ttf", "Inter", true, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "/usr/share/fonts/Inter.ttf", path)
+}
+
+func TestFontconfigEscape(t *testing.T) {
+	assert.Equal(t, "JetBrains Mono", fontconfigEscape("JetBrains Mono"))
+	assert.Equal(t, `Foo\-Bar\:Baz\,\\`, fontconfigEscape(`Foo-Bar:Baz,\`))
+}
@@ -95,7 +95,7 @@ func NewPty(x, y int) (Pty, error) {
 		pty: innerPty,
 		tty: innerTty,
 		platformDependentSettings: PlatformDependentSettings{
-			OSCTerminators: map[rune]struct{}{0x07: {}, 0x5c: {}},
+			OSCTerminators: map[rune]struct{}{0x07: {}},
 		},
 	}, nil
 }
@@ -0,0 +1,63 @@
+// +build !windows
+
+package platform
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// commonShellPaths are checked as well as /etc/shells, which often doesn't list shells installed by a package manager
+var commonShellPaths = []string{
+	"/bin/bash",
+	"/bin/zsh",
+	"/bin/sh",
+	"/usr/bin/fish",
+	"/usr/local/bin/bash",
+	"/usr/local/bin/zsh",
+	"/usr/local/bin/fish",
+	"/opt/homebrew/bin/bash",
+	"/opt/homebrew/bin/zsh",
+	"/opt/homebrew/bin/fish",
+}
+
+// DetectShells returns the login shells available on this machine
+func DetectShells() []string {
+	return detectShells("/etc/shells", commonShellPaths)
+}
+
+// detectShells returns the executable shells listed in shellsFile followed by any of the candidates which weren't
+// listed. Paths which lead to the same file, such as /bin/bash and /usr/bin/bash on systems where /bin is a link to
+// /usr/bin, are only returned once.
+func detectShells(shellsFile string, candidates []string) []string {
+	paths := []string{}
+	if f, err := os.Open(shellsFile); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				paths = append(paths, line)
+			}
+		}
+		f.Close()
+	}
+	paths = append(paths, candidates...)
+
+	shells := []string{}
+	seen := map[string]bool{}
+	for _, path := range paths {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err != nil || seen[resolved] {
+			continue
+		}
+		info, err := os.Stat(resolved)
+		if err != nil || !info.Mode().IsRegular() || info.Mode()&0111 == 0 {
+			continue
+		}
+		seen[resolved] = true
+		shells = append(shells, path)
+	}
+	return shells
+}
@@ -0,0 +1,35 @@
+// +build !windows
+
+package platform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectShells(t *testing.T) {
+	dir, err := ioutil.TempDir("", "aminal-shells")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	bash := filepath.Join(dir, "bash")
+	zsh := filepath.Join(dir, "zsh")
+	notExecutable := filepath.Join(dir, "notes")
+	link := filepath.Join(dir, "sh")
+	require.Nil(t, ioutil.WriteFile(bash, nil, 0755))
+	require.Nil(t, ioutil.WriteFile(zsh, nil, 0755))
+	require.Nil(t, ioutil.WriteFile(notExecutable, nil, 0644))
+	require.Nil(t, os.Symlink(bash, link))
+
+	shellsFile := filepath.Join(dir, "shells")
+	contents := "# valid login shells\n" + zsh + "\n\n" + notExecutable + "\n" + filepath.Join(dir, "missing") + "\n"
+	require.Nil(t, ioutil.WriteFile(shellsFile, []byte(contents), 0644))
+
+	assert.Equal(t, []string{zsh, bash}, detectShells(shellsFile, []string{bash, link, zsh}))
+	assert.Equal(t, []string{bash}, detectShells(filepath.Join(dir, "nope"), []string{bash}))
+}
@@ -0,0 +1,17 @@
+// +build windows
+
+package platform
+
+import (
+	"fmt"
+
+	"github.com/lxn/win"
+)
+
+// Beep plays the Windows default beep sound
+func Beep() error {
+	if !win.MessageBeep(win.MB_OK) {
+		return fmt.Errorf("Failed to play the bell sound")
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+// +build windows
+
+package platform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/lxn/win"
+)
+
+// fontsKey lists the installed fonts, with the name of each font mapped to its file
+const fontsKey = `SOFTWARE\Microsoft\Windows NT\CurrentVersion\Fonts`
+
+type registryFont struct {
+	name string
+	file string
+}
+
+// FindFont returns the path of the font file for a font family installed on the system, looked up in the registry
+func FindFont(family string, bold bool, italic bool) (string, error) {
+	// fonts installed for the current user are listed with their full path, and fonts installed for all users with a
+	// path relative to the fonts directory
+	for _, root := range []win.HKEY{win.HKEY_CURRENT_USER, win.HKEY_LOCAL_MACHINE} {
+		for _, font := range registryFonts(root) {
+			if !fontNameMatches(font.name, family, bold, italic) {
+				continue
+			}
+			if filepath.IsAbs(font.file) {
+				return font.file, nil
+			}
+			return filepath.Join(os.Getenv("WINDIR"), "Fonts", font.file), nil
+		}
+	}
+	return "", fmt.Errorf("Font '%s' not found", family)
+}
+
+func registryFonts(root win.HKEY) []registryFont {
+	var key win.HKEY
+	if win.RegOpenKeyEx(root, syscall.StringToUTF16Ptr(fontsKey), 0, win.KEY_READ, &key) != win.ERROR_SUCCESS {
+		return nil
+	}
+	defer win.RegCloseKey(key)
+
+	fonts := []registryFont{}
+	name := make([]uint16, 16384) // the maximum length of a value name
+	data := make([]uint16, syscall.MAX_PATH)
+	for i := uint32(0); ; i++ {
+		nameLen := uint32(len(name))
+		dataLen := uint32(len(data) * 2)
+		var valueType uint32
+		ret := win.RegEnumValue(key, i, &name[0], &nameLen, nil, &valueType, (*byte)(unsafe.Pointer(&data[0])), &dataLen)
+		if ret == win.ERROR_NO_MORE_ITEMS {
+			break
+		}
+		if ret != win.ERROR_SUCCESS || valueType != win.REG_SZ {
+			continue
+		}
+		fonts = append(fonts, registryFont{
+			name: syscall.UTF16ToString(name[:nameLen]),
+			file: syscall.UTF16ToString(data[:dataLen/2]),
+		})
+	}
+	return fonts
+}
+
+// fontNameMatches returns true if a font name from the registry, such as "Consolas Bold (TrueType)" or
+// "Cambria & Cambria Math (TrueType)", is a font in the family with the given style
+func fontNameMatches(name string, family string, bold bool, italic bool) bool {
+	if i := strings.LastIndex(name, " ("); i >= 0 {
+		name = name[:i]
+	}
+	names := []string{family, family + " Regular"}
+	switch {
+	case bold && italic:
+		names = []string{family + " Bold Italic"}
+	case bold:
+		names = []string{family + " Bold"}
+	case italic:
+		names = []string{family + " Italic"}
+	}
+	for _, face := range strings.Split(name, " & ") {
+		face = strings.TrimSpace(face)
+		for _, n := range names {
+			if strings.EqualFold(face, n) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -167,6 +167,7 @@ import (
 	"errors"
 	"os"
 	"syscall"
+	"time"
 	"unicode/utf16"
 )
 
@@ -226,6 +227,11 @@ func (process *winProcess) Wait() error {
 	return nil
 }
 
+// Hangup kills the process, there's no equivalent of SIGHUP for it to clean up on
+func (process *winProcess) Hangup(timeout time.Duration) error {
+	return process.Close()
+}
+
 func (process *winProcess) Close() error {
 	err := process.goProcess.Kill()
 	if err != nil {
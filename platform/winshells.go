@@ -0,0 +1,20 @@
+// +build windows
+
+package platform
+
+import (
+	"os/exec"
+)
+
+var commonShells = []string{"powershell.exe", "pwsh.exe", "cmd.exe", "bash.exe"}
+
+// DetectShells returns the shells available on this machine
+func DetectShells() []string {
+	shells := []string{}
+	for _, shell := range commonShells {
+		if path, err := exec.LookPath(shell); err == nil {
+			shells = append(shells, path)
+		}
+	}
+	return shells
+}
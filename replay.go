@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/liamg/aminal/cast"
+	"github.com/liamg/aminal/platform"
+)
+
+// replayPty stands in for a pty when playing back a cast, so that its output goes through the terminal just as a
+// shell's would. Input is discarded, and the window stays open at the end of the cast until it's closed.
+type replayPty struct {
+	file    *os.File
+	player  *cast.Player
+	started bool
+	resize  func(width int, height int) // called before the first output, to size the terminal as it was recorded
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func newReplayPty(path string) (*replayPty, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open cast: %s", err)
+	}
+	player, err := cast.NewPlayer(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &replayPty{file: file, player: player, closed: make(chan struct{})}, nil
+}
+
+func (pty *replayPty) Read(data []byte) (int, error) {
+	if !pty.started {
+		pty.started = true
+		if pty.resize != nil && pty.player.Header.Width > 0 && pty.player.Header.Height > 0 {
+			pty.resize(pty.player.Header.Width, pty.player.Header.Height)
+		}
+	}
+
+	n, err := pty.player.Read(data)
+	if err == io.EOF {
+		<-pty.closed
+	}
+	return n, err
+}
+
+func (pty *replayPty) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (pty *replayPty) Close() error {
+	pty.once.Do(func() {
+		close(pty.closed)
+	})
+	return pty.file.Close()
+}
+
+func (pty *replayPty) Resize(x int, y int) error {
+	return nil
+}
+
+// CreateGuestProcess doesn't start anything, as the cast takes the place of the shell's output
+func (pty *replayPty) CreateGuestProcess(imagePath string) (platform.Process, error) {
+	return &replayProcess{pty: pty}, nil
+}
+
+func (pty *replayPty) GetPlatformDependentSettings() platform.PlatformDependentSettings {
+	return platform.PlatformDependentSettings{}
+}
+
+// replayProcess is the guest process of a replayPty, which runs until the window is closed
+type replayProcess struct {
+	pty *replayPty
+}
+
+func (process *replayProcess) Wait() error {
+	<-process.pty.closed
+	return nil
+}
+
+func (process *replayProcess) Hangup(timeout time.Duration) error {
+	return process.pty.Close()
+}
+
+func (process *replayProcess) Close() error {
+	return process.pty.Close()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// shellIntegrationScripts report the working directory (OSC 7) and mark prompts, commands and their output
+// (OSC 133) from the shell's prompt hooks. Each script only installs its hooks once, so it can be sourced repeatedly.
+var shellIntegrationScripts = map[string]string{
+	"bash": `# aminal shell integration for bash (4.4 or later), add this to ~/.bashrc:
+#   eval "$(aminal --generate-shell-integration bash)"
+if [ -z "$__aminal_integration" ]; then
+    __aminal_integration=1
+
+    # the working directory is sent as a file:// URL, so every byte of the path but unreserved ones is percent-encoded
+    __aminal_report_directory() {
+        local LC_ALL=C path=$PWD encoded= c i
+        for (( i = 0; i < ${#path}; i++ )); do
+            c=${path:i:1}
+            case "$c" in
+                [a-zA-Z0-9/._~-]) encoded+=$c ;;
+                *) printf -v c '%%%02X' "'$c"; encoded+=$c ;;
+            esac
+        done
+        printf '\033]7;file://%s%s\007' "$HOSTNAME" "$encoded"
+    }
+
+    __aminal_prompt_command() {
+        local status=$?
+        if [ -n "$__aminal_prompted" ]; then
+            printf '\033]133;D;%s\007' "$status"
+        fi
+        __aminal_prompted=1
+        __aminal_report_directory
+        printf '\033]133;A\007'
+        case "$PS1" in
+            *'133;B'*) ;;
+            *) PS1="$PS1"'\[\033]133;B\007\]' ;;
+        esac
+        return $status
+    }
+
+    PS0="$PS0"'\033]133;C\007'
+    PROMPT_COMMAND="__aminal_prompt_command${PROMPT_COMMAND:+; $PROMPT_COMMAND}"
+fi
+`,
+	"zsh": `# aminal shell integration for zsh, add this to ~/.zshrc:
+#   eval "$(aminal --generate-shell-integration zsh)"
+if [[ -z $__aminal_integration ]]; then
+    __aminal_integration=1
+
+    # the working directory is sent as a file:// URL, so every byte of the path but unreserved ones is percent-encoded
+    __aminal_report_directory() {
+        setopt localoptions nomultibyte
+        local encoded= c
+        for c in ${(s::)PWD}; do
+            case $c in
+                [a-zA-Z0-9/._~-]) encoded+=$c ;;
+                *) encoded+=%${(l:2::0:)$(( [##16] #c ))} ;;
+            esac
+        done
+        printf '\e]7;file://%s%s\a' "$HOST" "$encoded"
+    }
+
+    __aminal_precmd() {
+        local ret=$?
+        if [[ -n $__aminal_running ]]; then
+            printf '\e]133;D;%s\a' $ret
+        fi
+        __aminal_running=
+        __aminal_report_directory
+        printf '\e]133;A\a'
+        if [[ $PS1 != *'133;B'* ]]; then
+            PS1="$PS1"$'%{\e]133;B\a%}'
+        fi
+    }
+
+    __aminal_preexec() {
+        __aminal_running=1
+        printf '\e]133;C\a'
+    }
+
+    # run first, so the exit status of the command hasn't been changed by other hooks
+    precmd_functions=(__aminal_precmd $precmd_functions)
+    preexec_functions+=(__aminal_preexec)
+fi
+`,
+	"fish": `# aminal shell integration for fish, add this to ~/.config/fish/config.fish:
+#   aminal --generate-shell-integration fish | source
+if not set -q __aminal_integration
+    set -g __aminal_integration 1
+
+    function __aminal_prompt --on-event fish_prompt
+        # the working directory is sent as a file:// URL, so the path is percent-encoded
+        printf '\e]7;file://%s%s\a' (hostname) (string escape --style=url -- $PWD)
+        printf '\e]133;A\a'
+    end
+
+    function __aminal_preexec --on-event fish_preexec
+        printf '\e]133;C\a'
+    end
+
+    function __aminal_postexec --on-event fish_postexec
+        printf '\e]133;D;%s\a' $status
+    end
+
+    functions -c fish_prompt __aminal_fish_prompt
+    function fish_prompt
+        __aminal_fish_prompt
+        printf '\e]133;B\a'
+    end
+end
+`,
+}
+
+// shellIntegrationScript returns the integration script for a shell, to be sourced from the shell's startup file
+func shellIntegrationScript(shell string) (string, error) {
+	script, ok := shellIntegrationScripts[shell]
+	if !ok {
+		shells := []string{}
+		for name := range shellIntegrationScripts {
+			shells = append(shells, name)
+		}
+		sort.Strings(shells)
+		return "", fmt.Errorf("No shell integration is available for '%s'. Should be one of %s", shell, strings.Join(shells, ", "))
+	}
+	return script, nil
+}
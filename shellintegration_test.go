@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShellIntegrationScript(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		script, err := shellIntegrationScript(shell)
+		require.NoError(t, err, shell)
+		assert.Contains(t, script, "7;file://", shell)
+		assert.Contains(t, script, "133;A", shell)
+	}
+
+	_, err := shellIntegrationScript("csh")
+	assert.EqualError(t, err, "No shell integration is available for 'csh'. Should be one of bash, fish, zsh")
+}
+
+func TestShellIntegrationScriptEncodesWorkingDirectory(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash isn't installed")
+	}
+	script, err := shellIntegrationScript("bash")
+	require.NoError(t, err)
+
+	parent, err := ioutil.TempDir("", "aminal-shellintegration")
+	require.NoError(t, err)
+	defer os.RemoveAll(parent)
+	dir := filepath.Join(parent, "a b#c?d%é")
+	require.NoError(t, os.Mkdir(dir, 0700))
+
+	cmd := exec.Command(bash, "-c", script+"\ncd \"$1\" && __aminal_report_directory", "bash", dir)
+	out, err := cmd.Output()
+	require.NoError(t, err)
+
+	location := strings.TrimSuffix(strings.TrimPrefix(string(out), "\033]7;"), "\007")
+	u, err := url.Parse(location)
+	require.NoError(t, err, location)
+	assert.Equal(t, "file", u.Scheme)
+	assert.Equal(t, dir, u.Path)
+	assert.Empty(t, u.RawQuery)
+	assert.Empty(t, u.Fragment)
+}
@@ -4,155 +4,148 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"strconv"
-	"strings"
+	"image/draw"
+	"math"
 )
 
 type Sixel struct {
-	px     map[uint]map[uint]colour
+	// pixels holds the pixels set so far, and is grown in steps as the image is, so it may be larger than the image
+	pixels *image.RGBA
 	width  uint
 	height uint
 }
 
 type colour [3]uint8
 
-func decompress(data string) string {
-
-	output := ""
-
-	inMarker := false
-	countStr := ""
+const (
+	// maxImageSize is the most pixels wide or high an image may be, and maxImageArea the most pixels it may have in
+	// all: a few screens' worth, which take 64MiB at 4 bytes a pixel. Images beyond them are rejected rather than
+	// running out of memory.
+	maxImageSize = 8192
+	maxImageArea = 4096 * 4096
+	// maxAspectRatio is the tallest a pixel may be, in pixels
+	maxAspectRatio = 16
+	// maxParam is the largest a parameter is read as, so that long runs of digits can't overflow
+	maxParam = 1 << 20
+)
 
-	for _, r := range data {
+// defaultColours are the colour registers of a VT340 until they're redefined, as red, green and blue percentages
+var defaultColours = [16][3]int{
+	{0, 0, 0},
+	{20, 20, 80},
+	{80, 13, 13},
+	{20, 80, 20},
+	{80, 20, 80},
+	{20, 80, 80},
+	{80, 80, 20},
+	{53, 53, 53},
+	{26, 26, 26},
+	{33, 33, 60},
+	{60, 26, 26},
+	{33, 60, 33},
+	{60, 33, 60},
+	{33, 60, 60},
+	{60, 60, 33},
+	{80, 80, 80},
+}
 
-		if !inMarker {
-			if r == '!' {
-				inMarker = true
-				countStr = ""
-			} else {
-				output += string(r)
-			}
-			continue
-		}
+// ParseString decodes sixel data: everything after ESC P and before ST, starting with the parameters before the q.
+// Pixels which no sixel sets are left transparent, whatever the background parameter, so that whatever is behind the
+// image shows through. An image larger than maxImageSize or maxImageArea allow is an error.
+func ParseString(data string) (*Sixel, error) {
+	runes := []rune(data)
 
-		if r >= 0x30 && r <= 0x39 {
-			countStr = fmt.Sprintf("%s%c", countStr, r)
-		} else {
-			count, _ := strconv.Atoi(countStr)
-			output += strings.Repeat(string(r), count)
-			inMarker = false
+	start := -1
+	for i, r := range runes {
+		if r == 'q' {
+			start = i
+			break
 		}
 	}
+	if start < 0 {
+		return nil, fmt.Errorf("Missing sixel introducer")
+	}
 
-	return output
-}
-
-// pass in everything after ESC+P and before ST
-func ParseString(data string) (*Sixel, error) {
-
-	data = decompress(data)
+	var ratio uint = 2
+	if params, _ := readParams(runes[:start], 0); len(params) > 0 {
+		ratio = aspectRatio(params[0])
+	}
 
-	inHeader := true
-	inColour := false
+	colours := map[int]colour{}
+	for i, c := range defaultColours {
+		colours[i] = rgbColour(c[0], c[1], c[2])
+	}
+	selectedColour := colours[0]
 
 	six := Sixel{}
 	var x, y uint
-
-	colourStr := ""
-
-	colourMap := map[string]colour{}
-	var selectedColour colour
-
-	headerStr := ""
-
-	remainMode := false
-
-	var ratio uint
-
-	// read p1 p2 p3
-	for i, r := range data {
-		switch true {
-		case inHeader:
-			// todo read p1 p2 p3
-			if r == 'q' {
-				headers := strings.Split(headerStr, ";")
-				switch headers[0] {
-				case "0", "1":
-					ratio = 5
-				case "2":
-					ratio = 3
-				case "3", "4", "5", "6":
-					ratio = 2
-				case "7", "8", "9", "":
-					ratio = 1
+	var params []int
+
+	for i := start + 1; i < len(runes); {
+		r := runes[i]
+		i++
+
+		switch r {
+		case '"':
+			// raster attributes: Pan;Pad;Ph;Pv
+			params, i = readParams(runes, i)
+			if len(params) >= 2 && params[0] > 0 && params[1] > 0 {
+				ratio = uint(math.Min(maxAspectRatio, math.Max(1, math.Round(float64(params[0])/float64(params[1])))))
+			}
+			if len(params) >= 4 {
+				if err := six.grow(uint(params[2]), uint(params[3])); err != nil {
+					return nil, err
 				}
-				if len(headers) > 1 {
-					remainMode = headers[1] == "1"
+			}
+		case '#':
+			params, i = readParams(runes, i)
+			switch len(params) {
+			case 1:
+			case 5:
+				switch params[1] {
+				case 1:
+					colours[params[0]] = hlsColour(params[2], params[3], params[4])
+				case 2:
+					colours[params[0]] = rgbColour(params[2], params[3], params[4])
+				default:
+					return nil, fmt.Errorf("Unknown colour definition type: %d", params[1])
 				}
-				inHeader = false
-			} else {
-				headerStr = fmt.Sprintf("%s%c", headerStr, r)
+			default:
+				return nil, fmt.Errorf("Invalid colour directive: #%v", params)
 			}
-		case inColour:
-			colourStr = fmt.Sprintf("%s%c", colourStr, r)
-			if i+1 >= len(data) || data[i+1] < 0x30 || data[i+1] > 0x3b {
-				// process colour string
-				inColour = false
-				parts := strings.Split(colourStr, ";")
-
-				// select colour
-				if len(parts) == 1 {
-					c, ok := colourMap[parts[0]]
-					if ok {
-						selectedColour = c
-					}
-				} else if len(parts) == 5 {
-					switch parts[1] {
-					case "1":
-						// HSL
-						return nil, fmt.Errorf("HSL colours are not yet supported")
-					case "2":
-						// RGB
-						r, _ := strconv.Atoi(parts[2])
-						g, _ := strconv.Atoi(parts[3])
-						b, _ := strconv.Atoi(parts[4])
-						colourMap[parts[0]] = colour([3]uint8{
-							uint8(r & 0xff),
-							uint8(g & 0xff),
-							uint8(b & 0xff),
-						})
-					default:
-						return nil, fmt.Errorf("Unknown colour definition type: %s", parts[1])
+			// defining a colour also selects it
+			if c, ok := colours[params[0]]; ok {
+				selectedColour = c
+			}
+		case '!':
+			params, i = readParams(runes, i)
+			count := 1
+			if len(params) > 0 && params[0] > 1 {
+				count = params[0]
+			}
+			if count > maxImageSize {
+				count = maxImageSize
+			}
+			if i < len(runes) {
+				if isSixel(runes[i]) {
+					for ; count > 0; count-- {
+						if err := six.drawSixel(x, y, runes[i], selectedColour, ratio); err != nil {
+							return nil, err
+						}
+						x++
 					}
-				} else {
-					return nil, fmt.Errorf("Invalid colour directive: #%s", colourStr)
 				}
-
-				colourStr = ""
+				i++
 			}
-
+		case '-':
+			y += 6
+			x = 0
+		case '$':
+			x = 0
 		default:
-			switch r {
-			case '-':
-				y += 6
-				x = 0
-			case '$':
-				x = 0
-			case '#':
-				inColour = true
-			default:
-				if r < 63 || r > 126 {
-					continue
-				}
-				b := (r & 0xff) - 0x3f
-				var bit int
-				for bit = 5; bit >= 0; bit-- {
-					if b&(1<<uint(bit)) > 0 {
-						six.setPixel(x, y+uint(bit), selectedColour, ratio)
-					} else if !remainMode {
-						// @todo use background colour here
-						//six.setPixel(x, y+uint(bit), selectedColour)
-					}
+			if isSixel(r) {
+				if err := six.drawSixel(x, y, r, selectedColour, ratio); err != nil {
+					return nil, err
 				}
 				x++
 			}
@@ -161,45 +154,200 @@ func ParseString(data string) (*Sixel, error) {
 	return &six, nil
 }
 
-func (six *Sixel) setPixel(x, y uint, c colour, vhRatio uint) {
+// readParams reads the numeric parameters separated by semicolons from data starting at i, returning them and the
+// index of the rune after them. Empty parameters are zero, and parameters beyond maxParam are maxParam.
+func readParams(data []rune, i int) ([]int, int) {
+	params := []int{}
+	value := 0
+	found := false
+	for ; i < len(data); i++ {
+		r := data[i]
+		if r >= '0' && r <= '9' {
+			value = value*10 + int(r-'0')
+			if value > maxParam {
+				value = maxParam
+			}
+			found = true
+		} else if r == ';' {
+			params = append(params, value)
+			value = 0
+			found = true
+		} else {
+			break
+		}
+	}
+	if found {
+		params = append(params, value)
+	}
+	return params, i
+}
+
+// aspectRatio returns the height of each pixel for the aspect ratio parameter P1. Raster attributes override it.
+func aspectRatio(p1 int) uint {
+	switch p1 {
+	case 2:
+		return 5
+	case 3, 4:
+		return 3
+	case 7, 8, 9:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// rgbColour returns the colour with red, green and blue percentages r, g and b
+func rgbColour(r, g, b int) colour {
+	return colour{percent(r), percent(g), percent(b)}
+}
+
+// hlsColour returns the colour with hue h in degrees, lightness l and saturation s as percentages. Hues are measured
+// from blue as they are on a VT340, so red is at 120 degrees and green at 240.
+func hlsColour(h, l, s int) colour {
+	hue := float64((h%360+240)%360) / 60
+	lightness := float64(clamp(l)) / 100
+	saturation := float64(clamp(s)) / 100
+
+	chroma := (1 - math.Abs(2*lightness-1)) * saturation
+	second := chroma * (1 - math.Abs(math.Mod(hue, 2)-1))
+
+	var r, g, b float64
+	switch {
+	case hue < 1:
+		r, g, b = chroma, second, 0
+	case hue < 2:
+		r, g, b = second, chroma, 0
+	case hue < 3:
+		r, g, b = 0, chroma, second
+	case hue < 4:
+		r, g, b = 0, second, chroma
+	case hue < 5:
+		r, g, b = second, 0, chroma
+	default:
+		r, g, b = chroma, 0, second
+	}
+
+	m := lightness - chroma/2
+	return colour{
+		uint8(math.Round((r + m) * 255)),
+		uint8(math.Round((g + m) * 255)),
+		uint8(math.Round((b + m) * 255)),
+	}
+}
+
+// percent converts a percentage to a colour channel
+func percent(p int) uint8 {
+	return uint8(math.Round(float64(clamp(p)) * 255 / 100))
+}
 
-	if six.px == nil {
-		six.px = map[uint]map[uint]colour{}
+// clamp limits a percentage to between 0 and 100
+func clamp(p int) int {
+	if p < 0 {
+		return 0
+	} else if p > 100 {
+		return 100
 	}
+	return p
+}
 
-	if _, exists := six.px[x]; !exists {
-		six.px[x] = map[uint]colour{}
+func isSixel(r rune) bool {
+	return r >= '?' && r <= '~'
+}
+
+// drawSixel draws the column of six pixels encoded by the sixel r at x, y in colour c
+func (six *Sixel) drawSixel(x, y uint, r rune, c colour, vhRatio uint) error {
+	bits := r - '?'
+	var bit uint
+	for bit = 0; bit < 6; bit++ {
+		if bits&(1<<bit) > 0 {
+			if err := six.setPixel(x, y+bit, c, vhRatio); err != nil {
+				return err
+			}
+		}
 	}
+	return nil
+}
 
-	if x+1 > six.width {
-		six.width = x
+// grow makes the image at least width by height pixels, returning an error if it would be too large
+func (six *Sixel) grow(width, height uint) error {
+	if width < six.width {
+		width = six.width
+	}
+	if height < six.height {
+		height = six.height
+	}
+	if width > maxImageSize || height > maxImageSize || uint64(width)*uint64(height) > maxImageArea {
+		return fmt.Errorf("Sixel image too large: %dx%d pixels", width, height)
 	}
+	six.width = width
+	six.height = height
+	return nil
+}
 
+func (six *Sixel) setPixel(x, y uint, c colour, vhRatio uint) error {
 	ay := vhRatio * y
+	if err := six.grow(x+1, ay+vhRatio); err != nil {
+		return err
+	}
 
+	six.reserve(x+1, ay+vhRatio)
 	var i uint
 	for i = 0; i < vhRatio; i++ {
-		if ay+i+1 > six.height {
-			six.height = ay + i + 1
+		six.pixels.SetRGBA(int(x), int(ay+i), color.RGBA{R: c[0], G: c[1], B: c[2], A: 255})
+	}
+	return nil
+}
+
+// reserve makes room in the pixels for an image of width by height, which grow has already checked. The room is
+// doubled each time it runs out, up to the limits, so that drawing an image a column at a time doesn't copy it for
+// every column.
+func (six *Sixel) reserve(width, height uint) {
+	var bounds image.Rectangle
+	if six.pixels != nil {
+		bounds = six.pixels.Bounds()
+		if int(width) <= bounds.Dx() && int(height) <= bounds.Dy() {
+			return
 		}
-		six.px[x][ay+i] = c
 	}
 
+	w, h := uint(bounds.Dx()), uint(bounds.Dy())
+	if width > w {
+		w = stepSize(w, width)
+	}
+	if height > h {
+		h = stepSize(h, height)
+	}
+	if uint64(w)*uint64(h) > maxImageArea {
+		w, h = width, height
+	}
+
+	pixels := image.NewRGBA(image.Rect(0, 0, int(w), int(h)))
+	if six.pixels != nil {
+		draw.Draw(pixels, bounds, six.pixels, image.Point{}, draw.Src)
+	}
+	six.pixels = pixels
 }
 
+// stepSize returns the size to grow from current to make room for at least needed, within maxImageSize
+func stepSize(current, needed uint) uint {
+	size := current * 2
+	if size < 64 {
+		size = 64
+	}
+	if size > maxImageSize {
+		size = maxImageSize
+	}
+	if size < needed {
+		size = needed
+	}
+	return size
+}
+
+// RGBA returns the image, with transparent pixels wherever no sixel set one
 func (six *Sixel) RGBA() *image.RGBA {
 	rgba := image.NewRGBA(image.Rect(0, 0, int(six.width), int(six.height)))
-
-	for x, r := range six.px {
-		for y, colour := range r {
-			rgba.Set(int(x), int(six.height)-int(y), color.RGBA{
-				R: colour[0],
-				G: colour[1],
-				B: colour[2],
-				A: 255,
-			})
-		}
+	if six.pixels != nil {
+		draw.Draw(rgba, rgba.Bounds(), six.pixels, image.Point{}, draw.Src)
 	}
-
 	return rgba
 }
@@ -1,16 +1,24 @@
 package sixel
 
 import (
+	"image/color"
+	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+var (
+	yellow = color.RGBA{R: 255, G: 255, A: 255}
+	green  = color.RGBA{G: 255, A: 255}
+)
+
 // from https://en.wikipedia.org/wiki/Sixel
 func TestParsing(t *testing.T) {
 
 	//raw := `q"1;1;16;16#0;2;0;0;0#1;2;94;75;22#2;2;97;78;31#3;2;97;82;35#4;2;97;82;44#5;2;94;78;25#6;2;91;78;41#7;2;69;60;38#8;2;56;50;35#9;2;63;56;35#10;2;41;38;31#0NB@@!8?@@BN$#1oCA?@!6?@?ACo$#3?O??A?!4@?A??O$#4?_w{{!6}{{w_$#5?G#2CA?@!4?@?AC#5G-#1{_#6K!4?__!4?K#1_{$#5B#4FRrrrz^^zrrrRF#5B$#3?G_#7CCGC??CGCC#3_G$#2?O#9?G!8?G#2?O$#8!4?GC!4?CG-#0NKGG!8?GGKN$#1?BFE!8KEFB$#3???@!8?@$#4!4?@@!4?@@$#5!4?A!6?A$#2!5?A!4?A$#7!6?A??A$#10!6?!4@$#6!7?AA`
-	raw := `q
+	raw := `q"1;1
 	#0;2;0;0;0#1;2;100;100;0#2;2;0;100;0
 	#1~~@@vv@@~~@@~~$
 	#2??}}GG}}??}}??-
@@ -20,5 +28,92 @@ func TestParsing(t *testing.T) {
 
 	img := six.RGBA()
 	require.NotNil(t, img)
+	assert.Equal(t, 14, img.Bounds().Dx())
+	assert.Equal(t, 7, img.Bounds().Dy())
+
+	assert.Equal(t, yellow, img.RGBAAt(0, 0))
+	assert.Equal(t, yellow, img.RGBAAt(0, 5))
+	assert.Equal(t, yellow, img.RGBAAt(2, 0))
+	assert.Equal(t, green, img.RGBAAt(2, 1))
+	assert.Equal(t, yellow, img.RGBAAt(13, 6))
+}
+
+func TestAspectRatio(t *testing.T) {
+	six, err := ParseString("q~")
+	require.Nil(t, err)
+	assert.Equal(t, 12, six.RGBA().Bounds().Dy(), "pixels should be twice as tall as they're wide by default")
+
+	six, err = ParseString("2q~")
+	require.Nil(t, err)
+	assert.Equal(t, 30, six.RGBA().Bounds().Dy())
 
+	six, err = ParseString(`2q"3;1~`)
+	require.Nil(t, err)
+	assert.Equal(t, 18, six.RGBA().Bounds().Dy(), "raster attributes should override the aspect ratio parameter")
+}
+
+func TestRasterAttributesSetSize(t *testing.T) {
+	six, err := ParseString(`q"1;1;10;20~`)
+	require.Nil(t, err)
+
+	img := six.RGBA()
+	assert.Equal(t, 10, img.Bounds().Dx())
+	assert.Equal(t, 20, img.Bounds().Dy())
+}
+
+func TestRepeat(t *testing.T) {
+	six, err := ParseString(`q"1;1#1;2;100;100;0!3~?!2@`)
+	require.Nil(t, err)
+
+	img := six.RGBA()
+	assert.Equal(t, 6, img.Bounds().Dx())
+	assert.Equal(t, yellow, img.RGBAAt(2, 5))
+	assert.Equal(t, color.RGBA{}, img.RGBAAt(3, 0), "pixels which aren't set should be transparent")
+	assert.Equal(t, yellow, img.RGBAAt(5, 0))
+	assert.Equal(t, color.RGBA{}, img.RGBAAt(5, 1))
+}
+
+func TestColourRegisters(t *testing.T) {
+	six, err := ParseString(`q"1;1#3~#1;2;100;100;0~#1;1;120;50;100~#1;1;240;50;100~#1;1;0;50;100~#3~`)
+	require.Nil(t, err)
+
+	img := six.RGBA()
+	assert.Equal(t, color.RGBA{R: 51, G: 204, B: 51, A: 255}, img.RGBAAt(0, 0), "registers should start with the VT340 colours")
+	assert.Equal(t, yellow, img.RGBAAt(1, 0), "defining a colour should select it")
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, img.RGBAAt(2, 0), "HLS hues should start from blue")
+	assert.Equal(t, green, img.RGBAAt(3, 0))
+	assert.Equal(t, color.RGBA{B: 255, A: 255}, img.RGBAAt(4, 0))
+	assert.Equal(t, color.RGBA{R: 51, G: 204, B: 51, A: 255}, img.RGBAAt(5, 0))
+
+	_, err = ParseString(`q#1;3;0;0;0~`)
+	assert.NotNil(t, err)
+}
+
+func TestOversizedImagesAreRejected(t *testing.T) {
+	_, err := ParseString(`q"1;1;200000;200000#1~`)
+	assert.NotNil(t, err, "raster attributes beyond the limits should be rejected")
+
+	_, err = ParseString(`q"1;1;99999999999999999999999;1~`)
+	assert.NotNil(t, err, "parameters too large for an int should be rejected, not overflow")
+
+	_, err = ParseString(`q!99999999~!99999999~`)
+	assert.NotNil(t, err, "repeats beyond the limits should be rejected")
+
+	six, err := ParseString(`q"1000;1~`)
+	require.Nil(t, err)
+	assert.Equal(t, maxAspectRatio*6, six.RGBA().Bounds().Dy(), "the aspect ratio should be clamped")
+
+	_, err = ParseString(`q"1;1;8192;2048~`)
+	assert.Nil(t, err, "images within the limits should be parsed")
+}
+
+func TestLargestImageIsParsed(t *testing.T) {
+	six, err := ParseString(`q"1;1;4096;4096#1;2;100;100;0` + strings.Repeat("!4096~-", 4096/6))
+	require.Nil(t, err)
+
+	img := six.RGBA()
+	assert.Equal(t, 4096, img.Bounds().Dx())
+	assert.Equal(t, 4096, img.Bounds().Dy())
+	assert.Equal(t, yellow, img.RGBAAt(4095, 4091))
+	assert.Equal(t, color.RGBA{}, img.RGBAAt(4095, 4095))
 }
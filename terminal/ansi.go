@@ -1,83 +1,110 @@
 package terminal
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/liamg/aminal/buffer"
+)
 
 // https://www.xfree86.org/4.8.0/ctlseqs.html
 // https://vt100.net/docs/vt100-ug/chapter3.html
 
 var ansiSequenceMap = map[rune]escapeSequenceHandler{
-	'[': csiHandler,
-	']': oscHandler,
-	'7': saveCursorHandler,
-	'8': restoreCursorHandler,
-	'D': indexHandler,
-	'E': nextLineHandler, // NEL
-	'H': tabSetHandler,   // HTS
-	'M': reverseIndexHandler,
-	'P': sixelHandler,
-	'c': risHandler, //RIS
+	'7':  saveCursorHandler,
+	'8':  restoreCursorHandler,
+	'D':  indexHandler,
+	'E':  nextLineHandler, // NEL
+	'H':  tabSetHandler,   // HTS
+	'M':  reverseIndexHandler,
+	'c':  risHandler,    //RIS
+	'>':  ignoreHandler, // numeric char selection  //@todo
+	'=':  ignoreHandler, // alt char selection  //@todo
+	'\\': ignoreHandler, // ST, which ends a string the parser has already dispatched
+}
+
+// ansiIntermediateSequenceMap holds the escape sequences with an intermediate byte, whose handlers are passed the final byte
+var ansiIntermediateSequenceMap = map[rune]func(final rune, terminal *Terminal) error{
 	'#': screenStateHandler,
-	'(': scs0Handler,       // select character set into G0
-	')': scs1Handler,       // select character set into G1
-	'*': swallowHandler(1), // character set bullshit
-	'+': swallowHandler(1), // character set bullshit
-	'>': swallowHandler(0), // numeric char selection  //@todo
-	'=': swallowHandler(0), // alt char selection  //@todo
+	'(': scs0Handler,                                               // select character set into G0
+	')': scs1Handler,                                               // select character set into G1
+	'*': func(final rune, terminal *Terminal) error { return nil }, // character set bullshit
+	'+': func(final rune, terminal *Terminal) error { return nil }, // character set bullshit
 }
 
-func swallowHandler(n int) func(pty chan rune, terminal *Terminal) error {
-	return func(pty chan rune, terminal *Terminal) error {
-		for i := 0; i < n; i++ {
-			<-pty
-		}
-		return nil
-	}
+func ignoreHandler(terminal *Terminal) error {
+	return nil
 }
 
-func risHandler(pty chan rune, terminal *Terminal) error {
+// risHandler handles RIS, which also undoes colour changes made with OSC 4, 10 and 11 and resets the text attributes
+func risHandler(terminal *Terminal) error {
+	terminal.ResetColours()
+	fg, bg := terminal.textDefaults()
+	*terminal.ActiveBuffer().CursorAttr() = buffer.CellAttributes{
+		FgColour: fg,
+		BgColour: bg,
+	}
+	terminal.terminalState.SetHyperlink(nil)
 	terminal.ActiveBuffer().Clear()
 	return nil
 }
 
-func indexHandler(pty chan rune, terminal *Terminal) error {
+func indexHandler(terminal *Terminal) error {
 	terminal.ActiveBuffer().Index()
 	return nil
 }
 
-func reverseIndexHandler(pty chan rune, terminal *Terminal) error {
+func reverseIndexHandler(terminal *Terminal) error {
 	terminal.ActiveBuffer().ReverseIndex()
 	return nil
 }
 
-func saveCursorHandler(pty chan rune, terminal *Terminal) error {
+func saveCursorHandler(terminal *Terminal) error {
 	terminal.ActiveBuffer().SaveCursor()
 	return nil
 }
 
-func restoreCursorHandler(pty chan rune, terminal *Terminal) error {
+func restoreCursorHandler(terminal *Terminal) error {
 	terminal.ActiveBuffer().RestoreCursor()
 	return nil
 }
 
-func ansiHandler(pty chan rune, terminal *Terminal) error {
-	// if the byte is an escape character, read the next byte to determine which one
-	b := <-pty
-
-	handler, ok := ansiSequenceMap[b]
-	if ok {
-		//terminal.logger.Debugf("Handling ansi sequence %c", b)
-		return handler(pty, terminal)
+// ansiHandler handles an escape sequence, ESC followed by any intermediate bytes (0x20-0x2F) and a final byte
+func ansiHandler(intermediates string, final rune, terminal *Terminal) error {
+	switch len(intermediates) {
+	case 0:
+		if handler, ok := ansiSequenceMap[final]; ok {
+			//terminal.logger.Debugf("Handling ansi sequence %c", final)
+			return handler(terminal)
+		}
+	case 1:
+		if handler, ok := ansiIntermediateSequenceMap[rune(intermediates[0])]; ok {
+			return handler(final, terminal)
+		}
 	}
 
-	return fmt.Errorf("Unknown ANSI control sequence byte: 0x%02X [%v]", b, string(b))
+	return fmt.Errorf("Unknown ANSI control sequence: ESC %s%c [0x%02X]", intermediates, final, final)
+}
+
+// c1ST is the 8-bit form of ST (ESC \), which terminates OSC and DCS strings
+const c1ST rune = 0x9c
+
+// c1Handler handles an 8-bit C1 control other than those which introduce strings and CSI, as the equivalent escape
+// sequence of ESC followed by the control minus 0x40, e.g. 0x84 is IND (ESC D). C1 controls without an escape sequence
+// handler are ignored.
+func c1Handler(b rune, terminal *Terminal) error {
+	handler, ok := ansiSequenceMap[b-0x40]
+	if !ok {
+		return nil
+	}
+	return handler(terminal)
 }
 
-func nextLineHandler(pty chan rune, terminal *Terminal) error {
+func nextLineHandler(terminal *Terminal) error {
 	terminal.ActiveBuffer().NewLineEx(true)
 	return nil
 }
 
-func tabSetHandler(pty chan rune, terminal *Terminal) error {
+func tabSetHandler(terminal *Terminal) error {
 	terminal.terminalState.TabSetAtCursor()
 	return nil
 }
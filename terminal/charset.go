@@ -43,16 +43,15 @@ var decSpecGraphics = map[rune]rune{
 	0x7e: 0x00B7, // MIDDLE DOT
 }
 
-func scs0Handler(pty chan rune, terminal *Terminal) error {
-	return scsHandler(pty, terminal, 0)
+func scs0Handler(b rune, terminal *Terminal) error {
+	return scsHandler(b, terminal, 0)
 }
 
-func scs1Handler(pty chan rune, terminal *Terminal) error {
-	return scsHandler(pty, terminal, 1)
+func scs1Handler(b rune, terminal *Terminal) error {
+	return scsHandler(b, terminal, 1)
 }
 
-func scsHandler(pty chan rune, terminal *Terminal, which int) error {
-	b := <-pty
+func scsHandler(b rune, terminal *Terminal, which int) error {
 
 	cs, ok := charSets[b]
 	if ok {
@@ -0,0 +1,67 @@
+package terminal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDECSpecialGraphics(t *testing.T) {
+	expected := map[rune]rune{
+		'_': '\u00a0', // blank (no-break space)
+		'`': '◆',
+		'a': '▒',
+		'b': '␉',
+		'c': '␌',
+		'd': '␍',
+		'e': '␊',
+		'f': '°',
+		'g': '±',
+		'h': '␤',
+		'i': '␋',
+		'j': '┘',
+		'k': '┐',
+		'l': '┌',
+		'm': '└',
+		'n': '┼',
+		'o': '⎺',
+		'p': '⎻',
+		'q': '─',
+		'r': '⎼',
+		's': '⎽',
+		't': '├',
+		'u': '┤',
+		'v': '┴',
+		'w': '┬',
+		'x': '│',
+		'y': '≤',
+		'z': '≥',
+		'{': 'π',
+		'|': '≠',
+		'}': '£',
+		'~': '·',
+	}
+	assert.Len(t, expected, 32)
+
+	for b, r := range expected {
+		terminal, _ := newTestTerminal(t, 10, 2)
+		feed(terminal, "\x1b(0"+string(b)+"\x1b(B"+string(b))
+		assert.Equal(t, r, terminal.GetCell(0, 0).Rune(), "0x%02X with DEC Special Graphics", b)
+		assert.Equal(t, b, terminal.GetCell(1, 0).Rune(), "0x%02X with ASCII", b)
+	}
+}
+
+func TestDECSpecialGraphicsLeavesOtherCharacters(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 2)
+
+	feed(terminal, "\x1b(0AZ09 ^\x1b(B")
+	assert.Equal(t, []string{"AZ09 ^"}, visibleText(terminal))
+}
+
+func TestDECSpecialGraphicsInG1(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 2)
+
+	// shift out to G1 for the border, and back in to G0 for the text
+	feed(terminal, "\x1b)0\x0elqk\x0f lqk")
+	assert.Equal(t, []string{"┌─┐ lqk"}, visibleText(terminal))
+}
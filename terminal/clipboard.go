@@ -0,0 +1,70 @@
+package terminal
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// ClipboardRequest is an application's request to read or write the clipboard via OSC 52
+type ClipboardRequest struct {
+	Selection string // the selections to use, e.g. "c" for the clipboard
+	Read      bool   // true if the application is asking for the clipboard contents
+	Data      string // the text to write to the clipboard, if Read is false
+}
+
+func (terminal *Terminal) AttachClipboardHandler(handler chan ClipboardRequest) {
+	terminal.clipboardHandlers = append(terminal.clipboardHandlers, handler)
+}
+
+func (terminal *Terminal) emitClipboardRequest(request ClipboardRequest) {
+	for _, h := range terminal.clipboardHandlers {
+		go func(c chan ClipboardRequest) {
+			c <- request
+		}(h)
+	}
+}
+
+// handleClipboard handles OSC 52 ; Pc ; Pd
+func (terminal *Terminal) handleClipboard(params []string) error {
+	if len(params) != 2 {
+		return fmt.Errorf("Invalid OSC 52 parameters: %v", params)
+	}
+
+	selection := params[0]
+	if selection == "" {
+		selection = "s0"
+	}
+
+	if params[1] == "?" {
+		terminal.emitClipboardRequest(ClipboardRequest{Selection: selection, Read: true})
+		return nil
+	}
+
+	if !terminal.config.ClipboardWrite {
+		terminal.logger.Infof("Denied clipboard write as clipboard_write is disabled")
+		return nil
+	}
+
+	// data which is obviously too large isn't decoded
+	if max := terminal.config.ClipboardMaxSize; max > 0 && len(params[1]) > base64.StdEncoding.EncodedLen(max) {
+		terminal.logger.Infof("Denied clipboard write of more than clipboard_max_size")
+		return nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(params[1])
+	if err != nil {
+		return fmt.Errorf("Invalid OSC 52 clipboard data: %s", err)
+	}
+	if max := terminal.config.ClipboardMaxSize; max > 0 && len(data) > max {
+		terminal.logger.Infof("Denied clipboard write of %d bytes, more than clipboard_max_size", len(data))
+		return nil
+	}
+
+	terminal.emitClipboardRequest(ClipboardRequest{Selection: selection, Data: string(data)})
+	return nil
+}
+
+// ReplyToClipboardRead sends the clipboard contents to the application in response to an OSC 52 read
+func (terminal *Terminal) ReplyToClipboardRead(request ClipboardRequest, data string) error {
+	return terminal.Write([]byte(fmt.Sprintf("\x1b]52;%s;%s\x07", request.Selection, base64.StdEncoding.EncodeToString([]byte(data)))))
+}
@@ -0,0 +1,60 @@
+package terminal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receiveClipboardRequest(t *testing.T, requests chan ClipboardRequest) ClipboardRequest {
+	select {
+	case request := <-requests:
+		return request
+	case <-time.After(time.Second):
+		require.FailNow(t, "No clipboard request received")
+	}
+	return ClipboardRequest{}
+}
+
+func TestClipboardWrite(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 5)
+	requests := make(chan ClipboardRequest, 1)
+	terminal.AttachClipboardHandler(requests)
+
+	feed(terminal, "\x1b]52;c;aGVsbG8=\x1b\\")
+	assert.Equal(t, ClipboardRequest{Selection: "c", Data: "hello"}, receiveClipboardRequest(t, requests))
+}
+
+func TestClipboardRead(t *testing.T) {
+	terminal, pty := newTestTerminal(t, 20, 5)
+	requests := make(chan ClipboardRequest, 1)
+	terminal.AttachClipboardHandler(requests)
+
+	feed(terminal, "\x1b]52;c;?\x07")
+	request := receiveClipboardRequest(t, requests)
+	assert.Equal(t, ClipboardRequest{Selection: "c", Read: true}, request)
+
+	require.Nil(t, terminal.ReplyToClipboardRead(request, "hello"))
+	assert.Equal(t, "\x1b]52;c;aGVsbG8=\x07", pty.output.String())
+}
+
+func TestClipboardWriteLimits(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 5)
+	requests := make(chan ClipboardRequest, 1)
+	terminal.AttachClipboardHandler(requests)
+
+	terminal.config.ClipboardMaxSize = 5
+	feed(terminal, "\x1b]52;c;aGVsbG8gd29ybGQ=\x07")
+	feed(terminal, "\x1b]52;c;aGVsbG8=\x07")
+	assert.Equal(t, ClipboardRequest{Selection: "c", Data: "hello"}, receiveClipboardRequest(t, requests))
+
+	terminal.config.ClipboardWrite = false
+	feed(terminal, "\x1b]52;c;aGk=\x07")
+	select {
+	case request := <-requests:
+		assert.Fail(t, "Clipboard written while clipboard_write is disabled", "%v", request)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,150 @@
+package terminal
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/liamg/aminal/config"
+)
+
+// x11Colours are the most commonly used of the colour names understood by XParseColor
+var x11Colours = map[string]string{
+	"black":         "#000000",
+	"white":         "#ffffff",
+	"red":           "#ff0000",
+	"green":         "#00ff00",
+	"blue":          "#0000ff",
+	"yellow":        "#ffff00",
+	"cyan":          "#00ffff",
+	"magenta":       "#ff00ff",
+	"gray":          "#bebebe",
+	"grey":          "#bebebe",
+	"darkgray":      "#a9a9a9",
+	"darkgrey":      "#a9a9a9",
+	"lightgray":     "#d3d3d3",
+	"lightgrey":     "#d3d3d3",
+	"dimgray":       "#696969",
+	"dimgrey":       "#696969",
+	"darkred":       "#8b0000",
+	"darkgreen":     "#006400",
+	"darkblue":      "#00008b",
+	"darkcyan":      "#008b8b",
+	"darkmagenta":   "#8b008b",
+	"navy":          "#000080",
+	"navyblue":      "#000080",
+	"orange":        "#ffa500",
+	"darkorange":    "#ff8c00",
+	"purple":        "#a020f0",
+	"pink":          "#ffc0cb",
+	"brown":         "#a52a2a",
+	"gold":          "#ffd700",
+	"maroon":        "#b03060",
+	"violet":        "#ee82ee",
+	"turquoise":     "#40e0d0",
+	"beige":         "#f5f5dc",
+	"ivory":         "#fffff0",
+	"wheat":         "#f5deb3",
+	"salmon":        "#fa8072",
+	"khaki":         "#f0e68c",
+	"coral":         "#ff7f50",
+	"tomato":        "#ff6347",
+	"orchid":        "#da70d6",
+	"plum":          "#dda0dd",
+	"steelblue":     "#4682b4",
+	"skyblue":       "#87ceeb",
+	"slategray":     "#708090",
+	"slategrey":     "#708090",
+	"forestgreen":   "#228b22",
+	"seagreen":      "#2e8b57",
+	"limegreen":     "#32cd32",
+	"olivedrab":     "#6b8e23",
+	"midnightblue":  "#191970",
+	"royalblue":     "#4169e1",
+	"dodgerblue":    "#1e90ff",
+	"firebrick":     "#b22222",
+	"chocolate":     "#d2691e",
+	"tan":           "#d2b48c",
+	"lavender":      "#e6e6fa",
+	"whitesmoke":    "#f5f5f5",
+	"gainsboro":     "#dcdcdc",
+	"snow":          "#fffafa",
+	"linen":         "#faf0e6",
+	"aquamarine":    "#7fffd4",
+	"chartreuse":    "#7fff00",
+	"darkslategray": "#2f4f4f",
+	"darkslategrey": "#2f4f4f",
+}
+
+// parseColourSpec parses a colour in one of the forms accepted by xterm for dynamic colours: rgb:r/g/b and rgbi:r/g/b
+// (as used by XParseColor), #rgb with 1 to 4 hex digits per channel, or an X11 colour name
+func parseColourSpec(spec string) (config.Colour, error) {
+	spec = strings.TrimSpace(spec)
+	lower := strings.ToLower(spec)
+
+	switch {
+	case strings.HasPrefix(lower, "rgb:"):
+		return parseColourChannels(spec, lower[4:], parseHexChannel)
+	case strings.HasPrefix(lower, "rgbi:"):
+		return parseColourChannels(spec, lower[5:], parseIntensityChannel)
+	case strings.HasPrefix(lower, "#"):
+		digits := lower[1:]
+		if len(digits) == 0 || len(digits)%3 != 0 || len(digits) > 12 {
+			return config.Colour{}, fmt.Errorf("Invalid colour spec: %s", spec)
+		}
+		n := len(digits) / 3
+		return parseColourChannels(spec, digits[:n]+"/"+digits[n:2*n]+"/"+digits[2*n:], parseHexChannel)
+	}
+
+	if hex, ok := x11Colours[strings.Replace(lower, " ", "", -1)]; ok {
+		return parseColourSpec(hex)
+	}
+	return config.Colour{}, fmt.Errorf("Unknown colour: %s", spec)
+}
+
+func parseColourChannels(spec string, channels string, parse func(string) (float32, bool)) (config.Colour, error) {
+	var colour config.Colour
+	parts := strings.Split(channels, "/")
+	if len(parts) != 3 {
+		return colour, fmt.Errorf("Invalid colour spec: %s", spec)
+	}
+	for i, part := range parts {
+		value, ok := parse(part)
+		if !ok {
+			return colour, fmt.Errorf("Invalid colour spec: %s", spec)
+		}
+		colour[i] = value
+	}
+	return colour, nil
+}
+
+// parseHexChannel parses 1 to 4 hex digits, scaled so that all fs is full intensity
+func parseHexChannel(digits string) (float32, bool) {
+	if len(digits) < 1 || len(digits) > 4 {
+		return 0, false
+	}
+	value, err := strconv.ParseUint(digits, 16, 16)
+	if err != nil {
+		return 0, false
+	}
+	max := uint64(1)<<(4*uint(len(digits))) - 1
+	return float32(value) / float32(max), true
+}
+
+// parseIntensityChannel parses a floating point intensity between 0 and 1
+func parseIntensityChannel(text string) (float32, bool) {
+	value, err := strconv.ParseFloat(text, 32)
+	if err != nil || value < 0 || value > 1 {
+		return 0, false
+	}
+	return float32(value), true
+}
+
+// formatColourSpec formats a colour as xterm does when replying to a dynamic colour query
+func formatColourSpec(colour config.Colour) string {
+	channel := func(value float32) uint16 {
+		return uint16(math.Round(float64(value) * 0xffff))
+	}
+	return fmt.Sprintf("rgb:%04x/%04x/%04x", channel(colour[0]), channel(colour[1]), channel(colour[2]))
+}
@@ -0,0 +1,163 @@
+package terminal
+
+import (
+	"testing"
+
+	"github.com/liamg/aminal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseColourSpec(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected config.Colour
+	}{
+		{"rgb:ff/80/00", config.Colour{1, 128.0 / 255, 0}},
+		{"rgb:f/8/0", config.Colour{1, 8.0 / 15, 0}},
+		{"rgb:ffff/0000/8000", config.Colour{1, 0, 32768.0 / 65535}},
+		{"RGB:FF/FF/FF", config.Colour{1, 1, 1}},
+		{"rgbi:1/0.5/0", config.Colour{1, 0.5, 0}},
+		{"#ff8000", config.Colour{1, 128.0 / 255, 0}},
+		{"#f80", config.Colour{1, 8.0 / 15, 0}},
+		{"#ffff00000000", config.Colour{1, 0, 0}},
+		{"white", config.Colour{1, 1, 1}},
+		{"Dark Slate Gray", config.Colour{0x2f / 255.0, 0x4f / 255.0, 0x4f / 255.0}},
+	}
+
+	for _, test := range tests {
+		colour, err := parseColourSpec(test.spec)
+		require.Nil(t, err, test.spec)
+		for i := range colour {
+			assert.InDelta(t, test.expected[i], colour[i], 0.001, test.spec)
+		}
+	}
+
+	for _, spec := range []string{"", "rgb:ff/ff", "rgb:fffff/0/0", "rgb:gg/00/00", "rgbi:2/0/0", "#ff80", "#", "notacolour"} {
+		_, err := parseColourSpec(spec)
+		assert.NotNil(t, err, spec)
+	}
+}
+
+func TestDynamicColours(t *testing.T) {
+	terminal, pty := newTestTerminal(t, 10, 2)
+	original := terminal.colours
+
+	feed(terminal, "ab\x1b]10;?\x07")
+	assert.Equal(t, "\x1b]10;"+formatColourSpec(original.Foreground)+"\x1b\\", pty.output.String())
+
+	feed(terminal, "\x1b]11;#102030\x07")
+	background := config.Colour{0x10 / 255.0, 0x20 / 255.0, 0x30 / 255.0}
+	assert.Equal(t, background, terminal.colours.Background)
+	assert.Equal(t, [3]float32(background), terminal.GetCell(0, 0).Bg(), "existing text should be repainted")
+	assert.Equal(t, [3]float32(original.Foreground), terminal.GetCell(0, 0).Fg())
+
+	pty.output.Reset()
+	feed(terminal, "\x1b]10;red;?\x07")
+	assert.Equal(t, config.Colour{1, 0, 0}, terminal.colours.Foreground)
+	assert.Equal(t, "\x1b]11;rgb:1010/2020/3030\x1b\\", pty.output.String())
+
+	feed(terminal, "c\x1b]110\x07\x1b]111\x07")
+	assert.Equal(t, original.Foreground, terminal.colours.Foreground)
+	assert.Equal(t, original.Background, terminal.colours.Background)
+	assert.Equal(t, [3]float32(original.Foreground), terminal.GetCell(2, 0).Fg())
+	assert.Equal(t, [3]float32(original.Background), terminal.GetCell(2, 0).Bg())
+
+	// an unparseable colour leaves the colours alone
+	feed(terminal, "\x1b]11;nonsense\x07")
+	assert.Equal(t, original.Background, terminal.colours.Background)
+}
+
+func TestColourChangesStayInTheirTerminal(t *testing.T) {
+	conf := config.DefaultConfig
+	first := New(&testPty{}, zap.NewNop().Sugar(), &conf)
+	second := New(&testPty{}, zap.NewNop().Sugar(), &conf)
+
+	feed(first, "\x1b]10;red\x07\x1b]11;blue\x07")
+	assert.Equal(t, config.Colour{1, 0, 0}, first.Colours().Foreground)
+	assert.Equal(t, config.DefaultConfig.ColourScheme.Foreground, second.Colours().Foreground, "other tabs should keep their colours")
+	assert.Equal(t, config.DefaultConfig.ColourScheme.Background, second.Colours().Background)
+	assert.Equal(t, config.DefaultConfig.ColourScheme, conf.ColourScheme, "the config should be left alone")
+}
+
+func TestPaletteColours(t *testing.T) {
+	terminal, pty := newTestTerminal(t, 10, 2)
+	original := terminal.colours
+
+	feed(terminal, "\x1b[31ma\x1b]4;1;#ff00ff;200;rgb:10/20/30\x07b\x1b[38;5;200mc")
+	magenta := config.Colour{1, 0, 1}
+	assert.Equal(t, [3]float32(magenta), terminal.GetCell(0, 0).Fg(), "existing text should be repainted")
+	assert.Equal(t, [3]float32(magenta), terminal.GetCell(1, 0).Fg())
+	assert.Equal(t, [3]float32{0x10 / 255.0, 0x20 / 255.0, 0x30 / 255.0}, terminal.GetCell(2, 0).Fg())
+
+	feed(terminal, "\x1b]4;1;?\x07")
+	assert.Equal(t, "\x1b]4;1;rgb:ffff/0000/ffff\x1b\\", pty.output.String())
+
+	feed(terminal, "\x1b]104;1\x07")
+	assert.Equal(t, [3]float32(original.Red), terminal.GetCell(0, 0).Fg())
+	assert.Equal(t, [3]float32{0x10 / 255.0, 0x20 / 255.0, 0x30 / 255.0}, terminal.get8BitSGRColour(200))
+
+	feed(terminal, "\x1b]104\x07")
+	assert.Zero(t, len(terminal.palette))
+}
+
+func TestColourChangesOnlyRepaintTheirColour(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 10, 2)
+	original := terminal.colours
+	require.Equal(t, [3]float32{128 / 255.0, 0, 0}, [3]float32(original.Red), "the test needs a true colour equal to red")
+	require.Equal(t, [3]float32{232 / 255.0, 223 / 255.0, 214 / 255.0}, [3]float32(original.Foreground), "the test needs a true colour equal to the foreground")
+
+	feed(terminal, "\x1b[31ma\x1b[38;2;128;0;0mb\x1b[38;2;232;223;214mc\x1b[39md\x1b[41m\x1b[K")
+	feed(terminal, "\x1b]4;1;#ff00ff\x07\x1b]10;#102030\x07")
+
+	assert.Equal(t, [3]float32{1, 0, 1}, terminal.GetCell(0, 0).Fg())
+	assert.Equal(t, [3]float32(original.Red), terminal.GetCell(1, 0).Fg(), "a true colour which happens to be red should be left alone")
+	assert.Equal(t, [3]float32(original.Foreground), terminal.GetCell(2, 0).Fg(), "a true colour which happens to be the foreground should be left alone")
+	assert.Equal(t, [3]float32{0x10 / 255.0, 0x20 / 255.0, 0x30 / 255.0}, terminal.GetCell(3, 0).Fg())
+	assert.Equal(t, [3]float32{1, 0, 1}, terminal.GetCell(5, 0).Bg(), "cells erased in red should be repainted")
+}
+
+func TestResetRestoresConfiguredColours(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 10, 2)
+	original := terminal.colours
+
+	feed(terminal, "\x1b]4;1;#ff00ff\x07\x1b]10;#102030\x07\x1b]11;#405060\x07\x1b[31;1m")
+	feed(terminal, "\x1bc")
+
+	assert.Equal(t, [3]float32(original.Red), terminal.get8BitSGRColour(1))
+	assert.Equal(t, original.Foreground, terminal.colours.Foreground)
+	assert.Equal(t, original.Background, terminal.colours.Background)
+
+	feed(terminal, "a\x1b[31mb")
+	assert.Equal(t, [3]float32(original.Foreground), terminal.GetCell(0, 0).Fg(), "RIS should reset the text attributes")
+	assert.False(t, terminal.GetCell(0, 0).Attr().Bold)
+	assert.Equal(t, [3]float32(original.Red), terminal.GetCell(1, 0).Fg())
+}
+
+func TestConfiguredPalette(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 3)
+	terminal.colours.Palette = map[string]config.Colour{"1": {0, 0, 1}, "200": {0, 1, 0}}
+
+	feed(terminal, "\x1b[31;48;5;200m")
+	assert.Equal(t, [3]float32{0, 0, 1}, [3]float32(terminal.ActiveBuffer().CursorAttr().FgColour))
+	assert.Equal(t, [3]float32{0, 1, 0}, [3]float32(terminal.ActiveBuffer().CursorAttr().BgColour))
+
+	// colours set by the application still take precedence until they're reset
+	feed(terminal, "\x1b]4;200;rgb:ff/00/00\x07\x1b[48;5;200m")
+	assert.Equal(t, [3]float32{1, 0, 0}, [3]float32(terminal.ActiveBuffer().CursorAttr().BgColour))
+	feed(terminal, "\x1b]104\x07\x1b[48;5;200m")
+	assert.Equal(t, [3]float32{0, 1, 0}, [3]float32(terminal.ActiveBuffer().CursorAttr().BgColour))
+}
+
+func TestBoldColourComesFromConfiguredPalette(t *testing.T) {
+	conf := config.DefaultConfig
+	conf.ColourScheme.Palette = map[string]config.Colour{"1": {0, 0, 1}, "9": {0, 1, 1}}
+	terminal := New(&testPty{}, zap.NewNop().Sugar(), &conf)
+	require.Nil(t, terminal.SetSize(10, 2))
+
+	feed(terminal, "\x1b[1;31ma\x1b[38;2;0;0;255mb")
+	cells := terminal.ActiveBuffer().GetVisibleLines()[0].Cells()
+	assert.Equal(t, [3]float32{0, 1, 1}, terminal.BoldColour(cells[0].Attr()), "bold text should be brightened to the configured bright colour")
+	assert.Equal(t, [3]float32{0, 0, 1}, terminal.BoldColour(cells[1].Attr()), "a true colour equal to a configured colour shouldn't be brightened")
+}
@@ -0,0 +1,34 @@
+package terminal
+
+// controlPicture returns the glyph from the Unicode Control Pictures block (U+2400) which represents a C0 control or DEL
+func controlPicture(b rune) (rune, bool) {
+	switch {
+	case b >= 0 && b < 0x20:
+		return 0x2400 + b, true
+	case b == 0x7f:
+		return 0x2421, true
+	}
+	return 0, false
+}
+
+// showControl writes the control picture for b instead of acting on it, if show_controls is enabled.
+// Line feeds also start a new line, so the output stays readable.
+func (terminal *Terminal) showControl(b rune) bool {
+	if !terminal.config.ShowControls {
+		return false
+	}
+
+	picture, ok := controlPicture(b)
+	if !ok {
+		return false
+	}
+
+	buffer := terminal.ActiveBuffer()
+	buffer.Write(picture)
+	if b == 0x0a {
+		buffer.CarriageReturn()
+		buffer.NewLine()
+	}
+	terminal.isDirty = true
+	return true
+}
@@ -4,12 +4,16 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+
+	"github.com/liamg/aminal/config"
+	"github.com/liamg/aminal/version"
 )
 
 type csiSequenceHandler func(params []string, terminal *Terminal) error
 
 type csiMapping struct {
 	id             rune
+	intermediate   string // intermediate bytes (0x20-0x2F) which must precede the final byte
 	handler        csiSequenceHandler
 	description    string
 	expectedParams *expectedParams
@@ -25,12 +29,15 @@ var csiSequences = []csiMapping{
 	{id: 'd', handler: csiLinePositionAbsolute, expectedParams: &expectedParams{min: 0, max: 1}, description: "Line Position Absolute  [row] (default = [1,column]) (VPA)"},
 	{id: 'f', handler: csiCursorPositionHandler, description: "Horizontal and Vertical Position [row;column] (default = [1,1]) (HVP)"},
 	{id: 'g', handler: csiTabClearHandler, description: "Tab Clear (TBC)"},
-	{id: 'h', handler: csiSetModeHandler, expectedParams: &expectedParams{min: 1, max: 1}, description: "Set Mode (SM)"},
-	{id: 'l', handler: csiResetModeHandler, expectedParams: &expectedParams{min: 1, max: 1}, description: "Reset Mode (RM)"},
+	{id: 'h', handler: csiSetModeHandler, expectedParams: &expectedParams{min: 1, max: 255}, description: "Set Mode (SM)"},
+	{id: 'l', handler: csiResetModeHandler, expectedParams: &expectedParams{min: 1, max: 255}, description: "Reset Mode (RM)"},
 	{id: 'm', handler: sgrSequenceHandler, description: "Character Attributes (SGR)"},
 	{id: 'n', handler: csiDeviceStatusReportHandler, description: "Device Status Report (DSR)"},
+	{id: 'q', intermediate: " ", handler: csiSetCursorStyleHandler, expectedParams: &expectedParams{min: 0, max: 1}, description: "Set cursor style (DECSCUSR), VT520"},
 	{id: 'r', handler: csiSetMarginsHandler, expectedParams: &expectedParams{min: 0, max: 2}, description: "Set Scrolling Region [top;bottom] (default = full size of window) (DECSTBM), VT100"},
+	{id: 's', handler: csiSetLeftRightMarginsHandler, expectedParams: &expectedParams{min: 0, max: 2}, description: "Set left and right margins [left;right] (DECSLRM), VT420, or Save Cursor (SCOSC) when DECLRMM is disabled"},
 	{id: 't', handler: csiWindowManipulation, description: "Window manipulation"},
+	{id: 'u', handler: csiRestoreCursorHandler, expectedParams: &expectedParams{min: 0, max: 0}, description: "Restore Cursor (SCORC)"},
 	{id: 'A', handler: csiCursorUpHandler, description: "Cursor Up Ps Times (default = 1) (CUU)"},
 	{id: 'B', handler: csiCursorDownHandler, description: "Cursor Down Ps Times (default = 1) (CUD)"},
 	{id: 'C', handler: csiCursorForwardHandler, description: "Cursor Forward Ps Times (default = 1) (CUF)"},
@@ -45,39 +52,12 @@ var csiSequences = []csiMapping{
 	{id: 'M', handler: csiDeleteLinesHandler, description: "Delete Ps Line(s) (default = 1) (DL)"},
 	{id: 'P', handler: csiDeleteHandler, description: " Delete Ps Character(s) (default = 1) (DCH)"},
 	{id: 'S', handler: csiScrollUpHandler, description: "Scroll up Ps lines (default = 1) (SU), VT420, ECMA-48"},
+	{id: 'T', handler: csiHighlightMouseHandler, expectedParams: &expectedParams{min: 5, max: 5}, description: "Initiate highlight mouse tracking [func;startx;starty;firstrow;lastrow]"},
 	{id: 'T', handler: csiScrollDownHandler, description: "Scroll down Ps lines (default = 1) (SD), VT420"},
 	{id: 'X', handler: csiEraseCharactersHandler, description: "Erase Ps Character(s) (default = 1) (ECH"},
 	{id: '@', handler: csiInsertBlankCharactersHandler, description: "Insert Ps (Blank) Character(s) (default = 1) (ICH)"},
 }
 
-type runeRange struct {
-	min rune
-	max rune
-}
-
-var csiTerminators = runeRange{0x40, 0x7e}
-
-func loadCSI(pty chan rune) (final rune, param string, intermediate []rune) {
-	var b rune
-	param = ""
-	intermediate = []rune{}
-CSI:
-	for {
-		b = <-pty
-		switch true {
-		case b >= 0x30 && b <= 0x3F:
-			param = param + string(b)
-		case b > 0 && b <= 0x2F:
-			intermediate = append(intermediate, b)
-		case b >= csiTerminators.min && b <= csiTerminators.max:
-			final = b
-			break CSI
-		}
-	}
-
-	return final, param, intermediate
-}
-
 func splitParams(paramString string) []string {
 	params := strings.Split(paramString, ";")
 	if paramString == "" {
@@ -86,18 +66,13 @@ func splitParams(paramString string) []string {
 	return params
 }
 
-func csiHandler(pty chan rune, terminal *Terminal) error {
-	final, param, intermediate := loadCSI(pty)
-
-	// process intermediate control codes before the CSI
-	for _, b := range intermediate {
-		terminal.processRune(b)
-	}
-
+// csiHandler handles a CSI with the parameter bytes param (0x30-0x3F) and intermediate bytes (0x20-0x2F) before its
+// final byte
+func csiHandler(param string, intermediates string, final rune, terminal *Terminal) error {
 	params := splitParams(param)
 
 	for _, sequence := range csiSequences {
-		if sequence.id == final {
+		if sequence.id == final && sequence.intermediate == intermediates {
 			if sequence.expectedParams != nil && (uint8(len(params)) < sequence.expectedParams.min || uint8(len(params)) > sequence.expectedParams.max) {
 				continue
 			}
@@ -108,24 +83,30 @@ func csiHandler(pty chan rune, terminal *Terminal) error {
 		}
 	}
 
-	return fmt.Errorf("Unknown CSI control sequence: 0x%02X (ESC[%s%s)", final, param, string(final))
+	return fmt.Errorf("Unknown CSI control sequence: 0x%02X (ESC[%s%s%s)", final, param, intermediates, string(final))
 }
 
-func csiSendDeviceAttributesHandler(params []string, terminal *Terminal) error {
-
-	// we are VT100
-	// for DA1 we'll respond ?1;2
-	// for DA2 we'll respond >0;0;0
+// primaryDeviceAttributes is the reply to Primary DA: a VT220 (62) with 132 columns (1), sixel graphics (4) and ANSI
+// colour (22). Applications use the features listed without checking any further, so only ones which work are listed.
+const primaryDeviceAttributes = "\x1b[?62;1;4;22c"
 
-	response := "?1;2"
-
-	if len(params) > 0 && len(params[0]) > 0 && params[0][0] == '>' {
-		response = ">0;0;0"
+// csiSendDeviceAttributesHandler answers Primary DA (CSI c), Secondary DA (CSI > c), which reports the version, and
+// Tertiary DA (CSI = c), which reports a unit id of zero
+func csiSendDeviceAttributesHandler(params []string, terminal *Terminal) error {
+	param := ""
+	if len(params) > 0 {
+		param = params[0]
 	}
 
-	_ = terminal.Write([]byte("\x1b[" + response + "c"))
-
-	return nil
+	switch param {
+	case "", "0":
+		return terminal.Write([]byte(primaryDeviceAttributes))
+	case ">", ">0":
+		return terminal.Write([]byte(fmt.Sprintf("\x1b[>0;%d;0c", version.Number())))
+	case "=", "=0":
+		return terminal.Write([]byte("\x1bP!|00000000\x1b\\"))
+	}
+	return fmt.Errorf("Unknown Device Attributes request: %s", param)
 }
 
 func csiDeviceStatusReportHandler(params []string, terminal *Terminal) error {
@@ -134,15 +115,34 @@ func csiDeviceStatusReportHandler(params []string, terminal *Terminal) error {
 		return fmt.Errorf("Missing Device Status Report identifier")
 	}
 
+	// after writing in the last column the cursor waits beyond it to wrap, but it's reported in the last column
+	line := terminal.ActiveBuffer().CursorLine() + 1
+	col := terminal.ActiveBuffer().CursorColumn() + 1
+	if width := terminal.ActiveBuffer().Width(); col > width && width > 0 {
+		col = width
+	}
+
 	switch params[0] {
 	case "5":
 		_ = terminal.Write([]byte("\x1b[0n")) // everything is cool
 	case "6": // report cursor position
-		_ = terminal.Write([]byte(fmt.Sprintf(
-			"\x1b[%d;%dR",
-			terminal.ActiveBuffer().CursorLine()+1,
-			terminal.ActiveBuffer().CursorColumn()+1,
-		)))
+		_ = terminal.Write([]byte(fmt.Sprintf("\x1b[%d;%dR", line, col)))
+	case "?6": // extended cursor position report (DECXCPR), which includes the page - there is only ever one
+		_ = terminal.Write([]byte(fmt.Sprintf("\x1b[?%d;%d;1R", line, col)))
+	case "?15": // printer status
+		_ = terminal.Write([]byte("\x1b[?13n")) // no printer
+	case "?25": // user defined keys
+		_ = terminal.Write([]byte("\x1b[?20n")) // unlocked, though there aren't any
+	case "?26": // keyboard status
+		_ = terminal.Write([]byte("\x1b[?27;1;0;0n")) // North American, ready, LK201
+	case "?55": // locator status
+		_ = terminal.Write([]byte("\x1b[?50n")) // no locator
+	case "?56": // locator type
+		_ = terminal.Write([]byte("\x1b[?57;0n")) // can't identify
+	case "?75": // data integrity
+		_ = terminal.Write([]byte("\x1b[?70n")) // ready, no errors
+	case "?85": // multiple session status
+		_ = terminal.Write([]byte("\x1b[?83n")) // not configured for sessions
 	default:
 		return fmt.Errorf("Unknown Device Status Report identifier: %s", params[0])
 	}
@@ -150,6 +150,27 @@ func csiDeviceStatusReportHandler(params []string, terminal *Terminal) error {
 	return nil
 }
 
+// csiHighlightMouseHandler handles CSI func ; startx ; starty ; firstrow ; lastrow T. As in xterm, missing or 0
+// coordinates default to the first cell, and a missing or 0 last row to the one below the screen, so the highlight can
+// reach every row.
+func csiHighlightMouseHandler(params []string, terminal *Terminal) error {
+	_, rows := terminal.GetSize()
+	values := []int{0, 1, 1, 1, rows + 1}
+	for i, param := range params {
+		if param == "" {
+			continue
+		}
+		value, err := strconv.Atoi(param)
+		if err != nil {
+			return fmt.Errorf("Invalid highlight mouse tracking parameter: %s", param)
+		}
+		if value != 0 || i == 0 {
+			values[i] = value
+		}
+	}
+	return terminal.StartMouseHighlight(values[0], values[1], values[2], values[3], values[4])
+}
+
 func csiCursorUpHandler(params []string, terminal *Terminal) error {
 	distance := 1
 	if len(params) > 0 {
@@ -398,6 +419,41 @@ func csiSetMarginsHandler(params []string, terminal *Terminal) error {
 	return nil
 }
 
+func csiSetLeftRightMarginsHandler(params []string, terminal *Terminal) error {
+	if !terminal.terminalState.LeftRightMarginMode {
+		terminal.ActiveBuffer().SaveCursor()
+		return nil
+	}
+
+	left := 1
+	right := int(terminal.ActiveBuffer().ViewWidth())
+
+	if len(params) > 0 {
+		var err error
+		left, err = strconv.Atoi(params[0])
+		if err != nil || left < 1 {
+			left = 1
+		}
+
+		if len(params) > 1 {
+			var err error
+			right, err = strconv.Atoi(params[1])
+			if err != nil || right > int(terminal.ActiveBuffer().ViewWidth()) || right < 1 {
+				right = int(terminal.ActiveBuffer().ViewWidth())
+			}
+		}
+	}
+
+	if left >= right {
+		return fmt.Errorf("Invalid left and right margins %d;%d", left, right)
+	}
+
+	terminal.terminalState.SetHorizontalMargins(uint(left-1), uint(right-1))
+	terminal.ActiveBuffer().SetPosition(0, 0)
+
+	return nil
+}
+
 func csiEraseCharactersHandler(params []string, terminal *Terminal) error {
 	count := 1
 	if len(params) > 0 {
@@ -415,15 +471,52 @@ func csiEraseCharactersHandler(params []string, terminal *Terminal) error {
 
 func csiResetModeHandler(params []string, terminal *Terminal) error {
 	terminal.ActiveBuffer().ClearSelection()
-	return csiSetMode(strings.Join(params, ""), false, terminal)
+	return csiSetModes(params, false, terminal)
 }
 
 func csiSetModeHandler(params []string, terminal *Terminal) error {
-	return csiSetMode(strings.Join(params, ""), true, terminal)
+	return csiSetModes(params, true, terminal)
+}
+
+// csiSetModes applies each mode in a sequence such as CSI ? 1002 ; 1006 h, where the private marker applies to all of them
+func csiSetModes(params []string, enabled bool, terminal *Terminal) error {
+	private := strings.HasPrefix(params[0], "?")
+	var modeErr error
+	for _, param := range params {
+		mode := strings.TrimPrefix(param, "?")
+		if private {
+			mode = "?" + mode
+		}
+		if err := csiSetMode(mode, enabled, terminal); err != nil && modeErr == nil {
+			modeErr = err
+		}
+	}
+	return modeErr
+}
+
+func csiRestoreCursorHandler(params []string, terminal *Terminal) error {
+	terminal.ActiveBuffer().RestoreCursor()
+	return nil
 }
 
+// csiWindowManipulation handles CSI Ps ; Ps ; Ps t. Only the title stack is supported, as the other operations let
+// applications move and resize the window, or report its title back as if it had been typed.
 func csiWindowManipulation(params []string, terminal *Terminal) error {
-	return fmt.Errorf("Window manipulation is not yet supported")
+	which := ""
+	if len(params) > 1 {
+		which = params[1]
+	}
+
+	if len(params) > 0 {
+		switch params[0] {
+		case "22":
+			return terminal.pushTitle(which)
+		case "23":
+			return terminal.popTitle(which)
+		}
+	}
+
+	return fmt.Errorf("Window manipulation is not yet supported: CSI %s t", strings.Join(params, ";"))
 }
 
 func csiLinePositionAbsolute(params []string, terminal *Terminal) error {
@@ -515,3 +608,58 @@ func csiEraseInLineHandler(params []string, terminal *Terminal) error {
 	}
 	return nil
 }
+
+// CSI Ps SP q
+func csiSetCursorStyleHandler(params []string, terminal *Terminal) error {
+	n := "0"
+	if len(params) > 0 && params[0] != "" {
+		n = params[0]
+	}
+
+	switch n {
+	case "0":
+		terminal.SetCursorStyle(defaultCursorShape(terminal.config), true)
+	case "1":
+		terminal.SetCursorStyle(config.CursorShapeBlock, true)
+	case "2":
+		terminal.SetCursorStyle(config.CursorShapeBlock, false)
+	case "3":
+		terminal.SetCursorStyle(config.CursorShapeUnderline, true)
+	case "4":
+		terminal.SetCursorStyle(config.CursorShapeUnderline, false)
+	case "5":
+		terminal.SetCursorStyle(config.CursorShapeBar, true)
+	case "6":
+		terminal.SetCursorStyle(config.CursorShapeBar, false)
+	default:
+		return fmt.Errorf("Unsupported DECSCUSR: CSI %s SP q", n)
+	}
+
+	return nil
+}
+
+// cursorStyleParam returns the DECSCUSR parameter which sets the current cursor style
+func cursorStyleParam(modes Modes) int {
+	n := 1
+	switch modes.CursorShape {
+	case config.CursorShapeUnderline:
+		n = 3
+	case config.CursorShapeBar:
+		n = 5
+	}
+	if !modes.BlinkingCursor {
+		n++
+	}
+	return n
+}
+
+// requestStatusStringHandler answers DECRQSS, DCS $ q Pt ST, which asks for the control sequence that sets the current
+// value of a setting. Only the cursor style (DECSCUSR) is reported; anything else is answered as invalid.
+func requestStatusStringHandler(setting string, terminal *Terminal) error {
+	switch setting {
+	case " q":
+		return terminal.Write([]byte(fmt.Sprintf("\x1bP1$r%d q\x1b\\", cursorStyleParam(terminal.modes))))
+	default:
+		return terminal.Write([]byte("\x1bP0$r\x1b\\"))
+	}
+}
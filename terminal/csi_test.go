@@ -0,0 +1,253 @@
+package terminal
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/liamg/aminal/config"
+	"github.com/liamg/aminal/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetCursorStyle(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 5)
+
+	assert.Equal(t, config.CursorShapeBlock, terminal.Modes().CursorShape)
+
+	tests := []struct {
+		sequence string
+		shape    config.CursorShape
+		blinking bool
+	}{
+		{"\x1b[6 q", config.CursorShapeBar, false},
+		{"\x1b[5 q", config.CursorShapeBar, true},
+		{"\x1b[4 q", config.CursorShapeUnderline, false},
+		{"\x1b[3 q", config.CursorShapeUnderline, true},
+		{"\x1b[2 q", config.CursorShapeBlock, false},
+		{"\x1b[1 q", config.CursorShapeBlock, true},
+		{"\x1b[6 q\x1b[ q", config.CursorShapeBlock, true},
+	}
+
+	for _, test := range tests {
+		feed(terminal, test.sequence)
+		assert.Equal(t, test.shape, terminal.Modes().CursorShape, "%q", test.sequence)
+		assert.Equal(t, test.blinking, terminal.Modes().BlinkingCursor, "%q", test.sequence)
+	}
+
+	// the default is the configured shape
+	terminal, _ = newTestTerminal(t, 20, 5)
+	terminal.config.CursorStyle = config.CursorShapeUnderline
+	feed(terminal, "\x1b[6 q\x1b[0 q")
+	assert.Equal(t, config.CursorShapeUnderline, terminal.Modes().CursorShape)
+	assert.True(t, terminal.Modes().BlinkingCursor)
+
+	// the intermediate space must not be printed
+	assert.Equal(t, []string{""}, visibleText(terminal))
+	assert.Equal(t, uint16(0), terminal.ActiveBuffer().CursorColumn())
+}
+
+func TestEraseUsesCurrentBackground(t *testing.T) {
+	blue := config.DefaultConfig.ColourScheme.Blue
+	background := config.DefaultConfig.ColourScheme.Background
+
+	tests := []struct {
+		name     string
+		sequence string
+		erased   [][2]uint16 // col, row
+		kept     [][2]uint16
+	}{
+		{"ED 2", "\x1b[2J", [][2]uint16{{0, 0}, {9, 2}, {19, 4}}, nil},
+		{"ED 0", "\x1b[3;5H\x1b[J", [][2]uint16{{4, 2}, {19, 2}, {0, 4}}, [][2]uint16{{3, 2}, {0, 0}}},
+		{"ED 1", "\x1b[3;5H\x1b[1J", [][2]uint16{{0, 0}, {4, 2}}, [][2]uint16{{5, 2}, {0, 4}}},
+		{"EL 2", "\x1b[2;1H\x1b[2K", [][2]uint16{{0, 1}, {19, 1}}, [][2]uint16{{0, 0}, {0, 2}}},
+		{"EL 0", "\x1b[2;5H\x1b[K", [][2]uint16{{4, 1}, {19, 1}}, [][2]uint16{{3, 1}}},
+		{"EL 1", "\x1b[2;5H\x1b[1K", [][2]uint16{{0, 1}, {4, 1}}, [][2]uint16{{5, 1}}},
+		{"ECH", "\x1b[2;3H\x1b[4X", [][2]uint16{{2, 1}, {5, 1}}, [][2]uint16{{1, 1}, {6, 1}}},
+		{"DCH", "\x1b[2;3H\x1b[4P", [][2]uint16{{16, 1}, {19, 1}}, [][2]uint16{{15, 1}}},
+		{"SU", "\x1b[S", [][2]uint16{{0, 4}, {19, 4}}, [][2]uint16{{0, 3}}},
+		{"IND at bottom", "\x1b[5;1H\n", [][2]uint16{{0, 4}, {19, 4}}, [][2]uint16{{0, 3}}},
+		{"IL", "\x1b[2;4r\x1b[2;1H\x1b[L", [][2]uint16{{0, 1}, {19, 1}}, [][2]uint16{{0, 0}, {0, 2}, {0, 4}}},
+	}
+
+	for _, test := range tests {
+		terminal, _ := newTestTerminal(t, 20, 5)
+		for i := 0; i < 5; i++ {
+			feed(terminal, fmt.Sprintf("\x1b[%d;1H%s", i+1, strings.Repeat("x", 20)))
+		}
+
+		feed(terminal, "\x1b[44m"+test.sequence+"\x1b[0m")
+
+		for _, pos := range test.erased {
+			cell := terminal.GetCell(pos[0], pos[1])
+			require.NotNil(t, cell, "%s: cell %d,%d", test.name, pos[0], pos[1])
+			assert.Equal(t, [3]float32(blue), cell.Bg(), "%s: cell %d,%d should be erased", test.name, pos[0], pos[1])
+			assert.Equal(t, rune(0), cell.Rune(), "%s: cell %d,%d should be erased", test.name, pos[0], pos[1])
+		}
+		for _, pos := range test.kept {
+			cell := terminal.GetCell(pos[0], pos[1])
+			require.NotNil(t, cell, "%s: cell %d,%d", test.name, pos[0], pos[1])
+			assert.Equal(t, [3]float32(background), cell.Bg(), "%s: cell %d,%d should be untouched", test.name, pos[0], pos[1])
+			assert.Equal(t, 'x', cell.Rune(), "%s: cell %d,%d should be untouched", test.name, pos[0], pos[1])
+		}
+	}
+}
+
+func screenText(terminal *Terminal) []string {
+	lines := visibleText(terminal)
+	for i := range lines {
+		lines[i] = strings.Replace(lines[i], "\x00", " ", -1)
+	}
+	return lines
+}
+
+func TestLeftRightMarginsWrapText(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 10, 3)
+
+	feed(terminal, "\x1b[?69h\x1b[3;6s")
+	assert.Equal(t, uint16(0), terminal.ActiveBuffer().CursorColumn())
+
+	feed(terminal, "\x1b[1;3Habcdefg\rx")
+	assert.Equal(t, []string{"  abcd", "  xfg"}, screenText(terminal))
+
+	// text outside the margins wraps at the edge of the screen as usual
+	feed(terminal, "\x1b[3;8Hxyz")
+	assert.Equal(t, uint16(2), terminal.ActiveBuffer().CursorLine())
+	assert.Equal(t, uint16(10), terminal.ActiveBuffer().CursorColumn())
+}
+
+func TestLeftRightMarginsRestrictEditing(t *testing.T) {
+	tests := []struct {
+		name     string
+		sequence string
+		expected []string
+	}{
+		{"ICH", "\x1b[1;4H\x1b[2@", []string{"012  36789", "bbbbbbbbbb", "cccccccccc", "dddddddddd", "eeeeeeeeee"}},
+		{"DCH", "\x1b[1;4H\x1b[2P", []string{"0125  6789", "bbbbbbbbbb", "cccccccccc", "dddddddddd", "eeeeeeeeee"}},
+		{"IL", "\x1b[2;4H\x1b[L", []string{"0123456789", "bb    bbbb", "ccbbbbcccc", "ddccccdddd", "eeddddeeee"}},
+		{"DL", "\x1b[3;4H\x1b[M", []string{"0123456789", "bbbbbbbbbb", "ccddddcccc", "ddeeeedddd", "ee    eeee"}},
+		{"DL outside margins", "\x1b[3;8H\x1b[M", []string{"0123456789", "bbbbbbbbbb", "cccccccccc", "dddddddddd", "eeeeeeeeee"}},
+		{"SU", "\x1b[S", []string{"01bbbb6789", "bbccccbbbb", "ccddddcccc", "ddeeeedddd", "ee    eeee"}},
+		{"IND at bottom", "\x1b[5;4H\n", []string{"01bbbb6789", "bbccccbbbb", "ccddddcccc", "ddeeeedddd", "ee    eeee"}},
+		{"DECLRMM reset", "\x1b[?69l\x1b[1;4H\x1b[2P", []string{"01256789", "bbbbbbbbbb", "cccccccccc", "dddddddddd", "eeeeeeeeee"}},
+	}
+
+	for _, test := range tests {
+		terminal, _ := newTestTerminal(t, 10, 5)
+		feed(terminal, "0123456789")
+		for i := 1; i < 5; i++ {
+			feed(terminal, fmt.Sprintf("\x1b[%d;1H%s", i+1, strings.Repeat(string('a'+rune(i)), 10)))
+		}
+
+		feed(terminal, "\x1b[?69h\x1b[3;6s"+test.sequence)
+		assert.Equal(t, test.expected, screenText(terminal), test.name)
+	}
+}
+
+func TestSaveCursorWithoutLeftRightMargins(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 10, 5)
+
+	feed(terminal, "\x1b[2;3H\x1b[s\x1b[H\x1b[u")
+	assert.Equal(t, uint16(2), terminal.ActiveBuffer().CursorColumn())
+	assert.Equal(t, uint16(1), terminal.ActiveBuffer().CursorLine())
+}
+
+func TestIndexSequencesHonourScrollRegion(t *testing.T) {
+	tests := []struct {
+		name     string
+		sequence string
+		expected []string
+		col      uint16
+		line     uint16
+	}{
+		{"IND inside region", "\x1b[3;3H\x1bD", []string{"aaaaa", "bbbbb", "ccccc", "ddddd", "eeeee"}, 2, 3},
+		{"IND at bottom margin", "\x1b[4;3H\x1bD", []string{"aaaaa", "ccccc", "ddddd", "", "eeeee"}, 2, 3},
+		{"IND below region", "\x1b[5;3H\x1bD", []string{"aaaaa", "bbbbb", "ccccc", "ddddd", "eeeee"}, 2, 4},
+		{"RI inside region", "\x1b[3;3H\x1bM", []string{"aaaaa", "bbbbb", "ccccc", "ddddd", "eeeee"}, 2, 1},
+		{"RI at top margin", "\x1b[2;3H\x1bM", []string{"aaaaa", "", "bbbbb", "ccccc", "eeeee"}, 2, 1},
+		{"RI above region", "\x1b[1;3H\x1bM", []string{"aaaaa", "bbbbb", "ccccc", "ddddd", "eeeee"}, 2, 0},
+		{"NEL inside region", "\x1b[3;3H\x1bE", []string{"aaaaa", "bbbbb", "ccccc", "ddddd", "eeeee"}, 0, 3},
+		{"NEL at bottom margin", "\x1b[4;3H\x1bE", []string{"aaaaa", "ccccc", "ddddd", "", "eeeee"}, 0, 3},
+	}
+
+	for _, test := range tests {
+		terminal, _ := newTestTerminal(t, 5, 5)
+		for i := 0; i < 5; i++ {
+			feed(terminal, fmt.Sprintf("\x1b[%d;1H%s", i+1, strings.Repeat(string('a'+rune(i)), 5)))
+		}
+
+		feed(terminal, "\x1b[2;4r"+test.sequence)
+		assert.Equal(t, test.expected, screenText(terminal), test.name)
+		assert.Equal(t, test.col, terminal.ActiveBuffer().CursorColumn(), test.name)
+		assert.Equal(t, test.line, terminal.ActiveBuffer().CursorLine(), test.name)
+	}
+}
+
+func TestDeviceStatusReports(t *testing.T) {
+	tests := []struct {
+		sequence string
+		reply    string
+	}{
+		{"\x1b[5n", "\x1b[0n"},
+		{"\x1b[3;5H\x1b[6n", "\x1b[3;5R"},
+		{"\x1b[3;5H\x1b[?6n", "\x1b[?3;5;1R"},
+		{"\x1b[?15n", "\x1b[?13n"},
+		{"\x1b[?26n", "\x1b[?27;1;0;0n"},
+		{"\x1b[?55n", "\x1b[?50n"},
+		{"\x1b[1;20H\x1b[6n", "\x1b[1;20R"},
+		{"\x1b[2;1H" + strings.Repeat("x", 20) + "\x1b[6n", "\x1b[2;20R"},
+		{"\x1b[2;4r\x1b[?6h\x1b[2;3H\x1b[6n", "\x1b[2;3R"},
+	}
+
+	for _, test := range tests {
+		terminal, pty := newTestTerminal(t, 20, 5)
+		feed(terminal, test.sequence)
+		assert.Equal(t, test.reply, pty.output.String(), "%q", test.sequence)
+	}
+}
+
+func TestRequestCursorStyle(t *testing.T) {
+	tests := []struct {
+		sequence string
+		reply    string
+	}{
+		{"", "\x1bP1$r2 q\x1b\\"},
+		{"\x1b[1 q", "\x1bP1$r1 q\x1b\\"},
+		{"\x1b[4 q", "\x1bP1$r4 q\x1b\\"},
+		{"\x1b[5 q", "\x1bP1$r5 q\x1b\\"},
+	}
+
+	for _, test := range tests {
+		terminal, pty := newTestTerminal(t, 20, 5)
+		feed(terminal, test.sequence+"\x1bP$q q\x1b\\")
+		assert.Equal(t, test.reply, pty.output.String(), "%q", test.sequence)
+	}
+
+	terminal, pty := newTestTerminal(t, 20, 5)
+	feed(terminal, "\x1bP$qm\x1b\\")
+	assert.Equal(t, "\x1bP0$r\x1b\\", pty.output.String(), "settings which aren't reported are invalid")
+}
+
+func TestDeviceAttributes(t *testing.T) {
+	defer func(v string) { version.Version = v }(version.Version)
+	version.Version = "v0.9.12"
+
+	tests := []struct {
+		sequence string
+		reply    string
+	}{
+		{"\x1b[c", "\x1b[?62;1;4;22c"},
+		{"\x1b[0c", "\x1b[?62;1;4;22c"},
+		{"\x1b[>c", "\x1b[>0;912;0c"},
+		{"\x1b[>0c", "\x1b[>0;912;0c"},
+		{"\x1b[=c", "\x1bP!|00000000\x1b\\"},
+		{"\x1b[1c", ""},
+	}
+
+	for _, test := range tests {
+		terminal, pty := newTestTerminal(t, 20, 5)
+		feed(terminal, test.sequence)
+		assert.Equal(t, test.reply, pty.output.String(), "%q", test.sequence)
+	}
+}
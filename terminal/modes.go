@@ -73,15 +73,28 @@ func csiSetMode(modeStr string, enabled bool, terminal *Terminal) error {
 		terminal.modes.BlinkingCursor = enabled
 	case "?25":
 		terminal.modes.ShowCursor = enabled
-	case "?47", "?1047":
+	case "?47":
+		// legacy alternate screen, which doesn't save or restore the cursor
 		if enabled {
 			terminal.UseAltBuffer()
 		} else {
 			terminal.UseMainBuffer()
 		}
-	case "?1000", "?1006;1000", "?10061000": // ?10061000 seen from htop
+	case "?1047":
+		// as 47, but the alternate screen is cleared when leaving it
+		if enabled {
+			terminal.UseAltBuffer()
+		} else {
+			if terminal.ActiveBuffer() == terminal.buffers[AltBuffer] {
+				terminal.ActiveBuffer().EraseDisplay()
+			}
+			terminal.UseMainBuffer()
+		}
+	case "?69":
+		// DECLRMM - allow left and right margins to be set with DECSLRM
+		terminal.SetLeftRightMarginMode(enabled)
+	case "?1000":
 		// enable mouse tracking
-		// 1000 refers to ext mode for extended mouse click area - otherwise only x <= 255-31
 		if enabled {
 			terminal.logger.Infof("Turning on VT200 mouse mode")
 			terminal.SetMouseMode(MouseModeVT200)
@@ -89,6 +102,39 @@ func csiSetMode(modeStr string, enabled bool, terminal *Terminal) error {
 			terminal.logger.Infof("Turning off VT200 mouse mode")
 			terminal.SetMouseMode(MouseModeNone)
 		}
+	case "?1001":
+		if enabled {
+			terminal.logger.Infof("Turning on VT200 highlight mouse mode")
+			terminal.SetMouseMode(MouseModeVT200Highlight)
+		} else {
+			terminal.logger.Infof("Turning off VT200 highlight mouse mode")
+			terminal.SetMouseMode(MouseModeNone)
+		}
+	case "?1002":
+		if enabled {
+			terminal.logger.Infof("Turning on button event mouse mode")
+			terminal.SetMouseMode(MouseModeButtonEvent)
+		} else {
+			terminal.logger.Infof("Turning off button event mouse mode")
+			terminal.SetMouseMode(MouseModeNone)
+		}
+	case "?1003":
+		if enabled {
+			terminal.logger.Infof("Turning on any event mouse mode")
+			terminal.SetMouseMode(MouseModeAnyEvent)
+		} else {
+			terminal.logger.Infof("Turning off any event mouse mode")
+			terminal.SetMouseMode(MouseModeNone)
+		}
+	case "?1004":
+		terminal.SetFocusReportingMode(enabled)
+	case "?1006":
+		// SGR encoding, which isn't limited to x <= 255-32 and identifies the button on release
+		if enabled {
+			terminal.SetMouseExtMode(MouseExtSGR)
+		} else {
+			terminal.SetMouseExtMode(MouseExtNone)
+		}
 	case "?1048":
 		if enabled {
 			terminal.ActiveBuffer().SaveCursor()
@@ -96,10 +142,14 @@ func csiSetMode(modeStr string, enabled bool, terminal *Terminal) error {
 			terminal.ActiveBuffer().RestoreCursor()
 		}
 	case "?1049":
+		// save the cursor and switch to a cleared alternate screen, restoring the cursor on the way back
 		if enabled {
+			terminal.ActiveBuffer().SaveCursor()
 			terminal.UseAltBuffer()
+			terminal.ActiveBuffer().EraseDisplay()
 		} else {
 			terminal.UseMainBuffer()
+			terminal.ActiveBuffer().RestoreCursor()
 		}
 	case "?2004":
 		terminal.SetBracketedPasteMode(enabled)
@@ -0,0 +1,115 @@
+package terminal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func assertCursor(t *testing.T, terminal *Terminal, col uint16, line uint16, msg string) {
+	assert.Equal(t, col, terminal.ActiveBuffer().CursorColumn(), msg)
+	assert.Equal(t, line, terminal.ActiveBuffer().CursorLine(), msg)
+}
+
+func TestAlternateScreenModes(t *testing.T) {
+	tests := []struct {
+		mode          string
+		clearsOnEnter bool
+		clearsOnExit  bool
+		savesCursor   bool
+	}{
+		{"47", false, false, false},
+		{"1047", false, true, false},
+		{"1049", true, false, true},
+	}
+
+	for _, test := range tests {
+		terminal, _ := newTestTerminal(t, 10, 3)
+		set := "\x1b[?" + test.mode + "h"
+		reset := "\x1b[?" + test.mode + "l"
+
+		// leave something on the alternate screen from a previous visit
+		feed(terminal, "\x1b[?47hold\x1b[?47l\x1b[Hmain\x1b[3;5H")
+
+		feed(terminal, set)
+		assert.Equal(t, terminal.buffers[AltBuffer], terminal.ActiveBuffer(), test.mode)
+		assertCursor(t, terminal, 4, 2, test.mode+" keeps the cursor position on entry")
+		if test.clearsOnEnter {
+			assert.Equal(t, "", screenText(terminal)[0], test.mode)
+		} else {
+			assert.Equal(t, "old", screenText(terminal)[0], test.mode)
+		}
+
+		feed(terminal, "\x1b[Halt\x1b[2;2H")
+		feed(terminal, reset)
+		assert.Equal(t, terminal.buffers[MainBuffer], terminal.ActiveBuffer(), test.mode)
+		assert.Equal(t, "main", screenText(terminal)[0], test.mode)
+		if test.savesCursor {
+			assertCursor(t, terminal, 4, 2, test.mode+" restores the cursor")
+		} else {
+			assertCursor(t, terminal, 1, 1, test.mode+" doesn't restore the cursor")
+		}
+
+		// look at the alternate screen again without clearing it
+		feed(terminal, "\x1b[?47h")
+		if test.clearsOnExit {
+			assert.Equal(t, "", screenText(terminal)[0], test.mode)
+		} else {
+			assert.Equal(t, "alt", screenText(terminal)[0], test.mode)
+		}
+	}
+}
+
+func TestAlternateScreenHasNoScrollback(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 10, 3)
+	feed(terminal, "1\r\n2\r\n3\r\n4\r\n5")
+	terminal.ScreenScrollUp(2)
+	require.Equal(t, uint(2), terminal.GetScrollOffset())
+
+	feed(terminal, "\x1b[?1049h")
+	assert.Equal(t, uint(0), terminal.GetScrollOffset())
+	feed(terminal, "a\r\nb\r\nc\r\nd\r\ne")
+	assert.Equal(t, 3, terminal.ActiveBuffer().Height())
+	terminal.ScreenScrollUp(2)
+	assert.Equal(t, uint(0), terminal.GetScrollOffset())
+	assert.Equal(t, []string{"c", "d", "e"}, screenText(terminal))
+
+	feed(terminal, "\x1b[?1049l")
+	assert.Equal(t, 5, terminal.ActiveBuffer().Height())
+	assert.Equal(t, []string{"3", "4", "5"}, screenText(terminal))
+}
+
+func TestMainScreenIsReflowedWhileAlternateScreenIsActive(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 10, 3)
+	feed(terminal, "abcdefghij\r\n$ ")
+
+	feed(terminal, "\x1b[?1049h")
+	require.Nil(t, terminal.SetSize(5, 3))
+	feed(terminal, "\x1b[?1049l")
+
+	assert.Equal(t, []string{"abcde", "fghij", "$"}, screenText(terminal))
+	assert.Equal(t, uint16(2), terminal.ActiveBuffer().CursorColumn(), "the cursor should be restored after the prompt")
+	assert.Equal(t, uint16(2), terminal.ActiveBuffer().CursorLine())
+
+	feed(terminal, "X")
+	assert.Equal(t, []string{"abcde", "fghij", "$ X"}, screenText(terminal))
+}
+
+func TestFocusReporting(t *testing.T) {
+	terminal, pty := newTestTerminal(t, 10, 3)
+
+	assert.Nil(t, terminal.ReportFocus(true))
+	assert.Nil(t, terminal.ReportFocus(false))
+	assert.Equal(t, "", pty.output.String(), "nothing is reported until the mode is set")
+
+	feed(terminal, "\x1b[?1004h")
+	assert.Nil(t, terminal.ReportFocus(false))
+	assert.Nil(t, terminal.ReportFocus(true))
+	assert.Equal(t, "\x1b[O\x1b[I", pty.output.String())
+
+	pty.output.Reset()
+	feed(terminal, "\x1b[?1004l")
+	assert.Nil(t, terminal.ReportFocus(true))
+	assert.Equal(t, "", pty.output.String())
+}
@@ -0,0 +1,234 @@
+package terminal
+
+import (
+	"fmt"
+
+	"github.com/liamg/aminal/buffer"
+)
+
+// MouseExtMode is the encoding used for mouse reports
+type MouseExtMode uint
+
+const (
+	MouseExtNone MouseExtMode = iota // legacy encoding: CSI M Cb Cx Cy, with each value added to 32
+	MouseExtSGR                      // SGR encoding (1006): CSI < Cb ; Cx ; Cy M, with a final m for releases
+)
+
+// MouseButton is a mouse button as encoded in the low bits of Cb
+type MouseButton uint8
+
+const (
+	MouseButtonLeft   MouseButton = 0
+	MouseButtonMiddle MouseButton = 1
+	MouseButtonRight  MouseButton = 2
+	MouseButtonNone   MouseButton = 3 // motion with no button held, and every release in the legacy encoding
+
+	// the wheel is reported as presses of buttons 4 and 5, which have 64 added to them, and never released
+	MouseButtonWheelUp   MouseButton = 64
+	MouseButtonWheelDown MouseButton = 65
+)
+
+type MouseAction uint8
+
+const (
+	MousePress MouseAction = iota
+	MouseRelease
+	MouseMotion
+)
+
+// MouseModifiers are the modifier keys held during a mouse event, as encoded in Cb
+type MouseModifiers uint8
+
+const (
+	MouseModShift   MouseModifiers = 4
+	MouseModMeta    MouseModifiers = 8
+	MouseModControl MouseModifiers = 16
+)
+
+const mouseMotionFlag = 32
+
+// mouseHighlight is the state of highlight tracking (1001), in which the application decides which part of the screen
+// may be highlighted by dragging with the left button. Positions are 1-indexed, as in the sequences.
+type mouseHighlight struct {
+	waiting  bool // a left press has been reported, and other events are ignored until the application replies
+	tracking bool // the region from start to end is highlighted until the button is released
+	startX   int
+	startY   int
+	endX     int
+	endY     int
+	firstRow int
+	lastRow  int
+}
+
+// SetMouseExtMode sets the encoding used for mouse reports
+func (terminal *Terminal) SetMouseExtMode(mode MouseExtMode) {
+	terminal.mouseExtMode = mode
+}
+
+// ReportMouse sends a mouse event to the application, if the current mouse mode asks for events of this kind.
+// x and y are the 1-indexed column and row of the cell under the mouse.
+func (terminal *Terminal) ReportMouse(button MouseButton, action MouseAction, mods MouseModifiers, x int, y int) error {
+	// the buttons are tracked in every mode, so that motion is reported as a drag if the application enables
+	// button event tracking while a button is held
+	wheel := button == MouseButtonWheelUp || button == MouseButtonWheelDown
+	switch {
+	case wheel:
+		// the wheel is never released, so isn't held
+	case action == MousePress:
+		terminal.mouseButtonHeld = true
+		terminal.mouseButton = button
+	case action == MouseRelease:
+		terminal.mouseButtonHeld = false
+	}
+
+	mode := terminal.mouseMode
+	if mode == MouseModeNone || wheel && mode == MouseModeX10 {
+		return nil
+	}
+	if mode == MouseModeVT200Highlight {
+		if handled, err := terminal.reportHighlightMouse(button, action, x, y); handled {
+			return err
+		}
+	}
+
+	switch action {
+	case MouseRelease:
+		if mode == MouseModeX10 {
+			return nil
+		}
+	case MouseMotion:
+		// motion is only reported when it moves into a different cell
+		if x == terminal.mouseX && y == terminal.mouseY {
+			return nil
+		}
+		if terminal.mouseButtonHeld {
+			if mode != MouseModeButtonEvent && mode != MouseModeAnyEvent {
+				return nil
+			}
+			button = terminal.mouseButton
+		} else {
+			if mode != MouseModeAnyEvent {
+				return nil
+			}
+			button = MouseButtonNone
+		}
+	}
+
+	terminal.mouseX = x
+	terminal.mouseY = y
+
+	packet := terminal.encodeMouse(button, action, mods, x, y)
+	terminal.logger.Debugf("Sending mouse packet: %q", packet)
+	return terminal.Write([]byte(packet))
+}
+
+// reportHighlightMouse handles a mouse event during highlight tracking, returning false if it's reported as in VT200
+// mode. The press of the left button is reported as usual, after which events are ignored until the application
+// replies with CSI func ; startx ; starty ; firstrow ; lastrow T. If func is non-zero, dragging then highlights from
+// the start position, and the region is reported when the button is released.
+func (terminal *Terminal) reportHighlightMouse(button MouseButton, action MouseAction, x int, y int) (bool, error) {
+	highlight := &terminal.mouseHighlight
+
+	switch {
+	case highlight.tracking:
+		switch {
+		case action == MouseMotion:
+			terminal.extendMouseHighlight(x, y)
+		case action == MouseRelease && button == MouseButtonLeft:
+			highlight.tracking = false
+			terminal.ActiveBuffer().ClearSelection()
+			if highlight.endX == highlight.startX && highlight.endY == highlight.startY {
+				return true, terminal.Write([]byte{0x1b, '[', 't', legacyMousePosition(highlight.endX), legacyMousePosition(highlight.endY)})
+			}
+			return true, terminal.Write([]byte{
+				0x1b, '[', 'T',
+				legacyMousePosition(highlight.startX), legacyMousePosition(highlight.startY),
+				legacyMousePosition(highlight.endX), legacyMousePosition(highlight.endY),
+				legacyMousePosition(x), legacyMousePosition(y),
+			})
+		}
+		return true, nil
+
+	case highlight.waiting:
+		// the application hasn't replied, so the release is reported as usual
+		if action == MouseRelease && button == MouseButtonLeft {
+			highlight.waiting = false
+			return false, nil
+		}
+		return true, nil
+
+	case action == MousePress && button == MouseButtonLeft:
+		highlight.waiting = true
+	}
+
+	return false, nil
+}
+
+// StartMouseHighlight handles the application's reply to a left press during highlight tracking. Highlighting starts
+// from startX, startY if function is non-zero, and is limited to the rows from firstRow up to but not including lastRow.
+func (terminal *Terminal) StartMouseHighlight(function int, startX int, startY int, firstRow int, lastRow int) error {
+	highlight := &terminal.mouseHighlight
+	if !highlight.waiting {
+		return fmt.Errorf("Highlight tracking started without a press to highlight from")
+	}
+	highlight.waiting = false
+	if function == 0 {
+		return nil
+	}
+
+	highlight.tracking = true
+	highlight.startX, highlight.startY = startX, startY
+	highlight.firstRow, highlight.lastRow = firstRow, lastRow
+	terminal.ActiveBuffer().StartSelection(uint16(startX-1), uint16(startY-1), buffer.SelectionChar)
+	terminal.extendMouseHighlight(startX, startY)
+	return nil
+}
+
+// extendMouseHighlight highlights up to the cell under the mouse, kept within the rows given by the application
+func (terminal *Terminal) extendMouseHighlight(x int, y int) {
+	highlight := &terminal.mouseHighlight
+	if y >= highlight.lastRow {
+		y = highlight.lastRow - 1
+	}
+	if y < highlight.firstRow {
+		y = highlight.firstRow
+	}
+	if x < 1 {
+		x = 1
+	}
+	highlight.endX, highlight.endY = x, y
+	terminal.ActiveBuffer().ExtendSelection(uint16(x-1), uint16(y-1), false)
+}
+
+func (terminal *Terminal) encodeMouse(button MouseButton, action MouseAction, mods MouseModifiers, x int, y int) string {
+	cb := int(button)
+	if terminal.mouseMode != MouseModeX10 {
+		cb |= int(mods)
+	}
+	if action == MouseMotion {
+		cb += mouseMotionFlag
+	}
+
+	if terminal.mouseExtMode == MouseExtSGR {
+		// unlike the legacy encoding, SGR releases identify the button which was released
+		final := 'M'
+		if action == MouseRelease {
+			final = 'm'
+		}
+		return fmt.Sprintf("\x1b[<%d;%d;%d%c", cb, x, y, final)
+	}
+
+	if action == MouseRelease {
+		cb = int(MouseButtonNone) | int(mods)
+	}
+	return string([]byte{0x1b, '[', 'M', byte(cb + 32), legacyMousePosition(x), legacyMousePosition(y)})
+}
+
+// legacyMousePosition encodes a position as a single byte, added to 32. Positions beyond 223 don't fit, so are sent
+// as 0 as they are by xterm, the SGR encoding being the only way for applications to tell where they are.
+func legacyMousePosition(position int) byte {
+	if position > 255-32 {
+		return 0
+	}
+	return byte(position + 32)
+}
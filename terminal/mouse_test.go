@@ -0,0 +1,207 @@
+package terminal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSGRMousePressDragRelease(t *testing.T) {
+	terminal, pty := newTestTerminal(t, 80, 24)
+	feed(terminal, "\x1b[?1002;1006h")
+	require.Equal(t, MouseModeButtonEvent, terminal.GetMouseMode())
+
+	require.Nil(t, terminal.ReportMouse(MouseButtonRight, MousePress, MouseModControl, 3, 4))
+	require.Nil(t, terminal.ReportMouse(MouseButtonNone, MouseMotion, 0, 3, 4)) // same cell, not reported
+	require.Nil(t, terminal.ReportMouse(MouseButtonNone, MouseMotion, 0, 5, 4))
+	require.Nil(t, terminal.ReportMouse(MouseButtonRight, MouseRelease, MouseModShift, 300, 4))
+	require.Nil(t, terminal.ReportMouse(MouseButtonNone, MouseMotion, 0, 6, 4)) // no button held, not reported
+
+	assert.Equal(t, "\x1b[<18;3;4M\x1b[<34;5;4M\x1b[<6;300;4m", pty.output.String())
+}
+
+func TestLegacyMouseRelease(t *testing.T) {
+	terminal, pty := newTestTerminal(t, 80, 24)
+	feed(terminal, "\x1b[?1000h")
+
+	require.Nil(t, terminal.ReportMouse(MouseButtonMiddle, MousePress, 0, 1, 2))
+	require.Nil(t, terminal.ReportMouse(MouseButtonNone, MouseMotion, 0, 2, 2))
+	require.Nil(t, terminal.ReportMouse(MouseButtonMiddle, MouseRelease, 0, 2, 2))
+
+	assert.Equal(t, "\x1b[M!!\"\x1b[M#\"\"", pty.output.String())
+}
+
+func TestLegacyMousePositionLimit(t *testing.T) {
+	terminal, pty := newTestTerminal(t, 400, 24)
+	feed(terminal, "\x1b[?1000h")
+
+	require.Nil(t, terminal.ReportMouse(MouseButtonLeft, MousePress, 0, 200, 2))
+	require.Nil(t, terminal.ReportMouse(MouseButtonLeft, MousePress, 0, 223, 2))
+	require.Nil(t, terminal.ReportMouse(MouseButtonLeft, MousePress, 0, 300, 2))
+
+	assert.Equal(t, []byte("\x1b[M \xe8\"\x1b[M \xff\"\x1b[M \x00\""), pty.output.Bytes())
+}
+
+func TestLegacyButtonEventMotion(t *testing.T) {
+	terminal, pty := newTestTerminal(t, 80, 24)
+	feed(terminal, "\x1b[?1002h")
+
+	require.Nil(t, terminal.ReportMouse(MouseButtonLeft, MousePress, MouseModShift, 1, 1))
+	require.Nil(t, terminal.ReportMouse(MouseButtonNone, MouseMotion, MouseModShift|MouseModMeta, 1, 1)) // same cell, not reported
+	require.Nil(t, terminal.ReportMouse(MouseButtonNone, MouseMotion, MouseModShift|MouseModMeta, 2, 1))
+	require.Nil(t, terminal.ReportMouse(MouseButtonNone, MouseMotion, MouseModControl, 2, 3))
+	require.Nil(t, terminal.ReportMouse(MouseButtonLeft, MouseRelease, 0, 2, 3))
+	require.Nil(t, terminal.ReportMouse(MouseButtonNone, MouseMotion, 0, 4, 3)) // no button held, not reported
+
+	assert.Equal(t, "\x1b[M$!!\x1b[ML\"!\x1b[MP\"#\x1b[M#\"#", pty.output.String())
+}
+
+func TestLegacyAnyEventMotion(t *testing.T) {
+	terminal, pty := newTestTerminal(t, 80, 24)
+	feed(terminal, "\x1b[?1003h")
+
+	require.Nil(t, terminal.ReportMouse(MouseButtonNone, MouseMotion, 0, 1, 1))
+	require.Nil(t, terminal.ReportMouse(MouseButtonNone, MouseMotion, 0, 1, 1)) // same cell, not reported
+	require.Nil(t, terminal.ReportMouse(MouseButtonNone, MouseMotion, MouseModControl, 2, 1))
+	feed(terminal, "\x1b[?1003l")
+	require.Nil(t, terminal.ReportMouse(MouseButtonNone, MouseMotion, 0, 3, 1))
+
+	assert.Equal(t, "\x1b[MC!!\x1b[MS\"!", pty.output.String())
+}
+
+func TestMouseWheel(t *testing.T) {
+	terminal, pty := newTestTerminal(t, 80, 24)
+	feed(terminal, "\x1b[?1002h")
+
+	require.Nil(t, terminal.ReportMouse(MouseButtonWheelUp, MousePress, 0, 1, 2))
+	require.Nil(t, terminal.ReportMouse(MouseButtonWheelDown, MousePress, MouseModControl, 1, 2))
+	require.Nil(t, terminal.ReportMouse(MouseButtonNone, MouseMotion, 0, 2, 2)) // the wheel isn't held, not reported
+	feed(terminal, "\x1b[?1006h")
+	require.Nil(t, terminal.ReportMouse(MouseButtonWheelDown, MousePress, 0, 1, 2))
+	assert.Equal(t, "\x1b[M`!\"\x1b[Mq!\"\x1b[<65;1;2M", pty.output.String())
+
+	// X10 mode only reports the buttons
+	pty.output.Reset()
+	feed(terminal, "\x1b[?9h")
+	require.Nil(t, terminal.ReportMouse(MouseButtonWheelUp, MousePress, 0, 1, 2))
+	assert.Equal(t, "", pty.output.String())
+}
+
+func TestMouseModes(t *testing.T) {
+	tests := []struct {
+		name     string
+		sequence string
+		expected string
+	}{
+		{"none", "", ""},
+		{"X10 reports presses only", "\x1b[?9h", "\x1b[M \"!"},
+		{"any event reports motion without a button", "\x1b[?1003;1006h", "\x1b[<35;1;1M\x1b[<4;2;1M\x1b[<0;2;1m"},
+		{"reset", "\x1b[?1003;1006h\x1b[?1003;1006l", ""},
+	}
+
+	for _, test := range tests {
+		terminal, pty := newTestTerminal(t, 80, 24)
+		feed(terminal, test.sequence)
+
+		require.Nil(t, terminal.ReportMouse(MouseButtonNone, MouseMotion, 0, 1, 1))
+		require.Nil(t, terminal.ReportMouse(MouseButtonLeft, MousePress, MouseModShift, 2, 1))
+		require.Nil(t, terminal.ReportMouse(MouseButtonLeft, MouseRelease, 0, 2, 1))
+
+		assert.Equal(t, test.expected, pty.output.String(), test.name)
+	}
+}
+
+func TestMouseModeTransitions(t *testing.T) {
+	terminal, pty := newTestTerminal(t, 80, 24)
+	report := func(button MouseButton, action MouseAction, x int) {
+		require.Nil(t, terminal.ReportMouse(button, action, 0, x, 1))
+	}
+
+	feed(terminal, "\x1b[?1006h\x1b[?1000h")
+	report(MouseButtonLeft, MousePress, 2)
+	report(MouseButtonNone, MouseMotion, 3)
+
+	// enabling a higher mode replaces the current one, and knows the button is still held
+	feed(terminal, "\x1b[?1002h")
+	assert.Equal(t, MouseModeButtonEvent, terminal.GetMouseMode())
+	report(MouseButtonNone, MouseMotion, 3)
+	report(MouseButtonNone, MouseMotion, 4)
+
+	feed(terminal, "\x1b[?1003h")
+	report(MouseButtonLeft, MouseRelease, 4)
+	report(MouseButtonNone, MouseMotion, 5)
+	assert.Equal(t, "\x1b[<0;2;1M\x1b[<32;3;1M\x1b[<32;4;1M\x1b[<0;4;1m\x1b[<35;5;1M", pty.output.String())
+
+	// disabling any of the modes turns mouse reporting off
+	pty.output.Reset()
+	feed(terminal, "\x1b[?1000l")
+	assert.Equal(t, MouseModeNone, terminal.GetMouseMode())
+	report(MouseButtonNone, MouseMotion, 6)
+	report(MouseButtonRight, MousePress, 6)
+	assert.Equal(t, "", pty.output.String())
+
+	// a drag which started while reporting was off is reported once button events are enabled
+	feed(terminal, "\x1b[?1002h")
+	report(MouseButtonNone, MouseMotion, 7)
+	report(MouseButtonRight, MouseRelease, 7)
+	report(MouseButtonNone, MouseMotion, 8)
+	feed(terminal, "\x1b[?1002l")
+	report(MouseButtonLeft, MousePress, 8)
+	assert.Equal(t, "\x1b[<34;7;1M\x1b[<2;7;1m", pty.output.String())
+}
+
+func TestHighlightMouseTracking(t *testing.T) {
+	terminal, pty := newTestTerminal(t, 80, 24)
+	feed(terminal, "\x1b[?1001h")
+	require.Equal(t, MouseModeVT200Highlight, terminal.GetMouseMode())
+	feed(terminal, "abcdefgh\r\nijklmnop")
+
+	// the press is reported as in VT200 mode, and then events are ignored until the application replies
+	require.Nil(t, terminal.ReportMouse(MouseButtonLeft, MousePress, 0, 2, 1))
+	require.Nil(t, terminal.ReportMouse(MouseButtonNone, MouseMotion, 0, 3, 1))
+	assert.Equal(t, "\x1b[M \"!", pty.output.String())
+
+	// highlight from column 2, limited to the first row
+	pty.output.Reset()
+	feed(terminal, "\x1b[1;2;1;1;2T")
+	require.Nil(t, terminal.ReportMouse(MouseButtonNone, MouseMotion, 0, 5, 2))
+	assert.Equal(t, "bcde", terminal.ActiveBuffer().GetSelectedText())
+	assert.Equal(t, "", pty.output.String())
+
+	require.Nil(t, terminal.ReportMouse(MouseButtonLeft, MouseRelease, 0, 5, 2))
+	assert.Equal(t, "\x1b[T\"!%!%\"", pty.output.String())
+	assert.Equal(t, "", terminal.ActiveBuffer().GetSelectedText())
+
+	// a release where the highlight started is reported without the region
+	pty.output.Reset()
+	require.Nil(t, terminal.ReportMouse(MouseButtonLeft, MousePress, 0, 4, 2))
+	feed(terminal, "\x1b[1;4;2;1;3T")
+	require.Nil(t, terminal.ReportMouse(MouseButtonLeft, MouseRelease, 0, 4, 2))
+	assert.Equal(t, "\x1b[M $\"\x1b[t$\"", pty.output.String())
+
+	// missing and 0 parameters are the defaults, here highlighting from the first cell over the whole screen
+	pty.output.Reset()
+	require.Nil(t, terminal.ReportMouse(MouseButtonLeft, MousePress, 0, 1, 1))
+	feed(terminal, "\x1b[1;;0;;0T")
+	require.Nil(t, terminal.ReportMouse(MouseButtonNone, MouseMotion, 0, 3, 2))
+	assert.Equal(t, "abcdefgh\nijk", terminal.ActiveBuffer().GetSelectedText())
+	require.Nil(t, terminal.ReportMouse(MouseButtonLeft, MouseRelease, 0, 3, 2))
+
+	// if the application declines, the release is reported as usual
+	pty.output.Reset()
+	require.Nil(t, terminal.ReportMouse(MouseButtonLeft, MousePress, 0, 1, 1))
+	feed(terminal, "\x1b[0;1;1;1;3T")
+	require.Nil(t, terminal.ReportMouse(MouseButtonLeft, MouseRelease, 0, 1, 1))
+	assert.Equal(t, "\x1b[M !!\x1b[M#!!", pty.output.String())
+
+	// other buttons are reported as in VT200 mode
+	pty.output.Reset()
+	require.Nil(t, terminal.ReportMouse(MouseButtonRight, MousePress, 0, 1, 1))
+	require.Nil(t, terminal.ReportMouse(MouseButtonRight, MouseRelease, 0, 1, 1))
+	assert.Equal(t, "\x1b[M\"!!\x1b[M#!!", pty.output.String())
+
+	// CSI T with a single parameter is still scroll down
+	feed(terminal, "\x1b[1T")
+	assert.Equal(t, "", screenText(terminal)[0])
+}
@@ -2,57 +2,209 @@ package terminal
 
 import (
 	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
+
+	"github.com/liamg/aminal/buffer"
+	"github.com/liamg/aminal/config"
 )
 
-func oscHandler(pty chan rune, terminal *Terminal) error {
+// oscHandler handles an OSC, whose string data is a list of params separated by semicolons
+func oscHandler(data string, terminal *Terminal) error {
+
+	params := strings.Split(data, ";")
+
+	pT := params[len(params)-1]
+	pS := params[:len(params)-1]
+
+	if len(pS) == 0 {
+		pS = []string{pT}
+		pT = ""
+	}
+
+	switch pS[0] {
+	case "0": // icon name and title
+		terminal.iconName = strings.Join(params[1:], ";")
+		terminal.SetTitle(strings.Join(params[1:], ";"))
+	case "1": // icon name
+		terminal.iconName = strings.Join(params[1:], ";")
+	case "2": // title
+		terminal.SetTitle(strings.Join(params[1:], ";"))
+	case "7": // current working directory
+		return terminal.handleWorkingDirectory(strings.Join(params[1:], ";"))
+	case "4": // get/set palette colours
+		return terminal.handlePaletteColours(params[1:])
+	case "104": // reset palette colours
+		return terminal.resetPaletteColours(params[1:])
+	case "10", "11": // get/set foreground/background colour
+		return terminal.handleDynamicColours(params)
+	case "110": // reset foreground colour
+		terminal.SetDefaultColours(terminal.defaultColours[0], terminal.colours.Background)
+	case "111": // reset background colour
+		terminal.SetDefaultColours(terminal.colours.Foreground, terminal.defaultColours[1])
+	case "52": // get/set clipboard
+		return terminal.handleClipboard(params[1:])
+	case "8": // hyperlink
+		return terminal.handleHyperlink(params[1:])
+	case "133": // shell integration (semantic prompt) marks
+		return terminal.handleSemanticPromptMark(params[1:])
+	default:
+		return fmt.Errorf("Unknown OSC control sequence: %s", strings.Join(params, ";"))
+	}
+	return nil
+}
+
+// handleWorkingDirectory handles OSC 7 ; file://host/path, which shells send to report their working directory
+func (terminal *Terminal) handleWorkingDirectory(location string) error {
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme != "file" {
+		return fmt.Errorf("Invalid OSC 7 working directory: %s", location)
+	}
+	terminal.workingDirectory = u.Path
+	return nil
+}
 
-	params := []string{}
-	param := ""
+// handleHyperlink handles OSC 8 ; params ; URI, which marks the text written after it as a link to URI, until an
+// OSC 8 with an empty URI. params is a colon separated list of key=value pairs, of which only id is defined.
+func (terminal *Terminal) handleHyperlink(params []string) error {
+	if len(params) < 2 {
+		return fmt.Errorf("Invalid OSC 8 parameters: %v", params)
+	}
 
-	for {
-		b := <-pty
-		if terminal.IsOSCTerminator(b) {
-			params = append(params, param)
-			break
+	// the URI may itself contain semicolons
+	uri := strings.Join(params[1:], ";")
+	if uri == "" {
+		terminal.terminalState.SetHyperlink(nil)
+		return nil
+	}
+
+	link := &buffer.Hyperlink{URI: uri}
+	for _, param := range strings.Split(params[0], ":") {
+		if strings.HasPrefix(param, "id=") {
+			link.ID = strings.TrimPrefix(param, "id=")
 		}
-		if b == ';' {
-			params = append(params, param)
-			param = ""
+	}
+	terminal.terminalState.SetHyperlink(link)
+	return nil
+}
+
+// handleDynamicColours handles OSC 10 and 11, which query or set the default foreground and background colours.
+// As in xterm, further params apply to the following colours, so OSC 10 ; fg ; bg sets both.
+func (terminal *Terminal) handleDynamicColours(params []string) error {
+	code, _ := strconv.Atoi(params[0])
+	if len(params) < 2 {
+		return fmt.Errorf("Missing colour for OSC %d", code)
+	}
+
+	fg := terminal.colours.Foreground
+	bg := terminal.colours.Background
+
+	for i, spec := range params[1:] {
+		var colour *config.Colour
+		switch code + i {
+		case 10:
+			colour = &fg
+		case 11:
+			colour = &bg
+		default:
+			// cursor and highlight colours etc. aren't supported
+			continue
+		}
+
+		if spec == "?" {
+			terminal.Write([]byte(fmt.Sprintf("\x1b]%d;%s\x1b\\", code+i, formatColourSpec(*colour))))
 			continue
 		}
-		param = fmt.Sprintf("%s%c", param, b)
+
+		parsed, err := parseColourSpec(spec)
+		if err != nil {
+			return err
+		}
+		*colour = parsed
 	}
 
-	if len(params) == 0 {
-		return fmt.Errorf("OSC with no params")
+	terminal.SetDefaultColours(fg, bg)
+	return nil
+}
+
+// handlePaletteColours handles OSC 4 ; c ; spec, which queries or sets colour c of the 256 colour palette. Any number
+// of colour and spec pairs may be given.
+func (terminal *Terminal) handlePaletteColours(params []string) error {
+	if len(params) == 0 || len(params)%2 != 0 {
+		return fmt.Errorf("Invalid OSC 4 parameters: %v", params)
 	}
 
-	pT := params[len(params)-1]
-	pS := params[:len(params)-1]
+	for i := 0; i < len(params); i += 2 {
+		n, err := strconv.ParseUint(params[i], 10, 8)
+		if err != nil {
+			return fmt.Errorf("Invalid OSC 4 colour number: %s", params[i])
+		}
 
-	if len(pS) == 0 {
-		pS = []string{pT}
-		pT = ""
+		spec := params[i+1]
+		if spec == "?" {
+			terminal.Write([]byte(fmt.Sprintf("\x1b]4;%d;%s\x1b\\", n, formatColourSpec(terminal.get8BitSGRColour(uint8(n))))))
+			continue
+		}
+
+		colour, err := parseColourSpec(spec)
+		if err != nil {
+			return err
+		}
+		terminal.SetPaletteColour(uint8(n), colour)
 	}
+	return nil
+}
 
-	switch pS[0] {
-	case "0", "2":
-		terminal.SetTitle(pT)
-	case "10": // get/set foreground colour
-		if len(pS) > 1 {
-			if pS[1] == "?" {
-				terminal.Write([]byte("\x1b]10;15"))
-			}
+// resetPaletteColours handles OSC 104 ; c, which restores colour c of the palette to the one from the config, or every
+// colour if none are given
+func (terminal *Terminal) resetPaletteColours(params []string) error {
+	if len(params) == 0 || (len(params) == 1 && params[0] == "") {
+		for n := range terminal.palette {
+			terminal.ResetPaletteColour(n)
+		}
+		return nil
+	}
+
+	for _, param := range params {
+		n, err := strconv.ParseUint(param, 10, 8)
+		if err != nil {
+			return fmt.Errorf("Invalid OSC 104 colour number: %s", param)
 		}
-	case "11": // get/set background colour
-		if len(pS) > 1 {
-			if pS[1] == "?" {
-				terminal.Write([]byte("\x1b]10;0"))
+		terminal.ResetPaletteColour(uint8(n))
+	}
+	return nil
+}
+
+// handleSemanticPromptMark handles OSC 133 ; Ps [; Pt] as emitted by shell integration scripts
+func (terminal *Terminal) handleSemanticPromptMark(params []string) error {
+	if len(params) == 0 {
+		return fmt.Errorf("Missing OSC 133 mark type")
+	}
+
+	buffer := terminal.ActiveBuffer()
+
+	switch params[0] {
+	case "A":
+		buffer.MarkPromptStart()
+	case "B":
+		buffer.MarkCommandStart()
+	case "C":
+		buffer.MarkOutputStart()
+	case "D":
+		exitCode := 0
+		if len(params) > 1 && params[1] != "" {
+			var err error
+			exitCode, err = strconv.Atoi(params[1])
+			if err != nil {
+				return fmt.Errorf("Invalid OSC 133 exit status: %s", params[1])
 			}
 		}
+		buffer.MarkCommandEnd(exitCode)
 	default:
-		return fmt.Errorf("Unknown OSC control sequence: %s", strings.Join(params, ";"))
+		return fmt.Errorf("Unknown OSC 133 mark type: %s", params[0])
 	}
+
+	terminal.SetDirty()
 	return nil
 }
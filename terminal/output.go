@@ -11,7 +11,7 @@ type TerminalCharSet int
 // single rune handler
 type runeHandler func(terminal *Terminal) error
 
-type escapeSequenceHandler func(pty chan rune, terminal *Terminal) error
+type escapeSequenceHandler func(terminal *Terminal) error
 
 var runeMap = map[rune]runeHandler{
 	0x05: enqHandler,
@@ -51,7 +51,7 @@ func backspaceHandler(terminal *Terminal) error {
 }
 
 func bellHandler(terminal *Terminal) error {
-	// @todo ring bell - flash red or some shit?
+	terminal.ringBell()
 	return nil
 }
 
@@ -72,17 +72,20 @@ func shiftInHandler(terminal *Terminal) error {
 	return nil
 }
 
-func (terminal *Terminal) processRune(b rune) {
+// execute handles a C0 control, ignoring those without a handler
+func (terminal *Terminal) execute(b rune) {
 	if handler, ok := runeMap[b]; ok {
 		if err := handler(terminal); err != nil {
 			terminal.logger.Errorf("Error handling control code: %s", err)
 		}
-		terminal.isDirty = true
-		return
+		terminal.markChanged()
 	}
+}
+
+func (terminal *Terminal) print(b rune) {
 	//terminal.logger.Debugf("Received character 0x%X: %q", b, string(b))
 	terminal.ActiveBuffer().Write(terminal.translateRune(b))
-	terminal.isDirty = true
+	terminal.markChanged()
 }
 
 func (terminal *Terminal) translateRune(b rune) rune {
@@ -97,29 +100,12 @@ func (terminal *Terminal) translateRune(b rune) rune {
 	return b
 }
 
+// processInput parses runes from pty until it's closed
 func (terminal *Terminal) processInput(pty chan rune) {
-
-	// https://en.wikipedia.org/wiki/ANSI_escape_code
-
-	var b rune
-
-	for {
-
+	for b := range pty {
 		if terminal.config.Slomo {
 			time.Sleep(time.Millisecond * 100)
 		}
-
-		b = <-pty
-
-		if b == 0x1b {
-			//terminal.logger.Debugf("Handling escape sequence: 0x%x", b)
-			if err := ansiHandler(pty, terminal); err != nil {
-				terminal.logger.Errorf("Error handling escape sequence: %s", err)
-			}
-			terminal.isDirty = true
-			continue
-		}
-
-		terminal.processRune(b)
+		terminal.parse(b)
 	}
 }
@@ -0,0 +1,247 @@
+package terminal
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNULPaddingIsDiscarded(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 5)
+
+	feed(terminal, "a\x00\x00b")
+	assert.Equal(t, []string{"ab"}, visibleText(terminal))
+
+	// NULs inside a CSI sequence must not break parameter parsing
+	feed(terminal, "\x1b\x00[\x002\x00;\x00\x005\x00H")
+	assert.Equal(t, uint16(1), terminal.ActiveBuffer().CursorLine())
+	assert.Equal(t, uint16(4), terminal.ActiveBuffer().CursorColumn())
+
+	feed(terminal, "x\x00")
+	assert.Equal(t, 'x', terminal.GetCell(4, 1).Rune())
+	assert.Equal(t, uint16(5), terminal.ActiveBuffer().CursorColumn())
+
+	feed(terminal, "\x1b]2;ti\x00tle\x07")
+	assert.Equal(t, "title", terminal.GetTitle())
+}
+
+func TestShowControls(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 5)
+	terminal.config.ShowControls = true
+
+	feed(terminal, "\x1b[1ma\tb\x7f\r\nc")
+	assert.Equal(t, []string{"\u241b[1ma\u2409b\u2421\u240d\u240a", "c"}, visibleText(terminal))
+	assert.False(t, terminal.ActiveBuffer().CursorAttr().Bold)
+
+	terminal.config.ShowControls = false
+	feed(terminal, "\rd")
+	assert.Equal(t, []string{"\u241b[1ma\u2409b\u2421\u240d\u240a", "d"}, visibleText(terminal))
+}
+
+func receivedBells(bells chan bool) int {
+	count := 0
+	for {
+		select {
+		case <-bells:
+			count++
+		case <-time.After(50 * time.Millisecond):
+			return count
+		}
+	}
+}
+
+func TestBellIsRateLimited(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 5)
+	terminal.config.Bell.MinInterval = 60000
+	bells := make(chan bool, 10)
+	terminal.AttachBellHandler(bells)
+
+	feed(terminal, "\a\a\a")
+	assert.Equal(t, 1, receivedBells(bells))
+
+	// bells during the interval are coalesced rather than queued
+	feed(terminal, "\a")
+	assert.Equal(t, 0, receivedBells(bells))
+
+	terminal.config.Bell.MinInterval = 0
+	feed(terminal, "\a\a")
+	assert.Equal(t, 2, receivedBells(bells))
+}
+
+func TestBellCanBeMuted(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 5)
+	terminal.config.Bell.Mute = true
+	bells := make(chan bool, 10)
+	terminal.AttachBellHandler(bells)
+
+	feed(terminal, "\a")
+	assert.Equal(t, 0, receivedBells(bells))
+}
+
+func TestWorkingDirectory(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 10, 2)
+	assert.Equal(t, "", terminal.WorkingDirectory())
+
+	feed(terminal, "\x1b]7;file://host/home/user/a%20b\x07")
+	assert.Equal(t, "/home/user/a b", terminal.WorkingDirectory())
+
+	feed(terminal, "\x1b]7;/not/a/url\x07")
+	assert.Equal(t, "/home/user/a b", terminal.WorkingDirectory())
+}
+
+func decodePtyOutput(data string) []rune {
+	buffer := make(chan rune, len(data))
+	_ = readRunes(bufio.NewReader(iotest.OneByteReader(strings.NewReader(data))), buffer)
+	close(buffer)
+	runes := []rune{}
+	for r := range buffer {
+		runes = append(runes, r)
+	}
+	return runes
+}
+
+func TestInvalidUTF8IsReplaced(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected []rune
+	}{
+		{"valid runes split across reads", "a€b😀", []rune{'a', '€', 'b', '😀'}},
+		{"invalid byte", "a\xffb", []rune{'a', utf8.RuneError, 'b'}},
+		{"truncated sequence", "a\xe2\x82b", []rune{'a', utf8.RuneError, utf8.RuneError, 'b'}},
+		{"stray continuation bytes", "\x80\x80€", []rune{utf8.RuneError, utf8.RuneError, '€'}},
+		{"overlong encoding", "\xc0\xaf", []rune{utf8.RuneError, utf8.RuneError}},
+		{"truncated at end of output", "ab\xf0\x9f\x98", []rune{'a', 'b', utf8.RuneError, utf8.RuneError, utf8.RuneError}},
+		{"escape sequence after invalid bytes", "\xff\x1b[1m", []rune{utf8.RuneError, 0x1b, '[', '1', 'm'}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, decodePtyOutput(test.data), test.name)
+	}
+}
+
+func TestBinaryOutputDoesNotBreakTerminal(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 5)
+
+	feed(terminal, "a\xe2\x82\xffb\x1b[2;1Hok")
+	assert.Equal(t, []string{"a���b", "ok"}, visibleText(terminal))
+}
+
+func TestProcessInput(t *testing.T) {
+	terminal, pty := newTestTerminal(t, 20, 5)
+
+	terminal.ProcessInput([]byte("hello\r\n"))
+	assert.Equal(t, []string{"hello", ""}, visibleText(terminal))
+	assert.Equal(t, "", pty.output.String(), "displayed data must not be sent to the host")
+
+	// sequences and characters split across calls are completed by the next call
+	terminal.ProcessInput([]byte("\x1b[3;"))
+	assert.Equal(t, uint16(0), terminal.ActiveBuffer().CursorColumn())
+	terminal.ProcessInput([]byte("5Hx\xe2\x82"))
+	assert.Equal(t, []string{"hello", "", "    x"}, screenText(terminal))
+	terminal.ProcessInput([]byte("\xac\x1b]2;my "))
+	assert.Equal(t, "", terminal.GetTitle())
+	terminal.ProcessInput([]byte("title\x07!"))
+	assert.Equal(t, []string{"hello", "", "    x€!"}, screenText(terminal))
+	assert.Equal(t, "my title", terminal.GetTitle())
+
+	// a lone ESC is completed by the next call too
+	terminal.ProcessInput([]byte("\x1b"))
+	terminal.ProcessInput([]byte("[H"))
+	assert.Equal(t, uint16(0), terminal.ActiveBuffer().CursorLine())
+	assert.Equal(t, uint16(0), terminal.ActiveBuffer().CursorColumn())
+}
+
+func TestC1Controls(t *testing.T) {
+	t.Run("Latin-1", func(t *testing.T) {
+		terminal, _ := newTestTerminal(t, 10, 4)
+		terminal.config.Latin1 = true
+
+		feed(terminal, "caf\xe9\x9b3;2Hx\x85y\x9d2;title\x9c\x9cz")
+		assert.Equal(t, []string{"café", "", " x", "yz"}, screenText(terminal))
+		assert.Equal(t, "title", terminal.GetTitle())
+	})
+
+	t.Run("UTF-8", func(t *testing.T) {
+		terminal, _ := newTestTerminal(t, 10, 4)
+
+		// a lone 0x9B is an invalid continuation byte rather than CSI
+		feed(terminal, "caf\xc3\xa9\x9b3;2Hx")
+		assert.Equal(t, []string{"café�3;2Hx"}, visibleText(terminal)[:1])
+	})
+}
+
+func TestHyperlinks(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 2)
+
+	feed(terminal, "see \x1b]8;id=1;https://example.com/?a=1;b=2\x1b\\here\x1b]8;;\x1b\\ ok")
+	assert.Equal(t, []string{"see here ok"}, visibleText(terminal)[:1])
+	assert.Nil(t, terminal.ActiveBuffer().GetLinkAtPosition(3, 0))
+	assert.Nil(t, terminal.ActiveBuffer().GetLinkAtPosition(8, 0))
+	for col := uint16(4); col < 8; col++ {
+		link := terminal.ActiveBuffer().GetLinkAtPosition(col, 0)
+		if assert.NotNil(t, link) {
+			assert.Equal(t, "https://example.com/?a=1;b=2", link.URI)
+			assert.Equal(t, "1", link.ID)
+		}
+	}
+}
+
+func TestTitles(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 5)
+
+	feed(terminal, "\x1b]0;vim: a;b.txt\x07")
+	assert.Equal(t, "vim: a;b.txt", terminal.GetTitle())
+	assert.Equal(t, "vim: a;b.txt", terminal.iconName)
+
+	feed(terminal, "\x1b]1;icon\x07")
+	assert.Equal(t, "vim: a;b.txt", terminal.GetTitle())
+	assert.Equal(t, "icon", terminal.iconName)
+
+	feed(terminal, "\x1b]2;title\x07")
+	assert.Equal(t, "title", terminal.GetTitle())
+	assert.Equal(t, "icon", terminal.iconName)
+}
+
+func TestTitleStack(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 5)
+
+	feed(terminal, "\x1b]2;shell\x07\x1b[22;0t\x1b]0;editor\x07")
+	feed(terminal, "\x1b[22;2t\x1b]2;help\x07")
+	feed(terminal, "\x1b[23;2t")
+	assert.Equal(t, "editor", terminal.GetTitle())
+	feed(terminal, "\x1b[23t")
+	assert.Equal(t, "shell", terminal.GetTitle())
+	assert.Equal(t, "", terminal.iconName)
+
+	// popping an empty stack does nothing
+	feed(terminal, "\x1b[23t")
+	assert.Equal(t, "shell", terminal.GetTitle())
+
+	// only the most recent titles are kept
+	for i := 0; i < maxTitleStackDepth+5; i++ {
+		feed(terminal, fmt.Sprintf("\x1b]2;%d\x07\x1b[22t", i))
+	}
+	assert.Equal(t, maxTitleStackDepth, len(terminal.titleStack))
+	assert.Equal(t, "5", terminal.titleStack[0].title)
+}
+
+func TestTitleStackRestoresOnlyWhatWasSaved(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 5)
+
+	feed(terminal, "\x1b]0;shell\x07\x1b[22;2t\x1b]0;editor\x07")
+	feed(terminal, "\x1b[23;0t")
+	assert.Equal(t, "shell", terminal.GetTitle())
+	assert.Equal(t, "editor", terminal.iconName)
+
+	feed(terminal, "\x1b[22;1t\x1b]0;help\x07")
+	feed(terminal, "\x1b[23;0t")
+	assert.Equal(t, "help", terminal.GetTitle())
+	assert.Equal(t, "editor", terminal.iconName)
+}
@@ -0,0 +1,307 @@
+package terminal
+
+// The parser is the state machine from Paul Williams' DEC compatible parser, which defines what every character does
+// in every state, so malformed and unknown sequences are consumed whole rather than leaking into the display.
+// https://vt100.net/emu/dec_ansi_parser
+
+type parserState int
+
+const (
+	stateGround parserState = iota
+	stateEscape
+	stateEscapeIntermediate
+	stateCSIEntry
+	stateCSIParam
+	stateCSIIntermediate
+	stateCSIIgnore
+	stateOSCString
+	stateDCSEntry
+	stateDCSParam
+	stateDCSIntermediate
+	stateDCSPassthrough
+	stateDCSPassthroughEscape // an ESC in a DCS string, which is either ST or part of the string
+	stateDCSIgnore
+	stateSOSPMAPCString
+)
+
+// maxStringLength is the longest an OSC or DCS string may be, in characters. Longer strings are discarded whole when
+// they end, rather than using up memory without limit while the application never ends them.
+const maxStringLength = 8 << 20
+
+// parser is the state of a sequence being parsed, which is kept between reads so that sequences may be split across them
+type parser struct {
+	state         parserState
+	intermediates []rune // intermediate bytes (0x20-0x2F) of an escape sequence, CSI or DCS
+	params        []rune // parameter bytes (0x30-0x3F) of a CSI or DCS, including any private marker
+	final         rune   // final byte of a DCS, which selects the handler for its string
+	data          []rune // OSC or DCS string
+	overflowed    bool   // the string is longer than maxStringLength, so the rest of it isn't kept
+}
+
+// enter moves to the start of a new sequence
+func (p *parser) enter(state parserState) {
+	p.state = state
+	p.intermediates = p.intermediates[:0]
+	p.params = p.params[:0]
+	p.final = 0
+	p.data = p.data[:0]
+	p.overflowed = false
+}
+
+// appendData adds characters to the OSC or DCS string, unless it's already too long
+func (p *parser) appendData(data ...rune) {
+	if len(p.data)+len(data) > maxStringLength {
+		p.overflowed = true
+		return
+	}
+	p.data = append(p.data, data...)
+}
+
+// parse applies a character of output from the host
+func (terminal *Terminal) parse(b rune) {
+	p := &terminal.parser
+
+	if b == 0x00 {
+		// NUL is sent by some hosts as time-fill padding, and is ignored in every state (including mid-sequence), except
+		// where the pty ends OSC strings with it
+		if p.state == stateOSCString && terminal.IsOSCTerminator(b) {
+			terminal.finishString()
+		}
+		return
+	}
+
+	if p.state == stateGround && terminal.showControl(b) {
+		terminal.notifyDirty()
+		return
+	}
+
+	// transitions from any state
+	switch {
+	case b == 0x18 || b == 0x1a:
+		// CAN and SUB cancel the sequence, discarding any OSC or DCS string
+		p.state = stateGround
+		return
+	case b == 0x1b:
+		switch p.state {
+		case stateOSCString:
+			terminal.finishString()
+		case stateDCSPassthrough:
+			p.state = stateDCSPassthroughEscape
+			return
+		case stateDCSPassthroughEscape:
+			p.appendData(0x1b)
+			return
+		}
+		p.enter(stateEscape)
+		return
+	case b >= 0x80 && b <= 0x9f && terminal.c1Controls():
+		terminal.parseC1(b)
+		return
+	}
+
+	switch p.state {
+	case stateGround:
+		switch {
+		case b < 0x20:
+			terminal.execute(b)
+		case b == 0x7f:
+			// DEL is ignored
+		default:
+			terminal.print(b)
+		}
+
+	case stateEscape:
+		switch {
+		case b < 0x20:
+			terminal.execute(b)
+		case b <= 0x2f:
+			p.intermediates = append(p.intermediates, b)
+			p.state = stateEscapeIntermediate
+		case b == '[':
+			p.enter(stateCSIEntry)
+		case b == ']':
+			p.enter(stateOSCString)
+		case b == 'P':
+			p.enter(stateDCSEntry)
+		case b == 'X' || b == '^' || b == '_':
+			p.enter(stateSOSPMAPCString)
+		case b == 0x7f:
+		default:
+			terminal.dispatchEscape(b)
+		}
+
+	case stateEscapeIntermediate:
+		switch {
+		case b < 0x20:
+			terminal.execute(b)
+		case b <= 0x2f:
+			p.intermediates = append(p.intermediates, b)
+		case b == 0x7f:
+		default:
+			terminal.dispatchEscape(b)
+		}
+
+	case stateCSIEntry, stateCSIParam:
+		switch {
+		case b < 0x20:
+			terminal.execute(b)
+		case b <= 0x2f:
+			p.intermediates = append(p.intermediates, b)
+			p.state = stateCSIIntermediate
+		case b <= 0x3b, b <= 0x3f && p.state == stateCSIEntry:
+			// parameters, separated by semicolons or colons, or a private marker before them
+			p.params = append(p.params, b)
+			p.state = stateCSIParam
+		case b <= 0x3f:
+			p.state = stateCSIIgnore
+		case b <= 0x7e:
+			terminal.dispatchCSI(b)
+		}
+		// DEL and characters beyond ASCII are ignored
+
+	case stateCSIIntermediate:
+		switch {
+		case b < 0x20:
+			terminal.execute(b)
+		case b <= 0x2f:
+			p.intermediates = append(p.intermediates, b)
+		case b <= 0x3f:
+			p.state = stateCSIIgnore
+		case b <= 0x7e:
+			terminal.dispatchCSI(b)
+		}
+
+	case stateCSIIgnore:
+		switch {
+		case b < 0x20:
+			terminal.execute(b)
+		case b >= 0x40 && b <= 0x7e:
+			p.state = stateGround
+		}
+
+	case stateOSCString:
+		switch {
+		case terminal.IsOSCTerminator(b):
+			terminal.finishString()
+		case b < 0x20:
+		default:
+			p.appendData(b)
+		}
+
+	case stateDCSEntry, stateDCSParam:
+		switch {
+		case b < 0x20:
+		case b <= 0x2f:
+			p.intermediates = append(p.intermediates, b)
+			p.state = stateDCSIntermediate
+		case b <= 0x3b, b <= 0x3f && p.state == stateDCSEntry:
+			p.params = append(p.params, b)
+			p.state = stateDCSParam
+		case b <= 0x3f:
+			p.state = stateDCSIgnore
+		case b <= 0x7e:
+			p.final = b
+			p.state = stateDCSPassthrough
+		}
+
+	case stateDCSIntermediate:
+		switch {
+		case b < 0x20:
+		case b <= 0x2f:
+			p.intermediates = append(p.intermediates, b)
+		case b <= 0x3f:
+			p.state = stateDCSIgnore
+		case b <= 0x7e:
+			p.final = b
+			p.state = stateDCSPassthrough
+		}
+
+	case stateDCSPassthrough:
+		if b != 0x7f {
+			p.appendData(b)
+		}
+
+	case stateDCSPassthroughEscape:
+		if b == '\\' || b == 0x07 {
+			// sixel data has long been ended by ESC BEL as well as by ST
+			terminal.finishString()
+			return
+		}
+		// ConPTY injects escape sequences into sixel data it redraws, which the sixel handler accounts for
+		p.appendData(0x1b, b)
+		p.state = stateDCSPassthrough
+
+	case stateDCSIgnore, stateSOSPMAPCString:
+		// ignored until ST
+	}
+}
+
+// parseC1 handles an 8-bit C1 control, which can interrupt a sequence in any state
+func (terminal *Terminal) parseC1(b rune) {
+	p := &terminal.parser
+	if p.state == stateOSCString || p.state == stateDCSPassthrough || p.state == stateDCSPassthroughEscape {
+		terminal.finishString()
+	}
+
+	switch b {
+	case 0x90:
+		p.enter(stateDCSEntry)
+	case 0x9b:
+		p.enter(stateCSIEntry)
+	case 0x9d:
+		p.enter(stateOSCString)
+	case 0x98, 0x9e, 0x9f:
+		p.enter(stateSOSPMAPCString)
+	case c1ST:
+		p.state = stateGround
+	default:
+		p.state = stateGround
+		terminal.handleSequenceError(c1Handler(b, terminal))
+		terminal.markChanged()
+	}
+}
+
+// finishString dispatches the OSC or DCS string which has just been terminated
+func (terminal *Terminal) finishString() {
+	p := &terminal.parser
+	if p.overflowed && (p.state == stateOSCString || p.state == stateDCSPassthrough || p.state == stateDCSPassthroughEscape) {
+		terminal.logger.Errorf("Discarded an OSC or DCS string longer than %d characters", maxStringLength)
+		p.state = stateGround
+		return
+	}
+	switch p.state {
+	case stateOSCString:
+		terminal.handleSequenceError(oscHandler(string(p.data), terminal))
+	case stateDCSPassthrough, stateDCSPassthroughEscape:
+		terminal.handleSequenceError(dcsHandler(string(p.params), string(p.intermediates), p.final, p.data, terminal))
+	}
+	p.state = stateGround
+	terminal.markChanged()
+}
+
+func (terminal *Terminal) dispatchEscape(final rune) {
+	p := &terminal.parser
+	p.state = stateGround
+	terminal.handleSequenceError(ansiHandler(string(p.intermediates), final, terminal))
+	terminal.markChanged()
+}
+
+func (terminal *Terminal) dispatchCSI(final rune) {
+	p := &terminal.parser
+	p.state = stateGround
+	terminal.handleSequenceError(csiHandler(string(p.params), string(p.intermediates), final, terminal))
+	terminal.markChanged()
+}
+
+func (terminal *Terminal) handleSequenceError(err error) {
+	if err != nil {
+		terminal.logger.Errorf("Error handling escape sequence: %s", err)
+	}
+}
+
+// markChanged notifies the gui that the display may have been changed by the character just parsed, without forcing
+// a full redraw as SetDirty does
+func (terminal *Terminal) markChanged() {
+	terminal.isDirty = true
+	terminal.notifyDirty()
+}
@@ -0,0 +1,194 @@
+package terminal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/liamg/aminal/buffer"
+	"github.com/stretchr/testify/assert"
+)
+
+// terminalSnapshot is everything about a terminal which the parser is expected to change
+type terminalSnapshot struct {
+	text   []string
+	cursor [2]uint16
+	title  string
+	output string
+	attr   buffer.CellAttributes
+}
+
+func snapshot(terminal *Terminal, pty *testPty) terminalSnapshot {
+	return terminalSnapshot{
+		text:   screenText(terminal),
+		cursor: [2]uint16{terminal.ActiveBuffer().CursorColumn(), terminal.ActiveBuffer().CursorLine()},
+		title:  terminal.GetTitle(),
+		output: pty.output.String(),
+		attr:   *terminal.ActiveBuffer().CursorAttr(),
+	}
+}
+
+var supportedSequences = []string{
+	"plain text",
+	"\x1b[1;31mbold red\x1b[0m normal",
+	"\x1b[38;5;200m\x1b[48;2;1;2;3mcolours\x1b[39;49m",
+	"\x1b[3;5Hx\x1b[2Ay\x1b[B\x1b[3Cz\x1b[D\x1b[1G",
+	"abc\x1b[2D\x1b[K\x1b[2J",
+	"\x1b]0;window title\x07",
+	"\x1b]2;title;with;semicolons\x1b\\",
+	"\x1b(0lqqk\x1b(B",
+	"\x0e\x1b)0q\x0fq",
+	"\x1b#8",
+	"\x1b7\x1b[4;4Hmoved\x1b8back",
+	"\x1b[6n\x1b[5n\x1b[c\x1b[>c",
+	"\x1b[?1049hon the alt screen\x1b[?1049l",
+	"\x1b[?25l\x1b[?25h",
+	"line\n\x1bD\x1bM\x1bEnext",
+	"\x1b[6 q",
+	"\x1b]8;id=1;http://example.com\x07link\x1b]8;;\x07",
+	"\x1b[2;4rscroll region\x1b[r",
+	"tab\there",
+	"wide \u4e16\u754c and é",
+	"combining e\u0301 and \U0001f469\u200d\U0001f4bb",
+}
+
+func TestSequencesSplitAtEveryCharacter(t *testing.T) {
+	for _, sequence := range supportedSequences {
+		whole, wholePty := newTestTerminal(t, 20, 5)
+		feed(whole, sequence)
+
+		split, splitPty := newTestTerminal(t, 20, 5)
+		for _, r := range sequence {
+			feed(split, string(r))
+		}
+
+		assert.Equal(t, snapshot(whole, wholePty), snapshot(split, splitPty), "%q", sequence)
+	}
+}
+
+func TestMalformedSequencesAreConsumed(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a\x1b[?1;2$zb", "ab"},           // unknown CSI with an intermediate
+		{"a\x1b[1?2mb", "ab"},             // private marker after the parameters
+		{"a\x1b[1 2mb", "ab"},             // parameter after an intermediate
+		{"a\x1b[1\x1b[mb", "ab"},          // ESC cancels the CSI and starts another sequence
+		{"a\x1b[31\x18b", "ab"},           // CAN cancels the CSI
+		{"a\x1b[3\x1a1mb", "a1mb"},        // as does SUB
+		{"a\x1b%Gb", "ab"},                // unknown escape sequence with an intermediate
+		{"a\x1bzb", "ab"},                 // unknown escape sequence
+		{"a\x1b]999;foo\x07b", "ab"},      // unknown OSC
+		{"a\x1b]0;x\x1b[1Cb", "a b"},      // OSC ended by the ESC of another sequence
+		{"a\x1bP+q544e\x1b\\b", "ab"},     // unknown DCS
+		{"a\x1bP1?2q#0\x1b\\b", "ab"},     // malformed DCS
+		{"a\x1b_apc data\x1b\\b", "ab"},   // APC
+		{"a\x1b^pm data\x1b\\b", "ab"},    // PM
+		{"a\x1bXsos data\x1b\\b", "ab"},   // SOS
+		{"a\x7fb\x1b[1\x7fCc", "ab c"},    // DEL is ignored everywhere
+		{"a\x1b[\u00e9Cb", "a b"},         // as are characters beyond ASCII in a CSI
+		{"a\x04\x1cb", "ab"},              // C0 controls without a handler
+		{"a\x1b\\b", "ab"},                // lone ST
+		{"ab\x1b[\r1Cc", "ac"},            // C0 controls in a CSI are executed straight away
+		{"a\x1b\x1b[1Cb", "a b"},          // ESC restarts an escape sequence
+		{"a\x1b(\x1b[1Cb", "a b"},         // even after an intermediate
+		{"a\x1b]0;\x00x\x05y\x07b", "ab"}, // C0 controls in an OSC are ignored
+	}
+
+	for _, test := range tests {
+		terminal, _ := newTestTerminal(t, 20, 5)
+		feed(terminal, test.input)
+		assert.Equal(t, test.expected, screenText(terminal)[0], "%q", test.input)
+		assert.False(t, terminal.ActiveBuffer().CursorAttr().Bold, "%q", test.input)
+		assert.Equal(t, stateGround, terminal.parser.state, "%q", test.input)
+	}
+}
+
+func TestOSCStringTerminators(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 5)
+
+	feed(terminal, "\x1b]2;one\x07")
+	assert.Equal(t, "one", terminal.GetTitle())
+
+	feed(terminal, "\x1b]2;two\x1b\\")
+	assert.Equal(t, "two", terminal.GetTitle())
+
+	// a backslash is only ST after an ESC
+	feed(terminal, "\x1b]2;C:\\Users\x07")
+	assert.Equal(t, "C:\\Users", terminal.GetTitle())
+
+	// the sequence starting with the ESC is still handled
+	feed(terminal, "\x1b]2;three\x1b[1m")
+	assert.Equal(t, "three", terminal.GetTitle())
+	assert.True(t, terminal.ActiveBuffer().CursorAttr().Bold)
+
+	// cancelled strings aren't dispatched
+	feed(terminal, "\x1b]2;four\x18")
+	assert.Equal(t, "three", terminal.GetTitle())
+}
+
+func TestC1ControlsInterruptSequences(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 5)
+	terminal.config.Latin1 = true
+
+	feed(terminal, "\x1b]2;title\x9c")
+	assert.Equal(t, "title", terminal.GetTitle())
+
+	// CSI in the middle of another CSI starts over
+	feed(terminal, "\x1b[5\x9b2Cx")
+	assert.Equal(t, "  x", screenText(terminal)[0])
+
+	// an OSC is dispatched when interrupted by a C1 control, as by ESC
+	feed(terminal, "\x9d2;other\x9b1Cy")
+	assert.Equal(t, "other", terminal.GetTitle())
+	assert.Equal(t, "  x y", screenText(terminal)[0])
+
+	feed(terminal, "\x98ignored\x9cz")
+	assert.Equal(t, "  x yz", screenText(terminal)[0])
+}
+
+func TestEscapeSequencesInDCSStrings(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 5)
+
+	feed(terminal, "\x1bP+qabc\x1b[Hdef\x1b\x1b")
+	assert.Equal(t, stateDCSPassthroughEscape, terminal.parser.state)
+	assert.Equal(t, "abc\x1b[Hdef\x1b", string(terminal.parser.data))
+
+	feed(terminal, "\\x")
+	assert.Equal(t, stateGround, terminal.parser.state)
+	assert.Equal(t, "x", screenText(terminal)[0])
+}
+
+func TestOverlongStringsAreDiscarded(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 5)
+
+	feed(terminal, "\x1b]2;"+strings.Repeat("x", maxStringLength+1))
+	assert.Equal(t, maxStringLength, len(terminal.parser.data), "the string shouldn't grow beyond the limit")
+	feed(terminal, "\x07")
+	assert.Equal(t, "", terminal.GetTitle())
+
+	feed(terminal, "\x1b]2;short\x07abc")
+	assert.Equal(t, "short", terminal.GetTitle(), "the next string should be handled")
+	assert.Equal(t, "abc", screenText(terminal)[0])
+}
+
+func TestDCSStringsEndedByEscapeBell(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 5)
+
+	feed(terminal, "\x1bPq#0;2;0;0;0#0~~\x1b\x07after")
+	assert.Equal(t, stateGround, terminal.parser.state)
+	assert.Equal(t, "after", strings.TrimLeft(screenText(terminal)[0], " "))
+}
+
+func TestOSCStringsEndedByNul(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 5)
+
+	// NUL is ignored in OSC strings, unless the pty ends them with it as ConPTY does
+	feed(terminal, "\x1b]2;one\x00two\x07")
+	assert.Equal(t, "onetwo", terminal.GetTitle())
+
+	terminal.platformDependentSettings.OSCTerminators = map[rune]struct{}{0x00: {}, 0x07: {}}
+	feed(terminal, "\x1b]2;three\x00after")
+	assert.Equal(t, "three", terminal.GetTitle())
+	assert.Equal(t, stateGround, terminal.parser.state)
+}
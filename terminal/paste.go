@@ -0,0 +1,48 @@
+package terminal
+
+import (
+	"strings"
+)
+
+// privilegedCommands run another command with elevated privileges
+var privilegedCommands = map[string]bool{
+	"sudo":   true,
+	"doas":   true,
+	"su":     true,
+	"pkexec": true,
+	"runas":  true,
+}
+
+// commandSeparators start a new command within a line
+var commandSeparators = strings.NewReplacer(";", "\n", "&", "\n", "|", "\n", "$(", "\n", "`", "\n", "(", "\n")
+
+// PrivilegedPasteLine returns the first line of text which looks like it runs a command with elevated privileges, e.g.
+// with sudo, if the text is more than one line. A web page can swap what was copied for something harmful, and a line
+// break may run it as soon as it's pasted. This is a heuristic, so a well hidden privileged command won't be found.
+func PrivilegedPasteLine(text string) (string, bool) {
+	if !strings.ContainsAny(text, "\r\n") {
+		return "", false
+	}
+
+	for _, line := range strings.FieldsFunc(text, func(r rune) bool { return r == '\r' || r == '\n' }) {
+		for _, command := range strings.Split(commandSeparators.Replace(line), "\n") {
+			if runsPrivileged(command) {
+				return strings.TrimSpace(line), true
+			}
+		}
+	}
+	return "", false
+}
+
+// runsPrivileged returns true if the program run by a simple command is one of the privilegedCommands
+func runsPrivileged(command string) bool {
+	for _, field := range strings.Fields(command) {
+		// skip variable assignments before the program, and a prompt copied along with the command
+		if strings.Contains(field, "=") || field == "$" || field == "#" || field == ">" {
+			continue
+		}
+		name := field[strings.LastIndex(field, "/")+1:]
+		return privilegedCommands[name]
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package terminal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivilegedPasteLine(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"sudo apt install vim", ""}, // not run until enter is pressed
+		{"ls\nsudo rm -rf /\n", "sudo rm -rf /"},
+		{"echo hi\n  $ sudo make install", "$ sudo make install"},
+		{"curl https://example.com/install.sh | sudo bash\n", "curl https://example.com/install.sh | sudo bash"},
+		{"cd build && DEBUG=1 /usr/bin/doas ninja install\r\n", "cd build && DEBUG=1 /usr/bin/doas ninja install"},
+		{"echo $(su -c id)\n", "echo $(su -c id)"},
+		{"echo sudo is not run\ngit status\n", ""},
+		{"pseudo-random\nsudoku\n", ""},
+	}
+
+	for _, test := range tests {
+		line, ok := PrivilegedPasteLine(test.text)
+		assert.Equal(t, test.expected != "", ok, test.text)
+		assert.Equal(t, test.expected, line, test.text)
+	}
+}
+
+func TestBracketedPaste(t *testing.T) {
+	terminal, pty := newTestTerminal(t, 80, 24)
+
+	assert.Nil(t, terminal.Paste([]byte("echo hi\n")))
+	assert.Equal(t, "echo hi\n", pty.output.String())
+
+	pty.output.Reset()
+	feed(terminal, "\x1b[?2004h")
+	assert.Nil(t, terminal.Paste([]byte("echo hi\n\x1b[201~rm -rf ~\n\x1b[20\x1b[201~1~")))
+	assert.Equal(t, "\x1b[200~echo hi\nrm -rf ~\n\x1b[201~", pty.output.String())
+}
@@ -0,0 +1,41 @@
+package terminal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingFile is a cast file which records whether it's been closed
+type recordingFile struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (file *recordingFile) Close() error {
+	file.closed = true
+	return nil
+}
+
+func TestRecordingPtyOutput(t *testing.T) {
+	terminal, pty := newTestTerminal(t, 40, 10)
+	pty.host = strings.NewReader("hello\r\n")
+
+	file := &recordingFile{}
+	require.Nil(t, terminal.StartRecording(file))
+	assert.True(t, terminal.IsRecording())
+	assert.NotNil(t, terminal.StartRecording(&recordingFile{}), "only one recording can be made at a time")
+
+	require.Nil(t, terminal.Read())
+	require.Nil(t, terminal.StopRecording())
+	assert.False(t, terminal.IsRecording())
+	assert.True(t, file.closed)
+
+	lines := strings.Split(strings.TrimSpace(file.String()), "\n")
+	require.Equal(t, 2, len(lines))
+	assert.Contains(t, lines[0], `"width":40,"height":10`)
+	assert.Contains(t, lines[1], `"o","hello\r\n"]`)
+}
@@ -2,8 +2,7 @@ package terminal
 
 import "fmt"
 
-func screenStateHandler(pty chan rune, terminal *Terminal) error {
-	b := <-pty
+func screenStateHandler(b rune, terminal *Terminal) error {
 	switch b {
 	case '8': // DECALN -- Screen Alignment Pattern
 		// hide cursor?
@@ -9,30 +9,64 @@ import (
 	"github.com/liamg/aminal/config"
 )
 
+// textDefaults returns the default foreground and background colours, as concrete colours to store in the cell
+// attributes. They are swapped while the screen is in reverse video (DECSCNM), as the colours of existing cells are,
+// so that SGR 39 or 49 can't give text the same foreground and background, and reverse video (SGR 7) always swaps two
+// different colours.
+func (terminal *Terminal) textDefaults() (config.Colour, config.Colour) {
+	fg, bg := terminal.colours.Foreground, terminal.colours.Background
+	if terminal.terminalState.ScreenMode {
+		return bg, fg
+	}
+	return fg, bg
+}
+
 func sgrSequenceHandler(params []string, terminal *Terminal) error {
 
 	if len(params) == 0 {
 		params = []string{"0"}
 	}
 
-	for i := range params {
+	for i := 0; i < len(params); i++ {
 
 		p := strings.Replace(strings.Replace(params[i], "[", "", -1), "]", "", -1)
 
+		if sub := strings.Split(p, ":"); len(sub) > 1 && (sub[0] == "38" || sub[0] == "48") {
+			// ISO 8613-6 separates the parts of a colour with colons, and includes a colour space ID in true colours
+			colour := sub[1:]
+			if colour[0] == "2" && len(colour) > 4 {
+				colour = append([]string{"2"}, colour[2:]...)
+			}
+			c, index, _, err := terminal.getANSIColour(colour)
+			if err != nil {
+				return err
+			}
+			if sub[0] == "38" {
+				terminal.ActiveBuffer().CursorAttr().SetFg(c, index)
+			} else {
+				terminal.ActiveBuffer().CursorAttr().SetBg(c, index)
+			}
+			continue
+		}
+
 		switch p {
 		case "00", "0", "":
+			fg, bg := terminal.textDefaults()
 			attr := terminal.ActiveBuffer().CursorAttr()
 			*attr = buffer.CellAttributes{
-				FgColour: terminal.config.ColourScheme.Foreground,
-				BgColour: terminal.config.ColourScheme.Background,
+				FgColour: fg,
+				BgColour: bg,
 			}
 		case "1", "01":
 			terminal.ActiveBuffer().CursorAttr().Bold = true
 		case "2", "02":
 			terminal.ActiveBuffer().CursorAttr().Dim = true
+		case "3", "03":
+			terminal.ActiveBuffer().CursorAttr().Italic = true
 		case "4", "04":
 			terminal.ActiveBuffer().CursorAttr().Underline = true
-		case "5", "05":
+		case "5", "05", "6", "06":
+			// rapid blinking is drawn as slow blinking
 			terminal.ActiveBuffer().CursorAttr().Blink = true
 		case "7", "07":
 			terminal.ActiveBuffer().CursorAttr().Inverse = true
@@ -43,7 +77,7 @@ func sgrSequenceHandler(params []string, terminal *Terminal) error {
 		case "22":
 			terminal.ActiveBuffer().CursorAttr().Dim = false
 		case "23":
-			// not italic
+			terminal.ActiveBuffer().CursorAttr().Italic = false
 		case "24":
 			terminal.ActiveBuffer().CursorAttr().Underline = false
 		case "25":
@@ -55,87 +89,89 @@ func sgrSequenceHandler(params []string, terminal *Terminal) error {
 		case "29":
 			// not strikethrough
 		case "39":
-			terminal.ActiveBuffer().CursorAttr().FgColour = terminal.config.ColourScheme.Foreground
+			fg, _ := terminal.textDefaults()
+			terminal.ActiveBuffer().CursorAttr().SetFg(fg, buffer.DefaultColour)
 		case "30":
-			terminal.ActiveBuffer().CursorAttr().FgColour = terminal.config.ColourScheme.Black
+			terminal.ActiveBuffer().CursorAttr().SetFg(terminal.get8BitSGRColour(0), buffer.PaletteIndex(0))
 		case "31":
-			terminal.ActiveBuffer().CursorAttr().FgColour = terminal.config.ColourScheme.Red
+			terminal.ActiveBuffer().CursorAttr().SetFg(terminal.get8BitSGRColour(1), buffer.PaletteIndex(1))
 		case "32":
-			terminal.ActiveBuffer().CursorAttr().FgColour = terminal.config.ColourScheme.Green
+			terminal.ActiveBuffer().CursorAttr().SetFg(terminal.get8BitSGRColour(2), buffer.PaletteIndex(2))
 		case "33":
-			terminal.ActiveBuffer().CursorAttr().FgColour = terminal.config.ColourScheme.Yellow
+			terminal.ActiveBuffer().CursorAttr().SetFg(terminal.get8BitSGRColour(3), buffer.PaletteIndex(3))
 		case "34":
-			terminal.ActiveBuffer().CursorAttr().FgColour = terminal.config.ColourScheme.Blue
+			terminal.ActiveBuffer().CursorAttr().SetFg(terminal.get8BitSGRColour(4), buffer.PaletteIndex(4))
 		case "35":
-			terminal.ActiveBuffer().CursorAttr().FgColour = terminal.config.ColourScheme.Magenta
+			terminal.ActiveBuffer().CursorAttr().SetFg(terminal.get8BitSGRColour(5), buffer.PaletteIndex(5))
 		case "36":
-			terminal.ActiveBuffer().CursorAttr().FgColour = terminal.config.ColourScheme.Cyan
+			terminal.ActiveBuffer().CursorAttr().SetFg(terminal.get8BitSGRColour(6), buffer.PaletteIndex(6))
 		case "37":
-			terminal.ActiveBuffer().CursorAttr().FgColour = terminal.config.ColourScheme.White
+			terminal.ActiveBuffer().CursorAttr().SetFg(terminal.get8BitSGRColour(7), buffer.PaletteIndex(7))
 		case "90":
-			terminal.ActiveBuffer().CursorAttr().FgColour = terminal.config.ColourScheme.DarkGrey
+			terminal.ActiveBuffer().CursorAttr().SetFg(terminal.get8BitSGRColour(8), buffer.PaletteIndex(8))
 		case "91":
-			terminal.ActiveBuffer().CursorAttr().FgColour = terminal.config.ColourScheme.LightRed
+			terminal.ActiveBuffer().CursorAttr().SetFg(terminal.get8BitSGRColour(9), buffer.PaletteIndex(9))
 		case "92":
-			terminal.ActiveBuffer().CursorAttr().FgColour = terminal.config.ColourScheme.LightGreen
+			terminal.ActiveBuffer().CursorAttr().SetFg(terminal.get8BitSGRColour(10), buffer.PaletteIndex(10))
 		case "93":
-			terminal.ActiveBuffer().CursorAttr().FgColour = terminal.config.ColourScheme.LightYellow
+			terminal.ActiveBuffer().CursorAttr().SetFg(terminal.get8BitSGRColour(11), buffer.PaletteIndex(11))
 		case "94":
-			terminal.ActiveBuffer().CursorAttr().FgColour = terminal.config.ColourScheme.LightBlue
+			terminal.ActiveBuffer().CursorAttr().SetFg(terminal.get8BitSGRColour(12), buffer.PaletteIndex(12))
 		case "95":
-			terminal.ActiveBuffer().CursorAttr().FgColour = terminal.config.ColourScheme.LightMagenta
+			terminal.ActiveBuffer().CursorAttr().SetFg(terminal.get8BitSGRColour(13), buffer.PaletteIndex(13))
 		case "96":
-			terminal.ActiveBuffer().CursorAttr().FgColour = terminal.config.ColourScheme.LightCyan
+			terminal.ActiveBuffer().CursorAttr().SetFg(terminal.get8BitSGRColour(14), buffer.PaletteIndex(14))
 		case "97":
-			terminal.ActiveBuffer().CursorAttr().FgColour = terminal.config.ColourScheme.White
+			terminal.ActiveBuffer().CursorAttr().SetFg(terminal.get8BitSGRColour(15), buffer.PaletteIndex(15))
 		case "49":
-			terminal.ActiveBuffer().CursorAttr().BgColour = terminal.config.ColourScheme.Background
+			_, bg := terminal.textDefaults()
+			terminal.ActiveBuffer().CursorAttr().SetBg(bg, buffer.DefaultColour)
 		case "40":
-			terminal.ActiveBuffer().CursorAttr().BgColour = terminal.config.ColourScheme.Black
+			terminal.ActiveBuffer().CursorAttr().SetBg(terminal.get8BitSGRColour(0), buffer.PaletteIndex(0))
 		case "41":
-			terminal.ActiveBuffer().CursorAttr().BgColour = terminal.config.ColourScheme.Red
+			terminal.ActiveBuffer().CursorAttr().SetBg(terminal.get8BitSGRColour(1), buffer.PaletteIndex(1))
 		case "42":
-			terminal.ActiveBuffer().CursorAttr().BgColour = terminal.config.ColourScheme.Green
+			terminal.ActiveBuffer().CursorAttr().SetBg(terminal.get8BitSGRColour(2), buffer.PaletteIndex(2))
 		case "43":
-			terminal.ActiveBuffer().CursorAttr().BgColour = terminal.config.ColourScheme.Yellow
+			terminal.ActiveBuffer().CursorAttr().SetBg(terminal.get8BitSGRColour(3), buffer.PaletteIndex(3))
 		case "44":
-			terminal.ActiveBuffer().CursorAttr().BgColour = terminal.config.ColourScheme.Blue
+			terminal.ActiveBuffer().CursorAttr().SetBg(terminal.get8BitSGRColour(4), buffer.PaletteIndex(4))
 		case "45":
-			terminal.ActiveBuffer().CursorAttr().BgColour = terminal.config.ColourScheme.Magenta
+			terminal.ActiveBuffer().CursorAttr().SetBg(terminal.get8BitSGRColour(5), buffer.PaletteIndex(5))
 		case "46":
-			terminal.ActiveBuffer().CursorAttr().BgColour = terminal.config.ColourScheme.Cyan
+			terminal.ActiveBuffer().CursorAttr().SetBg(terminal.get8BitSGRColour(6), buffer.PaletteIndex(6))
 		case "47":
-			terminal.ActiveBuffer().CursorAttr().BgColour = terminal.config.ColourScheme.White
+			terminal.ActiveBuffer().CursorAttr().SetBg(terminal.get8BitSGRColour(7), buffer.PaletteIndex(7))
 		case "100":
-			terminal.ActiveBuffer().CursorAttr().BgColour = terminal.config.ColourScheme.DarkGrey
+			terminal.ActiveBuffer().CursorAttr().SetBg(terminal.get8BitSGRColour(8), buffer.PaletteIndex(8))
 		case "101":
-			terminal.ActiveBuffer().CursorAttr().BgColour = terminal.config.ColourScheme.LightRed
+			terminal.ActiveBuffer().CursorAttr().SetBg(terminal.get8BitSGRColour(9), buffer.PaletteIndex(9))
 		case "102":
-			terminal.ActiveBuffer().CursorAttr().BgColour = terminal.config.ColourScheme.LightGreen
+			terminal.ActiveBuffer().CursorAttr().SetBg(terminal.get8BitSGRColour(10), buffer.PaletteIndex(10))
 		case "103":
-			terminal.ActiveBuffer().CursorAttr().BgColour = terminal.config.ColourScheme.LightYellow
+			terminal.ActiveBuffer().CursorAttr().SetBg(terminal.get8BitSGRColour(11), buffer.PaletteIndex(11))
 		case "104":
-			terminal.ActiveBuffer().CursorAttr().BgColour = terminal.config.ColourScheme.LightBlue
+			terminal.ActiveBuffer().CursorAttr().SetBg(terminal.get8BitSGRColour(12), buffer.PaletteIndex(12))
 		case "105":
-			terminal.ActiveBuffer().CursorAttr().BgColour = terminal.config.ColourScheme.LightMagenta
+			terminal.ActiveBuffer().CursorAttr().SetBg(terminal.get8BitSGRColour(13), buffer.PaletteIndex(13))
 		case "106":
-			terminal.ActiveBuffer().CursorAttr().BgColour = terminal.config.ColourScheme.LightCyan
+			terminal.ActiveBuffer().CursorAttr().SetBg(terminal.get8BitSGRColour(14), buffer.PaletteIndex(14))
 		case "107":
-			terminal.ActiveBuffer().CursorAttr().BgColour = terminal.config.ColourScheme.White
+			terminal.ActiveBuffer().CursorAttr().SetBg(terminal.get8BitSGRColour(15), buffer.PaletteIndex(15))
 		case "38": // set foreground
-			c, err := terminal.getANSIColour(params[i:])
+			c, index, n, err := terminal.getANSIColour(params[i+1:])
 			if err != nil {
 				return err
 			}
-			terminal.ActiveBuffer().CursorAttr().FgColour = c
-			return nil
+			terminal.ActiveBuffer().CursorAttr().SetFg(c, index)
+			i += n
 		case "48": // set background
-			c, err := terminal.getANSIColour(params[i:])
+			c, index, n, err := terminal.getANSIColour(params[i+1:])
 			if err != nil {
 				return err
 			}
-			terminal.ActiveBuffer().CursorAttr().BgColour = c
-			return nil
+			terminal.ActiveBuffer().CursorAttr().SetBg(c, index)
+			i += n
 		default:
 			return fmt.Errorf("Unknown SGR control sequence: (ESC[%sm)", params[i:])
 		}
@@ -146,66 +182,47 @@ func sgrSequenceHandler(params []string, terminal *Terminal) error {
 	return nil
 }
 
-func (terminal *Terminal) getANSIColour(params []string) (config.Colour, error) {
+// getANSIColour parses the colour following SGR 38 or 48, either 5;n for a colour from the 256 colour palette or
+// 2;r;g;b for a true colour, returning where the colour came from and the number of parameters it took up
+func (terminal *Terminal) getANSIColour(params []string) (config.Colour, buffer.ColourIndex, int, error) {
 
-	if len(params) > 2 {
-		switch params[1] {
+	if len(params) > 1 {
+		switch params[0] {
 		case "5":
 			// 8 bit colour
-			colNum, err := strconv.Atoi(params[2])
+			colNum, err := strconv.Atoi(params[1])
 
 			if err != nil || colNum >= 256 || colNum < 0 {
-				return [3]float32{0, 0, 0}, fmt.Errorf("Invalid 8-bit colour specifier")
+				return [3]float32{0, 0, 0}, 0, 0, fmt.Errorf("Invalid 8-bit colour specifier")
 			}
-			return terminal.get8BitSGRColour(uint8(colNum)), nil
+			return terminal.get8BitSGRColour(uint8(colNum)), buffer.PaletteIndex(uint8(colNum)), 2, nil
 
 		case "2":
+			// 24 bit colour
 			if len(params) < 4 {
-				return [3]float32{0, 0, 0}, fmt.Errorf("Invalid true colour specifier")
+				return [3]float32{0, 0, 0}, 0, 0, fmt.Errorf("Invalid true colour specifier")
 			}
-			// 24 bit colour
-			if len(params) == 5 { // standard true colour
-
-				r, err := strconv.Atoi(params[2])
-				if err != nil {
-					return [3]float32{0, 0, 0}, fmt.Errorf("Invalid true colour specifier")
-				}
-				g, err := strconv.Atoi(params[3])
-				if err != nil {
-					return [3]float32{0, 0, 0}, fmt.Errorf("Invalid true colour specifier")
-				}
-				b, err := strconv.Atoi(params[4])
-				if err != nil {
-					return [3]float32{0, 0, 0}, fmt.Errorf("Invalid true colour specifier")
+			var colour config.Colour
+			for i, component := range params[1:4] {
+				value := 0
+				if component != "" {
+					var err error
+					if value, err = strconv.Atoi(component); err != nil {
+						return [3]float32{0, 0, 0}, 0, 0, fmt.Errorf("Invalid true colour specifier")
+					}
 				}
-				return [3]float32{
-					float32(r) / 0xff,
-					float32(g) / 0xff,
-					float32(b) / 0xff,
-				}, nil
-			} else if len(params) > 5 { // ISO/IEC International Standard 8613-6
-				r, err := strconv.Atoi(params[3])
-				if err != nil {
-					return [3]float32{0, 0, 0}, fmt.Errorf("Invalid true colour specifier")
+				if value > 0xff {
+					value = 0xff
+				} else if value < 0 {
+					value = 0
 				}
-				g, err := strconv.Atoi(params[4])
-				if err != nil {
-					return [3]float32{0, 0, 0}, fmt.Errorf("Invalid true colour specifier")
-				}
-				b, err := strconv.Atoi(params[5])
-				if err != nil {
-					return [3]float32{0, 0, 0}, fmt.Errorf("Invalid true colour specifier")
-				}
-				return [3]float32{
-					float32(r) / 0xff,
-					float32(g) / 0xff,
-					float32(b) / 0xff,
-				}, nil
+				colour[i] = float32(value) / 0xff
 			}
+			return colour, buffer.TrueColour, 4, nil
 		}
 	}
 
-	return [3]float32{}, fmt.Errorf("Unknown ANSI colour format identifier")
+	return [3]float32{}, 0, 0, fmt.Errorf("Unknown ANSI colour format identifier")
 
 }
 
@@ -213,39 +230,46 @@ func (terminal *Terminal) get8BitSGRColour(colNum uint8) [3]float32 {
 
 	// https://en.wikipedia.org/wiki/ANSI_escape_code#8-bit
 
+	if colour, ok := terminal.palette[colNum]; ok {
+		return colour
+	}
+	if colour, ok := terminal.colours.PaletteColour(colNum); ok {
+		return colour
+	}
+
 	switch colNum {
 	case 0:
-		return terminal.config.ColourScheme.Black
+		return terminal.colours.Black
 	case 1:
-		return terminal.config.ColourScheme.Red
+		return terminal.colours.Red
 	case 2:
-		return terminal.config.ColourScheme.Green
+		return terminal.colours.Green
 	case 3:
-		return terminal.config.ColourScheme.Yellow
+		return terminal.colours.Yellow
 	case 4:
-		return terminal.config.ColourScheme.Blue
+		return terminal.colours.Blue
 	case 5:
-		return terminal.config.ColourScheme.Magenta
+		return terminal.colours.Magenta
 	case 6:
-		return terminal.config.ColourScheme.Cyan
+		return terminal.colours.Cyan
 	case 7:
-		return terminal.config.ColourScheme.White
+		return terminal.colours.White
 	case 8:
-		return terminal.config.ColourScheme.DarkGrey
+		return terminal.colours.DarkGrey
 	case 9:
-		return terminal.config.ColourScheme.LightRed
+		return terminal.colours.LightRed
 	case 10:
-		return terminal.config.ColourScheme.LightGreen
+		return terminal.colours.LightGreen
 	case 11:
-		return terminal.config.ColourScheme.LightYellow
+		return terminal.colours.LightYellow
 	case 12:
-		return terminal.config.ColourScheme.LightBlue
+		return terminal.colours.LightBlue
 	case 13:
-		return terminal.config.ColourScheme.LightMagenta
+		return terminal.colours.LightMagenta
 	case 14:
-		return terminal.config.ColourScheme.LightCyan
+		return terminal.colours.LightCyan
 	case 15:
-		return terminal.config.ColourScheme.White
+		return terminal.colours.White
 	}
 
 	if colNum < 232 {
@@ -0,0 +1,116 @@
+package terminal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReverseVideoWithDefaultColours(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 3)
+	fg, bg := terminal.colours.Foreground, terminal.colours.Background
+
+	feed(terminal, "\x1b[7ma\x1b[39;49mb\x1b[0;7mc")
+	cells := terminal.ActiveBuffer().GetVisibleLines()[0].Cells()
+	require.Equal(t, 3, len(cells))
+	for _, cell := range cells {
+		assert.Equal(t, [3]float32(bg), cell.Fg())
+		assert.Equal(t, [3]float32(fg), cell.Bg())
+	}
+
+	// with the whole screen in reverse video, the defaults are swapped too, so they must not end up the same
+	terminal.SetScreenMode(true)
+	feed(terminal, "\r\n\x1b[27;39mx\x1b[49my\x1b[0mz\x1b[7mw")
+	cells = terminal.ActiveBuffer().GetVisibleLines()[1].Cells()
+	require.Equal(t, 4, len(cells))
+	for _, cell := range cells[:3] {
+		assert.Equal(t, [3]float32(bg), cell.Fg(), string(cell.Rune()))
+		assert.Equal(t, [3]float32(fg), cell.Bg(), string(cell.Rune()))
+	}
+	assert.Equal(t, [3]float32(fg), cells[3].Fg())
+	assert.Equal(t, [3]float32(bg), cells[3].Bg())
+}
+
+func TestSGRColours(t *testing.T) {
+	tests := []struct {
+		sequence string
+		fg       [3]float32
+		bg       [3]float32
+	}{
+		{"\x1b[38;2;255;128;0m", [3]float32{1, 128.0 / 255, 0}, [3]float32{}},
+		{"\x1b[38;2;1;2;3;48;2;4;5;6m", [3]float32{1.0 / 255, 2.0 / 255, 3.0 / 255}, [3]float32{4.0 / 255, 5.0 / 255, 6.0 / 255}},
+		{"\x1b[38:2::10:20:30m", [3]float32{10.0 / 255, 20.0 / 255, 30.0 / 255}, [3]float32{}},
+		{"\x1b[38:2:10:20:30m", [3]float32{10.0 / 255, 20.0 / 255, 30.0 / 255}, [3]float32{}},
+		{"\x1b[48:2:0:1000:128:0m", [3]float32{}, [3]float32{1, 128.0 / 255, 0}},
+		{"\x1b[38;2;300;;0m", [3]float32{1, 0, 0}, [3]float32{}},
+	}
+
+	for _, test := range tests {
+		terminal, _ := newTestTerminal(t, 20, 3)
+		feed(terminal, "\x1b[38;2;0;0;0;48;2;0;0;0m"+test.sequence)
+		attr := terminal.ActiveBuffer().CursorAttr()
+		assert.Equal(t, test.fg, [3]float32(attr.FgColour), "%q", test.sequence)
+		assert.Equal(t, test.bg, [3]float32(attr.BgColour), "%q", test.sequence)
+	}
+
+	terminal, _ := newTestTerminal(t, 20, 3)
+	feed(terminal, "\x1b[38;5;196;48:5:21m")
+	assert.Equal(t, terminal.get8BitSGRColour(196), terminal.ActiveBuffer().CursorAttr().FgColour)
+	assert.Equal(t, terminal.get8BitSGRColour(21), terminal.ActiveBuffer().CursorAttr().BgColour)
+
+	// attributes after a colour aren't lost
+	feed(terminal, "\x1b[38;2;1;2;3;1;4m")
+	assert.True(t, terminal.ActiveBuffer().CursorAttr().Bold)
+	assert.True(t, terminal.ActiveBuffer().CursorAttr().Underline)
+}
+
+func TestBlinkAttribute(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 3)
+
+	feed(terminal, "\x1b[5ma\x1b[25mb\x1b[6mc")
+	cells := terminal.ActiveBuffer().GetVisibleLines()[0].Cells()
+	require.Equal(t, 3, len(cells))
+	assert.True(t, cells[0].Attr().Blink)
+	assert.False(t, cells[1].Attr().Blink)
+	assert.True(t, cells[2].Attr().Blink)
+}
+
+func TestItalicAttribute(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 3)
+
+	feed(terminal, "\x1b[3ma\x1b[1mb\x1b[23mc\x1b[0;3md\x1b[0me")
+	cells := terminal.ActiveBuffer().GetVisibleLines()[0].Cells()
+	require.Equal(t, 5, len(cells))
+	assert.True(t, cells[0].Attr().Italic)
+	assert.True(t, cells[1].Attr().Italic)
+	assert.True(t, cells[1].Attr().Bold)
+	assert.False(t, cells[2].Attr().Italic)
+	assert.True(t, cells[2].Attr().Bold)
+	assert.True(t, cells[3].Attr().Italic)
+	assert.False(t, cells[4].Attr().Italic)
+}
+
+func TestBoldColour(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 20, 3)
+	scheme := terminal.colours
+	require.Equal(t, [3]float32{128 / 255.0, 0, 0}, [3]float32(scheme.Red), "the test needs a true colour equal to red")
+
+	feed(terminal, "\x1b[1;31ma\x1b[38;5;6mb\x1b[38;2;128;0;0mc\x1b[91md\x1b[39me")
+	cells := terminal.ActiveBuffer().GetVisibleLines()[0].Cells()
+	bold := func(i int) [3]float32 { return terminal.BoldColour(cells[i].Attr()) }
+
+	assert.Equal(t, [3]float32(scheme.LightRed), bold(0))
+	assert.Equal(t, [3]float32(scheme.LightCyan), bold(1))
+	assert.Equal(t, [3]float32(scheme.Red), bold(2), "a true colour which happens to be red shouldn't be brightened")
+	assert.Equal(t, [3]float32(scheme.LightRed), bold(3))
+	assert.Equal(t, [3]float32(scheme.Foreground), bold(4))
+
+	feed(terminal, "\x1b]4;9;#ff00ff\x07\x1b]4;1;#00ff00\x07\x1b[31mf")
+	cells = terminal.ActiveBuffer().GetVisibleLines()[0].Cells()
+	assert.Equal(t, [3]float32{1, 0, 1}, bold(0), "bold red should be drawn in the bright red of the palette, as OSC 4 changed it")
+	assert.Equal(t, [3]float32{1, 0, 1}, bold(5))
+
+	terminal.config.BoldIsBright = false
+	assert.Equal(t, [3]float32{0, 1, 0}, bold(5))
+}
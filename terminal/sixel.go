@@ -3,8 +3,8 @@ package terminal
 import (
 	"fmt"
 	"image"
+	"image/color"
 	"image/draw"
-	"math"
 	"strings"
 
 	"github.com/liamg/aminal/matrix"
@@ -15,13 +15,57 @@ type boolFormRuneFunc func(rune) bool
 
 func swallowByFunction(pty chan rune, isTerminator boolFormRuneFunc) {
 	for {
-		b := <-pty
-		if isTerminator(b) {
+		b, ok := <-pty
+		if !ok || isTerminator(b) {
 			break
 		}
 	}
 }
 
+type runeRange struct {
+	min rune
+	max rune
+}
+
+var csiTerminators = runeRange{0x40, 0x7e}
+
+// loadCSI reads a CSI injected into sixel data from pty, after its ESC [
+func loadCSI(pty chan rune) (final rune, param string, intermediate []rune) {
+	var b rune
+	param = ""
+	intermediate = []rune{}
+CSI:
+	for {
+		var ok bool
+		b, ok = <-pty
+		if !ok {
+			break CSI
+		}
+		switch true {
+		case b >= 0x30 && b <= 0x3F:
+			param = param + string(b)
+		case b > 0 && b <= 0x2F:
+			intermediate = append(intermediate, b)
+		case b >= csiTerminators.min && b <= csiTerminators.max:
+			final = b
+			break CSI
+		}
+	}
+
+	return final, param, intermediate
+}
+
+// dcsHandler handles a DCS, with the parameter bytes param, intermediate bytes and final byte before its string data
+func dcsHandler(param string, intermediates string, final rune, data []rune, terminal *Terminal) error {
+	if final == 'q' && intermediates == "" {
+		return sixelHandler(param, data, terminal)
+	}
+	if final == 'q' && intermediates == "$" && param == "" {
+		return requestStatusStringHandler(string(data), terminal)
+	}
+	return fmt.Errorf("Unknown DCS control sequence: 0x%02X (ESC P%s%s%s)", final, param, intermediates, string(final))
+}
+
 func filter(src []rune) []rune {
 	result := make([]rune, 0, len(src))
 	for _, v := range src {
@@ -32,9 +76,20 @@ func filter(src []rune) []rune {
 	return result
 }
 
-func sixelHandler(pty chan rune, terminal *Terminal) error {
+// sixelHandler draws the sixel image in data, which is laid out as it was drawn in the window with any escape sequences
+// ConPTY injected into it while redrawing
+func sixelHandler(param string, data []rune, terminal *Terminal) error {
 	debug := ""
 
+	pty := make(chan rune, len(param)+1+len(data))
+	for _, b := range param + "q" {
+		pty <- b
+	}
+	for _, b := range data {
+		pty <- b
+	}
+	close(pty)
+
 	// data := []rune{}
 
 	// track for Windows formatting workaround
@@ -46,7 +101,10 @@ func sixelHandler(pty chan rune, terminal *Terminal) error {
 	yStartWithOffset := y + scrollOffset
 	matrix := matrix.NewAutoMatrix() // a simplified version of Buffer
 	for {
-		b := <-pty
+		b, ok := <-pty
+		if !ok {
+			break
+		}
 		if b == 0x1b {
 			t := <-pty
 			if t == '[' { // Windows injected a CSI sequence
@@ -76,10 +134,8 @@ func sixelHandler(pty chan rune, terminal *Terminal) error {
 				x += 2
 				continue
 			}
-			if t != 0x07 && t != 0x5c {
-				return fmt.Errorf("Incorrect terminator in sixel sequence: 0x%02X [%c]", t, t)
-			}
-			break // terminated by ESC bell or ESC \
+			// the parser ends the data at ESC \ or ESC BEL, so any other escape sequence is out of place
+			return fmt.Errorf("Unexpected escape sequence in sixel data: ESC 0x%02X [%c]", t, t)
 		}
 
 		if b == 0x0d {
@@ -136,42 +192,45 @@ func sixelHandler(pty chan rune, terminal *Terminal) error {
 	return nil
 }
 
+// drawSixel draws the image with its top left corner in the cursor's cell, leaving the cursor at the start of the line
+// below it. The image is cut into tiles the size of a cell, drawn over the cell's background, which are stored in the
+// cells they cover so that they scroll, and are evicted from the scrollback, along with the text.
 func drawSixel(six *sixel.Sixel, terminal *Terminal) {
-	originalImage := six.RGBA()
-
-	w := originalImage.Bounds().Size().X
-	h := originalImage.Bounds().Size().Y
-
-	x, y := terminal.ActiveBuffer().CursorColumn(), terminal.ActiveBuffer().CursorLine()
-
-	fromBottom := int(terminal.ActiveBuffer().ViewHeight() - y)
-	lines := int(math.Ceil(float64(h) / float64(terminal.charHeight)))
-	if fromBottom < lines+2 {
-		y -= (uint16(lines+2) - uint16(fromBottom))
+	img := six.RGBA()
+	cellWidth, cellHeight := int(terminal.charWidth), int(terminal.charHeight)
+	if cellWidth == 0 || cellHeight == 0 {
+		return
 	}
-	for l := 0; l <= int(lines); l++ {
-		terminal.ActiveBuffer().Write([]rune(strings.Repeat(" ", int(terminal.ActiveBuffer().ViewWidth())))...)
-		terminal.ActiveBuffer().NewLine()
+
+	buffer := terminal.ActiveBuffer()
+	x := buffer.CursorColumn()
+	cols := (img.Bounds().Dx() + cellWidth - 1) / cellWidth
+	if max := int(buffer.ViewWidth()) - int(x); cols > max {
+		cols = max
 	}
-	cols := int(math.Ceil(float64(w) / float64(terminal.charWidth)))
+	lines := (img.Bounds().Dy() + cellHeight - 1) / cellHeight
 
-	for offsetY := 0; offsetY < lines-1; offsetY++ {
-		for offsetX := 0; offsetX < cols-1; offsetX++ {
+	for offsetY := 0; offsetY < lines; offsetY++ {
+		buffer.MovePosition(int16(x)-int16(buffer.CursorColumn()), 0)
+		buffer.Write([]rune(strings.Repeat(" ", cols))...)
 
-			cell := terminal.ActiveBuffer().GetCell(x+uint16(offsetX), y+uint16((lines-2)-offsetY))
+		for offsetX := 0; offsetX < cols; offsetX++ {
+			cell := buffer.GetCell(x+uint16(offsetX), buffer.CursorLine())
 			if cell == nil {
 				continue
 			}
-			img := originalImage.SubImage(image.Rect(
-				offsetX*int(terminal.charWidth),
-				offsetY*int(terminal.charHeight),
-				(offsetX*int(terminal.charWidth))+int(terminal.charWidth),
-				(offsetY*int(terminal.charHeight))+int(terminal.charHeight),
-			))
-
-			rgba := image.NewRGBA(image.Rect(0, 0, int(terminal.charWidth), int(terminal.charHeight)))
-			draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
-			cell.SetImage(rgba)
+			bg := cell.Bg()
+			tile := image.NewRGBA(image.Rect(0, 0, cellWidth, cellHeight))
+			draw.Draw(tile, tile.Bounds(), image.NewUniform(color.RGBA{
+				R: uint8(bg[0] * 255),
+				G: uint8(bg[1] * 255),
+				B: uint8(bg[2] * 255),
+				A: 255,
+			}), image.Point{}, draw.Src)
+			draw.Draw(tile, tile.Bounds(), img, image.Pt(offsetX*cellWidth, offsetY*cellHeight), draw.Over)
+			cell.SetImage(tile)
 		}
+
+		buffer.NewLine()
 	}
 }
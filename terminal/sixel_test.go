@@ -0,0 +1,50 @@
+package terminal
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSixelIsDrawnAtCursor(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 10, 5)
+	terminal.SetCharSize(2, 2)
+
+	feed(terminal, "ab\x1bPq\"1;1;3;6#1;2;100;0;0!3~\x1b\\")
+
+	red := color.RGBA{R: 255, A: 255}
+	buffer := terminal.ActiveBuffer()
+	for row := uint16(0); row < 3; row++ {
+		for col := uint16(2); col < 4; col++ {
+			cell := buffer.GetCell(col, row)
+			require.NotNil(t, cell)
+			require.NotNil(t, cell.Image(), "cell %d,%d should be part of the image", col, row)
+			assert.Equal(t, 2, cell.Image().Bounds().Dx())
+			assert.Equal(t, red, cell.Image().RGBAAt(0, 1))
+		}
+	}
+	bg := terminal.ActiveBuffer().GetCell(3, 0).Bg()
+	assert.Equal(t, color.RGBA{R: uint8(bg[0] * 255), G: uint8(bg[1] * 255), B: uint8(bg[2] * 255), A: 255}, buffer.GetCell(3, 0).Image().RGBAAt(1, 0), "the image should be drawn over the cell background")
+	assert.False(t, hasImage(terminal, 4, 0))
+	assert.Equal(t, "ab", visibleText(terminal)[0][:2])
+
+	assert.Equal(t, uint16(0), buffer.CursorColumn())
+	assert.Equal(t, uint16(3), buffer.CursorLine(), "the cursor should be below the image")
+
+	// the image scrolls with the text
+	feed(terminal, "\n\n\n")
+	assert.True(t, hasImage(terminal, 2, 0))
+	assert.False(t, hasImage(terminal, 2, 1))
+
+	// and text replaces it
+	feed(terminal, "\x1b[1;3Hx")
+	assert.False(t, hasImage(terminal, 2, 0))
+	assert.True(t, hasImage(terminal, 3, 0))
+}
+
+func hasImage(terminal *Terminal, col uint16, row uint16) bool {
+	cell := terminal.ActiveBuffer().GetCell(col, row)
+	return cell != nil && cell.Image() != nil
+}
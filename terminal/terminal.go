@@ -2,11 +2,15 @@ package terminal
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/liamg/aminal/buffer"
+	"github.com/liamg/aminal/cast"
 	"github.com/liamg/aminal/config"
 	"github.com/liamg/aminal/platform"
 	"go.uber.org/zap"
@@ -37,26 +41,52 @@ type Terminal struct {
 	pty                       platform.Pty
 	logger                    *zap.SugaredLogger
 	title                     string
+	iconName                  string // set by OSC 0 and 1, which is only kept to be saved on the title stack
+	titleStack                []titleStackEntry
+	workingDirectory          string // as reported by the shell with OSC 7
 	size                      Winsize
+	resizer                   *resizeDebouncer
 	config                    *config.Config
+	colours                   config.ColourScheme     // the configured colours, which the application may change in this terminal alone
+	defaultColours            [2]config.Colour        // foreground and background from the config, restored by OSC 110 and 111
+	palette                   map[uint8]config.Colour // colours set by OSC 4, in place of those from the config
+	brightColours             [8]config.Colour        // palette colours 8-15, kept out of the map for BoldColour, which the gui calls while output is processed
 	titleHandlers             []chan bool
 	resizeHandlers            []chan bool
 	reverseHandlers           []chan bool
+	bellHandlers              []chan bool
+	clipboardHandlers         []chan ClipboardRequest
+	dirtyHandlers             []chan bool
+	lastBell                  time.Time
 	modes                     Modes
 	mouseMode                 MouseMode
+	mouseExtMode              MouseExtMode
+	mouseButton               MouseButton // the button most recently pressed
+	mouseButtonHeld           bool
+	mouseX                    int // the cell of the last mouse report
+	mouseY                    int
+	mouseHighlight            mouseHighlight
 	bracketedPasteMode        bool
+	focusReportingMode        bool
 	isDirty                   bool
+	fullRedraw                bool // every row must be redrawn, regardless of the rows marked dirty by the active buffer
 	charWidth                 float32
 	charHeight                float32
 	lastBuffer                uint8
 	terminalState             *buffer.TerminalState
 	platformDependentSettings platform.PlatformDependentSettings
+	parser                    parser
+	partialRune               []byte // the bytes of a UTF-8 character cut off at the end of the data passed to ProcessInput
+	recorder                  *cast.Recorder
+	recording                 io.Closer // the file the recorder writes to
+	recordingLock             sync.Mutex
 }
 
 type Modes struct {
 	ShowCursor            bool
 	ApplicationCursorKeys bool
 	BlinkingCursor        bool
+	CursorShape           config.CursorShape // as requested by the application via DECSCUSR
 }
 
 type Winsize struct {
@@ -75,18 +105,28 @@ func New(pty platform.Pty, logger *zap.SugaredLogger, config *config.Config) *Te
 		pty:           pty,
 		logger:        logger,
 		config:        config,
+		colours:       config.ColourScheme,
 		titleHandlers: []chan bool{},
 		modes: Modes{
-			ShowCursor: true,
+			ShowCursor:  true,
+			CursorShape: defaultCursorShape(config),
 		},
 		platformDependentSettings: pty.GetPlatformDependentSettings(),
 	}
+	t.terminalState.WordSeparators = config.MultiClick.WordSeparators
+	t.terminalState.SetMaxScrollbackBytes(config.MaxScrollbackBytes)
 	t.buffers = []*buffer.Buffer{
 		buffer.NewBuffer(t.terminalState),
 		buffer.NewBuffer(t.terminalState),
 		buffer.NewBuffer(t.terminalState),
 	}
+	// full screen applications on the alternate screen redraw it, so the lines they scroll away aren't worth keeping
+	t.buffers[AltBuffer].DisableScrollback()
 	t.activeBuffer = t.buffers[0]
+	t.resizer = newResizeDebouncer(resizeDebounce, t.sendWinsize)
+	t.defaultColours[0] = config.ColourScheme.Foreground
+	t.defaultColours[1] = config.ColourScheme.Background
+	t.updateBrightColours()
 	return t
 
 }
@@ -99,6 +139,10 @@ func (terminal *Terminal) SetBracketedPasteMode(enabled bool) {
 	terminal.bracketedPasteMode = enabled
 }
 
+func (terminal *Terminal) SetFocusReportingMode(enabled bool) {
+	terminal.focusReportingMode = enabled
+}
+
 func (terminal *Terminal) CheckDirty() bool {
 	d := terminal.isDirty
 	terminal.isDirty = false
@@ -107,41 +151,129 @@ func (terminal *Terminal) CheckDirty() bool {
 
 func (terminal *Terminal) SetDirty() {
 	terminal.isDirty = true
+	terminal.fullRedraw = true
+	terminal.notifyDirty()
+}
+
+// AttachDirtyHandler registers a channel which is signalled when the terminal needs redrawing. A single notification may cover many changes.
+func (terminal *Terminal) AttachDirtyHandler(handler chan bool) {
+	terminal.dirtyHandlers = append(terminal.dirtyHandlers, handler)
+}
+
+func (terminal *Terminal) notifyDirty() {
+	for _, h := range terminal.dirtyHandlers {
+		select {
+		case h <- true:
+		default:
+			// a notification is already pending
+		}
+	}
+}
+
+// TakeDirtyRows returns the view rows of the active buffer which have changed since the last call - all rows are dirty after SetDirty
+func (terminal *Terminal) TakeDirtyRows() buffer.DirtyRows {
+	rows := terminal.ActiveBuffer().TakeDirtyRows()
+	if terminal.fullRedraw {
+		rows.SetAll()
+		terminal.fullRedraw = false
+	}
+	return rows
 }
 
 func (terminal *Terminal) IsApplicationCursorKeysModeEnabled() bool {
 	return terminal.modes.ApplicationCursorKeys
 }
 
+// SetMouseMode sets the kinds of mouse event reported to the application, replacing the mode set previously. The GUI
+// passes every event to ReportMouse, which checks the current mode, so changing it never leaves events half reported.
 func (terminal *Terminal) SetMouseMode(mode MouseMode) {
 	terminal.mouseMode = mode
+	if terminal.mouseHighlight.tracking {
+		terminal.ActiveBuffer().ClearSelection()
+	}
+	terminal.mouseHighlight = mouseHighlight{}
+	// the first motion in the new mode is reported, even within the cell of the last event reported
+	terminal.mouseX = 0
+	terminal.mouseY = 0
+}
+
+// defaultCursorShape is the configured cursor shape, used until the application asks for another
+func defaultCursorShape(conf *config.Config) config.CursorShape {
+	if conf.CursorStyle == config.CursorShapeDefault {
+		return config.CursorShapeBlock
+	}
+	return conf.CursorStyle
+}
+
+// SetCursorStyle stores the cursor style requested by the application
+func (terminal *Terminal) SetCursorStyle(shape config.CursorShape, blinking bool) {
+	terminal.modes.CursorShape = shape
+	terminal.modes.BlinkingCursor = blinking
+	terminal.SetDirty()
 }
 
 func (terminal *Terminal) GetMouseMode() MouseMode {
 	return terminal.mouseMode
 }
 
+// IsOSCTerminator returns true if char ends an OSC string without an ESC before it. BEL always does, as in xterm, and
+// ConPTY also ends the strings it writes with NUL.
 func (terminal *Terminal) IsOSCTerminator(char rune) bool {
+	if char == 0x07 {
+		return true
+	}
 	_, ok := terminal.platformDependentSettings.OSCTerminators[char]
 	return ok
 }
 
+// c1Controls returns true if the bytes 0x80-0x9F in the output are 8-bit C1 controls. This is only the case when the
+// output is decoded as Latin-1, as in UTF-8 they're continuation bytes which are part of other characters.
+func (terminal *Terminal) c1Controls() bool {
+	return terminal.config.Latin1
+}
+
+// runeReader returns a reader which decodes the output in r as Latin-1 or UTF-8, depending on the config
+func (terminal *Terminal) runeReader(r io.Reader) io.RuneReader {
+	reader := bufio.NewReader(r)
+	if terminal.config.Latin1 {
+		return latin1Reader{reader}
+	}
+	return reader
+}
+
+// latin1Reader decodes ISO 8859-1, in which every byte is the character with the same code point
+type latin1Reader struct {
+	io.ByteReader
+}
+
+func (r latin1Reader) ReadRune() (rune, int, error) {
+	b, err := r.ReadByte()
+	return rune(b), 1, err
+}
+
 func (terminal *Terminal) UseMainBuffer() {
+	defer terminal.SetDirty()
+	terminal.terminalState.SetScrollOffset(0)
 	terminal.activeBuffer = terminal.buffers[MainBuffer]
 	terminal.SetSize(uint(terminal.size.Width), uint(terminal.size.Height))
 }
 
 func (terminal *Terminal) UseAltBuffer() {
+	defer terminal.SetDirty()
+	// the scroll offset is shared by the buffers, and the alternate screen has no scrollback to be scrolled into
+	terminal.terminalState.SetScrollOffset(0)
 	terminal.activeBuffer = terminal.buffers[AltBuffer]
 	terminal.SetSize(uint(terminal.size.Width), uint(terminal.size.Height))
 }
 
 func (terminal *Terminal) UseInternalBuffer() {
+	defer terminal.SetDirty()
 	terminal.activeBuffer = terminal.buffers[InternalBuffer]
 	terminal.SetSize(uint(terminal.size.Width), uint(terminal.size.Height))
 }
 
 func (terminal *Terminal) ExitInternalBuffer() {
+	defer terminal.SetDirty()
 	terminal.activeBuffer = terminal.buffers[terminal.lastBuffer]
 }
 
@@ -210,6 +342,9 @@ func (terminal *Terminal) ScrollPageUp() {
 }
 
 func (terminal *Terminal) ScrollToEnd() {
+	if terminal.terminalState.GetScrollOffset() == 0 {
+		return
+	}
 	defer terminal.SetDirty()
 	terminal.terminalState.SetScrollOffset(0)
 }
@@ -234,6 +369,10 @@ func (terminal *Terminal) AttachReverseHandler(handler chan bool) {
 	terminal.reverseHandlers = append(terminal.reverseHandlers, handler)
 }
 
+func (terminal *Terminal) AttachBellHandler(handler chan bool) {
+	terminal.bellHandlers = append(terminal.bellHandlers, handler)
+}
+
 func (terminal *Terminal) Modes() Modes {
 	return terminal.modes
 }
@@ -262,6 +401,30 @@ func (terminal *Terminal) emitReverse(reverse bool) {
 	}
 }
 
+func (terminal *Terminal) emitBell() {
+	for _, h := range terminal.bellHandlers {
+		go func(c chan bool) {
+			c <- true
+		}(h)
+	}
+}
+
+// ringBell notifies the bell handlers, unless the bell is muted or was shown too recently
+func (terminal *Terminal) ringBell() {
+	if terminal.config.Bell.Mute {
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(terminal.lastBell) < time.Duration(terminal.config.Bell.MinInterval)*time.Millisecond {
+		// coalesce with the bell already shown rather than queueing another
+		return
+	}
+
+	terminal.lastBell = now
+	terminal.emitBell()
+}
+
 func (terminal *Terminal) GetLogicalCursorX() uint16 {
 	if terminal.ActiveBuffer().CursorColumn() >= terminal.ActiveBuffer().Width() {
 		return 0
@@ -287,7 +450,71 @@ func (terminal *Terminal) SetTitle(title string) {
 	terminal.emitTitleChange()
 }
 
-// Write sends data, i.e. locally typed keystrokes to the pty
+// titleStackEntry is a title and icon name saved by CSI 22 t, to be restored by CSI 23 t. Either can be saved without
+// the other, and only the parts which were saved are restored.
+type titleStackEntry struct {
+	title       string
+	iconName    string
+	hasTitle    bool
+	hasIconName bool
+}
+
+// maxTitleStackDepth is the most titles saved, as in xterm, so an application which never restores them can't use up
+// memory. The oldest is discarded to make room.
+const maxTitleStackDepth = 10
+
+// pushTitle saves the title and icon name, where which is the parameter of CSI 22 ; Ps t selecting what is saved
+func (terminal *Terminal) pushTitle(which string) error {
+	entry := titleStackEntry{title: terminal.title, iconName: terminal.iconName}
+	switch which {
+	case "", "0":
+		entry.hasTitle, entry.hasIconName = true, true
+	case "1":
+		entry.hasIconName = true
+	case "2":
+		entry.hasTitle = true
+	default:
+		return fmt.Errorf("Invalid title stack selector: %s", which)
+	}
+
+	if len(terminal.titleStack) == maxTitleStackDepth {
+		terminal.titleStack = terminal.titleStack[1:]
+	}
+	terminal.titleStack = append(terminal.titleStack, entry)
+	return nil
+}
+
+// popTitle restores the last title and icon name saved, where which is the parameter of CSI 23 ; Ps t selecting what
+// is restored
+func (terminal *Terminal) popTitle(which string) error {
+	if len(terminal.titleStack) == 0 {
+		return nil
+	}
+	entry := terminal.titleStack[len(terminal.titleStack)-1]
+	terminal.titleStack = terminal.titleStack[:len(terminal.titleStack)-1]
+
+	restoreTitle, restoreIconName := false, false
+	switch which {
+	case "", "0":
+		restoreTitle, restoreIconName = true, true
+	case "1":
+		restoreIconName = true
+	case "2":
+		restoreTitle = true
+	default:
+		return fmt.Errorf("Invalid title stack selector: %s", which)
+	}
+
+	if restoreIconName && entry.hasIconName {
+		terminal.iconName = entry.iconName
+	}
+	if restoreTitle && entry.hasTitle {
+		terminal.SetTitle(entry.title)
+	}
+	return nil
+}
+
+// Write sends data to the host through the pty, as if the user had typed it. Use ProcessInput to display data instead.
 func (terminal *Terminal) Write(data []byte) error {
 	_, err := terminal.pty.Write(data)
 	return err
@@ -301,23 +528,148 @@ func (terminal *Terminal) WriteReturn() error {
 	}
 }
 
+const bracketedPasteEnd = "\x1b[201~"
+
 func (terminal *Terminal) Paste(data []byte) error {
 
 	if terminal.bracketedPasteMode {
-		data = []byte(fmt.Sprintf("\x1b[200~%s\x1b[201~", string(data)))
+		// the end of the paste can't be in the pasted text, where it would let the rest run as if it had been typed.
+		// Removing one may join the text around it into another.
+		for bytes.Contains(data, []byte(bracketedPasteEnd)) {
+			data = bytes.Replace(data, []byte(bracketedPasteEnd), nil, -1)
+		}
+		data = []byte(fmt.Sprintf("\x1b[200~%s%s", string(data), bracketedPasteEnd))
 	}
 	_, err := terminal.pty.Write(data)
 	return err
 }
 
+// ReportFocus tells the application that the window has gained or lost focus, if it has asked to be told with DECSET
+// 1004
+func (terminal *Terminal) ReportFocus(focused bool) error {
+	if !terminal.focusReportingMode {
+		return nil
+	}
+	if focused {
+		return terminal.Write([]byte("\x1b[I"))
+	}
+	return terminal.Write([]byte("\x1b[O"))
+}
+
+// Close stops recording and closes the pty, which stops Read
+func (terminal *Terminal) Close() error {
+	if err := terminal.StopRecording(); err != nil {
+		terminal.logger.Errorf("Failed to stop recording: %s", err)
+	}
+	return terminal.pty.Close()
+}
+
+// ProcessInput parses data and applies it to the display as if the host had output it, without going through the pty.
+// This is the opposite of Write, which sends data to the host as if the user had typed it. The data has been applied by
+// the time ProcessInput returns, apart from any escape sequence or UTF-8 character cut off at the end of it, which is
+// completed by the data passed to the next call. ProcessInput must not be used while Read is processing pty output.
+func (terminal *Terminal) ProcessInput(data []byte) {
+	data = append(terminal.partialRune, data...)
+	terminal.partialRune = nil
+	if n := partialRuneSuffix(data); n > 0 && !terminal.config.Latin1 {
+		terminal.partialRune = append([]byte{}, data[len(data)-n:]...)
+		data = data[:len(data)-n]
+	}
+
+	decoded := make(chan rune, len(data))
+	_ = readRunes(terminal.runeReader(bytes.NewReader(data)), decoded)
+	close(decoded)
+	terminal.processInput(decoded)
+}
+
+// partialRuneSuffix returns the number of bytes at the end of data which are the start of a UTF-8 character
+func partialRuneSuffix(data []byte) int {
+	for n := 1; n <= utf8.UTFMax && n <= len(data); n++ {
+		if utf8.RuneStart(data[len(data)-n]) {
+			if utf8.FullRune(data[len(data)-n:]) {
+				return 0
+			}
+			return n
+		}
+	}
+	return 0
+}
+
 // Read needs to be run on a goroutine, as it continually reads output to set on the terminal
 func (terminal *Terminal) Read() error {
 
 	buffer := make(chan rune, 0xffff)
 
-	reader := bufio.NewReader(terminal.pty)
-
 	go terminal.processInput(buffer)
+
+	return readRunes(terminal.runeReader(io.TeeReader(terminal.pty, recordingTap{terminal})), buffer)
+}
+
+// StartRecording records the output read from the pty to an asciinema cast written to file, until StopRecording is
+// called
+func (terminal *Terminal) StartRecording(file io.WriteCloser) error {
+	terminal.recordingLock.Lock()
+	defer terminal.recordingLock.Unlock()
+
+	if terminal.recorder != nil {
+		return fmt.Errorf("The terminal is already being recorded")
+	}
+	width, height := terminal.GetSize()
+	recorder, err := cast.NewRecorder(file, width, height)
+	if err != nil {
+		return fmt.Errorf("Failed to start recording: %s", err)
+	}
+	terminal.recorder = recorder
+	terminal.recording = file
+	return nil
+}
+
+// StopRecording stops recording the output, closing the file the cast was written to
+func (terminal *Terminal) StopRecording() error {
+	terminal.recordingLock.Lock()
+	defer terminal.recordingLock.Unlock()
+
+	if terminal.recorder == nil {
+		return nil
+	}
+	err := terminal.recording.Close()
+	terminal.recorder = nil
+	terminal.recording = nil
+	return err
+}
+
+// IsRecording returns true if the output is being recorded
+func (terminal *Terminal) IsRecording() bool {
+	terminal.recordingLock.Lock()
+	defer terminal.recordingLock.Unlock()
+	return terminal.recorder != nil
+}
+
+// recordingTap passes the output read from the pty to the terminal's recorder, if it's being recorded
+type recordingTap struct {
+	terminal *Terminal
+}
+
+func (tap recordingTap) Write(data []byte) (int, error) {
+	tap.terminal.recordingLock.Lock()
+	defer tap.terminal.recordingLock.Unlock()
+
+	if tap.terminal.recorder != nil {
+		if _, err := tap.terminal.recorder.Write(data); err != nil {
+			// a recording which can't be written to shouldn't stop the output being shown
+			tap.terminal.logger.Errorf("Failed to record output: %s", err)
+			tap.terminal.recorder = nil
+			tap.terminal.recording.Close()
+			tap.terminal.recording = nil
+		}
+	}
+	return len(data), nil
+}
+
+// readRunes decodes the pty output stream into runes for the parser until EOF. The reader must buffer partial runes
+// split across reads, as bufio.Reader does, and must return utf8.RuneError for each byte which isn't part of a valid
+// UTF-8 sequence (when decoding UTF-8), so that binary output is shown as replacement characters and decoding resynchronises straight after.
+func readRunes(reader io.RuneReader, buffer chan<- rune) error {
 	for {
 		r, _, err := reader.ReadRune()
 		if err != nil {
@@ -333,6 +685,11 @@ func (terminal *Terminal) Read() error {
 	return nil
 }
 
+// WorkingDirectory returns the working directory last reported by the shell, or an empty string if it hasn't reported one
+func (terminal *Terminal) WorkingDirectory() string {
+	return terminal.workingDirectory
+}
+
 func (terminal *Terminal) Clear() {
 	terminal.ActiveBuffer().Clear()
 }
@@ -349,15 +706,25 @@ func (terminal *Terminal) SetSize(newCols uint, newLines uint) error {
 		return nil
 	}
 
-	err := terminal.pty.Resize(int(newCols), int(newLines))
-	if err != nil {
-		return fmt.Errorf("Failed to set terminal size vai ioctl: Error no %d", err)
+	// a change which follows closely behind another one is sent to the pty by the resizer once the size settles
+	if terminal.resizer.trigger() {
+		err := terminal.pty.Resize(int(newCols), int(newLines))
+		if err != nil {
+			return fmt.Errorf("Failed to set terminal size vai ioctl: Error no %d", err)
+		}
 	}
 
+	oldWidth, oldHeight := terminal.size.Width, terminal.size.Height
 	terminal.size.Width = uint16(newCols)
 	terminal.size.Height = uint16(newLines)
 
 	terminal.ActiveBuffer().ResizeView(terminal.size.Width, terminal.size.Height)
+	// the buffers share the view size, so the lines of the others are rewrapped as well
+	for _, b := range terminal.buffers {
+		if b != terminal.ActiveBuffer() && oldWidth != 0 {
+			b.Reflow(oldWidth, oldHeight)
+		}
+	}
 
 	terminal.emitResize()
 	return nil
@@ -396,6 +763,112 @@ func (terminal *Terminal) ResetVerticalMargins() {
 	terminal.terminalState.ResetVerticalMargins()
 }
 
+// SetLeftRightMarginMode enables or disables DECLRMM, disabling it also removes the left and right margins
+func (terminal *Terminal) SetLeftRightMarginMode(enabled bool) {
+	terminal.terminalState.LeftRightMarginMode = enabled
+	terminal.terminalState.ResetHorizontalMargins()
+}
+
+// Colours returns the terminal's colour scheme: the configured one, with any changes the application has made
+func (terminal *Terminal) Colours() *config.ColourScheme {
+	return &terminal.colours
+}
+
+// SetDefaultColours changes the default foreground and background colours, repainting everything drawn in the old ones.
+// Text given a palette or true colour with the same value as a default colour keeps it.
+func (terminal *Terminal) SetDefaultColours(fg config.Colour, bg config.Colour) {
+	oldFg := terminal.colours.Foreground
+	oldBg := terminal.colours.Background
+	if oldFg == fg && oldBg == bg {
+		return
+	}
+
+	terminal.colours.Foreground = fg
+	terminal.colours.Background = bg
+
+	replacements := map[[3]float32][3]float32{}
+	if oldFg != fg {
+		replacements[oldFg] = fg
+	}
+	if oldBg != bg {
+		replacements[oldBg] = bg
+	}
+	terminal.replaceColours(buffer.DefaultColour, replacements)
+
+	// the gui regenerates its background from the terminal's colours
+	terminal.emitReverse(terminal.terminalState.ScreenMode)
+	terminal.SetDirty()
+}
+
+// SetPaletteColour changes colour n of the 256 colour palette, repainting everything drawn in colour n. Text in other
+// colours with the same value, such as true colours, keeps its colour.
+func (terminal *Terminal) SetPaletteColour(n uint8, colour config.Colour) {
+	old := terminal.get8BitSGRColour(n)
+	if terminal.palette == nil {
+		terminal.palette = map[uint8]config.Colour{}
+	}
+	terminal.palette[n] = colour
+	terminal.updateBrightColours()
+	terminal.replacePaletteColour(n, old, colour)
+}
+
+// ResetPaletteColour restores colour n of the 256 colour palette to the one from the config
+func (terminal *Terminal) ResetPaletteColour(n uint8) {
+	colour, ok := terminal.palette[n]
+	if !ok {
+		return
+	}
+	delete(terminal.palette, n)
+	terminal.updateBrightColours()
+	terminal.replacePaletteColour(n, colour, terminal.get8BitSGRColour(n))
+}
+
+// BoldColour returns the colour to draw bold text with the given attributes in. If bold_is_bright is enabled, bold text
+// in one of the 8 standard colours of the palette is drawn in the matching bright colour, as the palette has it now.
+// Colours which only have the same value as a standard colour, such as true colours, are left alone.
+func (terminal *Terminal) BoldColour(attr buffer.CellAttributes) [3]float32 {
+	if n, ok := attr.FgIndex.Palette(); ok && n < 8 && terminal.config.BoldIsBright {
+		return terminal.brightColours[n]
+	}
+	return attr.FgColour
+}
+
+func (terminal *Terminal) updateBrightColours() {
+	for n := range terminal.brightColours {
+		terminal.brightColours[n] = terminal.get8BitSGRColour(uint8(n + 8))
+	}
+}
+
+// ResetColours restores the palette and the default foreground and background colours to the ones from the config,
+// undoing any changes made with OSC 4, 10 and 11
+func (terminal *Terminal) ResetColours() {
+	for n := range terminal.palette {
+		terminal.ResetPaletteColour(n)
+	}
+	terminal.SetDefaultColours(terminal.defaultColours[0], terminal.defaultColours[1])
+}
+
+func (terminal *Terminal) replacePaletteColour(n uint8, old config.Colour, colour config.Colour) {
+	if old == colour {
+		return
+	}
+	terminal.replaceColours(buffer.PaletteIndex(n), map[[3]float32][3]float32{old: colour})
+}
+
+// replaceColours repaints the colours which came from index in every buffer, and the cursor
+func (terminal *Terminal) replaceColours(index buffer.ColourIndex, replacements map[[3]float32][3]float32) {
+	terminal.terminalState.ReplaceColours(index, replacements)
+	for _, buffer := range terminal.buffers {
+		buffer.ReplaceColours(index, replacements)
+	}
+	terminal.SetDirty()
+}
+
+// ReverseVideo returns true if the screen is in reverse video (DECSCNM)
+func (terminal *Terminal) ReverseVideo() bool {
+	return terminal.terminalState.ScreenMode
+}
+
 func (terminal *Terminal) SetScreenMode(enabled bool) {
 	if terminal.terminalState.ScreenMode == enabled {
 		return
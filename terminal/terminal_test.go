@@ -0,0 +1,97 @@
+package terminal
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/liamg/aminal/config"
+	"github.com/liamg/aminal/platform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// testPty records everything the terminal sends to the host
+type testPty struct {
+	output bytes.Buffer
+	lock   sync.Mutex
+	sizes  [][2]int  // every size the pty was resized to
+	host   io.Reader // the output of the host, read by Terminal.Read
+}
+
+func (pty *testPty) Read(b []byte) (int, error) {
+	if pty.host == nil {
+		return 0, io.EOF
+	}
+	return pty.host.Read(b)
+}
+
+func (pty *testPty) Write(b []byte) (int, error) {
+	return pty.output.Write(b)
+}
+
+func (pty *testPty) Close() error {
+	return nil
+}
+
+func (pty *testPty) Resize(x int, y int) error {
+	pty.lock.Lock()
+	defer pty.lock.Unlock()
+	pty.sizes = append(pty.sizes, [2]int{x, y})
+	return nil
+}
+
+func (pty *testPty) resizes() [][2]int {
+	pty.lock.Lock()
+	defer pty.lock.Unlock()
+	return append([][2]int{}, pty.sizes...)
+}
+
+func (pty *testPty) CreateGuestProcess(imagePath string) (platform.Process, error) {
+	return nil, errors.New("Processes cannot be created on a test pty")
+}
+
+func (pty *testPty) GetPlatformDependentSettings() platform.PlatformDependentSettings {
+	return platform.PlatformDependentSettings{}
+}
+
+func newTestTerminal(t *testing.T, cols uint, rows uint) (*Terminal, *testPty) {
+	pty := &testPty{}
+	conf := config.DefaultConfig
+	terminal := New(pty, zap.NewNop().Sugar(), &conf)
+	require.Nil(t, terminal.SetSize(cols, rows))
+	return terminal, pty
+}
+
+// feed passes data through the parser as if it had been output by the host
+func feed(terminal *Terminal, data string) {
+	terminal.ProcessInput([]byte(data))
+}
+
+func visibleText(terminal *Terminal) []string {
+	strs := []string{}
+	for _, line := range terminal.GetVisibleLines() {
+		strs = append(strs, line.String())
+	}
+	return strs
+}
+
+func TestResetKeepsScrollbackWithinBudget(t *testing.T) {
+	terminal, _ := newTestTerminal(t, 10, 3)
+
+	feed(terminal, strings.Repeat("x\r\n", 10))
+	lines, budget := terminal.ActiveBuffer().ScrollbackUsage()
+	require.Equal(t, 8, lines)
+	terminal.terminalState.SetMaxScrollbackBytes(budget)
+
+	feed(terminal, "\x1bc")
+	feed(terminal, strings.Repeat("x\r\n", 20))
+
+	lines, bytes := terminal.ActiveBuffer().ScrollbackUsage()
+	assert.Equal(t, budget, bytes)
+	assert.Equal(t, 8, lines, "the oldest lines should be discarded only as far as the budget needs")
+}
@@ -0,0 +1,72 @@
+package terminal
+
+import (
+	"sync"
+	"time"
+)
+
+// resizeDebounce is how long the size must stay the same before a held back size change is sent to the pty
+const resizeDebounce = 100 * time.Millisecond
+
+// resizeDebouncer limits how often the pty is resized, as every resize sends SIGWINCH to the application, which will
+// usually redraw the whole screen. The first change is sent immediately, and changes which quickly follow it are
+// held back until the size settles, when only the final size is sent.
+type resizeDebouncer struct {
+	delay     time.Duration
+	send      func() // sends the current size to the pty
+	lock      sync.Mutex
+	waiting   bool      // changes are being held back
+	pending   bool      // a change was held back and must be sent once the size settles
+	changedAt time.Time // when the size last changed
+}
+
+func newResizeDebouncer(delay time.Duration, send func()) *resizeDebouncer {
+	return &resizeDebouncer{
+		delay: delay,
+		send:  send,
+	}
+}
+
+// trigger records that the size has changed, and returns true if the caller should send it to the pty immediately
+func (debouncer *resizeDebouncer) trigger() bool {
+	debouncer.lock.Lock()
+	defer debouncer.lock.Unlock()
+
+	debouncer.changedAt = time.Now()
+	if debouncer.waiting {
+		debouncer.pending = true
+		return false
+	}
+	debouncer.waiting = true
+	time.AfterFunc(debouncer.delay, debouncer.settle)
+	return true
+}
+
+func (debouncer *resizeDebouncer) settle() {
+	debouncer.lock.Lock()
+	if wait := debouncer.delay - time.Since(debouncer.changedAt); wait > 0 {
+		// the size changed again since the timer was started
+		time.AfterFunc(wait, debouncer.settle)
+		debouncer.lock.Unlock()
+		return
+	}
+	pending := debouncer.pending
+	debouncer.waiting = false
+	debouncer.pending = false
+	debouncer.lock.Unlock()
+
+	if pending {
+		debouncer.send()
+	}
+}
+
+// sendWinsize resizes the pty to the current size of the terminal. The size is read under the terminal lock, so the
+// application is always told the size of the buffer it is drawing into, even if it changed again while waiting.
+func (terminal *Terminal) sendWinsize() {
+	terminal.lock.Lock()
+	defer terminal.lock.Unlock()
+
+	if err := terminal.pty.Resize(int(terminal.size.Width), int(terminal.size.Height)); err != nil {
+		terminal.logger.Errorf("Failed to set terminal size: %s", err)
+	}
+}
@@ -0,0 +1,36 @@
+package terminal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/liamg/aminal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestResizesAreDebounced(t *testing.T) {
+	pty := &testPty{}
+	conf := config.DefaultConfig
+	terminal := New(pty, zap.NewNop().Sugar(), &conf)
+	terminal.resizer.delay = 20 * time.Millisecond
+
+	require.Nil(t, terminal.SetSize(80, 24))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, [][2]int{{80, 24}}, pty.resizes())
+
+	// the first change after the size has settled is sent straight away
+	require.Nil(t, terminal.SetSize(100, 30))
+	assert.Equal(t, [][2]int{{80, 24}, {100, 30}}, pty.resizes())
+
+	for cols := uint(101); cols <= 120; cols++ {
+		require.Nil(t, terminal.SetSize(cols, 30))
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, [][2]int{{80, 24}, {100, 30}}, pty.resizes())
+	assert.Equal(t, uint16(120), terminal.ActiveBuffer().ViewWidth())
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, [][2]int{{80, 24}, {100, 30}, {120, 30}}, pty.resizes())
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	version "github.com/mcuadros/go-version"
@@ -21,6 +23,26 @@ type Asset struct {
 	URL  string `json:"url"`
 }
 
+// Number returns the version as a single number, for reports which need one: 1.2.3 is 10203. Development builds,
+// which have no version, are 0.
+func Number() int {
+	number := 0
+	parts := strings.SplitN(strings.TrimPrefix(Version, "v"), ".", 3)
+	for i := 0; i < 3; i++ {
+		number *= 100
+		if i < len(parts) {
+			// anything after the number, such as a pre-release suffix, is ignored
+			digits := strings.IndexFunc(parts[i], func(r rune) bool { return r < '0' || r > '9' })
+			if digits < 0 {
+				digits = len(parts[i])
+			}
+			n, _ := strconv.Atoi(parts[i][:digits])
+			number += n % 100
+		}
+	}
+	return number
+}
+
 func getLatestRelease() (*Release, error) {
 
 	body, err := downloadFile("https://api.github.com/repos/liamg/aminal/releases/latest")
@@ -0,0 +1,5 @@
+package words
+
+// DefaultSeparators are the characters besides whitespace which end a word selected by double clicking, unless others
+// are configured
+const DefaultSeparators = ",:;'\"[](){}"